@@ -0,0 +1,480 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/georgifotev1/bms/internal/mailer"
+	"github.com/georgifotev1/bms/internal/store"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// passwordResetRequestedMessage is returned whether or not an account exists
+// for the email so the endpoint can't be used to find out who is registered
+const passwordResetRequestedMessage = "If an account exists for the email, a password reset link has been sent"
+
+var (
+	ErrEmailAlreadyVerified = errors.New("email is already verified")
+	ErrCustomerWithoutEmail = errors.New("customer has no email")
+)
+
+type ForgotPasswordPayload struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+type ResetPasswordPayload struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=3,max=72"`
+}
+
+// @Summary		Request a user password reset
+// @Description	Sends a single use password reset link to the email of the user. The response is the same whether or not the user exists
+// @Tags			auth
+// @Accept			json
+// @Produce		json
+// @Param			payload	body		ForgotPasswordPayload	true	"User email"
+// @Success		202		{string}	string					"Reset link sent if the user exists"
+// @Failure		400		{object}	error
+// @Failure		429		{object}	error
+// @Failure		500		{object}	error
+// @Router			/auth/password/forgot [post]
+func (app *application) forgotUserPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ForgotPasswordPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	user, err := app.store.GetUserByEmail(ctx, payload.Email)
+	if err != nil && err != sql.ErrNoRows {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err == nil && app.allowPasswordResetEmail(user.Email) {
+		plainToken := uuid.New().String()
+
+		err = app.store.CreateUserToken(ctx, store.CreateUserTokenParams{
+			Token:  hashToken(plainToken),
+			UserID: user.ID,
+			Scope:  store.TokenScopePasswordReset,
+			Expiry: time.Now().Add(app.config.mail.resetExp),
+		})
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		vars := passwordResetVars{
+			Username:  user.Name,
+			BrandName: mailer.FromName,
+			ResetUrl:  fmt.Sprintf("%s/reset-password/%s", app.config.clientUrl, plainToken),
+			ExpiresIn: expiryText(app.config.mail.resetExp),
+		}
+		app.sendAccountEmail(context.Background(), mailer.PasswordResetTemplate, user.Name, user.Email, vars)
+	}
+
+	response := map[string]string{
+		"message": passwordResetRequestedMessage,
+	}
+
+	if err := writeJSON(w, http.StatusAccepted, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// @Summary		Reset a user password
+// @Description	Sets a new password using the token from the password reset email. The token can be used once and every session of the user is signed out
+// @Tags			auth
+// @Accept			json
+// @Produce		json
+// @Param			payload	body		ResetPasswordPayload	true	"Reset token and new password"
+// @Success		200		{string}	string					"Password changed"
+// @Failure		400		{object}	error
+// @Failure		404		{object}	error
+// @Failure		500		{object}	error
+// @Router			/auth/password/reset [post]
+func (app *application) resetUserPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	payload, hashedPass, ok := app.readResetPasswordPayload(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+
+	userID, err := app.store.ResetUserPasswordTx(ctx, store.ResetUserPasswordTxParams{
+		Token:    hashToken(payload.Token),
+		Password: hashedPass,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidResetToken):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if app.config.cache.enabled {
+		app.cache.Users.Delete(ctx, userID)
+	}
+
+	app.ClearCookie(w, SESSION_TOKEN)
+
+	response := map[string]string{
+		"message": "Password successfully changed",
+	}
+
+	if err := writeJSON(w, http.StatusOK, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// @Summary		Verify a customer email
+// @Description	Verifies the email of a customer using the token sent after sign up
+// @Tags			customers
+// @Produce		json
+// @Param			token		path		string	true	"Verification token"
+// @Param			X-Brand-ID	header		string	false	"Brand ID header for development. In production this header is ignored"	default(1)
+// @Success		200			{string}	string	"Email verified"
+// @Failure		404			{object}	error
+// @Failure		500			{object}	error
+// @Router			/customers/auth/verify/{token} [get]
+func (app *application) verifyCustomerEmailHandler(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+	if token == "" {
+		app.badRequestResponse(w, r, errors.New("invalid verification link"))
+		return
+	}
+
+	ctx := r.Context()
+
+	customerID, err := app.store.VerifyCustomerTx(ctx, hashToken(token))
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidVerificationToken):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if app.config.cache.enabled {
+		app.cache.Customers.Delete(ctx, customerID)
+	}
+
+	response := map[string]string{
+		"message": "Email successfully verified",
+	}
+
+	if err := writeJSON(w, http.StatusOK, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// @Summary		Resend my verification email
+// @Description	Sends a new email verification link to the signed in customer
+// @Tags			customers
+// @Produce		json
+// @Param			X-Brand-ID	header		string	false	"Brand ID header for development. In production this header is ignored"	default(1)
+// @Success		202			{string}	string	"Verification link sent"
+// @Failure		400			{object}	error
+// @Failure		401			{object}	error
+// @Failure		409			{object}	error
+// @Failure		429			{object}	error
+// @Failure		500			{object}	error
+// @Router			/customers/me/verification [post]
+func (app *application) resendCustomerVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customer, err := getCustomerFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if customer.Verified {
+		app.conflictRespone(w, r, ErrEmailAlreadyVerified)
+		return
+	}
+
+	if err := app.sendCustomerVerification(ctx, customer); err != nil {
+		switch {
+		case errors.Is(err, ErrCustomerWithoutEmail):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	response := map[string]string{
+		"message": "Verification link sent",
+	}
+
+	if err := writeJSON(w, http.StatusAccepted, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// @Summary		Request a customer password reset
+// @Description	Sends a single use password reset link to the email of the customer. The response is the same whether or not the customer exists
+// @Tags			customers
+// @Accept			json
+// @Produce		json
+// @Param			payload		body		ForgotPasswordPayload	true	"Customer email"
+// @Param			X-Brand-ID	header		string					false	"Brand ID header for development. In production this header is ignored"	default(1)
+// @Success		202			{string}	string					"Reset link sent if the customer exists"
+// @Failure		400			{object}	error
+// @Failure		429			{object}	error
+// @Failure		500			{object}	error
+// @Router			/customers/auth/password/forgot [post]
+func (app *application) forgotCustomerPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ForgotPasswordPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	ctxBrandID, err := getBrandIDFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	customer, err := app.store.GetCustomerByEmail(ctx, store.GetCustomerByEmailParams{
+		BrandID: ctxBrandID,
+		Email:   toNullString(normalizeEmail(payload.Email)),
+	})
+	if err != nil && err != sql.ErrNoRows {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// Guests never set a password so they have nothing to reset
+	if err == nil && len(customer.Password) > 0 && app.allowPasswordResetEmail(fmt.Sprintf("%d:%s", customer.BrandID, customer.Email.String)) {
+		if err := app.sendCustomerPasswordReset(ctx, customer); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	response := map[string]string{
+		"message": passwordResetRequestedMessage,
+	}
+
+	if err := writeJSON(w, http.StatusAccepted, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// @Summary		Reset a customer password
+// @Description	Sets a new password using the token from the password reset email. The token can be used once and the customer is signed out everywhere
+// @Tags			customers
+// @Accept			json
+// @Produce		json
+// @Param			payload		body		ResetPasswordPayload	true	"Reset token and new password"
+// @Param			X-Brand-ID	header		string					false	"Brand ID header for development. In production this header is ignored"	default(1)
+// @Success		200			{string}	string					"Password changed"
+// @Failure		400			{object}	error
+// @Failure		404			{object}	error
+// @Failure		500			{object}	error
+// @Router			/customers/auth/password/reset [post]
+func (app *application) resetCustomerPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	payload, hashedPass, ok := app.readResetPasswordPayload(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+
+	customerID, err := app.store.ResetCustomerPasswordTx(ctx, store.ResetCustomerPasswordTxParams{
+		Token:    hashToken(payload.Token),
+		Password: hashedPass,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidResetToken):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if app.config.cache.enabled {
+		app.cache.Customers.Delete(ctx, customerID)
+	}
+
+	app.ClearCookie(w, CUSTOMER_SESSION_TOKEN)
+
+	response := map[string]string{
+		"message": "Password successfully changed",
+	}
+
+	if err := writeJSON(w, http.StatusOK, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) readResetPasswordPayload(w http.ResponseWriter, r *http.Request) (*ResetPasswordPayload, []byte, bool) {
+	var payload ResetPasswordPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return nil, nil, false
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return nil, nil, false
+	}
+
+	hashedPass, err := bcrypt.GenerateFromPassword([]byte(payload.Password), bcrypt.DefaultCost)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return nil, nil, false
+	}
+
+	return &payload, hashedPass, true
+}
+
+type passwordResetVars struct {
+	Username  string
+	BrandName string
+	ResetUrl  string
+	ExpiresIn string
+}
+
+// sendCustomerVerification stores a new verification token for the customer
+// and emails the link to them
+func (app *application) sendCustomerVerification(ctx context.Context, customer *store.Customer) error {
+	if !customer.Email.Valid {
+		return ErrCustomerWithoutEmail
+	}
+
+	brand, err := app.store.GetBrandById(ctx, customer.BrandID)
+	if err != nil {
+		return err
+	}
+
+	plainToken := uuid.New().String()
+
+	err = app.store.CreateCustomerToken(ctx, store.CreateCustomerTokenParams{
+		Token:      hashToken(plainToken),
+		CustomerID: customer.ID,
+		BrandID:    customer.BrandID,
+		Scope:      store.TokenScopeVerification,
+		Expiry:     time.Now().Add(app.config.mail.exp),
+	})
+	if err != nil {
+		return err
+	}
+
+	vars := struct {
+		Username        string
+		BrandName       string
+		VerificationUrl string
+		ExpiresIn       string
+	}{
+		Username:        customer.Name,
+		BrandName:       brand.Name,
+		VerificationUrl: fmt.Sprintf("%s/verify/%s", app.brandClientURL(brand), plainToken),
+		ExpiresIn:       expiryText(app.config.mail.exp),
+	}
+
+	mailCtx := store.WithBrandID(context.Background(), customer.BrandID)
+	app.sendAccountEmail(mailCtx, mailer.VerifyEmailTemplate, customer.Name, customer.Email.String, vars)
+	return nil
+}
+
+func (app *application) sendCustomerPasswordReset(ctx context.Context, customer *store.Customer) error {
+	brand, err := app.store.GetBrandById(ctx, customer.BrandID)
+	if err != nil {
+		return err
+	}
+
+	plainToken := uuid.New().String()
+
+	err = app.store.CreateCustomerToken(ctx, store.CreateCustomerTokenParams{
+		Token:      hashToken(plainToken),
+		CustomerID: customer.ID,
+		BrandID:    customer.BrandID,
+		Scope:      store.TokenScopePasswordReset,
+		Expiry:     time.Now().Add(app.config.mail.resetExp),
+	})
+	if err != nil {
+		return err
+	}
+
+	vars := passwordResetVars{
+		Username:  customer.Name,
+		BrandName: brand.Name,
+		ResetUrl:  fmt.Sprintf("%s/reset-password/%s", app.brandClientURL(brand), plainToken),
+		ExpiresIn: expiryText(app.config.mail.resetExp),
+	}
+
+	mailCtx := store.WithBrandID(context.Background(), customer.BrandID)
+	app.sendAccountEmail(mailCtx, mailer.PasswordResetTemplate, customer.Name, customer.Email.String, vars)
+	return nil
+}
+
+// sendAccountEmail sends the email in the background so the response time
+// doesn't tell whether an account exists
+func (app *application) sendAccountEmail(ctx context.Context, templateFile, username, email string, data any) {
+	app.background(func() {
+		status, err := app.mailer.Send(ctx, templateFile, username, email, data)
+		if err != nil {
+			app.logger.Errorw("error sending account email", "template", templateFile, "error", err)
+			return
+		}
+
+		app.logger.Infow("Email sent", "template", templateFile, "status code", status)
+	})
+}
+
+// allowPasswordResetEmail limits the reset emails sent to one address on top
+// of the per IP limit of the route
+func (app *application) allowPasswordResetEmail(key string) bool {
+	if !app.config.passwordResetLimiter.Enabled {
+		return true
+	}
+
+	allow, _ := app.passwordResetLimiter.Allow("email:" + key)
+	return allow
+}
+
+// brandClientURL returns the address of the booking site of the brand
+func (app *application) brandClientURL(brand *store.Brand) string {
+	return fmt.Sprintf("http://%s.%s", brand.PageUrl, app.config.clientUrl)
+}
+
+func expiryText(d time.Duration) string {
+	if d >= time.Hour {
+		hours := int(d.Hours())
+		if hours == 1 {
+			return "1 hour"
+		}
+		return fmt.Sprintf("%d hours", hours)
+	}
+	return fmt.Sprintf("%d minutes", int(d.Minutes()))
+}
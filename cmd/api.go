@@ -8,15 +8,20 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/cloudinary/cloudinary-go/v2"
 	"github.com/georgifotev1/bms/docs"
+	"github.com/georgifotev1/bms/internal/lockout"
 	"github.com/georgifotev1/bms/internal/mailer"
+	"github.com/georgifotev1/bms/internal/oidc"
 	"github.com/georgifotev1/bms/internal/ratelimiter"
+	"github.com/georgifotev1/bms/internal/realtime"
 	"github.com/georgifotev1/bms/internal/store"
 	"github.com/georgifotev1/bms/internal/store/cache"
+	"github.com/georgifotev1/bms/internal/webhook"
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/cors"
@@ -33,19 +38,37 @@ type application struct {
 	mailer       mailer.Client
 	rateLimiter  ratelimiter.Limiter
 	imageService *cloudinary.Cloudinary
+	wg           sync.WaitGroup
+	stop         chan struct{}
+
+	// passwordResetLimiter is stricter than rateLimiter and only applies to
+	// the requests that send account emails
+	passwordResetLimiter ratelimiter.Limiter
+	signInGuard          *lockout.Guard
+	oidc                 *oidc.Client
+	calendar             *realtime.Broker
+	webhooks             *webhook.Client
+	// calendarFetcher downloads the external calendars staff subscribe to
+	calendarFetcher *http.Client
 }
 
 type config struct {
-	address     string
-	db          dbConfig
-	auth        authConfig
-	mail        mailConfig
-	env         string
-	apiUrl      string
-	clientUrl   string
-	clientHost  string
-	cache       redisConfig
-	rateLimiter ratelimiter.Config
+	address           string
+	db                dbConfig
+	auth              authConfig
+	mail              mailConfig
+	env               string
+	apiUrl            string
+	clientUrl         string
+	clientHost        string
+	cache             redisConfig
+	audit             auditConfig
+	idempotency       idempotencyConfig
+	webhooks          webhookConfig
+	externalCalendars externalCalendarConfig
+	rateLimiter       ratelimiter.Config
+
+	passwordResetLimiter ratelimiter.Config
 }
 
 type dbConfig struct {
@@ -63,12 +86,72 @@ type redisConfig struct {
 }
 
 type authConfig struct {
-	basic   basicConfig
-	session sessionConfig
+	basic     basicConfig
+	session   sessionConfig
+	twoFactor twoFactorConfig
+	lockout   lockoutConfig
+	csrf      csrfConfig
+	oidc      oidcConfig
 }
 
+type auditConfig struct {
+	// retention is how long audit log entries are kept
+	retention time.Duration
+}
+
+type idempotencyConfig struct {
+	// retention is how long responses are kept for retried requests
+	retention time.Duration
+}
+
+type externalCalendarConfig struct {
+	timeout time.Duration
+	// syncInterval is how often subscribed calendars are fetched again
+	syncInterval time.Duration
+	// horizon is how far ahead busy times are kept
+	horizon time.Duration
+}
+
+type webhookConfig struct {
+	timeout time.Duration
+	// maxAttempts is how many times a delivery is tried before it fails
+	maxAttempts int32
+	// retention is how long finished deliveries stay in the log
+	retention time.Duration
+}
+
+type oidcConfig struct {
+	// redirectURL is the callback every brand registers with its provider
+	redirectURL string
+	stateExp    time.Duration
+	timeout     time.Duration
+}
+
+type csrfConfig struct {
+	// secret signs the CSRF tokens of the sessions
+	secret string
+}
+
+// lockoutConfig slows down failed sign ins per account and per IP address
+type lockoutConfig struct {
+	account lockout.Policy
+	ip      lockout.Policy
+}
+
+// Sessions expire after exp without activity and after maxAge in any case.
+// Remember me sessions use the longer remember durations.
 type sessionConfig struct {
-	exp time.Duration
+	exp            time.Duration
+	maxAge         time.Duration
+	rememberExp    time.Duration
+	rememberMaxAge time.Duration
+	purgeInterval  time.Duration
+}
+
+type twoFactorConfig struct {
+	// issuer is the account name shown in authenticator apps
+	issuer       string
+	challengeExp time.Duration
 }
 
 type basicConfig struct {
@@ -77,9 +160,12 @@ type basicConfig struct {
 }
 
 type mailConfig struct {
-	mailTrap  mailTrapConfig
-	fromEmail string
-	exp       time.Duration
+	mailTrap          mailTrapConfig
+	fromEmail         string
+	exp               time.Duration
+	resetExp          time.Duration
+	signInExp         time.Duration
+	unsubscribeSecret string
 }
 
 type mailTrapConfig struct {
@@ -92,11 +178,12 @@ func (app *application) mount() http.Handler {
 	r.Use(middleware.RealIP)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
+	r.Use(app.AuditMiddleware)
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{fmt.Sprintf("http://app.%v", app.config.clientUrl), fmt.Sprintf("http://*.%v", app.config.clientUrl)},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "User-Agent"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "User-Agent", "If-Match", "Idempotency-Key", "Last-Event-ID"},
+		ExposedHeaders:   []string{"Link", "ETag", "Idempotent-Replayed"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
@@ -105,7 +192,8 @@ func (app *application) mount() http.Handler {
 		r.Use(app.RateLimiterMiddleware)
 	}
 
-	r.Use(middleware.Timeout(60 * time.Second))
+	r.Use(timeoutUnlessStreaming(60 * time.Second))
+	r.Use(app.CSRFMiddleware)
 
 	r.Route("/v1", func(r chi.Router) {
 		r.Get("/health", app.healthCheckHandler)
@@ -117,11 +205,31 @@ func (app *application) mount() http.Handler {
 		r.Route("/users", func(r chi.Router) {
 			r.Get("/confirm/{token}", app.activateUserHandler)
 			r.Route("/", func(r chi.Router) {
-				r.Use(app.AuthUserMiddleware)
-				r.Get("/", app.getUsersHandler)
-				r.Get("/me", app.getUserProfile)
-				r.Post("/invite", app.inviteUserHandler)
-				r.Get("/{id}", app.getUserHandler)
+				r.With(app.AuthUserOrAPIKeyMiddleware(apiKeyResourceUsers)).Get("/", app.getUsersHandler)
+				r.With(app.AuthUserOrAPIKeyMiddleware(apiKeyResourceUsers)).Get("/{id}", app.getUserHandler)
+				r.Group(func(r chi.Router) {
+					r.Use(app.AuthUserMiddleware)
+					r.Get("/me", app.getUserProfile)
+					r.Get("/me/csrf-token", app.getUserCSRFTokenHandler)
+					r.Get("/me/sessions", app.getMySessionsHandler)
+					r.Delete("/me/sessions", app.revokeMyOtherSessionsHandler)
+					r.Delete("/me/sessions/{sessionId}", app.revokeMySessionHandler)
+					r.Get("/me/two-factor", app.getMyTwoFactorHandler)
+					r.Post("/me/two-factor", app.setupMyTwoFactorHandler)
+					r.Delete("/me/two-factor", app.disableMyTwoFactorHandler)
+					r.Post("/me/two-factor/confirm", app.confirmMyTwoFactorHandler)
+					r.Post("/me/two-factor/recovery-codes", app.regenerateMyRecoveryCodesHandler)
+					r.Get("/me/calendar-feed", app.getMyCalendarFeedHandler)
+					r.Post("/me/calendar-feed", app.rotateMyCalendarFeedHandler)
+					r.Delete("/me/calendar-feed", app.deleteMyCalendarFeedHandler)
+					r.Get("/me/external-calendars", app.getMyExternalCalendarsHandler)
+					r.Post("/me/external-calendars", app.createExternalCalendarHandler)
+					r.Post("/me/external-calendars/upload", app.uploadExternalCalendarHandler)
+					r.Post("/me/external-calendars/{calendarId}/sync", app.syncExternalCalendarHandler)
+					r.Delete("/me/external-calendars/{calendarId}", app.deleteExternalCalendarHandler)
+					r.Post("/invite", app.inviteUserHandler)
+					r.Post("/{id}/unlock", app.unlockUserHandler)
+				})
 			})
 		})
 
@@ -131,12 +239,21 @@ func (app *application) mount() http.Handler {
 		})
 
 		r.Route("/brand", func(r chi.Router) {
-			r.Use(app.AuthUserMiddleware)
-			r.Post("/", app.createBrandHandler)
-			r.Put("/{id}", app.updateBrandHandler)
-			r.Put("/{id}/working-hours", app.updateBrandWorkingHoursHandler)
-			r.Put("/{id}/social-links", app.updateBrandSocialLinksHandler)
-			r.Get("/", app.getBrandHandler)
+			r.With(app.AuthUserOrAPIKeyMiddleware(apiKeyResourceBrand)).Get("/", app.getBrandHandler)
+			r.Group(func(r chi.Router) {
+				r.Use(app.AuthUserMiddleware)
+				r.Post("/", app.createBrandHandler)
+				r.Put("/{id}", app.updateBrandHandler)
+				r.Put("/{id}/working-hours", app.updateBrandWorkingHoursHandler)
+				r.Put("/{id}/social-links", app.updateBrandSocialLinksHandler)
+				r.Put("/{id}/two-factor", app.updateBrandTwoFactorHandler)
+				r.Get("/{id}/sso", app.getBrandSsoHandler)
+				r.Put("/{id}/sso", app.updateBrandSsoHandler)
+				r.Delete("/{id}/sso", app.deleteBrandSsoHandler)
+				r.Get("/{id}/calendar-feed", app.getBrandCalendarFeedHandler)
+				r.Post("/{id}/calendar-feed", app.rotateBrandCalendarFeedHandler)
+				r.Delete("/{id}/calendar-feed", app.deleteBrandCalendarFeedHandler)
+			})
 		})
 
 		r.Route("/brand/public", func(r chi.Router) {
@@ -145,7 +262,7 @@ func (app *application) mount() http.Handler {
 		})
 
 		r.Route("/service", func(r chi.Router) {
-			r.Use(app.AuthUserMiddleware)
+			r.Use(app.AuthUserOrAPIKeyMiddleware(apiKeyResourceServices))
 			r.Post("/", app.createServiceHandler)
 			r.Put("/id/{serviceId}", app.updateServiceHandler)
 			r.Get("/", app.getServicesHandler)
@@ -157,10 +274,15 @@ func (app *application) mount() http.Handler {
 		})
 
 		r.Route("/events", func(r chi.Router) {
-			r.Use(app.AuthUserMiddleware)
-			r.Post("/", app.createEventHandler)
+			r.Use(app.AuthUserOrAPIKeyMiddleware(apiKeyResourceEvents))
+			r.With(app.IdempotencyMiddleware).Post("/", app.createEventHandler)
+			r.Get("/", app.listEventsHandler)
+			r.Get("/export", app.exportEventsHandler)
 			r.Get("/timestamp", app.getEventsByTimeStampHandler)
+			r.Get("/stream", app.streamCalendarHandler)
 			r.Put("/{eventId}", app.updateEventHandler)
+			r.Put("/{eventId}/no-show", app.setEventNoShowHandler)
+			r.Get("/{eventId}/history", app.getEventHistoryHandler)
 		})
 
 		r.Route("/timeslots", func(r chi.Router) {
@@ -171,20 +293,116 @@ func (app *application) mount() http.Handler {
 		r.Route("/auth", func(r chi.Router) {
 			r.Post("/signup", app.signUpUserHandler)
 			r.Post("/signin", app.signInUserHandler)
+			r.Post("/two-factor", app.signInUserWithTwoFactorHandler)
+			r.Post("/two-factor/setup", app.setupTwoFactorSignInHandler)
+			r.Get("/sso/{pageUrl}/start", app.startSsoHandler)
+			r.Get("/sso/callback", app.ssoCallbackHandler)
 			r.Post("/logout", app.logoutHandler)
+			r.With(app.PasswordResetRateLimiterMiddleware).Post("/password/forgot", app.forgotUserPasswordHandler)
+			r.Post("/password/reset", app.resetUserPasswordHandler)
 		})
 
 		r.Route("/customers", func(r chi.Router) {
-			r.With(app.AuthUserMiddleware).Get("/", app.getCustomersHandler)
-			r.Post("/guest", app.createGuestCustomerHandler)
+			r.With(app.AuthUserOrAPIKeyMiddleware(apiKeyResourceCustomers)).Get("/", app.getCustomersHandler)
+			r.With(app.AuthUserMiddleware).Get("/duplicates", app.getDuplicateCustomersHandler)
+			r.With(app.AuthUserMiddleware).Get("/export", app.exportCustomersHandler)
+			r.With(app.AuthUserMiddleware).Post("/merge", app.mergeCustomersHandler)
+			r.Route("/fields", func(r chi.Router) {
+				r.Use(app.AuthUserMiddleware)
+				r.Get("/", app.getCustomerFieldsHandler)
+				r.Post("/", app.createCustomerFieldHandler)
+				r.Delete("/{fieldId}", app.deleteCustomerFieldHandler)
+			})
+			r.With(app.BrandMiddleware, app.IdempotencyMiddleware).Post("/guest", app.createGuestCustomerHandler)
 			r.Route("/auth", func(r chi.Router) {
 				r.Use(app.BrandMiddleware)
 				r.Post("/signup", app.signUpCustomerHandler)
 				r.Post("/signin", app.signInCustomerHandler)
 				r.Post("/logout", app.logoutCustomerHandler)
+				r.Get("/verify/{token}", app.verifyCustomerEmailHandler)
+				r.With(app.PasswordResetRateLimiterMiddleware).Post("/password/forgot", app.forgotCustomerPasswordHandler)
+				r.Post("/password/reset", app.resetCustomerPasswordHandler)
+				r.With(app.PasswordResetRateLimiterMiddleware).Post("/passwordless", app.requestCustomerSignInCodeHandler)
+				r.Post("/passwordless/code", app.signInCustomerWithCodeHandler)
+				r.Post("/passwordless/link", app.signInCustomerWithLinkHandler)
+			})
+			r.Route("/me", func(r chi.Router) {
+				r.Use(app.BrandMiddleware)
+				r.Use(app.AuthCustomerMiddleware)
+				r.With(app.PasswordResetRateLimiterMiddleware).Post("/verification", app.resendCustomerVerificationHandler)
+				r.Put("/password", app.setMyPasswordHandler)
+				r.Get("/csrf-token", app.getCustomerCSRFTokenHandler)
+				r.Get("/sessions", app.getMyCustomerSessionsHandler)
+				r.Delete("/sessions", app.revokeMyOtherCustomerSessionsHandler)
+				r.Delete("/sessions/{sessionId}", app.revokeMyCustomerSessionHandler)
+				r.Get("/data-export", app.exportMyCustomerDataHandler)
+				r.Get("/consents", app.getMyConsentsHandler)
+				r.Put("/consents", app.updateMyConsentsHandler)
+				r.Get("/dependents", app.getMyDependentsHandler)
+				r.Post("/dependents", app.createMyDependentHandler)
+				r.Put("/dependents/{dependentId}", app.updateMyDependentHandler)
+				r.Delete("/dependents/{dependentId}", app.deleteMyDependentHandler)
+				r.Get("/calendar-feed", app.getMyCustomerCalendarFeedHandler)
+				r.Post("/calendar-feed", app.rotateMyCustomerCalendarFeedHandler)
+				r.Delete("/calendar-feed", app.deleteMyCustomerCalendarFeedHandler)
+				r.Delete("/", app.eraseMyCustomerAccountHandler)
+			})
+			r.Route("/{customerId}", func(r chi.Router) {
+				r.With(app.AuthUserOrAPIKeyScopeMiddleware(apiKeyScopeCustomersDelete)).Delete("/", app.eraseCustomerHandler)
+				r.Group(func(r chi.Router) {
+					r.Use(app.AuthUserOrAPIKeyMiddleware(apiKeyResourceCustomers))
+					r.Get("/", app.getCustomerDetailHandler)
+					r.Post("/unlock", app.unlockCustomerHandler)
+					r.Get("/data-export", app.exportCustomerDataHandler)
+					r.Get("/consents", app.getCustomerConsentsHandler)
+					r.Put("/consents", app.updateCustomerConsentsHandler)
+					r.Get("/dependents", app.getCustomerDependentsHandler)
+					r.Post("/dependents", app.createCustomerDependentHandler)
+					r.Put("/dependents/{dependentId}", app.updateCustomerDependentHandler)
+					r.Delete("/dependents/{dependentId}", app.deleteCustomerDependentHandler)
+					r.Put("/tags", app.updateCustomerTagsHandler)
+					r.Put("/fields", app.updateCustomerFieldValuesHandler)
+					r.Get("/notes", app.getCustomerNotesHandler)
+					r.Post("/notes", app.createCustomerNoteHandler)
+					r.Delete("/notes/{noteId}", app.deleteCustomerNoteHandler)
+				})
 			})
 		})
 
+		r.Get("/calendar/{token}.ics", app.calendarFeedHandler)
+
+		r.Route("/consents", func(r chi.Router) {
+			r.Get("/unsubscribe/{token}", app.unsubscribeHandler)
+			r.Post("/unsubscribe/{token}", app.unsubscribeHandler)
+		})
+
+		r.With(app.AuthUserMiddleware).Get("/audit-log", app.getAuditLogHandler)
+
+		r.Route("/api-keys", func(r chi.Router) {
+			r.Use(app.AuthUserMiddleware)
+			r.Get("/", app.getAPIKeysHandler)
+			r.Post("/", app.createAPIKeyHandler)
+			r.Delete("/{keyId}", app.revokeAPIKeyHandler)
+		})
+
+		r.Route("/webhooks", func(r chi.Router) {
+			r.Use(app.AuthUserMiddleware)
+			r.Get("/", app.getWebhooksHandler)
+			r.Post("/", app.createWebhookHandler)
+			r.Put("/{webhookId}", app.updateWebhookHandler)
+			r.Delete("/{webhookId}", app.deleteWebhookHandler)
+			r.Get("/{webhookId}/deliveries", app.getWebhookDeliveriesHandler)
+			r.Post("/{webhookId}/deliveries/{deliveryId}/redeliver", app.redeliverWebhookHandler)
+		})
+
+		r.Route("/imports", func(r chi.Router) {
+			r.Use(app.AuthUserMiddleware)
+			r.Get("/", app.getImportJobsHandler)
+			r.Post("/customers", app.importCustomersHandler)
+			r.Post("/events", app.importEventsHandler)
+			r.Get("/{importId}", app.getImportJobHandler)
+		})
+
 		r.Route("/admin", func(r chi.Router) {
 			r.Use(app.AuthUserMiddleware)
 			r.Get("/images", app.getImagesHandler)
@@ -206,6 +424,8 @@ func (app *application) run(mux http.Handler) error {
 		ReadTimeout:  time.Second * 10,
 		IdleTimeout:  time.Minute,
 	}
+	// Ends the calendar streams, they would hold up the shutdown
+	srv.RegisterOnShutdown(app.calendar.Close)
 
 	shutdown := make(chan error)
 
@@ -218,7 +438,14 @@ func (app *application) run(mux http.Handler) error {
 		defer cancel()
 
 		app.logger.Infow("signal caught", "signal", s.String())
-		shutdown <- srv.Shutdown(ctx)
+		if err := srv.Shutdown(ctx); err != nil {
+			shutdown <- err
+		}
+
+		app.logger.Infow("completing background tasks", "addr", app.config.address)
+		close(app.stop)
+		app.wg.Wait()
+		shutdown <- nil
 	}()
 
 	app.logger.Infow("server has started", "addr", app.config.address, "env", app.config.env)
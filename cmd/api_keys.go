@@ -0,0 +1,430 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/georgifotev1/bms/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+type apiKeyKey string
+
+const apiKeyCtx apiKeyKey = "apiKey"
+
+// API keys look like bms_<prefix>_<secret>. The prefix is stored in plain
+// text to find the key, the whole key only as a hash.
+const (
+	apiKeyMarker        = "bms"
+	apiKeyPrefixBytes   = 6
+	apiKeySecretBytes   = 32
+	apiKeyMaxPerBrand   = 50
+	apiKeyTouchInterval = time.Minute
+)
+
+// Resources API keys can be given access to. Keys with the read scope of a
+// resource can call its GET routes, keys with the write scope the others.
+const (
+	apiKeyResourceEvents    = "events"
+	apiKeyResourceServices  = "services"
+	apiKeyResourceCustomers = "customers"
+	apiKeyResourceBrand     = "brand"
+	apiKeyResourceUsers     = "users"
+
+	apiKeyReadSuffix  = ":read"
+	apiKeyWriteSuffix = ":write"
+
+	// apiKeyScopeCustomersDelete erases customers for good, which the write
+	// scope doesn't allow
+	apiKeyScopeCustomersDelete = apiKeyResourceCustomers + ":delete"
+)
+
+var apiKeyScopes = []string{
+	apiKeyResourceEvents + apiKeyReadSuffix,
+	apiKeyResourceEvents + apiKeyWriteSuffix,
+	apiKeyResourceServices + apiKeyReadSuffix,
+	apiKeyResourceServices + apiKeyWriteSuffix,
+	apiKeyResourceCustomers + apiKeyReadSuffix,
+	apiKeyResourceCustomers + apiKeyWriteSuffix,
+	apiKeyScopeCustomersDelete,
+	apiKeyResourceBrand + apiKeyReadSuffix,
+	apiKeyResourceUsers + apiKeyReadSuffix,
+}
+
+var (
+	ErrInvalidAPIKey      = errors.New("invalid API key")
+	ErrAPIKeyScope        = errors.New("the API key is missing the scope")
+	ErrAPIKeyNotFound     = errors.New("API key not found")
+	ErrAPIKeyLimitReached = fmt.Errorf("a brand can have at most %d active API keys", apiKeyMaxPerBrand)
+)
+
+type CreateAPIKeyPayload struct {
+	Name   string   `json:"name" validate:"required,max=100"`
+	Scopes []string `json:"scopes" validate:"required,min=1,dive,required"`
+	// ExpiresAt is optional. Keys without it work until they are revoked.
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+type APIKeyResponse struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedBy  int64      `json:"createdBy"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	LastUsedIP string     `json:"lastUsedIp"`
+	RevokedAt  *time.Time `json:"revokedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+type CreateAPIKeyResponse struct {
+	APIKeyResponse
+	// Key is only returned once
+	Key string `json:"key"`
+}
+
+// @Summary		List API keys
+// @Description	Lists the API keys of the brand, including revoked and expired ones
+// @Tags			api-keys
+// @Produce		json
+// @Success		200	{array}		APIKeyResponse
+// @Failure		403	{object}	error
+// @Failure		500	{object}	error
+// @Security		CookieAuth
+// @Router			/api-keys [get]
+func (app *application) getAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxUser, err := getUserFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if ctxUser.Role == userRole || !ctxUser.BrandID.Valid {
+		app.forbiddenResponse(w, r, ErrAccessDenied)
+		return
+	}
+
+	keys, err := app.store.ListBrandApiKeys(ctx, ctxUser.BrandID.Int32)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	response := make([]APIKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		response = append(response, apiKeyResponseMapper(key))
+	}
+
+	if err := writeJSON(w, http.StatusOK, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// @Summary		Create an API key
+// @Description	Creates a key for a server to server integration. Send it as "Authorization: Bearer <key>". The key acts as the user who created it, with admin rights at most, and only on the routes of its scopes. The key is only shown in this response
+// @Tags			api-keys
+// @Accept			json
+// @Produce		json
+// @Param			payload	body		CreateAPIKeyPayload	true	"Name, scopes and expiry of the key"
+// @Success		201		{object}	CreateAPIKeyResponse
+// @Failure		400		{object}	error
+// @Failure		403		{object}	error
+// @Failure		500		{object}	error
+// @Security		CookieAuth
+// @Router			/api-keys [post]
+func (app *application) createAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxUser, err := getUserFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if ctxUser.Role == userRole || !ctxUser.BrandID.Valid {
+		app.forbiddenResponse(w, r, ErrAccessDenied)
+		return
+	}
+
+	var payload CreateAPIKeyPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	for _, scope := range payload.Scopes {
+		if !slices.Contains(apiKeyScopes, scope) {
+			app.badRequestResponse(w, r, fmt.Errorf("unknown scope %q, the scopes are %s", scope, strings.Join(apiKeyScopes, ", ")))
+			return
+		}
+	}
+
+	var expiresAt sql.NullTime
+	if payload.ExpiresAt != nil {
+		if !payload.ExpiresAt.After(time.Now()) {
+			app.badRequestResponse(w, r, errors.New("expiresAt must be in the future"))
+			return
+		}
+		expiresAt = sql.NullTime{Time: payload.ExpiresAt.UTC(), Valid: true}
+	}
+
+	// Revoked and expired keys don't count towards the limit
+	active, err := app.store.CountActiveBrandApiKeys(ctx, ctxUser.BrandID.Int32)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if active >= apiKeyMaxPerBrand {
+		app.badRequestResponse(w, r, ErrAPIKeyLimitReached)
+		return
+	}
+
+	prefix, plainKey, err := generateAPIKey()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	slices.Sort(payload.Scopes)
+
+	key, err := app.store.CreateApiKey(ctx, store.CreateApiKeyParams{
+		BrandID:   ctxUser.BrandID.Int32,
+		CreatedBy: ctxUser.ID,
+		Name:      payload.Name,
+		Prefix:    prefix,
+		KeyHash:   hashToken(plainKey),
+		Scopes:    slices.Compact(payload.Scopes),
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.logger.Infow("api key created", "key", key.ID, "brand", key.BrandID, "by", ctxUser.ID, "scopes", key.Scopes)
+
+	response := CreateAPIKeyResponse{
+		APIKeyResponse: apiKeyResponseMapper(key),
+		Key:            plainKey,
+	}
+
+	if err := writeJSON(w, http.StatusCreated, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// @Summary		Revoke an API key
+// @Description	Stops the key from working. Revoked keys stay listed
+// @Tags			api-keys
+// @Produce		json
+// @Param			keyId	path		int	true	"API key ID"
+// @Success		200		{object}	APIKeyResponse
+// @Failure		400		{object}	error
+// @Failure		403		{object}	error
+// @Failure		404		{object}	error
+// @Failure		500		{object}	error
+// @Security		CookieAuth
+// @Router			/api-keys/{keyId} [delete]
+func (app *application) revokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxUser, err := getUserFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if ctxUser.Role == userRole || !ctxUser.BrandID.Valid {
+		app.forbiddenResponse(w, r, ErrAccessDenied)
+		return
+	}
+
+	keyID, err := strconv.ParseInt(chi.URLParam(r, "keyId"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	key, err := app.store.RevokeApiKey(ctx, store.RevokeApiKeyParams{
+		ID:      keyID,
+		BrandID: ctxUser.BrandID.Int32,
+	})
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			app.notFoundResponse(w, r, ErrAPIKeyNotFound)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.logger.Infow("api key revoked", "key", key.ID, "brand", key.BrandID, "by", ctxUser.ID)
+
+	if err := writeJSON(w, http.StatusOK, apiKeyResponseMapper(key)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// AuthUserOrAPIKeyMiddleware authenticates requests with an API key in the
+// Authorization header and falls back to the session cookie otherwise. Keys
+// need the read scope of the resource for GET requests and the write scope for
+// the others.
+func (app *application) AuthUserOrAPIKeyMiddleware(resource string) func(http.Handler) http.Handler {
+	return app.authUserOrAPIKey(func(r *http.Request) string {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			return resource + apiKeyWriteSuffix
+		}
+		return resource + apiKeyReadSuffix
+	})
+}
+
+// AuthUserOrAPIKeyScopeMiddleware is AuthUserOrAPIKeyMiddleware for routes
+// whose keys need a scope of their own
+func (app *application) AuthUserOrAPIKeyScopeMiddleware(scope string) func(http.Handler) http.Handler {
+	return app.authUserOrAPIKey(func(*http.Request) string {
+		return scope
+	})
+}
+
+func (app *application) authUserOrAPIKey(requiredScope func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		sessionAuth := app.AuthUserMiddleware(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			plainKey, ok := bearerToken(r)
+			if !ok {
+				sessionAuth.ServeHTTP(w, r)
+				return
+			}
+
+			ctx := r.Context()
+
+			key, user, err := app.authenticateAPIKey(ctx, plainKey)
+			if err != nil {
+				app.unauthorizedErrorResponse(w, r, err)
+				return
+			}
+
+			scope := requiredScope(r)
+			if !slices.Contains(key.Scopes, scope) {
+				app.forbiddenResponse(w, r, fmt.Errorf("%w %s", ErrAPIKeyScope, scope))
+				return
+			}
+
+			if !key.LastUsedAt.Valid || time.Since(key.LastUsedAt.Time) > apiKeyTouchInterval {
+				if err := app.store.TouchApiKey(ctx, store.TouchApiKeyParams{
+					ID:         key.ID,
+					LastUsedIp: toNullString(clientIP(r)),
+				}); err != nil {
+					app.logger.Warnw("error updating api key activity", "key", key.ID, "error", err)
+				}
+			}
+
+			actor, _ := store.ActorFromContext(ctx)
+			actor.Type, actor.ID, actor.Name, actor.APIKeyID = store.ActorAPIKey, key.ID, key.Name, key.ID
+
+			ctx = store.WithBrandID(ctx, key.BrandID)
+			ctx = store.WithActor(ctx, actor)
+			ctx = context.WithValue(ctx, userCtx, user)
+			ctx = context.WithValue(ctx, apiKeyCtx, key)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// authenticateAPIKey returns the key and the user it acts as. The user is a
+// copy of the creator of the key with admin rights at most, so a key never
+// does what only the owner may.
+func (app *application) authenticateAPIKey(ctx context.Context, plainKey string) (*store.ApiKey, *store.User, error) {
+	prefix, ok := apiKeyPrefix(plainKey)
+	if !ok {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	key, err := app.store.GetApiKeyByPrefix(ctx, prefix)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, nil, ErrInvalidAPIKey
+		default:
+			return nil, nil, err
+		}
+	}
+
+	if !hmac.Equal([]byte(hashToken(plainKey)), []byte(key.KeyHash)) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	switch {
+	case key.RevokedAt.Valid:
+		return nil, nil, fmt.Errorf("%w: revoked", ErrInvalidAPIKey)
+	case key.ExpiresAt.Valid && time.Now().After(key.ExpiresAt.Time):
+		return nil, nil, fmt.Errorf("%w: expired", ErrInvalidAPIKey)
+	}
+
+	creator, err := app.getUser(ctx, key.CreatedBy)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !creator.BrandID.Valid || creator.BrandID.Int32 != key.BrandID {
+		return nil, nil, fmt.Errorf("%w: the creator left the brand", ErrInvalidAPIKey)
+	}
+
+	user := *creator
+	if user.Role == ownerRole {
+		user.Role = adminRole
+	}
+
+	return key, &user, nil
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+func generateAPIKey() (string, string, error) {
+	prefixBytes := make([]byte, apiKeyPrefixBytes)
+	if _, err := rand.Read(prefixBytes); err != nil {
+		return "", "", err
+	}
+
+	secret := make([]byte, apiKeySecretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+
+	prefix := hex.EncodeToString(prefixBytes)
+	key := fmt.Sprintf("%s_%s_%s", apiKeyMarker, prefix, base64.RawURLEncoding.EncodeToString(secret))
+	return prefix, key, nil
+}
+
+func apiKeyPrefix(plainKey string) (string, bool) {
+	parts := strings.SplitN(plainKey, "_", 3)
+	if len(parts) != 3 || parts[0] != apiKeyMarker || len(parts[1]) != apiKeyPrefixBytes*2 {
+		return "", false
+	}
+	return parts[1], true
+}
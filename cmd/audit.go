@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/georgifotev1/bms/internal/store"
+	"github.com/go-chi/chi/middleware"
+)
+
+type AuditLogResponse struct {
+	ID         int64           `json:"id"`
+	ActorType  string          `json:"actorType"`
+	ActorID    *int64          `json:"actorId"`
+	ActorName  string          `json:"actorName"`
+	APIKeyID   *int64          `json:"apiKeyId"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entityType"`
+	EntityID   string          `json:"entityId"`
+	Changes    json.RawMessage `json:"changes" swaggertype:"object"`
+	RequestID  string          `json:"requestId"`
+	IPAddress  string          `json:"ipAddress"`
+	CreatedAt  time.Time       `json:"createdAt"`
+}
+
+// AuditMiddleware puts the request ID and the client IP address in the audit
+// actor of the request. Requests are anonymous until an auth middleware tells
+// who made them.
+func (app *application) AuditMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := store.WithActor(r.Context(), store.Actor{
+			Type:      store.ActorAnonymous,
+			RequestID: middleware.GetReqID(r.Context()),
+			IP:        clientIP(r),
+		})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// withAuditActor attributes the changes of the request to the actor, keeping
+// the request details set by AuditMiddleware
+func withAuditActor(ctx context.Context, actorType string, id int64, name string) context.Context {
+	actor, _ := store.ActorFromContext(ctx)
+	actor.Type = actorType
+	actor.ID = id
+	actor.Name = name
+	return store.WithActor(ctx, actor)
+}
+
+// @Summary		List the audit log
+// @Description	Lists the changes to the brand, newest first unless sorted by createdAt. Personal data of customers is redacted in the changes
+// @Tags			audit
+// @Produce		json
+// @Param			entityType	query		string	false	"Table of the entity, e.g. events, services, customers"
+// @Param			entityId	query		string	false	"ID of the entity"
+// @Param			action		query		string	false	"Action, e.g. events.update"
+// @Param			actorType	query		string	false	"user, customer, api_key, anonymous or system"
+// @Param			actorId		query		int		false	"ID of the user, customer or API key"
+// @Param			from		query		string	false	"Start date in YYYY-MM-DD format"
+// @Param			to			query		string	false	"End date (exclusive) in YYYY-MM-DD format"
+// @Param			sort		query		string	false	"createdAt, prefixed with - for descending order"	default(-createdAt)
+// @Param			limit		query		int		false	"Page size"	default(50)	maximum(100)
+// @Param			cursor		query		string	false	"Cursor of the next page"
+// @Success		200			{object}	PageResponse[AuditLogResponse]
+// @Failure		400			{object}	error
+// @Failure		403			{object}	error
+// @Failure		500			{object}	error
+// @Security		CookieAuth
+// @Router			/audit-log [get]
+func (app *application) getAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxUser, err := getUserFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if ctxUser.Role != ownerRole || !ctxUser.BrandID.Valid {
+		app.forbiddenResponse(w, r, ErrAccessDenied)
+		return
+	}
+
+	query := r.URL.Query()
+	if query.Get("sort") == "" {
+		query.Set("sort", "-createdAt")
+		r.URL.RawQuery = query.Encode()
+	}
+
+	page, err := parsePageParams(r, []string{"createdAt"}, "createdAt")
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	cursorID, err := page.cursorInt64()
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	params := store.ListAuditLogParams{
+		BrandID:    ctxUser.BrandID.Int32,
+		EntityType: query.Get("entityType"),
+		EntityID:   query.Get("entityId"),
+		Action:     query.Get("action"),
+		ActorType:  query.Get("actorType"),
+		HasCursor:  page.Cursor != nil,
+		Descending: page.Descending,
+		CursorID:   cursorID,
+		PageLimit:  page.fetchLimit(),
+	}
+
+	if value := query.Get("actorId"); value != "" {
+		params.ActorID, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			app.badRequestResponse(w, r, fmt.Errorf("invalid actorId: %w", err))
+			return
+		}
+	}
+
+	for name, target := range map[string]*sql.NullTime{"from": &params.CreatedFrom, "to": &params.CreatedTo} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		date, err := time.Parse(dateLayout, value)
+		if err != nil {
+			app.badRequestResponse(w, r, fmt.Errorf("invalid %s, must be YYYY-MM-DD", name))
+			return
+		}
+		*target = sql.NullTime{Time: date, Valid: true}
+	}
+
+	entries, err := app.store.ListAuditLog(ctx, params)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	response := paginate(page, entries, func(entry *store.AuditLog) (string, string) {
+		return "", strconv.FormatInt(entry.ID, 10)
+	}, auditLogResponseMapper)
+
+	if err := writeJSON(w, http.StatusOK, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// purgeAuditLog deletes the entries older than the retention
+func (app *application) purgeAuditLog(ctx context.Context) error {
+	count, err := app.store.DeleteAuditLogBefore(ctx, time.Now().Add(-app.config.audit.retention))
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		app.logger.Infow("purged audit log entries", "count", count)
+	}
+	return nil
+}
@@ -67,10 +67,7 @@ func (app *application) signUpUserHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	session, err := app.store.CreateUserSession(r.Context(), store.CreateUserSessionParams{
-		UserID:    user.ID,
-		ExpiresAt: time.Now().UTC().Add(app.config.auth.session.exp),
-	})
+	session, err := app.store.CreateUserSession(ctx, app.userSessionParams(r, user.ID, false))
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -82,7 +79,7 @@ func (app *application) signUpUserHandler(w http.ResponseWriter, r *http.Request
 		Username: user.Name,
 	}
 
-	status, err := app.mailer.Send(mailer.WelcomeTemplate, user.Name, user.Email, vars)
+	status, err := app.mailer.Send(ctx, mailer.WelcomeTemplate, user.Name, user.Email, vars)
 	if err != nil {
 		app.logger.Errorw("error sending welcome email", "error", err)
 
@@ -102,7 +99,7 @@ func (app *application) signUpUserHandler(w http.ResponseWriter, r *http.Request
 
 	app.logger.Infow("Email sent", "status code", status)
 
-	app.SetCookie(w, SESSION_TOKEN, session.ID.String())
+	app.setUserSessionCookie(w, session)
 
 	userResponse := userResponseMapper(user)
 
@@ -112,21 +109,24 @@ func (app *application) signUpUserHandler(w http.ResponseWriter, r *http.Request
 }
 
 type SignInUserPayload struct {
-	Email    string `json:"email" validate:"required,email,max=255"`
-	Password string `json:"password" validate:"required,min=3,max=72"`
+	Email      string `json:"email" validate:"required,email,max=255"`
+	Password   string `json:"password" validate:"required,min=3,max=72"`
+	RememberMe bool   `json:"rememberMe"`
 }
 
 // createTokenHandler godoc
 //
 //	@Summary		Sign in user
-//	@Description	Sign in user
+//	@Description	Sign in user. Users with two-factor authentication, or whose brand requires it, get a challenge for the second step instead of a session
 //	@Tags			auth
 //	@Accept			json
 //	@Produce		json
-//	@Param			payload	body		SignInUserPayload	true	"User credentials"
-//	@Success		200		{string}	UserResponse		"User data"
+//	@Param			payload	body		SignInUserPayload			true	"User credentials"
+//	@Success		200		{string}	UserResponse				"User data"
+//	@Success		202		{object}	TwoFactorChallengeResponse	"Second step required"
 //	@Failure		400		{object}	error
 //	@Failure		401		{object}	error
+//	@Failure		429		{object}	error	"Too many failed attempts"
 //	@Failure		500		{object}	error
 //	@Router			/auth/signin [post]
 func (app *application) signInUserHandler(w http.ResponseWriter, r *http.Request) {
@@ -143,10 +143,16 @@ func (app *application) signInUserHandler(w http.ResponseWriter, r *http.Request
 
 	ctx := r.Context()
 
+	attempt := newUserSignInAttempt(r, payload.Email)
+	if app.signInBlocked(w, r, attempt) {
+		return
+	}
+
 	user, err := app.store.GetUserByEmail(ctx, payload.Email)
 	if err != nil {
 		switch err {
 		case sql.ErrNoRows:
+			app.signInFailed(ctx, attempt, signInReasonUnknownAccount)
 			app.unauthorizedErrorResponse(w, r, err)
 		default:
 			app.internalServerError(w, r, err)
@@ -154,22 +160,47 @@ func (app *application) signInUserHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	attempt.AccountID = toNullInt64(user.ID)
+	attempt.BrandID = user.BrandID
+
 	err = bcrypt.CompareHashAndPassword(user.Password, []byte(payload.Password))
 	if err != nil {
+		if app.signInFailed(ctx, attempt, signInReasonWrongPassword) {
+			app.sendUserLockedEmail(user, attempt.IpAddress)
+		}
 		app.unauthorizedErrorResponse(w, r, err)
 		return
 	}
 
-	session, err := app.store.UpsertUserSession(ctx, store.UpsertUserSessionParams{
-		UserID:    user.ID,
-		ExpiresAt: time.Now().UTC().Add(app.config.auth.session.exp),
-	})
+	// Users with 2FA get the session from the second step
+	challenge, err := app.userSignInChallenge(ctx, user, payload.RememberMe)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if challenge != nil {
+		attempt.Reason = signInReasonTwoFactorChallenge
+		app.recordSignInAttempt(ctx, attempt)
+
+		if err := writeJSON(w, http.StatusAccepted, challenge); err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// A new session ID is issued on every sign in and the one the browser
+	// had before is revoked
+	app.revokeUserSessionFromCookie(r)
+
+	session, err := app.store.CreateUserSession(ctx, app.userSessionParams(r, user.ID, payload.RememberMe))
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	app.SetCookie(w, SESSION_TOKEN, session.ID.String())
+	app.signInSucceeded(ctx, attempt)
+	app.setUserSessionCookie(w, session)
 
 	userResponse := userResponseMapper(user)
 
@@ -181,7 +212,7 @@ func (app *application) signInUserHandler(w http.ResponseWriter, r *http.Request
 // logoutHandler godoc
 //
 //	@Summary		Logs out a user
-//	@Description	Clears the session cookie to log out the user
+//	@Description	Revokes the current session and clears the session cookie. Sessions on other devices stay signed in
 //	@Tags			auth
 //	@Produce		json
 //	@Success		200	{string}	string	"Logged out successfully"
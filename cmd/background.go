@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/georgifotev1/bms/internal/store"
+)
+
+// background runs fn in its own goroutine. Panics are recovered and logged and
+// the server waits for running tasks before it stops.
+func (app *application) background(fn func()) {
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.Errorw("background task panicked", "error", fmt.Sprint(err))
+			}
+		}()
+
+		fn()
+	}()
+}
+
+// every runs fn on each interval until the server stops. Jobs work across
+// brands so fn gets a privileged context. A failed run is logged and retried
+// on the next tick.
+func (app *application) every(interval time.Duration, name string, fn func(ctx context.Context) error) {
+	app.background(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-app.stop:
+				return
+			case <-ticker.C:
+				if err := fn(store.WithPrivileges(context.Background())); err != nil {
+					app.logger.Errorw("periodic task failed", "task", name, "error", err)
+				}
+			}
+		}
+	})
+}
@@ -75,6 +75,12 @@ func (app *application) createBrandHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	// The user became the owner of the brand
+	if err := app.rotateUserSession(w, r); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
 	brandResponse := brandResponseMapper(brand, nil, wh)
 	if err := writeJSON(w, http.StatusCreated, brandResponse); err != nil {
 		app.internalServerError(w, r, err)
@@ -0,0 +1,476 @@
+package main
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/georgifotev1/bms/internal/ical"
+	"github.com/georgifotev1/bms/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	calendarFeedTokenBytes = 32
+	// calendarFeedHistory is how far back staff and brand feeds go. Customer
+	// feeds only have upcoming bookings.
+	calendarFeedHistory   = 30 * 24 * time.Hour
+	calendarFeedMaxEvents = 2000
+	calendarFeedRefresh   = 15 * time.Minute
+	calendarFeedProdID    = "-//bms//Calendar feed//EN"
+)
+
+var ErrCalendarFeedNotFound = errors.New("calendar feed not found")
+
+type CalendarFeedResponse struct {
+	// URL is only returned when the feed is created or regenerated
+	URL           string     `json:"url,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	LastFetchedAt *time.Time `json:"lastFetchedAt"`
+}
+
+// @Summary		Get my calendar feed
+// @Description	Tells whether the signed in staff member has a calendar feed. The URL is only shown when it is generated
+// @Tags			calendar-feeds
+// @Produce		json
+// @Success		200	{object}	CalendarFeedResponse
+// @Failure		404	{object}	error
+// @Failure		500	{object}	error
+// @Security		CookieAuth
+// @Router			/users/me/calendar-feed [get]
+func (app *application) getMyCalendarFeedHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxUser, err := getUserFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	feed, err := app.store.GetUserCalendarFeed(ctx, sql.NullInt64{Int64: ctxUser.ID, Valid: true})
+	app.writeCalendarFeed(w, r, feed, "", err)
+}
+
+// @Summary		Generate my calendar feed
+// @Description	Returns a secret iCalendar URL with the appointments of the signed in staff member. Generating it again revokes the previous URL
+// @Tags			calendar-feeds
+// @Produce		json
+// @Success		201	{object}	CalendarFeedResponse
+// @Failure		403	{object}	error
+// @Failure		500	{object}	error
+// @Security		CookieAuth
+// @Router			/users/me/calendar-feed [post]
+func (app *application) rotateMyCalendarFeedHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxUser, err := getUserFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if !ctxUser.BrandID.Valid {
+		app.forbiddenResponse(w, r, ErrAccessDenied)
+		return
+	}
+
+	token, err := generateCalendarFeedToken()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	feed, err := app.store.RotateUserCalendarFeed(ctx, store.RotateUserCalendarFeedParams{
+		BrandID:   ctxUser.BrandID.Int32,
+		UserID:    sql.NullInt64{Int64: ctxUser.ID, Valid: true},
+		TokenHash: hashToken(token),
+	})
+	app.writeCalendarFeed(w, r, feed, token, err)
+}
+
+// @Summary		Revoke my calendar feed
+// @Description	Stops the calendar feed URL of the signed in staff member from working
+// @Tags			calendar-feeds
+// @Success		204
+// @Failure		404	{object}	error
+// @Failure		500	{object}	error
+// @Security		CookieAuth
+// @Router			/users/me/calendar-feed [delete]
+func (app *application) deleteMyCalendarFeedHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxUser, err := getUserFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	feed, err := app.store.GetUserCalendarFeed(ctx, sql.NullInt64{Int64: ctxUser.ID, Valid: true})
+	app.deleteCalendarFeed(w, r, feed, err)
+}
+
+// @Summary		Get my booking feed
+// @Description	Tells whether the signed in customer has a calendar feed. The URL is only shown when it is generated
+// @Tags			calendar-feeds
+// @Produce		json
+// @Param			X-Brand-ID	header		string	false	"Brand ID header for development. In production this header is ignored"	default(1)
+// @Success		200			{object}	CalendarFeedResponse
+// @Failure		404			{object}	error
+// @Failure		500			{object}	error
+// @Router			/customers/me/calendar-feed [get]
+func (app *application) getMyCustomerCalendarFeedHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customer, err := getCustomerFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	feed, err := app.store.GetCustomerCalendarFeed(ctx, sql.NullInt64{Int64: customer.ID, Valid: true})
+	app.writeCalendarFeed(w, r, feed, "", err)
+}
+
+// @Summary		Generate my booking feed
+// @Description	Returns a secret iCalendar URL with the upcoming bookings of the signed in customer. Generating it again revokes the previous URL
+// @Tags			calendar-feeds
+// @Produce		json
+// @Param			X-Brand-ID	header		string	false	"Brand ID header for development. In production this header is ignored"	default(1)
+// @Success		201			{object}	CalendarFeedResponse
+// @Failure		500			{object}	error
+// @Router			/customers/me/calendar-feed [post]
+func (app *application) rotateMyCustomerCalendarFeedHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customer, err := getCustomerFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	token, err := generateCalendarFeedToken()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	feed, err := app.store.RotateCustomerCalendarFeed(ctx, store.RotateCustomerCalendarFeedParams{
+		BrandID:    customer.BrandID,
+		CustomerID: sql.NullInt64{Int64: customer.ID, Valid: true},
+		TokenHash:  hashToken(token),
+	})
+	app.writeCalendarFeed(w, r, feed, token, err)
+}
+
+// @Summary		Revoke my booking feed
+// @Description	Stops the calendar feed URL of the signed in customer from working
+// @Tags			calendar-feeds
+// @Param			X-Brand-ID	header	string	false	"Brand ID header for development. In production this header is ignored"	default(1)
+// @Success		204
+// @Failure		404	{object}	error
+// @Failure		500	{object}	error
+// @Router			/customers/me/calendar-feed [delete]
+func (app *application) deleteMyCustomerCalendarFeedHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customer, err := getCustomerFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	feed, err := app.store.GetCustomerCalendarFeed(ctx, sql.NullInt64{Int64: customer.ID, Valid: true})
+	app.deleteCalendarFeed(w, r, feed, err)
+}
+
+// @Summary		Get the brand calendar feed
+// @Description	Tells whether the brand has a calendar feed with the appointments of all staff. The URL is only shown when it is generated
+// @Tags			calendar-feeds
+// @Produce		json
+// @Param			id	path		int	true	"Brand ID"
+// @Success		200	{object}	CalendarFeedResponse
+// @Failure		403	{object}	error
+// @Failure		404	{object}	error
+// @Failure		500	{object}	error
+// @Security		CookieAuth
+// @Router			/brand/{id}/calendar-feed [get]
+func (app *application) getBrandCalendarFeedHandler(w http.ResponseWriter, r *http.Request) {
+	brandID, ok := app.calendarFeedBrand(w, r)
+	if !ok {
+		return
+	}
+
+	feed, err := app.store.GetBrandCalendarFeed(r.Context(), brandID)
+	app.writeCalendarFeed(w, r, feed, "", err)
+}
+
+// @Summary		Generate the brand calendar feed
+// @Description	Returns a secret iCalendar URL with the appointments of all staff. Generating it again revokes the previous URL
+// @Tags			calendar-feeds
+// @Produce		json
+// @Param			id	path		int	true	"Brand ID"
+// @Success		201	{object}	CalendarFeedResponse
+// @Failure		403	{object}	error
+// @Failure		500	{object}	error
+// @Security		CookieAuth
+// @Router			/brand/{id}/calendar-feed [post]
+func (app *application) rotateBrandCalendarFeedHandler(w http.ResponseWriter, r *http.Request) {
+	brandID, ok := app.calendarFeedBrand(w, r)
+	if !ok {
+		return
+	}
+
+	token, err := generateCalendarFeedToken()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	feed, err := app.store.RotateBrandCalendarFeed(r.Context(), store.RotateBrandCalendarFeedParams{
+		BrandID:   brandID,
+		TokenHash: hashToken(token),
+	})
+	app.writeCalendarFeed(w, r, feed, token, err)
+}
+
+// @Summary		Revoke the brand calendar feed
+// @Description	Stops the brand calendar feed URL from working
+// @Tags			calendar-feeds
+// @Param			id	path	int	true	"Brand ID"
+// @Success		204
+// @Failure		403	{object}	error
+// @Failure		404	{object}	error
+// @Failure		500	{object}	error
+// @Security		CookieAuth
+// @Router			/brand/{id}/calendar-feed [delete]
+func (app *application) deleteBrandCalendarFeedHandler(w http.ResponseWriter, r *http.Request) {
+	brandID, ok := app.calendarFeedBrand(w, r)
+	if !ok {
+		return
+	}
+
+	feed, err := app.store.GetBrandCalendarFeed(r.Context(), brandID)
+	app.deleteCalendarFeed(w, r, feed, err)
+}
+
+// @Summary		Calendar feed
+// @Description	iCalendar feed of a staff member, a brand or a customer. The token in the URL is the only credential
+// @Tags			calendar-feeds
+// @Produce		text/calendar
+// @Param			token	path		string	true	"Feed token"
+// @Success		200		{string}	string	"iCalendar data"
+// @Failure		404		{object}	error
+// @Failure		500		{object}	error
+// @Router			/calendar/{token}.ics [get]
+func (app *application) calendarFeedHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	token := chi.URLParam(r, "token")
+
+	feed, err := app.store.GetCalendarFeedByTokenHash(ctx, hashToken(token))
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			app.notFoundResponse(w, r, ErrCalendarFeedNotFound)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	ctx = store.WithBrandID(ctx, feed.BrandID)
+
+	brand, err := app.store.GetBrandById(ctx, feed.BrandID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	params := store.ListCalendarFeedEventsParams{
+		BrandID:    feed.BrandID,
+		UserID:     feed.UserID,
+		CustomerID: feed.CustomerID,
+		StartFrom:  time.Now().UTC().Add(-calendarFeedHistory),
+		PageLimit:  calendarFeedMaxEvents,
+	}
+	if feed.CustomerID.Valid {
+		params.StartFrom = time.Now().UTC()
+	}
+
+	events, err := app.store.ListCalendarFeedEvents(ctx, params)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	calendar := ical.Calendar{
+		ProdID:          calendarFeedProdID,
+		Name:            brand.Name,
+		RefreshInterval: calendarFeedRefresh,
+		Events:          make([]ical.Event, 0, len(events)),
+	}
+	if feed.UserID.Valid {
+		user, err := app.getUser(ctx, feed.UserID.Int64)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		calendar.Name = fmt.Sprintf("%s - %s", brand.Name, user.Name)
+	}
+
+	location := brandLocation(brand)
+	for _, event := range events {
+		entry := ical.Event{
+			UID:          calendarFeedUID(event.ID, brand),
+			Start:        event.StartTime,
+			End:          event.EndTime,
+			Location:     location,
+			Sequence:     int(event.Version) - 1,
+			Created:      event.CreatedAt,
+			LastModified: event.UpdatedAt,
+		}
+
+		if feed.CustomerID.Valid {
+			// Customers see their booking, not the notes of the staff
+			entry.Summary = fmt.Sprintf("%s at %s", event.ServiceName, brand.Name)
+			entry.Description = "With " + event.UserName
+			if event.DependentName.Valid {
+				entry.Description += "\nFor " + event.DependentName.String
+			}
+		} else {
+			entry.Summary = fmt.Sprintf("%s - %s", event.ServiceName, event.CustomerName)
+			entry.Description = calendarFeedDescription(event, !feed.UserID.Valid)
+		}
+
+		calendar.Events = append(calendar.Events, entry)
+	}
+
+	if err := app.store.TouchCalendarFeed(ctx, feed.ID); err != nil {
+		app.logger.Warnw("error updating calendar feed activity", "feed", feed.ID, "error", err)
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="calendar.ics"`)
+	w.Header().Set("Cache-Control", "no-cache")
+	if err := calendar.Write(w); err != nil {
+		app.logger.Warnw("error writing calendar feed", "feed", feed.ID, "error", err)
+	}
+}
+
+// calendarFeedUID identifies an event in the feeds of its brand
+func calendarFeedUID(eventID int64, brand *store.Brand) string {
+	return fmt.Sprintf("event-%d@%s", eventID, brand.PageUrl)
+}
+
+// isCalendarFeedUID tells whether an event is a copy of one of the brand,
+// for example when staff import a calendar they subscribed to their feed
+func isCalendarFeedUID(uid string, brand *store.Brand) bool {
+	id, ok := strings.CutPrefix(uid, "event-")
+	if !ok {
+		return false
+	}
+	id, ok = strings.CutSuffix(id, "@"+brand.PageUrl)
+	if !ok {
+		return false
+	}
+	_, err := strconv.ParseInt(id, 10, 64)
+	return err == nil
+}
+
+func calendarFeedDescription(event *store.Event, withStaff bool) string {
+	lines := []string{"Service: " + event.ServiceName, "Customer: " + event.CustomerName}
+	if event.DependentName.Valid {
+		lines = append(lines, "For: "+event.DependentName.String)
+	}
+	if withStaff {
+		lines = append(lines, "Staff: "+event.UserName)
+	}
+	if event.Comment.Valid && event.Comment.String != "" {
+		lines = append(lines, "Comment: "+event.Comment.String)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func brandLocation(brand *store.Brand) string {
+	var parts []string
+	for _, part := range []sql.NullString{brand.Address, brand.City, brand.ZipCode, brand.Country} {
+		if part.Valid && part.String != "" {
+			parts = append(parts, part.String)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// calendarFeedBrand checks that the signed in user is an owner or admin of
+// the brand of the path
+func (app *application) calendarFeedBrand(w http.ResponseWriter, r *http.Request) (int32, bool) {
+	ctxUser, err := getUserFromCtx(r.Context())
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return 0, false
+	}
+
+	brandID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return 0, false
+	}
+
+	if ctxUser.Role == userRole || ctxUser.BrandID.Int32 != int32(brandID) {
+		app.forbiddenResponse(w, r, ErrAccessDenied)
+		return 0, false
+	}
+
+	return int32(brandID), true
+}
+
+// writeCalendarFeed answers with the feed, including its URL when the token
+// was just generated
+func (app *application) writeCalendarFeed(w http.ResponseWriter, r *http.Request, feed *store.CalendarFeed, token string, err error) {
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			app.notFoundResponse(w, r, ErrCalendarFeedNotFound)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	response := calendarFeedResponseMapper(feed)
+	status := http.StatusOK
+	if token != "" {
+		response.URL = fmt.Sprintf("%s/v1/calendar/%s.ics", app.config.apiUrl, token)
+		status = http.StatusCreated
+	}
+
+	if err := writeJSON(w, status, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) deleteCalendarFeed(w http.ResponseWriter, r *http.Request, feed *store.CalendarFeed, err error) {
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			app.notFoundResponse(w, r, ErrCalendarFeedNotFound)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if _, err := app.store.DeleteCalendarFeed(r.Context(), feed.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func generateCalendarFeedToken() (string, error) {
+	b := make([]byte, calendarFeedTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/georgifotev1/bms/internal/realtime"
+	"github.com/georgifotev1/bms/internal/store"
+)
+
+const (
+	// calendarChangeRetention is how far back a reconnecting client can
+	// catch up
+	calendarChangeRetention = 24 * time.Hour
+	calendarCatchUpPage     = 500
+	streamPingInterval      = 25 * time.Second
+	streamRetry             = 3 * time.Second
+)
+
+// @Summary		Stream calendar changes
+// @Description	Streams the created, updated and cancelled events of the brand as Server-Sent Events. The id of every
+// @Description	message is the change ID, a client that reconnects with Last-Event-ID receives the changes it missed
+// @Description	during the last 24 hours. The data is the changed event with its times, refetch the day to show it.
+// @Tags			events
+// @Produce		text/event-stream
+// @Param			userId			query		int		false	"Only changes on the calendar of this staff member"
+// @Param			Last-Event-ID	header		string	false	"ID of the last change the client received"
+// @Param			lastEventId		query		int		false	"Last-Event-ID for clients that can't set headers"
+// @Success		200				{string}	string	"Stream of created, updated and cancelled messages"
+// @Failure		400				{object}	error
+// @Failure		403				{object}	error
+// @Security		CookieAuth
+// @Router			/events/stream [get]
+func (app *application) streamCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxUser, err := getUserFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if !ctxUser.BrandID.Valid {
+		app.forbiddenResponse(w, r, ErrAccessDenied)
+		return
+	}
+	brandID := ctxUser.BrandID.Int32
+
+	query := r.URL.Query()
+	var staffID int64
+	if value := query.Get("userId"); value != "" {
+		staffID, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			app.badRequestResponse(w, r, fmt.Errorf("invalid userId: %w", err))
+			return
+		}
+	}
+
+	lastID := int64(0)
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = query.Get("lastEventId")
+	}
+	if lastEventID != "" {
+		lastID, err = strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil {
+			app.badRequestResponse(w, r, errors.New("invalid Last-Event-ID"))
+			return
+		}
+	}
+
+	// The stream outlives the write timeout of the server
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// Subscribe before catching up so nothing is missed in between
+	sub := app.calendar.Subscribe(brandID)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(change realtime.Change) error {
+		lastID = change.ID
+		if staffID != 0 && !change.Concerns(staffID) {
+			return nil
+		}
+
+		data, err := json.Marshal(change)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", change.ID, change.Kind, data); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", streamRetry.Milliseconds()); err != nil {
+		return
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	if lastID > 0 {
+		if err := app.catchUpCalendar(ctx, brandID, lastID, send); err != nil {
+			app.logger.Warnw("calendar stream catch up failed", "brand", brandID, "error", err)
+			return
+		}
+	}
+
+	ping := time.NewTicker(streamPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case change, ok := <-sub.C:
+			// Dropped by the broker, the client reconnects and catches up
+			if !ok {
+				return
+			}
+			// IDs of a brand commit in order, calendar_record_change makes
+			// sure of it, so anything up to lastID was sent
+			if change.ID <= lastID {
+				continue
+			}
+			if err := send(change); err != nil {
+				return
+			}
+		case <-ping.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// catchUpCalendar sends the changes made after the last one the client saw
+func (app *application) catchUpCalendar(ctx context.Context, brandID int32, afterID int64, send func(realtime.Change) error) error {
+	for {
+		changes, err := app.store.ListCalendarChangesAfter(ctx, store.ListCalendarChangesAfterParams{
+			BrandID: brandID,
+			ID:      afterID,
+			Limit:   calendarCatchUpPage,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, change := range changes {
+			if err := send(calendarChangeMapper(change)); err != nil {
+				return err
+			}
+			afterID = change.ID
+		}
+
+		if len(changes) < calendarCatchUpPage {
+			return nil
+		}
+	}
+}
+
+// listenCalendarChanges feeds the broker until the server stops
+func (app *application) listenCalendarChanges() {
+	for {
+		err := app.calendar.Listen(app.config.db.addr, app.stop, func(err error) {
+			app.logger.Warnw("calendar listener error", "error", err)
+		})
+		if err == nil {
+			return
+		}
+
+		app.logger.Errorw("calendar listener failed", "error", err)
+		select {
+		case <-app.stop:
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// purgeCalendarChanges deletes the changes too old to catch up from
+func (app *application) purgeCalendarChanges(ctx context.Context) error {
+	_, err := app.store.DeleteCalendarChangesBefore(ctx, time.Now().Add(-calendarChangeRetention))
+	return err
+}
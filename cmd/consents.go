@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/georgifotev1/bms/internal/mailer"
+	"github.com/georgifotev1/bms/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	consentSourceSignup      = "signup"
+	consentSourceCustomer    = "customer"
+	consentSourceStaff       = "staff"
+	consentSourceUnsubscribe = "unsubscribe"
+)
+
+var (
+	consentChannels = []string{mailer.ChannelEmail, mailer.ChannelSMS}
+	consentPurposes = []string{mailer.PurposeTransactional, mailer.PurposeReminders, mailer.PurposeMarketing}
+
+	ErrInvalidUnsubscribeToken = errors.New("invalid unsubscribe link")
+)
+
+type ConsentResponse struct {
+	Channel   string     `json:"channel"`
+	Purpose   string     `json:"purpose"`
+	Granted   bool       `json:"granted"`
+	Source    string     `json:"source,omitempty"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+}
+
+type ConsentPayload struct {
+	Channel string `json:"channel" validate:"required,oneof=email sms"`
+	Purpose string `json:"purpose" validate:"required,oneof=transactional reminders marketing"`
+	Granted bool   `json:"granted"`
+}
+
+type UpdateConsentsPayload struct {
+	Consents []ConsentPayload `json:"consents" validate:"required,min=1,max=6,dive"`
+}
+
+// @Summary		Get customer consents
+// @Description	Fetches the communication preferences of a customer for every channel and purpose
+// @Tags			customers
+// @Produce		json
+// @Param			customerId	path		int	true	"Customer ID"
+// @Success		200			{array}		ConsentResponse
+// @Failure		400			{object}	error
+// @Failure		403			{object}	error
+// @Failure		404			{object}	error
+// @Failure		500			{object}	error
+// @Security		CookieAuth
+// @Router			/customers/{customerId}/consents [get]
+func (app *application) getCustomerConsentsHandler(w http.ResponseWriter, r *http.Request) {
+	customer, ok := app.brandCustomerFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	consents, err := app.store.ListCustomerConsents(r.Context(), customer.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := writeJSON(w, http.StatusOK, consentResponseMapper(consents)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// @Summary		Update customer consents
+// @Description	Records consents given or withdrawn by a customer through the staff
+// @Tags			customers
+// @Accept			json
+// @Produce		json
+// @Param			customerId	path		int						true	"Customer ID"
+// @Param			payload		body		UpdateConsentsPayload	true	"Consent changes"
+// @Success		200			{array}		ConsentResponse
+// @Failure		400			{object}	error
+// @Failure		403			{object}	error
+// @Failure		404			{object}	error
+// @Failure		500			{object}	error
+// @Security		CookieAuth
+// @Router			/customers/{customerId}/consents [put]
+func (app *application) updateCustomerConsentsHandler(w http.ResponseWriter, r *http.Request) {
+	customer, ok := app.brandCustomerFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	app.updateConsents(w, r, customer, consentSourceStaff)
+}
+
+// @Summary		Get my consents
+// @Description	Fetches the communication preferences of the signed in customer
+// @Tags			customers
+// @Produce		json
+// @Param			X-Brand-ID	header		string	false	"Brand ID header for development. In production this header is ignored"	default(1)
+// @Success		200			{array}		ConsentResponse
+// @Failure		401			{object}	error
+// @Failure		500			{object}	error
+// @Router			/customers/me/consents [get]
+func (app *application) getMyConsentsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customer, err := getCustomerFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	consents, err := app.store.ListCustomerConsents(ctx, customer.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := writeJSON(w, http.StatusOK, consentResponseMapper(consents)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// @Summary		Update my consents
+// @Description	Gives or withdraws consents of the signed in customer
+// @Tags			customers
+// @Accept			json
+// @Produce		json
+// @Param			payload		body		UpdateConsentsPayload	true	"Consent changes"
+// @Param			X-Brand-ID	header		string					false	"Brand ID header for development. In production this header is ignored"	default(1)
+// @Success		200			{array}		ConsentResponse
+// @Failure		400			{object}	error
+// @Failure		401			{object}	error
+// @Failure		500			{object}	error
+// @Router			/customers/me/consents [put]
+func (app *application) updateMyConsentsHandler(w http.ResponseWriter, r *http.Request) {
+	customer, err := getCustomerFromCtx(r.Context())
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	app.updateConsents(w, r, customer, consentSourceCustomer)
+}
+
+// @Summary		Unsubscribe
+// @Description	Withdraws the consent carried by a signed unsubscribe link. It does not require a session so it works straight from an email
+// @Tags			consents
+// @Produce		json
+// @Param			token	path		string	true	"Unsubscribe token"
+// @Success		200		{object}	ConsentResponse
+// @Failure		400		{object}	error
+// @Failure		404		{object}	error
+// @Failure		500		{object}	error
+// @Router			/consents/unsubscribe/{token} [get]
+// @Router			/consents/unsubscribe/{token} [post]
+func (app *application) unsubscribeHandler(w http.ResponseWriter, r *http.Request) {
+	claims, err := app.parseUnsubscribeToken(chi.URLParam(r, "token"))
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := store.WithBrandID(r.Context(), claims.brandID)
+	customer, err := app.getCustomer(ctx, claims.customerID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrCustomerNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if customer.BrandID != claims.brandID {
+		app.notFoundResponse(w, r, ErrCustomerNotFound)
+		return
+	}
+
+	consent, err := app.store.UpsertCustomerConsent(ctx, store.UpsertCustomerConsentParams{
+		CustomerID: customer.ID,
+		BrandID:    customer.BrandID,
+		Channel:    claims.channel,
+		Purpose:    claims.purpose,
+		Granted:    false,
+		Source:     consentSourceUnsubscribe,
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := writeJSON(w, http.StatusOK, customerConsentResponseMapper(consent)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) updateConsents(w http.ResponseWriter, r *http.Request, customer *store.Customer, source string) {
+	var payload UpdateConsentsPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	consents, err := app.store.UpdateCustomerConsentsTx(r.Context(), store.UpdateCustomerConsentsTxParams{
+		CustomerID: customer.ID,
+		BrandID:    customer.BrandID,
+		Source:     source,
+		Changes:    consentChanges(payload.Consents),
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := writeJSON(w, http.StatusOK, consentResponseMapper(consents)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func consentChanges(payload []ConsentPayload) []store.CustomerConsentChange {
+	changes := make([]store.CustomerConsentChange, 0, len(payload))
+	for _, consent := range payload {
+		changes = append(changes, store.CustomerConsentChange{
+			Channel: consent.Channel,
+			Purpose: consent.Purpose,
+			Granted: consent.Granted,
+		})
+	}
+	return changes
+}
+
+// defaultConsent tells whether a message may be sent to a customer who never
+// stated a preference. Only transactional messages are sent without consent.
+func defaultConsent(purpose string) bool {
+	return purpose == mailer.PurposeTransactional
+}
+
+// consentPreferences checks outgoing messages against the consents recorded
+// for the customers of the brand carried by the context
+type consentPreferences struct {
+	store store.Store
+}
+
+func (p consentPreferences) Allows(ctx context.Context, channel, recipient, purpose string) (bool, error) {
+	brandID, ok := store.BrandIDFromContext(ctx)
+	if !ok {
+		// Messages sent outside of a brand go to staff accounts
+		return defaultConsent(purpose), nil
+	}
+
+	consent, err := p.store.GetCustomerConsentByRecipient(ctx, store.GetCustomerConsentByRecipientParams{
+		BrandID:   brandID,
+		Channel:   channel,
+		Purpose:   purpose,
+		Recipient: recipient,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return defaultConsent(purpose), nil
+		}
+		return false, err
+	}
+
+	return consent.Granted, nil
+}
+
+type unsubscribeClaims struct {
+	brandID    int32
+	customerID int64
+	channel    string
+	purpose    string
+}
+
+// unsubscribeURL returns a link that withdraws the consent of the customer for
+// a channel and purpose. It is meant for the footer of reminder and marketing messages.
+func (app *application) unsubscribeURL(customer *store.Customer, channel, purpose string) string {
+	payload := fmt.Sprintf("%d:%d:%s:%s", customer.BrandID, customer.ID, channel, purpose)
+	token := base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+		base64.RawURLEncoding.EncodeToString(app.signUnsubscribePayload(payload))
+
+	return fmt.Sprintf("%s/v1/consents/unsubscribe/%s", app.config.apiUrl, token)
+}
+
+func (app *application) parseUnsubscribeToken(token string) (*unsubscribeClaims, error) {
+	encodedPayload, encodedSignature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidUnsubscribeToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, ErrInvalidUnsubscribeToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return nil, ErrInvalidUnsubscribeToken
+	}
+
+	if !hmac.Equal(signature, app.signUnsubscribePayload(string(payload))) {
+		return nil, ErrInvalidUnsubscribeToken
+	}
+
+	parts := strings.Split(string(payload), ":")
+	if len(parts) != 4 {
+		return nil, ErrInvalidUnsubscribeToken
+	}
+
+	brandID, err := strconv.ParseInt(parts[0], 10, 32)
+	if err != nil {
+		return nil, ErrInvalidUnsubscribeToken
+	}
+
+	customerID, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidUnsubscribeToken
+	}
+
+	return &unsubscribeClaims{
+		brandID:    int32(brandID),
+		customerID: customerID,
+		channel:    parts[2],
+		purpose:    parts[3],
+	}, nil
+}
+
+func (app *application) signUnsubscribePayload(payload string) []byte {
+	mac := hmac.New(sha256.New, []byte(app.config.mail.unsubscribeSecret))
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
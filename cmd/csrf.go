@@ -0,0 +1,165 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const CSRF_HEADER = "X-CSRF-Token"
+
+var ErrInvalidCSRFToken = errors.New("missing or invalid CSRF token")
+
+// csrfExemptPrefixes are routes called by other servers rather than browsers.
+// They authenticate with a token of their own and never with the session
+// cookies.
+var csrfExemptPrefixes = []string{
+	"/v1/consents/unsubscribe/",
+}
+
+type CSRFTokenResponse struct {
+	Token string `json:"token"`
+}
+
+// @Summary		Get a CSRF token
+// @Description	Returns the token to send in the X-CSRF-Token header with every POST, PUT and DELETE request of the session. The token changes when the session does, after signing in or a session rotation
+// @Tags			users
+// @Produce		json
+// @Success		200	{object}	CSRFTokenResponse
+// @Failure		401	{object}	error
+// @Security		CookieAuth
+// @Router			/users/me/csrf-token [get]
+func (app *application) getUserCSRFTokenHandler(w http.ResponseWriter, r *http.Request) {
+	session, err := getUserSessionFromCtx(r.Context())
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	response := CSRFTokenResponse{Token: app.csrfToken(session.ID.String())}
+	if err := writeJSON(w, http.StatusOK, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// @Summary		Get a customer CSRF token
+// @Description	Returns the token to send in the X-CSRF-Token header with every POST, PUT and DELETE request of the customer session. The token changes when the session does
+// @Tags			customers
+// @Produce		json
+// @Param			X-Brand-ID	header		string	false	"Brand ID header for development. In production this header is ignored"	default(1)
+// @Success		200			{object}	CSRFTokenResponse
+// @Failure		401			{object}	error
+// @Router			/customers/me/csrf-token [get]
+func (app *application) getCustomerCSRFTokenHandler(w http.ResponseWriter, r *http.Request) {
+	session, err := getCustomerSessionFromCtx(r.Context())
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	response := CSRFTokenResponse{Token: app.csrfToken(session.ID.String())}
+	if err := writeJSON(w, http.StatusOK, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// CSRFMiddleware rejects state changing requests that carry a session cookie
+// without the token of that session. The token is signed with the session ID
+// so a page on another site can't make one up, and it stops working when the
+// session ends.
+func (app *application) CSRFMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !csrfProtected(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		sessionIDs := app.liveSessionIDs(r)
+
+		// Without a live session the browser sends no credentials to abuse.
+		// A cookie left over from a revoked or expired session must not keep
+		// its owner from signing in again.
+		if len(sessionIDs) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token := r.Header.Get(CSRF_HEADER)
+		for _, sessionID := range sessionIDs {
+			if token != "" && hmac.Equal([]byte(token), []byte(app.csrfToken(sessionID))) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		app.forbiddenResponse(w, r, ErrInvalidCSRFToken)
+	})
+}
+
+// liveSessionIDs returns the session cookies of the request whose session
+// still exists and hasn't expired
+func (app *application) liveSessionIDs(r *http.Request) []string {
+	ctx := r.Context()
+
+	var sessionIDs []string
+	for _, name := range []string{SESSION_TOKEN, CUSTOMER_SESSION_TOKEN} {
+		cookie, err := r.Cookie(name)
+		if err != nil {
+			continue
+		}
+		sessionID, err := uuid.Parse(cookie.Value)
+		if err != nil {
+			continue
+		}
+
+		var expiresAt time.Time
+		if name == SESSION_TOKEN {
+			session, err := app.store.GetUserSessionById(ctx, sessionID)
+			if err != nil {
+				continue
+			}
+			expiresAt = session.ExpiresAt
+		} else {
+			session, err := app.store.GetCustomerSessionById(ctx, sessionID)
+			if err != nil {
+				continue
+			}
+			expiresAt = session.ExpiresAt
+		}
+
+		if time.Now().Before(expiresAt) {
+			sessionIDs = append(sessionIDs, cookie.Value)
+		}
+	}
+	return sessionIDs
+}
+
+// csrfProtected tells whether the request needs a CSRF token. Safe methods,
+// server to server routes and API key callers don't. Browsers can't add an
+// Authorization header to a cross site request without passing CORS first.
+func csrfProtected(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return false
+	}
+
+	for _, prefix := range csrfExemptPrefixes {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return false
+		}
+	}
+
+	return r.Header.Get("Authorization") == ""
+}
+
+func (app *application) csrfToken(sessionID string) string {
+	mac := hmac.New(sha256.New, []byte(app.config.auth.csrf.secret))
+	mac.Write([]byte(sessionID))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
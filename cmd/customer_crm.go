@@ -0,0 +1,666 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/georgifotev1/bms/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	fieldTypeText   = "text"
+	fieldTypeNumber = "number"
+	fieldTypeDate   = "date"
+	fieldTypeSelect = "select"
+
+	// Number of past visits returned with the customer profile
+	customerVisitsLimit = 10
+)
+
+var (
+	ErrCustomerNoteNotFound  = errors.New("note not found")
+	ErrCustomerFieldNotFound = errors.New("custom field not found")
+)
+
+type CustomerNoteResponse struct {
+	ID         int64     `json:"id"`
+	CustomerID int64     `json:"customerId"`
+	AuthorID   int64     `json:"authorId"`
+	AuthorName string    `json:"authorName"`
+	Body       string    `json:"body"`
+	Pinned     bool      `json:"pinned"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+type CreateCustomerNotePayload struct {
+	Body   string `json:"body" validate:"required,max=5000"`
+	Pinned bool   `json:"pinned"`
+}
+
+type UpdateCustomerTagsPayload struct {
+	Tags []string `json:"tags" validate:"max=50,dive,required,max=50"`
+}
+
+type CustomerFieldResponse struct {
+	ID        int64    `json:"id"`
+	Label     string   `json:"label"`
+	FieldType string   `json:"fieldType"`
+	Options   []string `json:"options"`
+}
+
+type CreateCustomerFieldPayload struct {
+	Label     string   `json:"label" validate:"required,max=100"`
+	FieldType string   `json:"fieldType" validate:"required,oneof=text number date select"`
+	Options   []string `json:"options" validate:"required_if=FieldType select,dive,required,max=100"`
+}
+
+type CustomerFieldValueResponse struct {
+	FieldID   int64  `json:"fieldId"`
+	Label     string `json:"label"`
+	FieldType string `json:"fieldType"`
+	Value     string `json:"value"`
+}
+
+type CustomerFieldValuePayload struct {
+	FieldID int64  `json:"fieldId" validate:"required,gt=0"`
+	Value   string `json:"value" validate:"max=1000"`
+}
+
+type UpdateCustomerFieldValuesPayload struct {
+	Fields []CustomerFieldValuePayload `json:"fields" validate:"required,min=1,dive"`
+}
+
+type CustomerDetailResponse struct {
+	Customer        CustomerResponse             `json:"customer"`
+	Notes           []CustomerNoteResponse       `json:"notes"`
+	Fields          []CustomerFieldValueResponse `json:"fields"`
+	Dependents      []CustomerDependentResponse  `json:"dependents"`
+	VisitCount      int64                        `json:"visitCount"`
+	TotalSpend      string                       `json:"totalSpend"`
+	LastVisit       *time.Time                   `json:"lastVisit"`
+	NextAppointment *EventResponse               `json:"nextAppointment"`
+	RecentVisits    []EventResponse              `json:"recentVisits"`
+}
+
+// @Summary		Get a customer profile
+// @Description	Fetches a customer of the brand together with notes, custom fields, dependents, visit history, total spend and the next appointment
+// @Tags			customers
+// @Produce		json
+// @Param			customerId	path		int	true	"Customer ID"
+// @Success		200			{object}	CustomerDetailResponse
+// @Failure		400			{object}	error
+// @Failure		403			{object}	error
+// @Failure		404			{object}	error
+// @Failure		500			{object}	error
+// @Security		CookieAuth
+// @Router			/customers/{customerId} [get]
+func (app *application) getCustomerDetailHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customer, ok := app.brandCustomerFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	notes, err := app.store.ListCustomerNotes(ctx, customer.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	fields, err := app.store.ListCustomerFieldValues(ctx, customer.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	dependents, err := app.store.ListCustomerDependents(ctx, customer.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	stats, err := app.store.GetCustomerStats(ctx, toNullInt64(customer.ID))
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	visits, err := app.store.ListCustomerVisits(ctx, store.ListCustomerVisitsParams{
+		CustomerID: toNullInt64(customer.ID),
+		Limit:      customerVisitsLimit,
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	response := CustomerDetailResponse{
+		Customer:     customerResponseMapper(customer),
+		Notes:        []CustomerNoteResponse{},
+		Fields:       []CustomerFieldValueResponse{},
+		Dependents:   []CustomerDependentResponse{},
+		VisitCount:   stats.VisitCount,
+		TotalSpend:   stats.TotalSpend,
+		RecentVisits: []EventResponse{},
+	}
+
+	for _, note := range notes {
+		response.Notes = append(response.Notes, customerNoteResponseMapper(note))
+	}
+	for _, field := range fields {
+		response.Fields = append(response.Fields, customerFieldValueResponseMapper(field))
+	}
+	for _, dependent := range dependents {
+		response.Dependents = append(response.Dependents, customerDependentResponseMapper(dependent))
+	}
+	for _, visit := range visits {
+		response.RecentVisits = append(response.RecentVisits, eventResponseMapper(visit))
+	}
+
+	if stats.LastVisit.Valid {
+		response.LastVisit = &stats.LastVisit.Time
+	}
+
+	if stats.NextAppointment.Valid {
+		next, err := app.store.GetNextCustomerEvent(ctx, toNullInt64(customer.ID))
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			app.internalServerError(w, r, err)
+			return
+		}
+		if next != nil {
+			event := eventResponseMapper(next)
+			response.NextAppointment = &event
+		}
+	}
+
+	if err := writeJSON(w, http.StatusOK, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// @Summary		List customer notes
+// @Description	Fetches the internal notes of a customer, pinned notes first
+// @Tags			customers
+// @Produce		json
+// @Param			customerId	path		int	true	"Customer ID"
+// @Success		200			{array}		CustomerNoteResponse
+// @Failure		400			{object}	error
+// @Failure		403			{object}	error
+// @Failure		404			{object}	error
+// @Failure		500			{object}	error
+// @Security		CookieAuth
+// @Router			/customers/{customerId}/notes [get]
+func (app *application) getCustomerNotesHandler(w http.ResponseWriter, r *http.Request) {
+	customer, ok := app.brandCustomerFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	notes, err := app.store.ListCustomerNotes(r.Context(), customer.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	result := []CustomerNoteResponse{}
+	for _, note := range notes {
+		result = append(result, customerNoteResponseMapper(note))
+	}
+
+	if err := writeJSON(w, http.StatusOK, result); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// @Summary		Add a customer note
+// @Description	Adds an internal note to a customer. Notes are only visible to staff.
+// @Tags			customers
+// @Accept			json
+// @Produce		json
+// @Param			customerId	path		int							true	"Customer ID"
+// @Param			payload		body		CreateCustomerNotePayload	true	"Note"
+// @Success		201			{object}	CustomerNoteResponse
+// @Failure		400			{object}	error
+// @Failure		403			{object}	error
+// @Failure		404			{object}	error
+// @Failure		500			{object}	error
+// @Security		CookieAuth
+// @Router			/customers/{customerId}/notes [post]
+func (app *application) createCustomerNoteHandler(w http.ResponseWriter, r *http.Request) {
+	customer, ok := app.brandCustomerFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var payload CreateCustomerNotePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	ctxUser, err := getUserFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	note, err := app.store.CreateCustomerNote(ctx, store.CreateCustomerNoteParams{
+		CustomerID: customer.ID,
+		BrandID:    customer.BrandID,
+		AuthorID: sql.NullInt64{
+			Int64: ctxUser.ID,
+			Valid: true,
+		},
+		AuthorName: ctxUser.Name,
+		Body:       payload.Body,
+		Pinned:     payload.Pinned,
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := writeJSON(w, http.StatusCreated, customerNoteResponseMapper(note)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// @Summary		Delete a customer note
+// @Tags			customers
+// @Param			customerId	path	int	true	"Customer ID"
+// @Param			noteId		path	int	true	"Note ID"
+// @Success		204
+// @Failure		400	{object}	error
+// @Failure		403	{object}	error
+// @Failure		404	{object}	error
+// @Failure		500	{object}	error
+// @Security		CookieAuth
+// @Router			/customers/{customerId}/notes/{noteId} [delete]
+func (app *application) deleteCustomerNoteHandler(w http.ResponseWriter, r *http.Request) {
+	customer, ok := app.brandCustomerFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	noteID, err := strconv.ParseInt(chi.URLParam(r, "noteId"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	deleted, err := app.store.DeleteCustomerNote(r.Context(), store.DeleteCustomerNoteParams{
+		ID:         noteID,
+		CustomerID: customer.ID,
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if deleted == 0 {
+		app.notFoundResponse(w, r, ErrCustomerNoteNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary		Replace customer tags
+// @Description	Replaces the tags of a customer. Tags are trimmed, lowercased and deduplicated.
+// @Tags			customers
+// @Accept			json
+// @Produce		json
+// @Param			customerId	path		int							true	"Customer ID"
+// @Param			payload		body		UpdateCustomerTagsPayload	true	"Tags"
+// @Success		200			{object}	CustomerResponse
+// @Failure		400			{object}	error
+// @Failure		403			{object}	error
+// @Failure		404			{object}	error
+// @Failure		500			{object}	error
+// @Security		CookieAuth
+// @Router			/customers/{customerId}/tags [put]
+func (app *application) updateCustomerTagsHandler(w http.ResponseWriter, r *http.Request) {
+	customer, ok := app.brandCustomerFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var payload UpdateCustomerTagsPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	tags := []string{}
+	for _, tag := range payload.Tags {
+		if tag = normalizeTag(tag); tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+
+	ctx := r.Context()
+	updated, err := app.store.UpdateCustomerTags(ctx, store.UpdateCustomerTagsParams{
+		ID:   customer.ID,
+		Tags: tags,
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if app.config.cache.enabled {
+		app.cache.Customers.Delete(ctx, customer.ID)
+	}
+
+	if err := writeJSON(w, http.StatusOK, customerResponseMapper(updated)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// @Summary		List custom customer fields
+// @Description	Fetches the custom field definitions of the brand
+// @Tags			customers
+// @Produce		json
+// @Success		200	{array}		CustomerFieldResponse
+// @Failure		403	{object}	error
+// @Failure		500	{object}	error
+// @Security		CookieAuth
+// @Router			/customers/fields [get]
+func (app *application) getCustomerFieldsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxUser, err := getUserFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if !ctxUser.BrandID.Valid {
+		app.forbiddenResponse(w, r, ErrAccessDenied)
+		return
+	}
+
+	fields, err := app.store.ListCustomerFields(ctx, ctxUser.BrandID.Int32)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	result := []CustomerFieldResponse{}
+	for _, field := range fields {
+		result = append(result, customerFieldResponseMapper(field))
+	}
+
+	if err := writeJSON(w, http.StatusOK, result); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// @Summary		Create a custom customer field
+// @Description	Defines a custom field stored on every customer of the brand. Select fields require a list of options.
+// @Tags			customers
+// @Accept			json
+// @Produce		json
+// @Param			payload	body		CreateCustomerFieldPayload	true	"Field definition"
+// @Success		201		{object}	CustomerFieldResponse
+// @Failure		400		{object}	error
+// @Failure		403		{object}	error
+// @Failure		409		{object}	error
+// @Failure		500		{object}	error
+// @Security		CookieAuth
+// @Router			/customers/fields [post]
+func (app *application) createCustomerFieldHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxUser, err := getUserFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if ctxUser.Role == userRole || !ctxUser.BrandID.Valid {
+		app.forbiddenResponse(w, r, ErrAccessDenied)
+		return
+	}
+
+	var payload CreateCustomerFieldPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	options := []string{}
+	if payload.FieldType == fieldTypeSelect {
+		options = payload.Options
+	}
+
+	field, err := app.store.CreateCustomerField(ctx, store.CreateCustomerFieldParams{
+		BrandID:   ctxUser.BrandID.Int32,
+		Label:     strings.TrimSpace(payload.Label),
+		FieldType: payload.FieldType,
+		Options:   options,
+	})
+	if err != nil {
+		if isPgError(err, uniqueViolation) {
+			app.conflictRespone(w, r, fmt.Errorf("a field labeled %q already exists", payload.Label))
+			return
+		}
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := writeJSON(w, http.StatusCreated, customerFieldResponseMapper(field)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// @Summary		Delete a custom customer field
+// @Description	Deletes a custom field definition together with the values stored on customers
+// @Tags			customers
+// @Param			fieldId	path	int	true	"Field ID"
+// @Success		204
+// @Failure		400	{object}	error
+// @Failure		403	{object}	error
+// @Failure		404	{object}	error
+// @Failure		500	{object}	error
+// @Security		CookieAuth
+// @Router			/customers/fields/{fieldId} [delete]
+func (app *application) deleteCustomerFieldHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxUser, err := getUserFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if ctxUser.Role == userRole || !ctxUser.BrandID.Valid {
+		app.forbiddenResponse(w, r, ErrAccessDenied)
+		return
+	}
+
+	fieldID, err := strconv.ParseInt(chi.URLParam(r, "fieldId"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	deleted, err := app.store.DeleteCustomerField(ctx, store.DeleteCustomerFieldParams{
+		ID:      fieldID,
+		BrandID: ctxUser.BrandID.Int32,
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if deleted == 0 {
+		app.notFoundResponse(w, r, ErrCustomerFieldNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary		Set custom field values of a customer
+// @Description	Sets the values of custom fields on a customer. Values are validated against the field type and an empty value clears the field.
+// @Tags			customers
+// @Accept			json
+// @Produce		json
+// @Param			customerId	path		int									true	"Customer ID"
+// @Param			payload		body		UpdateCustomerFieldValuesPayload	true	"Field values"
+// @Success		200			{array}		CustomerFieldValueResponse
+// @Failure		400			{object}	error
+// @Failure		403			{object}	error
+// @Failure		404			{object}	error
+// @Failure		500			{object}	error
+// @Security		CookieAuth
+// @Router			/customers/{customerId}/fields [put]
+func (app *application) updateCustomerFieldValuesHandler(w http.ResponseWriter, r *http.Request) {
+	customer, ok := app.brandCustomerFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var payload UpdateCustomerFieldValuesPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	values := make(map[int64]string, len(payload.Fields))
+	for _, input := range payload.Fields {
+		field, err := app.store.GetCustomerField(ctx, input.FieldID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				app.notFoundResponse(w, r, ErrCustomerFieldNotFound)
+				return
+			}
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if field.BrandID != customer.BrandID {
+			app.notFoundResponse(w, r, ErrCustomerFieldNotFound)
+			return
+		}
+
+		value := strings.TrimSpace(input.Value)
+		if err := validateCustomerFieldValue(field, value); err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		values[field.ID] = value
+	}
+
+	fields, err := app.store.SetCustomerFieldValuesTx(ctx, store.SetCustomerFieldValuesTxParams{
+		CustomerID: customer.ID,
+		Values:     values,
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	result := []CustomerFieldValueResponse{}
+	for _, field := range fields {
+		result = append(result, customerFieldValueResponseMapper(field))
+	}
+
+	if err := writeJSON(w, http.StatusOK, result); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// brandCustomerFromRequest loads the customer referenced by the customerId URL
+// parameter and makes sure it belongs to the brand of the authenticated user.
+// It writes the error response itself and reports whether the handler may continue.
+func (app *application) brandCustomerFromRequest(w http.ResponseWriter, r *http.Request) (*store.Customer, bool) {
+	ctx := r.Context()
+	ctxUser, err := getUserFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return nil, false
+	}
+
+	if !ctxUser.BrandID.Valid {
+		app.forbiddenResponse(w, r, ErrAccessDenied)
+		return nil, false
+	}
+
+	customerID, err := strconv.ParseInt(chi.URLParam(r, "customerId"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return nil, false
+	}
+
+	customer, err := app.getCustomer(ctx, customerID)
+	if err != nil {
+		if errors.Is(err, ErrCustomerNotFound) {
+			app.notFoundResponse(w, r, err)
+			return nil, false
+		}
+		app.internalServerError(w, r, err)
+		return nil, false
+	}
+
+	if customer.BrandID != ctxUser.BrandID.Int32 {
+		app.notFoundResponse(w, r, ErrCustomerNotFound)
+		return nil, false
+	}
+
+	return customer, true
+}
+
+func validateCustomerFieldValue(field *store.CustomerField, value string) error {
+	if value == "" {
+		return nil
+	}
+
+	switch field.FieldType {
+	case fieldTypeNumber:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("%s must be a number", field.Label)
+		}
+	case fieldTypeDate:
+		if _, err := time.Parse(dateLayout, value); err != nil {
+			return fmt.Errorf("%s must be a date in the format YYYY-MM-DD", field.Label)
+		}
+	case fieldTypeSelect:
+		if !slices.Contains(field.Options, value) {
+			return fmt.Errorf("%s must be one of %s", field.Label, strings.Join(field.Options, ", "))
+		}
+	}
+
+	return nil
+}
+
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/georgifotev1/bms/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+var ErrDependentNotFound = errors.New("dependent not found")
+
+type CustomerDependentResponse struct {
+	ID         int64     `json:"id"`
+	CustomerID int64     `json:"customerId"`
+	Name       string    `json:"name"`
+	BirthDate  string    `json:"birthDate,omitempty"`
+	Notes      string    `json:"notes"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+type CustomerDependentPayload struct {
+	Name      string `json:"name" validate:"required,min=2,max=100"`
+	BirthDate string `json:"birthDate" validate:"omitempty,datetime=2006-01-02"`
+	Notes     string `json:"notes" validate:"max=5000"`
+}
+
+// @Summary		List customer dependents
+// @Description	Lists the dependent profiles, such as children or elderly relatives, the customer books for
+// @Tags			customers
+// @Produce		json
+// @Param			customerId	path		int	true	"Customer ID"
+// @Success		200			{array}		CustomerDependentResponse
+// @Failure		400			{object}	error
+// @Failure		403			{object}	error
+// @Failure		404			{object}	error
+// @Failure		500			{object}	error
+// @Security		CookieAuth
+// @Router			/customers/{customerId}/dependents [get]
+func (app *application) getCustomerDependentsHandler(w http.ResponseWriter, r *http.Request) {
+	customer, ok := app.brandCustomerFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	app.listDependents(w, r, customer)
+}
+
+// @Summary		Create a customer dependent
+// @Tags			customers
+// @Accept			json
+// @Produce		json
+// @Param			customerId	path		int							true	"Customer ID"
+// @Param			payload		body		CustomerDependentPayload	true	"Dependent"
+// @Success		201			{object}	CustomerDependentResponse
+// @Failure		400			{object}	error
+// @Failure		403			{object}	error
+// @Failure		404			{object}	error
+// @Failure		500			{object}	error
+// @Security		CookieAuth
+// @Router			/customers/{customerId}/dependents [post]
+func (app *application) createCustomerDependentHandler(w http.ResponseWriter, r *http.Request) {
+	customer, ok := app.brandCustomerFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	app.createDependent(w, r, customer)
+}
+
+// @Summary		Update a customer dependent
+// @Tags			customers
+// @Accept			json
+// @Produce		json
+// @Param			customerId	path		int							true	"Customer ID"
+// @Param			dependentId	path		int							true	"Dependent ID"
+// @Param			payload		body		CustomerDependentPayload	true	"Dependent"
+// @Success		200			{object}	CustomerDependentResponse
+// @Failure		400			{object}	error
+// @Failure		403			{object}	error
+// @Failure		404			{object}	error
+// @Failure		500			{object}	error
+// @Security		CookieAuth
+// @Router			/customers/{customerId}/dependents/{dependentId} [put]
+func (app *application) updateCustomerDependentHandler(w http.ResponseWriter, r *http.Request) {
+	customer, ok := app.brandCustomerFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	app.updateDependent(w, r, customer)
+}
+
+// @Summary		Delete a customer dependent
+// @Description	Deletes a dependent profile. Past events keep the name of the dependent
+// @Tags			customers
+// @Param			customerId	path	int	true	"Customer ID"
+// @Param			dependentId	path	int	true	"Dependent ID"
+// @Success		204
+// @Failure		400	{object}	error
+// @Failure		403	{object}	error
+// @Failure		404	{object}	error
+// @Failure		500	{object}	error
+// @Security		CookieAuth
+// @Router			/customers/{customerId}/dependents/{dependentId} [delete]
+func (app *application) deleteCustomerDependentHandler(w http.ResponseWriter, r *http.Request) {
+	customer, ok := app.brandCustomerFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	app.deleteDependent(w, r, customer)
+}
+
+// @Summary		List my dependents
+// @Tags			customers
+// @Produce		json
+// @Param			X-Brand-ID	header		string	false	"Brand ID header for development. In production this header is ignored"	default(1)
+// @Success		200			{array}		CustomerDependentResponse
+// @Failure		401			{object}	error
+// @Failure		500			{object}	error
+// @Router			/customers/me/dependents [get]
+func (app *application) getMyDependentsHandler(w http.ResponseWriter, r *http.Request) {
+	customer, err := getCustomerFromCtx(r.Context())
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	app.listDependents(w, r, customer)
+}
+
+// @Summary		Create my dependent
+// @Tags			customers
+// @Accept			json
+// @Produce		json
+// @Param			payload		body		CustomerDependentPayload	true	"Dependent"
+// @Param			X-Brand-ID	header		string						false	"Brand ID header for development. In production this header is ignored"	default(1)
+// @Success		201			{object}	CustomerDependentResponse
+// @Failure		400			{object}	error
+// @Failure		401			{object}	error
+// @Failure		500			{object}	error
+// @Router			/customers/me/dependents [post]
+func (app *application) createMyDependentHandler(w http.ResponseWriter, r *http.Request) {
+	customer, err := getCustomerFromCtx(r.Context())
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	app.createDependent(w, r, customer)
+}
+
+// @Summary		Update my dependent
+// @Tags			customers
+// @Accept			json
+// @Produce		json
+// @Param			dependentId	path		int							true	"Dependent ID"
+// @Param			payload		body		CustomerDependentPayload	true	"Dependent"
+// @Param			X-Brand-ID	header		string						false	"Brand ID header for development. In production this header is ignored"	default(1)
+// @Success		200			{object}	CustomerDependentResponse
+// @Failure		400			{object}	error
+// @Failure		401			{object}	error
+// @Failure		404			{object}	error
+// @Failure		500			{object}	error
+// @Router			/customers/me/dependents/{dependentId} [put]
+func (app *application) updateMyDependentHandler(w http.ResponseWriter, r *http.Request) {
+	customer, err := getCustomerFromCtx(r.Context())
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	app.updateDependent(w, r, customer)
+}
+
+// @Summary		Delete my dependent
+// @Tags			customers
+// @Param			dependentId	path	int		true	"Dependent ID"
+// @Param			X-Brand-ID	header	string	false	"Brand ID header for development. In production this header is ignored"	default(1)
+// @Success		204
+// @Failure		400	{object}	error
+// @Failure		401	{object}	error
+// @Failure		404	{object}	error
+// @Failure		500	{object}	error
+// @Router			/customers/me/dependents/{dependentId} [delete]
+func (app *application) deleteMyDependentHandler(w http.ResponseWriter, r *http.Request) {
+	customer, err := getCustomerFromCtx(r.Context())
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	app.deleteDependent(w, r, customer)
+}
+
+func (app *application) listDependents(w http.ResponseWriter, r *http.Request, customer *store.Customer) {
+	dependents, err := app.store.ListCustomerDependents(r.Context(), customer.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	response := make([]CustomerDependentResponse, 0, len(dependents))
+	for _, dependent := range dependents {
+		response = append(response, customerDependentResponseMapper(dependent))
+	}
+
+	if err := writeJSON(w, http.StatusOK, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) createDependent(w http.ResponseWriter, r *http.Request, customer *store.Customer) {
+	payload, birthDate, ok := app.readDependentPayload(w, r)
+	if !ok {
+		return
+	}
+
+	dependent, err := app.store.CreateCustomerDependent(r.Context(), store.CreateCustomerDependentParams{
+		CustomerID: customer.ID,
+		BrandID:    customer.BrandID,
+		Name:       payload.Name,
+		BirthDate:  birthDate,
+		Notes:      toNullString(payload.Notes),
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := writeJSON(w, http.StatusCreated, customerDependentResponseMapper(dependent)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) updateDependent(w http.ResponseWriter, r *http.Request, customer *store.Customer) {
+	dependentID, err := strconv.ParseInt(chi.URLParam(r, "dependentId"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	payload, birthDate, ok := app.readDependentPayload(w, r)
+	if !ok {
+		return
+	}
+
+	dependent, err := app.store.UpdateCustomerDependent(r.Context(), store.UpdateCustomerDependentParams{
+		ID:         dependentID,
+		CustomerID: customer.ID,
+		Name:       payload.Name,
+		BirthDate:  birthDate,
+		Notes:      toNullString(payload.Notes),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notFoundResponse(w, r, ErrDependentNotFound)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := writeJSON(w, http.StatusOK, customerDependentResponseMapper(dependent)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) deleteDependent(w http.ResponseWriter, r *http.Request, customer *store.Customer) {
+	dependentID, err := strconv.ParseInt(chi.URLParam(r, "dependentId"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	deleted, err := app.store.DeleteCustomerDependent(r.Context(), store.DeleteCustomerDependentParams{
+		ID:         dependentID,
+		CustomerID: customer.ID,
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if deleted == 0 {
+		app.notFoundResponse(w, r, ErrDependentNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) readDependentPayload(w http.ResponseWriter, r *http.Request) (*CustomerDependentPayload, sql.NullTime, bool) {
+	var payload CustomerDependentPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return nil, sql.NullTime{}, false
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return nil, sql.NullTime{}, false
+	}
+
+	if payload.BirthDate == "" {
+		return &payload, sql.NullTime{}, true
+	}
+
+	birthDate, err := time.Parse(dateLayout, payload.BirthDate)
+	if err != nil || birthDate.After(time.Now()) {
+		app.badRequestResponse(w, r, errors.New("birth date must be a past date in YYYY-MM-DD format"))
+		return nil, sql.NullTime{}, false
+	}
+
+	return &payload, sql.NullTime{Time: birthDate, Valid: true}, true
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/georgifotev1/bms/internal/store"
+)
+
+const defaultNameThreshold float32 = 0.6
+
+type DuplicateCustomerResponse struct {
+	Customer       CustomerResponse `json:"customer"`
+	Duplicate      CustomerResponse `json:"duplicate"`
+	PhoneMatch     bool             `json:"phoneMatch"`
+	EmailMatch     bool             `json:"emailMatch"`
+	NameSimilarity float32          `json:"nameSimilarity"`
+}
+
+type MergeCustomersPayload struct {
+	SurvivorID   int64   `json:"survivorId" validate:"required,gt=0"`
+	DuplicateIDs []int64 `json:"duplicateIds" validate:"required,min=1,dive,gt=0"`
+}
+
+type MergeCustomersResponse struct {
+	Customer    CustomerResponse `json:"customer"`
+	EventsMoved int64            `json:"eventsMoved"`
+}
+
+// @Summary		List possible duplicate customers
+// @Description	Finds pairs of customers of the brand sharing a normalized phone number or email, or with similar names. Pairs are ordered by customer and cursor paginated
+// @Tags			customers
+// @Produce		json
+// @Param			nameThreshold	query		number	false	"Minimum name similarity between 0 and 1"	default(0.6)
+// @Param			limit			query		int		false	"Page size"	default(50)	maximum(100)
+// @Param			cursor			query		string	false	"Cursor of the next page"
+// @Success		200				{object}	PageResponse[DuplicateCustomerResponse]
+// @Failure		400				{object}	error
+// @Failure		403				{object}	error
+// @Failure		500				{object}	error
+// @Security		CookieAuth
+// @Router			/customers/duplicates [get]
+func (app *application) getDuplicateCustomersHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxUser, err := getUserFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if !ctxUser.BrandID.Valid {
+		app.forbiddenResponse(w, r, ErrAccessDenied)
+		return
+	}
+
+	threshold := defaultNameThreshold
+	if value := r.URL.Query().Get("nameThreshold"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 32)
+		if err != nil || parsed <= 0 || parsed > 1 {
+			app.badRequestResponse(w, r, errors.New("nameThreshold must be a number between 0 and 1"))
+			return
+		}
+		threshold = float32(parsed)
+	}
+
+	page, err := parsePageParams(r, []string{"customerId"}, "customerId")
+	if err != nil || page.Descending {
+		if err == nil {
+			err = errors.New("duplicates are listed in ascending order only")
+		}
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	params := store.ListDuplicateCustomersTxParams{
+		ListDuplicateCustomersParams: store.ListDuplicateCustomersParams{
+			BrandID:   ctxUser.BrandID.Int32,
+			HasCursor: page.Cursor != nil,
+			PageLimit: page.fetchLimit(),
+		},
+		NameThreshold: threshold,
+	}
+	if page.Cursor != nil {
+		params.CursorCustomerID, err = strconv.ParseInt(page.Cursor.Key, 10, 64)
+		if err != nil {
+			app.badRequestResponse(w, r, ErrInvalidCursor)
+			return
+		}
+		params.CursorDuplicateID, err = page.cursorInt64()
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+	}
+
+	rows, err := app.store.ListDuplicateCustomersTx(ctx, params)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	result := paginate(page, rows, func(row *store.ListDuplicateCustomersRow) (string, string) {
+		return strconv.FormatInt(row.CustomerID, 10), strconv.FormatInt(row.DuplicateID, 10)
+	}, duplicateCustomerResponseMapper)
+
+	if err := writeJSON(w, http.StatusOK, result); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// @Summary		Merge duplicate customers
+// @Description	Moves the events, notes, dependents, custom fields and consents of the duplicates to the surviving customer and deletes the duplicates. Passwords of the duplicates are not carried over. Every merge is recorded for auditing.
+// @Tags			customers
+// @Accept			json
+// @Produce		json
+// @Param			payload	body		MergeCustomersPayload	true	"Customers to merge"
+// @Success		200		{object}	MergeCustomersResponse
+// @Failure		400		{object}	error
+// @Failure		403		{object}	error
+// @Failure		404		{object}	error
+// @Failure		500		{object}	error
+// @Security		CookieAuth
+// @Router			/customers/merge [post]
+func (app *application) mergeCustomersHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxUser, err := getUserFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if ctxUser.Role == userRole || !ctxUser.BrandID.Valid {
+		app.forbiddenResponse(w, r, ErrAccessDenied)
+		return
+	}
+
+	var payload MergeCustomersPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	result, err := app.store.MergeCustomersTx(ctx, store.MergeCustomersTxParams{
+		BrandID:      ctxUser.BrandID.Int32,
+		SurvivorID:   payload.SurvivorID,
+		DuplicateIDs: payload.DuplicateIDs,
+		MergedBy:     ctxUser.ID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrMergeCustomerNotFound):
+			app.notFoundResponse(w, r, err)
+		case errors.Is(err, store.ErrMergeSelf), errors.Is(err, store.ErrMergeAcrossBrands):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if app.config.cache.enabled {
+		app.cache.Customers.Delete(ctx, payload.SurvivorID)
+		for _, id := range payload.DuplicateIDs {
+			app.cache.Customers.Delete(ctx, id)
+		}
+	}
+
+	response := MergeCustomersResponse{
+		Customer:    customerResponseMapper(result.Customer),
+		EventsMoved: result.EventsMoved,
+	}
+
+	if err := writeJSON(w, http.StatusOK, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/georgifotev1/bms/internal/mailer"
+	"github.com/georgifotev1/bms/internal/store"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// maxSignInAttempts is how many wrong codes a sign in request takes before
+// it stops working
+const maxSignInAttempts = 5
+
+const signInCodeRequestedMessage = "If an account exists for the email, a sign in code has been sent"
+
+var ErrIncorrectPassword = errors.New("current password is incorrect")
+
+type RequestSignInCodePayload struct {
+	Email   string `json:"email" validate:"required,email,max=255"`
+	Channel string `json:"channel" validate:"omitempty,oneof=email"`
+}
+
+type SignInWithCodePayload struct {
+	Email      string `json:"email" validate:"required,email,max=255"`
+	Code       string `json:"code" validate:"required,len=6,numeric"`
+	RememberMe bool   `json:"rememberMe"`
+}
+
+type SignInWithLinkPayload struct {
+	Token      string `json:"token" validate:"required"`
+	RememberMe bool   `json:"rememberMe"`
+}
+
+type SetPasswordPayload struct {
+	CurrentPassword string `json:"currentPassword" validate:"omitempty,max=72"`
+	Password        string `json:"password" validate:"required,min=3,max=72"`
+}
+
+// @Summary		Request a passwordless sign in
+// @Description	Sends a magic link and a 6 digit code to the customer. Guests without a password can sign in this way too. The response is the same whether or not the customer exists
+// @Tags			customers
+// @Accept			json
+// @Produce		json
+// @Param			payload		body		RequestSignInCodePayload	true	"Customer email"
+// @Param			X-Brand-ID	header		string						false	"Brand ID header for development. In production this header is ignored"	default(1)
+// @Success		202			{string}	string						"Code sent if the customer exists"
+// @Failure		400			{object}	error
+// @Failure		429			{object}	error
+// @Failure		500			{object}	error
+// @Router			/customers/auth/passwordless [post]
+func (app *application) requestCustomerSignInCodeHandler(w http.ResponseWriter, r *http.Request) {
+	var payload RequestSignInCodePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if payload.Channel == "" {
+		payload.Channel = mailer.ChannelEmail
+	}
+
+	ctx := r.Context()
+	ctxBrandID, err := getBrandIDFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	customer, err := app.store.GetCustomerByEmail(ctx, store.GetCustomerByEmailParams{
+		BrandID: ctxBrandID,
+		Email:   toNullString(normalizeEmail(payload.Email)),
+	})
+	if err != nil && err != sql.ErrNoRows {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err == nil && app.allowPasswordResetEmail(fmt.Sprintf("%d:%s", customer.BrandID, customer.Email.String)) {
+		if err := app.sendCustomerSignInCode(ctx, customer, payload.Channel); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	response := map[string]string{
+		"message": signInCodeRequestedMessage,
+	}
+
+	if err := writeJSON(w, http.StatusAccepted, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// @Summary		Sign in with a code
+// @Description	Signs in a customer with the 6 digit code from the sign in email. A code stops working after it is used, after it expires or after 5 wrong attempts
+// @Tags			customers
+// @Accept			json
+// @Produce		json
+// @Param			payload		body		SignInWithCodePayload	true	"Customer email and code"
+// @Param			X-Brand-ID	header		string					false	"Brand ID header for development. In production this header is ignored"	default(1)
+// @Success		200			{object}	CustomerResponse
+// @Failure		400			{object}	error
+// @Failure		401			{object}	error
+// @Failure		500			{object}	error
+// @Router			/customers/auth/passwordless/code [post]
+func (app *application) signInCustomerWithCodeHandler(w http.ResponseWriter, r *http.Request) {
+	var payload SignInWithCodePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	ctxBrandID, err := getBrandIDFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	customer, err := app.store.GetCustomerByEmail(ctx, store.GetCustomerByEmailParams{
+		BrandID: ctxBrandID,
+		Email:   toNullString(normalizeEmail(payload.Email)),
+	})
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			app.unauthorizedErrorResponse(w, r, store.ErrInvalidSignInCode)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.revokeCustomerSessionFromCookie(r)
+
+	session, err := app.store.SignInCustomerWithCodeTx(ctx, store.SignInCustomerWithCodeTxParams{
+		CustomerID:  customer.ID,
+		Code:        hashSignInCode(customer.ID, payload.Code),
+		MaxAttempts: maxSignInAttempts,
+		Session:     app.customerSessionParams(r, customer.ID, payload.RememberMe),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidSignInCode):
+			app.unauthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.completeCustomerSignIn(w, r, session)
+}
+
+// @Summary		Sign in with a magic link
+// @Description	Signs in a customer with the token of the magic link from the sign in email. The link works once
+// @Tags			customers
+// @Accept			json
+// @Produce		json
+// @Param			payload		body		SignInWithLinkPayload	true	"Magic link token"
+// @Param			X-Brand-ID	header		string					false	"Brand ID header for development. In production this header is ignored"	default(1)
+// @Success		200			{object}	CustomerResponse
+// @Failure		400			{object}	error
+// @Failure		401			{object}	error
+// @Failure		500			{object}	error
+// @Router			/customers/auth/passwordless/link [post]
+func (app *application) signInCustomerWithLinkHandler(w http.ResponseWriter, r *http.Request) {
+	var payload SignInWithLinkPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	app.revokeCustomerSessionFromCookie(r)
+
+	session, err := app.store.SignInCustomerWithLinkTx(r.Context(), store.SignInCustomerWithLinkTxParams{
+		Token:       hashToken(payload.Token),
+		MaxAttempts: maxSignInAttempts,
+		Session:     app.customerSessionParams(r, 0, payload.RememberMe),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidSignInCode):
+			app.unauthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.completeCustomerSignIn(w, r, session)
+}
+
+// @Summary		Set my password
+// @Description	Changes the password of the signed in customer. Guests who signed in without a password set their first one this way and don't send the current password
+// @Tags			customers
+// @Accept			json
+// @Produce		json
+// @Param			payload		body		SetPasswordPayload	true	"Passwords"
+// @Param			X-Brand-ID	header		string				false	"Brand ID header for development. In production this header is ignored"	default(1)
+// @Success		200			{object}	CustomerResponse
+// @Failure		400			{object}	error
+// @Failure		401			{object}	error
+// @Failure		500			{object}	error
+// @Router			/customers/me/password [put]
+func (app *application) setMyPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customer, err := getCustomerFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	var payload SetPasswordPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if len(customer.Password) > 0 {
+		if err := bcrypt.CompareHashAndPassword(customer.Password, []byte(payload.CurrentPassword)); err != nil {
+			app.badRequestResponse(w, r, ErrIncorrectPassword)
+			return
+		}
+	}
+
+	hashedPass, err := bcrypt.GenerateFromPassword([]byte(payload.Password), bcrypt.DefaultCost)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.store.UpdateCustomerPassword(ctx, store.UpdateCustomerPasswordParams{
+		ID:       customer.ID,
+		Password: hashedPass,
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if app.config.cache.enabled {
+		app.cache.Customers.Delete(ctx, customer.ID)
+	}
+
+	if err := app.rotateCustomerSession(w, r); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	customer.Password = hashedPass
+
+	if err := writeJSON(w, http.StatusOK, customerResponseMapper(customer)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) completeCustomerSignIn(w http.ResponseWriter, r *http.Request, session *store.CustomerSession) {
+	ctx := r.Context()
+
+	// Signing in verifies the customer so the cached copy is stale
+	if app.config.cache.enabled {
+		app.cache.Customers.Delete(ctx, session.CustomerID)
+	}
+
+	customer, err := app.getCustomer(ctx, session.CustomerID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.setCustomerSessionCookie(w, session)
+
+	if err := writeJSON(w, http.StatusOK, customerResponseMapper(customer)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) sendCustomerSignInCode(ctx context.Context, customer *store.Customer, channel string) error {
+	brand, err := app.store.GetBrandById(ctx, customer.BrandID)
+	if err != nil {
+		return err
+	}
+
+	code, err := generateSignInCode()
+	if err != nil {
+		return err
+	}
+
+	plainToken := uuid.New().String()
+
+	_, err = app.store.CreateCustomerSignInCodeTx(ctx, store.CreateCustomerSignInCodeTxParams{
+		CustomerID: customer.ID,
+		BrandID:    customer.BrandID,
+		Token:      hashToken(plainToken),
+		Code:       hashSignInCode(customer.ID, code),
+		Channel:    channel,
+		Expiry:     time.Now().Add(app.config.mail.signInExp),
+	})
+	if err != nil {
+		return err
+	}
+
+	vars := struct {
+		Username  string
+		BrandName string
+		Code      string
+		SignInUrl string
+		ExpiresIn string
+	}{
+		Username:  customer.Name,
+		BrandName: brand.Name,
+		Code:      code,
+		SignInUrl: fmt.Sprintf("%s/sign-in/%s", app.brandClientURL(brand), plainToken),
+		ExpiresIn: expiryText(app.config.mail.signInExp),
+	}
+
+	mailCtx := store.WithBrandID(context.Background(), customer.BrandID)
+	app.sendAccountEmail(mailCtx, mailer.SignInCodeTemplate, customer.Name, customer.Email.String, vars)
+	return nil
+}
+
+func generateSignInCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// hashSignInCode salts the code with the customer so equal codes of different
+// customers don't share a hash
+func hashSignInCode(customerID int64, code string) string {
+	return hashToken(fmt.Sprintf("%d:%s", customerID, code))
+}
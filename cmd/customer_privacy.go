@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/georgifotev1/bms/internal/store"
+	"github.com/google/uuid"
+)
+
+// CustomerDataExport is everything held about a customer
+type CustomerDataExport struct {
+	ExportedAt time.Time                    `json:"exportedAt"`
+	Profile    CustomerResponse             `json:"profile"`
+	Fields     []CustomerFieldValueResponse `json:"fields"`
+	Dependents []CustomerDependentResponse  `json:"dependents"`
+	Events     []EventResponse              `json:"events"`
+	Sessions   []SessionResponse            `json:"sessions"`
+	Notes      []CustomerNoteResponse       `json:"notes"`
+	Consents   []ConsentResponse            `json:"consents"`
+}
+
+type CustomerErasureResponse struct {
+	CustomerID       int64     `json:"customerId"`
+	EventsAnonymized int32     `json:"eventsAnonymized"`
+	ErasedAt         time.Time `json:"erasedAt"`
+}
+
+// @Summary		Export customer data
+// @Description	Exports the profile, custom fields, dependents, events, sessions, notes and consents of a customer as a JSON archive
+// @Tags			customers
+// @Produce		json
+// @Param			customerId	path		int	true	"Customer ID"
+// @Success		200			{object}	CustomerDataExport
+// @Failure		400			{object}	error
+// @Failure		403			{object}	error
+// @Failure		404			{object}	error
+// @Failure		500			{object}	error
+// @Security		CookieAuth
+// @Router			/customers/{customerId}/data-export [get]
+func (app *application) exportCustomerDataHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxUser, err := getUserFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if ctxUser.Role == userRole {
+		app.forbiddenResponse(w, r, ErrAccessDenied)
+		return
+	}
+
+	customer, ok := app.brandCustomerFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	app.writeCustomerDataExport(w, r, customer)
+}
+
+// @Summary		Erase a customer
+// @Description	Deletes a customer with their sessions, notes, dependents and custom fields. Their events are kept without the customer and dependent names, comment and link to the customer. API keys need the customers:delete scope
+// @Tags			customers
+// @Produce		json
+// @Param			customerId	path		int	true	"Customer ID"
+// @Success		200			{object}	CustomerErasureResponse
+// @Failure		400			{object}	error
+// @Failure		403			{object}	error
+// @Failure		404			{object}	error
+// @Failure		500			{object}	error
+// @Security		CookieAuth
+// @Router			/customers/{customerId} [delete]
+func (app *application) eraseCustomerHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxUser, err := getUserFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if ctxUser.Role == userRole {
+		app.forbiddenResponse(w, r, ErrAccessDenied)
+		return
+	}
+
+	customer, ok := app.brandCustomerFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	erasure, err := app.eraseCustomer(ctx, customer, toNullInt64(ctxUser.ID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			app.notFoundResponse(w, r, ErrCustomerNotFound)
+			return
+		}
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := writeJSON(w, http.StatusOK, customerErasureResponseMapper(erasure)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// @Summary		Export my data
+// @Description	Exports the profile, custom fields, dependents, events, sessions, notes and consents of the signed in customer as a JSON archive
+// @Tags			customers
+// @Produce		json
+// @Param			X-Brand-ID	header		string	false	"Brand ID header for development. In production this header is ignored"	default(1)
+// @Success		200			{object}	CustomerDataExport
+// @Failure		401			{object}	error
+// @Failure		500			{object}	error
+// @Router			/customers/me/data-export [get]
+func (app *application) exportMyCustomerDataHandler(w http.ResponseWriter, r *http.Request) {
+	customer, err := getCustomerFromCtx(r.Context())
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	app.writeCustomerDataExport(w, r, customer)
+}
+
+// @Summary		Delete my account
+// @Description	Erases the signed in customer. Past events are kept without the customer name and comment
+// @Tags			customers
+// @Produce		json
+// @Param			X-Brand-ID	header		string	false	"Brand ID header for development. In production this header is ignored"	default(1)
+// @Success		200			{object}	CustomerErasureResponse
+// @Failure		401			{object}	error
+// @Failure		500			{object}	error
+// @Router			/customers/me [delete]
+func (app *application) eraseMyCustomerAccountHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customer, err := getCustomerFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	erasure, err := app.eraseCustomer(ctx, customer, sql.NullInt64{})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.ClearCookie(w, CUSTOMER_SESSION_TOKEN)
+
+	if err := writeJSON(w, http.StatusOK, customerErasureResponseMapper(erasure)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) eraseCustomer(ctx context.Context, customer *store.Customer, erasedBy sql.NullInt64) (*store.CustomerErasure, error) {
+	erasure, err := app.store.EraseCustomerTx(ctx, store.EraseCustomerTxParams{
+		CustomerID: customer.ID,
+		ErasedBy:   erasedBy,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if app.config.cache.enabled {
+		app.cache.Customers.Delete(ctx, customer.ID)
+	}
+
+	app.logger.Infow("customer erased", "customer", customer.ID, "brand", customer.BrandID, "events", erasure.EventsAnonymized)
+	return erasure, nil
+}
+
+func (app *application) writeCustomerDataExport(w http.ResponseWriter, r *http.Request, customer *store.Customer) {
+	export, err := app.customerDataExport(r.Context(), customer)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	fileName := fmt.Sprintf("customer-%d-%s.json", customer.ID, export.ExportedAt.Format(dateLayout))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+
+	if err := writeJSON(w, http.StatusOK, export); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) customerDataExport(ctx context.Context, customer *store.Customer) (*CustomerDataExport, error) {
+	fields, err := app.store.ListCustomerFieldValues(ctx, customer.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := app.store.ListCustomerEvents(ctx, toNullInt64(customer.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	notes, err := app.store.ListCustomerNotes(ctx, customer.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	consents, err := app.store.ListCustomerConsents(ctx, customer.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	dependents, err := app.store.ListCustomerDependents(ctx, customer.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := app.store.ListCustomerSessions(ctx, customer.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &CustomerDataExport{
+		ExportedAt: time.Now().UTC(),
+		Profile:    customerResponseMapper(customer),
+		Fields:     []CustomerFieldValueResponse{},
+		Dependents: []CustomerDependentResponse{},
+		Events:     []EventResponse{},
+		Sessions:   []SessionResponse{},
+		Notes:      []CustomerNoteResponse{},
+		Consents:   consentResponseMapper(consents),
+	}
+
+	for _, field := range fields {
+		export.Fields = append(export.Fields, customerFieldValueResponseMapper(field))
+	}
+	for _, dependent := range dependents {
+		export.Dependents = append(export.Dependents, customerDependentResponseMapper(dependent))
+	}
+	for _, session := range sessions {
+		export.Sessions = append(export.Sessions, customerSessionResponseMapper(session, uuid.Nil))
+	}
+	for _, event := range events {
+		export.Events = append(export.Events, eventResponseMapper(event))
+	}
+	for _, note := range notes {
+		export.Notes = append(export.Notes, customerNoteResponseMapper(note))
+	}
+
+	return export, nil
+}
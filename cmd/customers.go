@@ -4,7 +4,10 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/georgifotev1/bms/internal/store"
@@ -15,30 +18,36 @@ import (
 type customerKey string
 
 const (
-	customerIdCtx customerKey = "customer"
+	customerIdCtx      customerKey = "customer"
+	customerSessionCtx customerKey = "customerSession"
 )
 
 type CustomerResponse struct {
-	ID          int64     `json:"id"`
-	Name        string    `json:"name"`
-	Email       string    `json:"email"`
-	BrandId     int32     `json:"brandId"`
-	PhoneNumber string    `json:"phoneNumber"`
-	CreatedAt   time.Time `json:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt"`
+	ID          int64      `json:"id"`
+	Name        string     `json:"name"`
+	Email       string     `json:"email"`
+	BrandId     int32      `json:"brandId"`
+	PhoneNumber string     `json:"phoneNumber"`
+	Tags        []string   `json:"tags"`
+	Verified    bool       `json:"verified"`
+	LastVisit   *time.Time `json:"lastVisit,omitempty"`
+	NoShowCount int64      `json:"noShowCount,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
 }
 
 type SignUpCustomerPayload struct {
-	Email       string `json:"email" validate:"required,email"`
-	Password    string `json:"password" validate:"required,min=3,max=72"`
-	Name        string `json:"name" validate:"required,min=2,max=100"`
-	PhoneNumber string `json:"phoneNumber" validate:"required"`
+	Email       string           `json:"email" validate:"required,email"`
+	Password    string           `json:"password" validate:"required,min=3,max=72"`
+	Name        string           `json:"name" validate:"required,min=2,max=100"`
+	PhoneNumber string           `json:"phoneNumber" validate:"required"`
+	Consents    []ConsentPayload `json:"consents" validate:"max=6,dive"`
 }
 
 // registerCustomerHandler godoc
 //
 //	@Summary		Registers a customer
-//	@Description	Registers a customer
+//	@Description	Registers a customer and sends a link to verify the email address
 //	@Tags			customers
 //	@Accept			json
 //	@Produce		json
@@ -70,14 +79,16 @@ func (app *application) signUpCustomerHandler(w http.ResponseWriter, r *http.Req
 	ctxBrandID, err := getBrandIDFromCtx(ctx)
 	if err != nil {
 		app.badRequestResponse(w, r, err)
+		return
 	}
 
 	customer, err := app.store.CreateCustomer(ctx, store.CreateCustomerParams{
 		Name:        payload.Name,
-		Email:       toNullString(payload.Email),
+		Email:       toNullString(normalizeEmail(payload.Email)),
 		Password:    hashedPass,
 		BrandID:     ctxBrandID,
 		PhoneNumber: payload.PhoneNumber,
+		PhoneE164:   toNullString(normalizePhoneNumber(payload.PhoneNumber)),
 	})
 	if err != nil {
 		switch {
@@ -89,16 +100,29 @@ func (app *application) signUpCustomerHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	session, err := app.store.CreateCustomerSession(ctx, store.CreateCustomerSessionParams{
-		CustomerID: customer.ID,
-		ExpiresAt:  time.Now().UTC().Add(app.config.auth.session.exp),
-	})
+	if len(payload.Consents) > 0 {
+		if _, err := app.store.UpdateCustomerConsentsTx(ctx, store.UpdateCustomerConsentsTxParams{
+			CustomerID: customer.ID,
+			BrandID:    customer.BrandID,
+			Source:     consentSourceSignup,
+			Changes:    consentChanges(payload.Consents),
+		}); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	session, err := app.store.CreateCustomerSession(ctx, app.customerSessionParams(r, customer.ID, false))
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	app.SetCookie(w, CUSTOMER_SESSION_TOKEN, session.ID.String())
+	if err := app.sendCustomerVerification(ctx, customer); err != nil {
+		app.logger.Errorw("error sending verification email", "customer", customer.ID, "error", err)
+	}
+
+	app.setCustomerSessionCookie(w, session)
 
 	customerResponse := customerResponseMapper(customer)
 	if err := writeJSON(w, http.StatusCreated, customerResponse); err != nil {
@@ -107,8 +131,9 @@ func (app *application) signUpCustomerHandler(w http.ResponseWriter, r *http.Req
 }
 
 type SignInCustomerPayload struct {
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=3,max=72"`
+	Email      string `json:"email" validate:"required,email"`
+	Password   string `json:"password" validate:"required,min=3,max=72"`
+	RememberMe bool   `json:"rememberMe"`
 }
 
 // loginCustomerHandler godoc
@@ -122,6 +147,7 @@ type SignInCustomerPayload struct {
 //	@Param			X-Brand-ID	header		string					false	"Brand ID header for development. In production this header is ignored"	default(1)
 //	@Success		201			{object}	CustomerResponse		"customer logged in"
 //	@Failure		400			{object}	error
+//	@Failure		429			{object}	error	"Too many failed attempts"
 //	@Failure		500			{object}	error
 //	@Router			/customers/auth/signin [post]
 func (app *application) signInCustomerHandler(w http.ResponseWriter, r *http.Request) {
@@ -137,11 +163,25 @@ func (app *application) signInCustomerHandler(w http.ResponseWriter, r *http.Req
 	}
 
 	ctx := r.Context()
+	ctxBrandID, err := getBrandIDFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	attempt := newCustomerSignInAttempt(r, ctxBrandID, payload.Email)
+	if app.signInBlocked(w, r, attempt) {
+		return
+	}
 
-	customer, err := app.store.GetCustomerByEmail(ctx, toNullString(payload.Email))
+	customer, err := app.store.GetCustomerByEmail(ctx, store.GetCustomerByEmailParams{
+		BrandID: ctxBrandID,
+		Email:   toNullString(normalizeEmail(payload.Email)),
+	})
 	if err != nil {
 		switch err {
 		case sql.ErrNoRows:
+			app.signInFailed(ctx, attempt, signInReasonUnknownAccount)
 			app.unauthorizedErrorResponse(w, r, err)
 		default:
 			app.internalServerError(w, r, err)
@@ -149,22 +189,27 @@ func (app *application) signInCustomerHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	attempt.AccountID = toNullInt64(customer.ID)
+
 	err = bcrypt.CompareHashAndPassword(customer.Password, []byte(payload.Password))
 	if err != nil {
+		if app.signInFailed(ctx, attempt, signInReasonWrongPassword) {
+			app.sendCustomerLockedEmail(ctx, customer, attempt.IpAddress)
+		}
 		app.unauthorizedErrorResponse(w, r, err)
 		return
 	}
 
-	session, err := app.store.UpsertCustomerSession(ctx, store.UpsertCustomerSessionParams{
-		CustomerID: customer.ID,
-		ExpiresAt:  time.Now().UTC().Add(app.config.auth.session.exp),
-	})
+	app.revokeCustomerSessionFromCookie(r)
+
+	session, err := app.store.CreateCustomerSession(ctx, app.customerSessionParams(r, customer.ID, payload.RememberMe))
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	app.SetCookie(w, CUSTOMER_SESSION_TOKEN, session.ID.String())
+	app.signInSucceeded(ctx, attempt)
+	app.setCustomerSessionCookie(w, session)
 
 	customerResponse := customerResponseMapper(customer)
 	if err := writeJSON(w, http.StatusOK, customerResponse); err != nil {
@@ -175,7 +220,7 @@ func (app *application) signInCustomerHandler(w http.ResponseWriter, r *http.Req
 // logoutCustomerHandler godoc
 //
 //	@Summary		Logs out a customer
-//	@Description	Clears the refresh token cookie to log out the customer
+//	@Description	Revokes the current session and clears the session cookie to log out the customer
 //	@Tags			customers
 //	@Produce		json
 //	@Success		200	{string}	string	"Logged out successfully"
@@ -227,12 +272,13 @@ type CreateGuestCustomerPayload struct {
 //	@Tags			customers
 //	@Accept			json
 //	@Produce		json
-//	@Param			payload		body		CreateGuestCustomerPayload	true	"guest credentials"
-//	@Param			X-Brand-ID	header		string						false	"Brand ID header for development. In production this header is ignored"	default(1)
-//	@Success		201			{object}	CustomerResponse			"guest created"
-//	@Success		200			{object}	CustomerResponse			"guest already exists"
-//	@Failure		400			{object}	error
-//	@Failure		500			{object}	error
+//	@Param			payload			body		CreateGuestCustomerPayload	true	"guest credentials"
+//	@Param			X-Brand-ID		header		string						false	"Brand ID header for development. In production this header is ignored"	default(1)
+//	@Param			Idempotency-Key	header		string						false	"Unique key of the request, retries of the same request with the same key replay the first response"
+//	@Success		201				{object}	CustomerResponse			"guest created"
+//	@Success		200				{object}	CustomerResponse			"guest already exists"
+//	@Failure		400				{object}	error
+//	@Failure		500				{object}	error
 //	@Router			/customers/guest [post]
 func (app *application) createGuestCustomerHandler(w http.ResponseWriter, r *http.Request) {
 	var payload CreateGuestCustomerPayload
@@ -251,12 +297,14 @@ func (app *application) createGuestCustomerHandler(w http.ResponseWriter, r *htt
 	ctxBrandID, err := getBrandIDFromCtx(ctx)
 	if err != nil {
 		app.badRequestResponse(w, r, err)
+		return
 	}
 
 	customer, exist, err := app.store.CreateGuestTx(ctx, store.CreateGuestTxParams{
 		Name:        payload.Name,
-		Email:       payload.Email,
+		Email:       normalizeEmail(payload.Email),
 		PhoneNumber: payload.PhoneNumber,
+		PhoneE164:   normalizePhoneNumber(payload.PhoneNumber),
 		BrandId:     ctxBrandID,
 	})
 	if err != nil {
@@ -276,14 +324,24 @@ func (app *application) createGuestCustomerHandler(w http.ResponseWriter, r *htt
 }
 
 // @Summary		Get customers by brand
-// @Description	Fetches all customers of a brand
+// @Description	Searches the customers of a brand by name, email or phone and filters them by tags, custom field values, note text, last visit and no-show count. Results are cursor paginated.
 // @Tags			customers
 // @Accept			json
 // @Produce		json
-// @Success		200	{object}	[]CustomerResponse
-// @Failure		400	{object}	error
-// @Failure		404	{object}	error
-// @Failure		500	{object}	error
+// @Param			search			query		string		false	"Name, email or phone prefix. Names also match by similarity"
+// @Param			tag				query		[]string	false	"Only customers having all of the tags"	collectionFormat(multi)
+// @Param			field			query		[]string	false	"Custom field filter in the form <fieldId>:<value>"	collectionFormat(multi)
+// @Param			note			query		string		false	"Only customers with a note containing the text"
+// @Param			lastVisitBefore	query		string		false	"Last visit before the date in YYYY-MM-DD format"
+// @Param			lastVisitAfter	query		string		false	"Last visit on or after the date in YYYY-MM-DD format"
+// @Param			minNoShows		query		int			false	"Minimum number of no-shows"
+// @Param			sort			query		string		false	"name, createdAt or lastVisit, prefixed with - for descending order"	default(name)
+// @Param			limit			query		int			false	"Page size"	default(50)	maximum(100)
+// @Param			cursor			query		string		false	"Cursor of the next page"
+// @Success		200				{object}	PageResponse[CustomerResponse]
+// @Failure		400				{object}	error
+// @Failure		404				{object}	error
+// @Failure		500				{object}	error
 // @Security		CookieAuth
 // @Router			/customers [get]
 func (app *application) getCustomersHandler(w http.ResponseWriter, r *http.Request) {
@@ -294,17 +352,90 @@ func (app *application) getCustomersHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	customers, err := app.store.GetCustomersByBrand(ctx, ctxUser.BrandID.Int32)
+	page, err := parsePageParams(r, []string{"name", "createdAt", "lastVisit"}, "name")
 	if err != nil {
-		app.internalServerError(w, r, err)
+		app.badRequestResponse(w, r, err)
 		return
 	}
 
-	var result []CustomerResponse
-	for _, v := range customers {
-		result = append(result, customersResponseMapper(v))
+	cursorID, err := page.cursorInt64()
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	query := r.URL.Query()
+	search := strings.TrimSpace(query.Get("search"))
+	params := store.ListCustomersParams{
+		BrandID:     ctxUser.BrandID.Int32,
+		Sort:        page.Sort,
+		Search:      search,
+		Phone:       normalizePhoneNumber(search),
+		Tags:        []string{},
+		FieldIds:    []int64{},
+		FieldValues: []string{},
+		Note:        strings.TrimSpace(query.Get("note")),
+		HasCursor:   page.Cursor != nil,
+		Descending:  page.Descending,
+		CursorKey:   page.cursorKey(),
+		CursorID:    cursorID,
+		PageLimit:   page.fetchLimit(),
+	}
+
+	for _, tag := range query["tag"] {
+		if tag = normalizeTag(tag); tag != "" {
+			params.Tags = append(params.Tags, tag)
+		}
+	}
+
+	for _, field := range query["field"] {
+		id, value, found := strings.Cut(field, ":")
+		fieldID, err := strconv.ParseInt(id, 10, 64)
+		if !found || err != nil {
+			app.badRequestResponse(w, r, fmt.Errorf("invalid field filter %q, expected <fieldId>:<value>", field))
+			return
+		}
+		params.FieldIds = append(params.FieldIds, fieldID)
+		params.FieldValues = append(params.FieldValues, value)
+	}
+
+	if value := query.Get("lastVisitBefore"); value != "" {
+		date, err := time.Parse(dateLayout, value)
+		if err != nil {
+			app.badRequestResponse(w, r, errors.New("Invalid lastVisitBefore format. Must be YYYY-MM-DD"))
+			return
+		}
+		params.LastVisitBefore = sql.NullTime{Time: date, Valid: true}
+	}
+
+	if value := query.Get("lastVisitAfter"); value != "" {
+		date, err := time.Parse(dateLayout, value)
+		if err != nil {
+			app.badRequestResponse(w, r, errors.New("Invalid lastVisitAfter format. Must be YYYY-MM-DD"))
+			return
+		}
+		params.LastVisitAfter = sql.NullTime{Time: date, Valid: true}
+	}
+
+	if value := query.Get("minNoShows"); value != "" {
+		minNoShows, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || minNoShows < 0 {
+			app.badRequestResponse(w, r, errors.New("minNoShows must be a positive number"))
+			return
+		}
+		params.MinNoShows = minNoShows
 	}
 
+	customers, err := app.store.ListCustomers(ctx, params)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	result := paginate(page, customers, func(row *store.ListCustomersRow) (string, string) {
+		return row.SortKey, strconv.FormatInt(row.ID, 10)
+	}, customerListResponseMapper)
+
 	if err = writeJSON(w, http.StatusOK, result); err != nil {
 		app.internalServerError(w, r, err)
 	}
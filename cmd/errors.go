@@ -12,6 +12,8 @@ import (
 const (
 	uniqueViolation     = "23505"
 	foreignKeyViolation = "23503"
+	// raised when a row violates a row level security policy
+	insufficientPrivilege = "42501"
 )
 
 var (
@@ -20,6 +22,7 @@ var (
 	ErrUserNotFound         = errors.New("user not found")
 	ErrCustomerNotFound     = errors.New("customer not found")
 	ErrServiceNotFound      = errors.New("service not found")
+	ErrEventVersionConflict = errors.New("the event was changed since it was read, reload it and try again")
 )
 
 func (app *application) internalServerError(w http.ResponseWriter, r *http.Request, err error) {
@@ -57,6 +60,12 @@ func (app *application) conflictRespone(w http.ResponseWriter, r *http.Request,
 	writeJSONError(w, http.StatusConflict, err.Error())
 }
 
+func (app *application) preconditionFailedResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Warnf("precondition failed", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+
+	writeJSONError(w, http.StatusPreconditionFailed, err.Error())
+}
+
 func (app *application) unauthorizedBasicErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
 	app.logger.Warnf("unauthorized basic error", "method", r.Method, "path", r.URL.Path, "error", err.Error())
 
@@ -86,6 +95,8 @@ func (app *application) hadleEventValidationError(w http.ResponseWriter, r *http
 		app.badRequestResponse(w, r, err)
 	case errors.Is(err, ErrCustomerNotFound):
 		app.badRequestResponse(w, r, err)
+	case errors.Is(err, ErrDependentNotFound):
+		app.badRequestResponse(w, r, err)
 	case errors.Is(err, ErrServiceNotFound):
 		app.badRequestResponse(w, r, err)
 	default:
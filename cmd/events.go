@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -29,40 +30,61 @@ type CreateEventPayload struct {
 	StartTime  time.Time `json:"startTime" validate:"required,gt=now"`
 	EndTime    time.Time `json:"endTime" validate:"required,gtfield=StartTime"`
 	Comment    string    `json:"comment"`
+	// DependentID books the event for a dependent of the customer
+	DependentID int64 `json:"dependentId" validate:"omitempty,min=1"`
+	// Version is the version of the event the update is based on. The update
+	// is rejected when the event was changed since. Ignored on create.
+	Version int32 `json:"version" validate:"omitempty,min=1"`
 }
 
 type EventResponse struct {
-	ID           int64     `json:"id"`
-	CustomerID   int64     `json:"customerId"`
-	ServiceID    uuid.UUID `json:"serviceId"`
-	UserID       int64     `json:"userId"`
-	BrandID      int32     `json:"brandId"`
-	StartTime    time.Time `json:"startTime"`
-	EndTime      time.Time `json:"endTime"`
-	CustomerName string    `json:"customerName"`
-	ServiceName  string    `json:"serviceName"`
-	UserName     string    `json:"userName"`
-	Comment      string    `json:"comment"`
-	BufferTime   int32     `json:"bufferTime"`
-	Cost         string    `json:"cost"`
-	CreatedAt    time.Time `json:"createdAt"`
-	UpdatedAt    time.Time `json:"updatedAt"`
+	ID            int64     `json:"id"`
+	CustomerID    int64     `json:"customerId"`
+	ServiceID     uuid.UUID `json:"serviceId"`
+	UserID        int64     `json:"userId"`
+	BrandID       int32     `json:"brandId"`
+	StartTime     time.Time `json:"startTime"`
+	EndTime       time.Time `json:"endTime"`
+	CustomerName  string    `json:"customerName"`
+	ServiceName   string    `json:"serviceName"`
+	UserName      string    `json:"userName"`
+	Comment       string    `json:"comment"`
+	BufferTime    int32     `json:"bufferTime"`
+	Cost          string    `json:"cost"`
+	NoShow        bool      `json:"noShow"`
+	DependentID   int64     `json:"dependentId,omitempty"`
+	DependentName string    `json:"dependentName,omitempty"`
+	Version       int32     `json:"version"`
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+type EventHistoryResponse struct {
+	Version   int32           `json:"version"`
+	Action    string          `json:"action"`
+	ActorType string          `json:"actorType"`
+	ActorID   *int64          `json:"actorId"`
+	ActorName string          `json:"actorName"`
+	Changes   json.RawMessage `json:"changes" swaggertype:"object"`
+	CreatedAt time.Time       `json:"createdAt"`
 }
 
 type EventValidationParams struct {
-	UserID     int64
-	ServiceID  uuid.UUID
-	CustomerID int64
-	BrandID    int32
-	StartTime  time.Time
-	EndTime    time.Time
-	Comment    string
+	UserID      int64
+	ServiceID   uuid.UUID
+	CustomerID  int64
+	DependentID int64
+	BrandID     int32
+	StartTime   time.Time
+	EndTime     time.Time
+	Comment     string
 }
 
 type EventEntities struct {
-	User     *store.User
-	Customer *store.Customer
-	Service  *store.Service
+	User      *store.User
+	Customer  *store.Customer
+	Dependent *store.CustomerDependent
+	Service   *store.Service
 }
 
 // createEventHandler creates a new event in the system
@@ -72,11 +94,13 @@ type EventEntities struct {
 //	@Tags			events
 //	@Accept			json
 //	@Produce		json
-//	@Param			payload	body		CreateEventPayload	true	"Event details"
-//	@Success		201		{object}	EventResponse		"Event created successfully"
-//	@Failure		400		{object}	error				"Bad request - invalid input"
-//	@Failure		409		{object}	error				"Conflict - timeslot already booked"
-//	@Failure		500		{object}	error				"Internal server error"
+//	@Param			payload			body		CreateEventPayload	true	"Event details"
+//	@Param			Idempotency-Key	header		string				false	"Unique key of the booking, retries with the same key replay the first response"
+//	@Success		201				{object}	EventResponse		"Event created successfully"
+//	@Failure		400				{object}	error				"Bad request - invalid input"
+//	@Failure		409				{object}	error				"Conflict - timeslot already booked"
+//	@Failure		422				{object}	error				"Idempotency-Key used for a different request"
+//	@Failure		500				{object}	error				"Internal server error"
 //	@Router			/events [post]
 func (app *application) createEventHandler(w http.ResponseWriter, r *http.Request) {
 	var payload CreateEventPayload
@@ -93,13 +117,14 @@ func (app *application) createEventHandler(w http.ResponseWriter, r *http.Reques
 
 	ctx := r.Context()
 	validationParams := EventValidationParams{
-		UserID:     payload.UserID,
-		ServiceID:  payload.ServiceID,
-		CustomerID: payload.CustomerID,
-		BrandID:    payload.BrandID,
-		StartTime:  payload.StartTime,
-		EndTime:    payload.EndTime,
-		Comment:    payload.Comment,
+		UserID:      payload.UserID,
+		ServiceID:   payload.ServiceID,
+		CustomerID:  payload.CustomerID,
+		DependentID: payload.DependentID,
+		BrandID:     payload.BrandID,
+		StartTime:   payload.StartTime,
+		EndTime:     payload.EndTime,
+		Comment:     payload.Comment,
 	}
 
 	entities, err := app.validateEventEntities(ctx, validationParams)
@@ -109,18 +134,20 @@ func (app *application) createEventHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	event, err := app.store.CreateEvent(ctx, store.CreateEventParams{
-		CustomerID:   payload.CustomerID,
-		ServiceID:    payload.ServiceID,
-		UserID:       payload.UserID,
-		BrandID:      payload.BrandID,
-		StartTime:    payload.StartTime.UTC(),
-		EndTime:      payload.EndTime.UTC(),
-		Comment:      toNullString(payload.Comment),
-		CustomerName: entities.Customer.Name,
-		UserName:     entities.User.Name,
-		Cost:         entities.Service.Cost,
-		BufferTime:   entities.Service.BufferTime,
-		ServiceName:  entities.Service.Title,
+		CustomerID:    toNullInt64(payload.CustomerID),
+		ServiceID:     payload.ServiceID,
+		UserID:        payload.UserID,
+		BrandID:       payload.BrandID,
+		StartTime:     payload.StartTime.UTC(),
+		EndTime:       payload.EndTime.UTC(),
+		Comment:       toNullString(payload.Comment),
+		CustomerName:  entities.Customer.Name,
+		UserName:      entities.User.Name,
+		Cost:          entities.Service.Cost,
+		BufferTime:    entities.Service.BufferTime,
+		DependentID:   toNullInt64(payload.DependentID),
+		DependentName: entities.dependentName(),
+		ServiceName:   entities.Service.Title,
 	})
 
 	if err != nil {
@@ -131,6 +158,7 @@ func (app *application) createEventHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	setEventETag(w, event.Version)
 	if err = writeJSON(w, http.StatusCreated, eventResponseMapper(event)); err != nil {
 		app.internalServerError(w, r, err)
 	}
@@ -139,17 +167,22 @@ func (app *application) createEventHandler(w http.ResponseWriter, r *http.Reques
 // updateEventHandler update existing event in the system
 //
 //	@Summary		Update an event
-//	@Description	Updates an event with validation for timeslot availability
+//	@Description	Updates an event with validation for timeslot availability. Pass the version of the event the
+//	@Description	update is based on in the If-Match header or the version field to reject the update when
+//	@Description	someone else changed the event in the meantime
 //	@Tags			events
 //	@Accept			json
 //	@Produce		json
 //	@Security		CookieAuth
-//	@Param			payload	body		CreateEventPayload	true	"Event details"
-//	@Param			eventId	path		int					true	"Event ID"
-//	@Success		200		{object}	EventResponse		"Event updated successfully"
-//	@Failure		400		{object}	error				"Bad request - invalid input"
-//	@Failure		409		{object}	error				"Invalid timeslot"
-//	@Failure		500		{object}	error				"Internal server error"
+//	@Param			payload		body		CreateEventPayload	true	"Event details"
+//	@Param			eventId		path		int					true	"Event ID"
+//	@Param			If-Match	header		string				false	"ETag of the event the update is based on"
+//	@Success		200			{object}	EventResponse		"Event updated successfully"
+//	@Failure		400			{object}	error				"Bad request - invalid input"
+//	@Failure		404			{object}	error				"Event not found"
+//	@Failure		409			{object}	error				"Invalid timeslot or stale version"
+//	@Failure		412			{object}	error				"Stale If-Match version"
+//	@Failure		500			{object}	error				"Internal server error"
 //	@Router			/events/{eventId} [put]
 func (app *application) updateEventHandler(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "eventId")
@@ -175,16 +208,28 @@ func (app *application) updateEventHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	version := sql.NullInt32{Int32: payload.Version, Valid: payload.Version != 0}
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch != "" {
+		matched, err := parseEventETag(ifMatch)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		version = sql.NullInt32{Int32: matched, Valid: true}
+	}
+
 	ctx := r.Context()
 
 	validationParams := EventValidationParams{
-		UserID:     payload.UserID,
-		ServiceID:  payload.ServiceID,
-		CustomerID: payload.CustomerID,
-		BrandID:    payload.BrandID,
-		StartTime:  payload.StartTime,
-		EndTime:    payload.EndTime,
-		Comment:    payload.Comment,
+		UserID:      payload.UserID,
+		ServiceID:   payload.ServiceID,
+		CustomerID:  payload.CustomerID,
+		DependentID: payload.DependentID,
+		BrandID:     payload.BrandID,
+		StartTime:   payload.StartTime,
+		EndTime:     payload.EndTime,
+		Comment:     payload.Comment,
 	}
 
 	entities, err := app.validateEventEntities(ctx, validationParams)
@@ -194,23 +239,26 @@ func (app *application) updateEventHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	updatedEvent, err := app.store.UpdateEvent(ctx, store.UpdateEventParams{
-		ID:           eventId,
-		CustomerID:   payload.CustomerID,
-		ServiceID:    payload.ServiceID,
-		UserID:       payload.UserID,
-		BrandID:      payload.BrandID,
-		StartTime:    payload.StartTime.UTC(),
-		EndTime:      payload.EndTime.UTC(),
-		Comment:      toNullString(payload.Comment),
-		CustomerName: entities.Customer.Name,
-		UserName:     entities.User.Name,
-		ServiceName:  entities.Service.Title,
-		Cost:         entities.Service.Cost,
-		BufferTime:   entities.Service.BufferTime,
+		ID:            eventId,
+		CustomerID:    toNullInt64(payload.CustomerID),
+		ServiceID:     payload.ServiceID,
+		UserID:        payload.UserID,
+		BrandID:       payload.BrandID,
+		StartTime:     payload.StartTime.UTC(),
+		EndTime:       payload.EndTime.UTC(),
+		Comment:       toNullString(payload.Comment),
+		CustomerName:  entities.Customer.Name,
+		UserName:      entities.User.Name,
+		ServiceName:   entities.Service.Title,
+		Cost:          entities.Service.Cost,
+		BufferTime:    entities.Service.BufferTime,
+		DependentID:   toNullInt64(payload.DependentID),
+		DependentName: entities.dependentName(),
+		Version:       version,
 	})
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			app.notFoundResponse(w, r, err)
+			app.handleEventUpdateMiss(w, r, eventId, version.Valid && ifMatch != "")
 			return
 		}
 		if app.handleEventDatabaseError(w, r, err) {
@@ -220,6 +268,7 @@ func (app *application) updateEventHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	setEventETag(w, updatedEvent.Version)
 	if err = writeJSON(w, http.StatusOK, eventResponseMapper(updatedEvent)); err != nil {
 		app.internalServerError(w, r, err)
 	}
@@ -284,6 +333,267 @@ func (app *application) getEventsByTimeStampHandler(w http.ResponseWriter, r *ht
 	}
 }
 
+// listEventsHandler List the events of a brand
+//
+//	@Summary		List the events of a brand
+//	@Description	List the events of a brand filtered by date range, staff member or customer. Results are cursor paginated.
+//	@Tags			events
+//	@Produce		json
+//	@Security		CookieAuth
+//	@Param			from		query		string							false	"Start date in YYYY-MM-DD format"	example(2025-05-19)
+//	@Param			to			query		string							false	"End date (exclusive) in YYYY-MM-DD format"	example(2025-05-20)
+//	@Param			userId		query		integer							false	"Only events of the staff member"
+//	@Param			customerId	query		integer							false	"Only events of the customer"
+//	@Param			sort		query		string							false	"startTime or createdAt, prefixed with - for descending order"	default(startTime)
+//	@Param			limit		query		int								false	"Page size"	default(50)	maximum(100)
+//	@Param			cursor		query		string							false	"Cursor of the next page"
+//	@Success		200			{object}	PageResponse[EventResponse]
+//	@Failure		400			{object}	error	"Bad request - invalid input"
+//	@Failure		500			{object}	error	"Internal server error"
+//	@Router			/events [get]
+func (app *application) listEventsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxUser := ctx.Value(userCtx).(*store.User)
+
+	page, err := parsePageParams(r, []string{"startTime", "createdAt"}, "startTime")
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	cursorID, err := page.cursorInt64()
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	params := store.ListEventsParams{
+		BrandID:    ctxUser.BrandID.Int32,
+		Sort:       page.Sort,
+		HasCursor:  page.Cursor != nil,
+		Descending: page.Descending,
+		CursorKey:  page.cursorKey(),
+		CursorID:   cursorID,
+		PageLimit:  page.fetchLimit(),
+	}
+
+	query := r.URL.Query()
+	if value := query.Get("from"); value != "" {
+		from, err := time.Parse(dateLayout, value)
+		if err != nil {
+			app.badRequestResponse(w, r, errors.New("Invalid from format. Must be YYYY-MM-DD"))
+			return
+		}
+		params.StartFrom = sql.NullTime{Time: from, Valid: true}
+	}
+
+	if value := query.Get("to"); value != "" {
+		to, err := time.Parse(dateLayout, value)
+		if err != nil {
+			app.badRequestResponse(w, r, errors.New("Invalid to format. Must be YYYY-MM-DD"))
+			return
+		}
+		params.StartTo = sql.NullTime{Time: to, Valid: true}
+	}
+
+	if value := query.Get("userId"); value != "" {
+		params.UserID, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			app.badRequestResponse(w, r, errors.New("invalid userId"))
+			return
+		}
+	}
+
+	if value := query.Get("customerId"); value != "" {
+		params.CustomerID, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			app.badRequestResponse(w, r, errors.New("invalid customerId"))
+			return
+		}
+	}
+
+	events, err := app.store.ListEvents(ctx, params)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	result := paginate(page, events, func(row *store.ListEventsRow) (string, string) {
+		return row.SortKey, strconv.FormatInt(row.ID, 10)
+	}, eventListResponseMapper)
+
+	if err = writeJSON(w, http.StatusOK, result); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type SetEventNoShowPayload struct {
+	NoShow bool `json:"noShow"`
+}
+
+// setEventNoShowHandler Marks an event as a no-show
+//
+//	@Summary		Mark an event as a no-show
+//	@Description	Records whether the customer did not show up for the event
+//	@Tags			events
+//	@Accept			json
+//	@Produce		json
+//	@Security		CookieAuth
+//	@Param			eventId	path		int						true	"Event ID"
+//	@Param			payload	body		SetEventNoShowPayload	true	"No-show flag"
+//	@Success		200		{object}	EventResponse
+//	@Failure		400		{object}	error	"Bad request - invalid input"
+//	@Failure		403		{object}	error	"Forbidden - not a member of a brand"
+//	@Failure		404		{object}	error	"Event not found"
+//	@Failure		500		{object}	error	"Internal server error"
+//	@Router			/events/{eventId}/no-show [put]
+func (app *application) setEventNoShowHandler(w http.ResponseWriter, r *http.Request) {
+	eventID, err := strconv.ParseInt(chi.URLParam(r, "eventId"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, errors.New("invalid event id"))
+		return
+	}
+
+	var payload SetEventNoShowPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	ctxUser, err := getUserFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if !ctxUser.BrandID.Valid {
+		app.forbiddenResponse(w, r, ErrAccessDenied)
+		return
+	}
+
+	// Events of other brands look the same as missing ones
+	current, err := app.store.GetEventByID(ctx, eventID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			app.notFoundResponse(w, r, err)
+			return
+		}
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if current.BrandID != ctxUser.BrandID.Int32 {
+		app.notFoundResponse(w, r, sql.ErrNoRows)
+		return
+	}
+
+	event, err := app.store.SetEventNoShow(ctx, store.SetEventNoShowParams{
+		ID:     eventID,
+		NoShow: payload.NoShow,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			app.notFoundResponse(w, r, err)
+			return
+		}
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err = writeJSON(w, http.StatusOK, eventResponseMapper(event)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// handleEventUpdateMiss tells a missing event apart from a version that is
+// no longer current
+func (app *application) handleEventUpdateMiss(w http.ResponseWriter, r *http.Request, eventID int64, fromIfMatch bool) {
+	event, err := app.store.GetEventByID(r.Context(), eventID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			app.notFoundResponse(w, r, err)
+			return
+		}
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	setEventETag(w, event.Version)
+	if fromIfMatch {
+		app.preconditionFailedResponse(w, r, ErrEventVersionConflict)
+		return
+	}
+	app.conflictRespone(w, r, ErrEventVersionConflict)
+}
+
+// getEventHistoryHandler Lists the changes of an event
+//
+//	@Summary		List the changes of an event
+//	@Description	Lists every version of the event, oldest first, with who made the change and the previous
+//	@Description	and new values of the changed fields
+//	@Tags			events
+//	@Produce		json
+//	@Security		CookieAuth
+//	@Param			eventId	path		int	true	"Event ID"
+//	@Success		200		{array}		EventHistoryResponse
+//	@Failure		400		{object}	error	"Bad request - invalid input"
+//	@Failure		404		{object}	error	"Event not found"
+//	@Failure		500		{object}	error	"Internal server error"
+//	@Router			/events/{eventId}/history [get]
+func (app *application) getEventHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	eventID, err := strconv.ParseInt(chi.URLParam(r, "eventId"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, errors.New("invalid event id"))
+		return
+	}
+
+	ctx := r.Context()
+	history, err := app.store.ListEventHistory(ctx, eventID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// Events booked before versioning have no history yet
+	if len(history) == 0 {
+		if _, err := app.store.GetEventByID(ctx, eventID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				app.notFoundResponse(w, r, err)
+				return
+			}
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	response := make([]EventHistoryResponse, len(history))
+	for i, entry := range history {
+		response[i] = eventHistoryResponseMapper(entry)
+	}
+
+	if err = writeJSON(w, http.StatusOK, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func setEventETag(w http.ResponseWriter, version int32) {
+	w.Header().Set("ETag", strconv.Quote(strconv.FormatInt(int64(version), 10)))
+}
+
+// parseEventETag reads the version from an If-Match header set from the ETag
+// of the event
+func parseEventETag(header string) (int32, error) {
+	value := strings.TrimPrefix(strings.TrimSpace(header), "W/")
+	value = strings.Trim(value, `"`)
+
+	version, err := strconv.ParseInt(value, 10, 32)
+	if err != nil || version < 1 {
+		return 0, errors.New("invalid If-Match header, expected the ETag of the event")
+	}
+	return int32(version), nil
+}
+
 func (app *application) validateEventEntities(ctx context.Context, params EventValidationParams) (*EventEntities, error) {
 	availabilityParams := store.CheckSpecificTimeslotAvailabilityParams{
 		UserID:    params.UserID,
@@ -340,14 +650,41 @@ func (app *application) validateEventEntities(ctx context.Context, params EventV
 		return nil, fmt.Errorf("error getting service: %w", serviceErr)
 	}
 
-	return &EventEntities{
+	entities := &EventEntities{
 		User:     user,
 		Customer: customer,
 		Service:  service,
-	}, nil
+	}
+
+	if params.DependentID != 0 {
+		entities.Dependent, err = app.store.GetCustomerDependent(ctx, store.GetCustomerDependentParams{
+			ID:         params.DependentID,
+			CustomerID: customer.ID,
+		})
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, ErrDependentNotFound
+			}
+			return nil, fmt.Errorf("error getting dependent: %w", err)
+		}
+	}
+
+	return entities, nil
+}
+
+func (e *EventEntities) dependentName() sql.NullString {
+	if e.Dependent == nil {
+		return sql.NullString{}
+	}
+	return toNullString(e.Dependent.Name)
 }
 
 func (app *application) handleEventDatabaseError(w http.ResponseWriter, r *http.Request, err error) bool {
+	if isPgError(err, insufficientPrivilege) {
+		app.forbiddenResponse(w, r, ErrAccessDenied)
+		return true
+	}
+
 	pgError, ok := err.(*pq.Error)
 	if !ok || !isPgError(err, foreignKeyViolation) {
 		return false
@@ -356,6 +693,8 @@ func (app *application) handleEventDatabaseError(w http.ResponseWriter, r *http.
 	switch {
 	case strings.Contains(pgError.Message, "events_customer_id_fkey"):
 		app.badRequestResponse(w, r, errors.New("invalid customer"))
+	case strings.Contains(pgError.Message, "events_dependent_id_fkey"):
+		app.badRequestResponse(w, r, errors.New("invalid dependent"))
 	case strings.Contains(pgError.Message, "events_service_id_fkey"):
 		app.badRequestResponse(w, r, errors.New("invalid service"))
 	case strings.Contains(pgError.Message, "events_user_id_fkey"):
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/georgifotev1/bms/internal/store"
+)
+
+// Exports may take longer than the server write timeout
+const exportWriteTimeout = 5 * time.Minute
+
+// CustomerExport is a customer as written by the export. Field names match the
+// customer import so that an export can be imported again.
+type CustomerExport struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Phone     string    `json:"phone"`
+	Tags      []string  `json:"tags"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// EventExport is an event as written by the export. Field names match the
+// event import so that an export can be imported again.
+type EventExport struct {
+	ID            int64     `json:"id"`
+	CustomerID    int64     `json:"customerId"`
+	CustomerName  string    `json:"customerName"`
+	CustomerEmail string    `json:"customerEmail"`
+	CustomerPhone string    `json:"customerPhone"`
+	DependentName string    `json:"dependentName"`
+	Service       string    `json:"service"`
+	Staff         string    `json:"staff"`
+	StartTime     time.Time `json:"startTime"`
+	EndTime       time.Time `json:"endTime"`
+	Cost          string    `json:"cost"`
+	Comment       string    `json:"comment"`
+	NoShow        bool      `json:"noShow"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// @Summary		Export customers
+// @Description	Exports all customers of the brand as CSV or JSON
+// @Tags			customers
+// @Produce		json
+// @Produce		text/csv
+// @Param			format	query		string	false	"csv or json"	default(csv)
+// @Success		200		{array}		CustomerExport
+// @Failure		400		{object}	error
+// @Failure		403		{object}	error
+// @Failure		500		{object}	error
+// @Security		CookieAuth
+// @Router			/customers/export [get]
+func (app *application) exportCustomersHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxUser, err := getUserFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if ctxUser.Role == userRole || !ctxUser.BrandID.Valid {
+		app.forbiddenResponse(w, r, ErrAccessDenied)
+		return
+	}
+
+	format, err := exportFormat(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	customers, err := app.store.ExportCustomers(ctx, ctxUser.BrandID.Int32)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	rows := make([]CustomerExport, 0, len(customers))
+	for _, customer := range customers {
+		rows = append(rows, CustomerExport{
+			ID:        customer.ID,
+			Name:      customer.Name,
+			Email:     customer.Email.String,
+			Phone:     customer.PhoneNumber,
+			Tags:      customer.Tags,
+			CreatedAt: customer.CreatedAt,
+		})
+	}
+
+	header := []string{"id", "name", "email", "phone", "tags", "createdAt"}
+	writeExport(app, w, r, format, "customers", rows, header, func(c CustomerExport) []string {
+		return []string{
+			strconv.FormatInt(c.ID, 10),
+			c.Name,
+			c.Email,
+			c.Phone,
+			strings.Join(c.Tags, ";"),
+			c.CreatedAt.Format(time.RFC3339),
+		}
+	})
+}
+
+// @Summary		Export events
+// @Description	Exports the events of the brand as CSV or JSON, optionally limited to a date range
+// @Tags			events
+// @Produce		json
+// @Produce		text/csv
+// @Param			format	query		string	false	"csv or json"	default(csv)
+// @Param			from	query		string	false	"Start date in YYYY-MM-DD format"
+// @Param			to		query		string	false	"End date (exclusive) in YYYY-MM-DD format"
+// @Success		200		{array}		EventExport
+// @Failure		400		{object}	error
+// @Failure		403		{object}	error
+// @Failure		500		{object}	error
+// @Security		CookieAuth
+// @Router			/events/export [get]
+func (app *application) exportEventsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxUser, err := getUserFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if ctxUser.Role == userRole || !ctxUser.BrandID.Valid {
+		app.forbiddenResponse(w, r, ErrAccessDenied)
+		return
+	}
+
+	format, err := exportFormat(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	params := store.ExportEventsParams{BrandID: ctxUser.BrandID.Int32}
+	query := r.URL.Query()
+	if value := query.Get("from"); value != "" {
+		from, err := time.Parse(dateLayout, value)
+		if err != nil {
+			app.badRequestResponse(w, r, errors.New("Invalid from format. Must be YYYY-MM-DD"))
+			return
+		}
+		params.StartFrom = sql.NullTime{Time: from, Valid: true}
+	}
+
+	if value := query.Get("to"); value != "" {
+		to, err := time.Parse(dateLayout, value)
+		if err != nil {
+			app.badRequestResponse(w, r, errors.New("Invalid to format. Must be YYYY-MM-DD"))
+			return
+		}
+		params.StartTo = sql.NullTime{Time: to, Valid: true}
+	}
+
+	events, err := app.store.ExportEvents(ctx, params)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	rows := make([]EventExport, 0, len(events))
+	for _, event := range events {
+		rows = append(rows, EventExport{
+			ID:            event.ID,
+			CustomerID:    event.CustomerID.Int64,
+			CustomerName:  event.CustomerName,
+			CustomerEmail: event.CustomerEmail.String,
+			CustomerPhone: event.CustomerPhone.String,
+			DependentName: event.DependentName.String,
+			Service:       event.ServiceName,
+			Staff:         event.UserName,
+			StartTime:     event.StartTime,
+			EndTime:       event.EndTime,
+			Cost:          event.Cost.String,
+			Comment:       event.Comment.String,
+			NoShow:        event.NoShow,
+			CreatedAt:     event.CreatedAt,
+		})
+	}
+
+	header := []string{"id", "customerId", "customerName", "customerEmail", "customerPhone", "dependentName", "service", "staff", "startTime", "endTime", "cost", "comment", "noShow", "createdAt"}
+	writeExport(app, w, r, format, "events", rows, header, func(e EventExport) []string {
+		return []string{
+			strconv.FormatInt(e.ID, 10),
+			strconv.FormatInt(e.CustomerID, 10),
+			e.CustomerName,
+			e.CustomerEmail,
+			e.CustomerPhone,
+			e.DependentName,
+			e.Service,
+			e.Staff,
+			e.StartTime.Format(time.RFC3339),
+			e.EndTime.Format(time.RFC3339),
+			e.Cost,
+			e.Comment,
+			strconv.FormatBool(e.NoShow),
+			e.CreatedAt.Format(time.RFC3339),
+		}
+	})
+}
+
+func exportFormat(r *http.Request) (string, error) {
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	switch format {
+	case "":
+		return "csv", nil
+	case "csv", "json":
+		return format, nil
+	default:
+		return "", errors.New("format must be csv or json")
+	}
+}
+
+// writeExport writes the rows as a downloadable CSV or JSON file
+func writeExport[T any](app *application, w http.ResponseWriter, r *http.Request, format, name string, rows []T, header []string, record func(T) []string) {
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+
+	fileName := fmt.Sprintf("%s-%s.%s", name, time.Now().Format(dateLayout), format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+
+	if format == "json" {
+		if err := writeJSON(w, http.StatusOK, rows); err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(header); err != nil {
+		app.logger.Errorw("writing export", "error", err.Error())
+		return
+	}
+	for _, row := range rows {
+		if err := writer.Write(record(row)); err != nil {
+			app.logger.Errorw("writing export", "error", err.Error())
+			return
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		app.logger.Errorw("writing export", "error", err.Error())
+	}
+}
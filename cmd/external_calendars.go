@@ -0,0 +1,529 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/georgifotev1/bms/internal/ical"
+	"github.com/georgifotev1/bms/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	externalCalendarMaxPerUser = 10
+	maxExternalCalendarSize    = 5 << 20
+	externalCalendarBatchSize  = 20
+	externalCalendarWorkers    = 4
+	// externalCalendarLock keeps other instances off a calendar being synced
+	externalCalendarLock = 10 * time.Minute
+	// Uploaded calendars don't change, they are only expanded again as the
+	// busy times move ahead
+	uploadedCalendarSyncInterval = 24 * time.Hour
+	// externalCalendarPast is how far back busy times are kept
+	externalCalendarPast = 24 * time.Hour
+	// Only the first few unreadable events are reported
+	externalCalendarMaxErrors = 3
+)
+
+var (
+	ErrExternalCalendarNotFound     = errors.New("external calendar not found")
+	ErrExternalCalendarLimitReached = fmt.Errorf("a user can have at most %d external calendars", externalCalendarMaxPerUser)
+	ErrExternalCalendarTooLarge     = fmt.Errorf("the calendar is larger than %d MB", maxExternalCalendarSize>>20)
+)
+
+type ExternalCalendarPayload struct {
+	Name string `json:"name" validate:"required,max=100"`
+	URL  string `json:"url" validate:"required,url,max=2048"`
+}
+
+type ExternalCalendarResponse struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	// URL is empty for uploaded calendars
+	URL          string     `json:"url,omitempty"`
+	Source       string     `json:"source"`
+	LastSyncedAt *time.Time `json:"lastSyncedAt"`
+	// LastError tells why the last sync failed or which events it skipped
+	LastError  string    `json:"lastError"`
+	NextSyncAt time.Time `json:"nextSyncAt"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// @Summary		List my external calendars
+// @Description	Lists the calendars whose busy times block the availability of the signed in staff member, with the outcome of their last sync
+// @Tags			external-calendars
+// @Produce		json
+// @Success		200	{array}		ExternalCalendarResponse
+// @Failure		500	{object}	error
+// @Security		CookieAuth
+// @Router			/users/me/external-calendars [get]
+func (app *application) getMyExternalCalendarsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxUser, err := getUserFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	calendars, err := app.store.ListExternalCalendars(ctx, ctxUser.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	response := make([]ExternalCalendarResponse, len(calendars))
+	for i, calendar := range calendars {
+		response[i] = externalCalendarRowMapper(calendar)
+	}
+
+	if err := writeJSON(w, http.StatusOK, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// @Summary		Subscribe to an external calendar
+// @Description	Adds an ICS calendar by its URL, for example the secret address of a personal calendar. It is fetched
+// @Description	right away and then periodically. Only the busy times are kept, they block the availability of the
+// @Description	signed in staff member. Sync problems are reported in lastError
+// @Tags			external-calendars
+// @Accept			json
+// @Produce		json
+// @Param			payload	body		ExternalCalendarPayload	true	"Name and ICS URL"
+// @Success		201		{object}	ExternalCalendarResponse
+// @Failure		400		{object}	error
+// @Failure		403		{object}	error
+// @Failure		500		{object}	error
+// @Security		CookieAuth
+// @Router			/users/me/external-calendars [post]
+func (app *application) createExternalCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxUser, ok := app.externalCalendarUser(w, r)
+	if !ok {
+		return
+	}
+
+	var payload ExternalCalendarPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	calendarURL, err := normalizeCalendarURL(payload.URL)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	calendar, err := app.store.CreateExternalCalendar(ctx, store.CreateExternalCalendarParams{
+		BrandID: ctxUser.BrandID.Int32,
+		UserID:  ctxUser.ID,
+		Name:    strings.TrimSpace(payload.Name),
+		Url:     sql.NullString{String: calendarURL, Valid: true},
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.writeSyncedExternalCalendar(w, r, calendar, http.StatusCreated)
+}
+
+// @Summary		Upload an external calendar
+// @Description	Adds an ICS file. Only its busy times are kept, they block the availability of the signed in staff
+// @Description	member. Recurring events keep blocking as time goes by, upload the file again to pick up changes
+// @Tags			external-calendars
+// @Accept			multipart/form-data
+// @Produce		json
+// @Param			file	formData	file	true	"ICS file"
+// @Param			name	formData	string	true	"Name of the calendar"
+// @Success		201		{object}	ExternalCalendarResponse
+// @Failure		400		{object}	error
+// @Failure		403		{object}	error
+// @Failure		500		{object}	error
+// @Security		CookieAuth
+// @Router			/users/me/external-calendars/upload [post]
+func (app *application) uploadExternalCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxUser, ok := app.externalCalendarUser(w, r)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxExternalCalendarSize+1<<20)
+	if err := r.ParseMultipartForm(maxExternalCalendarSize); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" || utf8.RuneCountInString(name) > 100 {
+		app.badRequestResponse(w, r, errors.New("name is required and at most 100 characters"))
+		return
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		app.badRequestResponse(w, r, errors.New("file is required"))
+		return
+	}
+	defer file.Close()
+
+	content, err := readExternalCalendar(file)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Postgres only stores valid text
+	if !utf8.Valid(content) || bytes.IndexByte(content, 0) >= 0 {
+		app.badRequestResponse(w, r, errors.New("the calendar is not UTF-8 text"))
+		return
+	}
+
+	if _, err := ical.Parse(bytes.NewReader(content)); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	calendar, err := app.store.CreateExternalCalendar(ctx, store.CreateExternalCalendarParams{
+		BrandID: ctxUser.BrandID.Int32,
+		UserID:  ctxUser.ID,
+		Name:    name,
+		Content: sql.NullString{String: string(content), Valid: true},
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.writeSyncedExternalCalendar(w, r, calendar, http.StatusCreated)
+}
+
+// @Summary		Sync an external calendar
+// @Description	Fetches the calendar again now instead of waiting for the next periodic sync
+// @Tags			external-calendars
+// @Produce		json
+// @Param			calendarId	path		int	true	"External calendar ID"
+// @Success		200			{object}	ExternalCalendarResponse
+// @Failure		404			{object}	error
+// @Failure		500			{object}	error
+// @Security		CookieAuth
+// @Router			/users/me/external-calendars/{calendarId}/sync [post]
+func (app *application) syncExternalCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	calendar, ok := app.externalCalendarFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	app.writeSyncedExternalCalendar(w, r, calendar, http.StatusOK)
+}
+
+// @Summary		Remove an external calendar
+// @Description	Removes the calendar and the busy times it blocked
+// @Tags			external-calendars
+// @Param			calendarId	path	int	true	"External calendar ID"
+// @Success		204
+// @Failure		404	{object}	error
+// @Failure		500	{object}	error
+// @Security		CookieAuth
+// @Router			/users/me/external-calendars/{calendarId} [delete]
+func (app *application) deleteExternalCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxUser, err := getUserFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	calendarID, err := strconv.ParseInt(chi.URLParam(r, "calendarId"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	count, err := app.store.DeleteExternalCalendar(ctx, store.DeleteExternalCalendarParams{
+		ID:     calendarID,
+		UserID: ctxUser.ID,
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if count == 0 {
+		app.notFoundResponse(w, r, ErrExternalCalendarNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// externalCalendarUser returns the signed in user when they can add another
+// calendar
+func (app *application) externalCalendarUser(w http.ResponseWriter, r *http.Request) (*store.User, bool) {
+	ctx := r.Context()
+	ctxUser, err := getUserFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return nil, false
+	}
+
+	if !ctxUser.BrandID.Valid {
+		app.forbiddenResponse(w, r, ErrAccessDenied)
+		return nil, false
+	}
+
+	existing, err := app.store.ListExternalCalendars(ctx, ctxUser.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return nil, false
+	}
+
+	if len(existing) >= externalCalendarMaxPerUser {
+		app.badRequestResponse(w, r, ErrExternalCalendarLimitReached)
+		return nil, false
+	}
+
+	return ctxUser, true
+}
+
+func (app *application) externalCalendarFromRequest(w http.ResponseWriter, r *http.Request) (*store.ExternalCalendar, bool) {
+	ctx := r.Context()
+	ctxUser, err := getUserFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return nil, false
+	}
+
+	calendarID, err := strconv.ParseInt(chi.URLParam(r, "calendarId"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return nil, false
+	}
+
+	calendar, err := app.store.GetExternalCalendar(ctx, store.GetExternalCalendarParams{
+		ID:     calendarID,
+		UserID: ctxUser.ID,
+	})
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			app.notFoundResponse(w, r, ErrExternalCalendarNotFound)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return nil, false
+	}
+
+	return calendar, true
+}
+
+// writeSyncedExternalCalendar syncs the calendar and answers with the outcome
+func (app *application) writeSyncedExternalCalendar(w http.ResponseWriter, r *http.Request, calendar *store.ExternalCalendar, status int) {
+	synced, err := app.syncExternalCalendar(r.Context(), calendar)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := writeJSON(w, status, externalCalendarResponseMapper(synced)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// syncExternalCalendars syncs the calendars that are due. Claimed calendars
+// are locked so other instances skip them.
+func (app *application) syncExternalCalendars(ctx context.Context) error {
+	for {
+		calendars, err := app.store.ClaimDueExternalCalendars(ctx, store.ClaimDueExternalCalendarsParams{
+			BatchSize:   externalCalendarBatchSize,
+			LockedUntil: time.Now().UTC().Add(externalCalendarLock),
+		})
+		if err != nil {
+			return err
+		}
+
+		var wg sync.WaitGroup
+		work := make(chan *store.ExternalCalendar)
+		for range min(externalCalendarWorkers, len(calendars)) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for calendar := range work {
+					if _, err := app.syncExternalCalendar(ctx, calendar); err != nil {
+						app.logger.Errorw("error syncing external calendar", "calendar", calendar.ID, "error", err)
+					}
+				}
+			}()
+		}
+		for _, calendar := range calendars {
+			work <- calendar
+		}
+		close(work)
+		wg.Wait()
+
+		if len(calendars) < externalCalendarBatchSize {
+			return nil
+		}
+	}
+}
+
+// syncExternalCalendar replaces the busy times of the calendar with the ones
+// of its current version. A calendar that can't be read keeps its busy times
+// and reports why. Only database errors are returned.
+func (app *application) syncExternalCalendar(ctx context.Context, calendar *store.ExternalCalendar) (*store.ExternalCalendar, error) {
+	now := time.Now().UTC()
+	nextSyncAt := now.Add(app.config.externalCalendars.syncInterval)
+	if !calendar.Url.Valid {
+		nextSyncAt = now.Add(uploadedCalendarSyncInterval)
+	}
+
+	periods, skipped, err := app.busyPeriods(ctx, calendar, now)
+	if err != nil {
+		return app.store.RecordExternalCalendarSync(ctx, store.RecordExternalCalendarSyncParams{
+			ID:         calendar.ID,
+			Synced:     false,
+			LastError:  err.Error(),
+			NextSyncAt: nextSyncAt,
+		})
+	}
+
+	params := store.SyncExternalCalendarTxParams{
+		Calendar:   calendar,
+		StartTimes: make([]time.Time, len(periods)),
+		EndTimes:   make([]time.Time, len(periods)),
+		NextSyncAt: nextSyncAt,
+	}
+	for i, period := range periods {
+		params.StartTimes[i] = period.Start.UTC()
+		params.EndTimes[i] = period.End.UTC()
+	}
+
+	if len(skipped) > 0 {
+		messages := make([]string, 0, externalCalendarMaxErrors)
+		for _, err := range skipped[:min(len(skipped), externalCalendarMaxErrors)] {
+			messages = append(messages, err.Error())
+		}
+		params.LastError = fmt.Sprintf("%d events skipped: %s", len(skipped), strings.Join(messages, "; "))
+	}
+
+	return app.store.SyncExternalCalendarTx(ctx, params)
+}
+
+// busyPeriods reads the calendar and returns its busy times from a day ago
+// to the horizon, leaving out copies of the brand's own events
+func (app *application) busyPeriods(ctx context.Context, calendar *store.ExternalCalendar, now time.Time) ([]ical.Period, []error, error) {
+	var (
+		parsed *ical.Component
+		err    error
+	)
+	if calendar.Url.Valid {
+		parsed, err = app.fetchExternalCalendar(ctx, calendar.Url.String)
+	} else {
+		parsed, err = ical.Parse(strings.NewReader(calendar.Content.String))
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	location, err := time.LoadLocation(LOCATION_FORMAT)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	brand, err := app.store.GetBrandById(ctx, calendar.BrandID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	periods, skipped := ical.BusyPeriods(parsed, now.Add(-externalCalendarPast), now.Add(app.config.externalCalendars.horizon), location)
+
+	busy := periods[:0]
+	for _, period := range periods {
+		if !isCalendarFeedUID(period.UID, brand) {
+			busy = append(busy, period)
+		}
+	}
+	return busy, skipped, nil
+}
+
+// fetchExternalCalendar downloads and parses a subscribed calendar. Errors
+// leave the URL out since it often holds a secret.
+func (app *application) fetchExternalCalendar(ctx context.Context, calendarURL string) (*ical.Component, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, calendarURL, nil)
+	if err != nil {
+		return nil, errors.New("invalid calendar URL")
+	}
+	req.Header.Set("Accept", "text/calendar")
+	req.Header.Set("User-Agent", "bms-calendar-sync/1.0")
+
+	resp, err := app.calendarFetcher.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("fetching the calendar from %s failed: %w", req.URL.Host, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s answered %d", req.URL.Host, resp.StatusCode)
+	}
+
+	content, err := readExternalCalendar(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return ical.Parse(bytes.NewReader(content))
+}
+
+func readExternalCalendar(r io.Reader) ([]byte, error) {
+	content, err := io.ReadAll(io.LimitReader(r, maxExternalCalendarSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > maxExternalCalendarSize {
+		return nil, ErrExternalCalendarTooLarge
+	}
+	return content, nil
+}
+
+// normalizeCalendarURL accepts http, https and webcal URLs, webcal ones are
+// fetched over https
+func normalizeCalendarURL(raw string) (string, error) {
+	target, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", err
+	}
+
+	switch strings.ToLower(target.Scheme) {
+	case "webcal", "webcals":
+		target.Scheme = "https"
+	case "http", "https":
+	default:
+		return "", errors.New("the calendar URL must use https, http or webcal")
+	}
+
+	if target.Host == "" {
+		return "", errors.New("the calendar URL has no host")
+	}
+	return target.String(), nil
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/georgifotev1/bms/internal/safehttp"
+	"github.com/georgifotev1/bms/internal/store"
+)
+
+// syncStore records what a sync writes. Queries the sync doesn't make are
+// left to the nil Store and panic.
+type syncStore struct {
+	store.Store
+	brand  *store.Brand
+	synced *store.SyncExternalCalendarTxParams
+	failed *store.RecordExternalCalendarSyncParams
+}
+
+func (s *syncStore) GetBrandById(ctx context.Context, id int32) (*store.Brand, error) {
+	return s.brand, nil
+}
+
+func (s *syncStore) SyncExternalCalendarTx(ctx context.Context, arg store.SyncExternalCalendarTxParams) (*store.ExternalCalendar, error) {
+	s.synced = &arg
+	calendar := *arg.Calendar
+	calendar.LastError = arg.LastError
+	calendar.NextSyncAt = arg.NextSyncAt
+	return &calendar, nil
+}
+
+func (s *syncStore) RecordExternalCalendarSync(ctx context.Context, arg store.RecordExternalCalendarSyncParams) (*store.ExternalCalendar, error) {
+	s.failed = &arg
+	return &store.ExternalCalendar{ID: arg.ID, LastError: arg.LastError, NextSyncAt: arg.NextSyncAt}, nil
+}
+
+func TestSyncExternalCalendar(t *testing.T) {
+	start := time.Now().UTC().Add(48 * time.Hour).Truncate(time.Hour)
+	event := func(uid string, at time.Time) string {
+		return "BEGIN:VEVENT\r\n" +
+			"UID:" + uid + "\r\n" +
+			"DTSTART:" + at.Format("20060102T150405Z") + "\r\n" +
+			"DTEND:" + at.Add(time.Hour).Format("20060102T150405Z") + "\r\n" +
+			"SUMMARY:Dentist\r\n" +
+			"END:VEVENT\r\n"
+	}
+
+	body := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n" +
+		event("dentist@example.com", start) +
+		// A copy of a booking from the brand's own feed
+		event("event-7@studio", start.Add(3*time.Hour)) +
+		"BEGIN:VEVENT\r\nUID:hourly\r\nDTSTART:20260105T090000Z\r\nRRULE:FREQ=HOURLY\r\nEND:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+
+	var userAgent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/cal.ics":
+			userAgent = r.UserAgent()
+			w.Header().Set("Content-Type", "text/calendar")
+			w.Write([]byte(body))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	newApp := func() (*application, *syncStore) {
+		s := &syncStore{brand: &store.Brand{ID: 1, PageUrl: "studio"}}
+		app := &application{
+			store:           s,
+			calendarFetcher: safehttp.NewClient(5*time.Second, true),
+		}
+		app.config.externalCalendars = externalCalendarConfig{
+			timeout:      5 * time.Second,
+			syncInterval: 30 * time.Minute,
+			horizon:      30 * 24 * time.Hour,
+		}
+		return app, s
+	}
+
+	t.Run("imports busy times", func(t *testing.T) {
+		app, s := newApp()
+		calendar := &store.ExternalCalendar{
+			ID:      1,
+			BrandID: 1,
+			Url:     sql.NullString{String: server.URL + "/cal.ics?token=secret", Valid: true},
+		}
+
+		synced, err := app.syncExternalCalendar(context.Background(), calendar)
+		if err != nil {
+			t.Fatalf("syncExternalCalendar: %v", err)
+		}
+
+		if s.synced == nil {
+			t.Fatal("busy times were not stored")
+		}
+		if len(s.synced.StartTimes) != 1 || !s.synced.StartTimes[0].Equal(start) || !s.synced.EndTimes[0].Equal(start.Add(time.Hour)) {
+			t.Fatalf("busy times = %v - %v, want the dentist only", s.synced.StartTimes, s.synced.EndTimes)
+		}
+		if !strings.HasPrefix(synced.LastError, "1 events skipped") {
+			t.Fatalf("LastError = %q, want the hourly event reported", synced.LastError)
+		}
+		if userAgent != "bms-calendar-sync/1.0" {
+			t.Fatalf("User-Agent = %q", userAgent)
+		}
+	})
+
+	t.Run("keeps busy times when the fetch fails", func(t *testing.T) {
+		app, s := newApp()
+		calendar := &store.ExternalCalendar{
+			ID:      2,
+			BrandID: 1,
+			Url:     sql.NullString{String: server.URL + "/missing.ics?token=secret", Valid: true},
+		}
+
+		if _, err := app.syncExternalCalendar(context.Background(), calendar); err != nil {
+			t.Fatalf("syncExternalCalendar: %v", err)
+		}
+
+		if s.synced != nil {
+			t.Fatal("busy times were replaced after a failed fetch")
+		}
+		if s.failed == nil || s.failed.Synced || !strings.Contains(s.failed.LastError, "404") {
+			t.Fatalf("recorded sync = %+v, want the 404", s.failed)
+		}
+		if strings.Contains(s.failed.LastError, "secret") {
+			t.Fatalf("LastError %q leaks the calendar URL", s.failed.LastError)
+		}
+	})
+
+	t.Run("refuses private addresses outside development", func(t *testing.T) {
+		app, s := newApp()
+		app.calendarFetcher = safehttp.NewClient(5*time.Second, false)
+		calendar := &store.ExternalCalendar{
+			ID:      3,
+			BrandID: 1,
+			Url:     sql.NullString{String: server.URL + "/cal.ics?token=secret", Valid: true},
+		}
+
+		if _, err := app.syncExternalCalendar(context.Background(), calendar); err != nil {
+			t.Fatalf("syncExternalCalendar: %v", err)
+		}
+
+		if s.failed == nil || !strings.Contains(s.failed.LastError, safehttp.ErrForbiddenAddress.Error()) {
+			t.Fatalf("recorded sync = %+v, want the private address refused", s.failed)
+		}
+	})
+
+	t.Run("expands uploaded calendars", func(t *testing.T) {
+		app, s := newApp()
+		calendar := &store.ExternalCalendar{
+			ID:      4,
+			BrandID: 1,
+			Content: sql.NullString{String: body, Valid: true},
+		}
+
+		if _, err := app.syncExternalCalendar(context.Background(), calendar); err != nil {
+			t.Fatalf("syncExternalCalendar: %v", err)
+		}
+
+		if s.synced == nil || len(s.synced.StartTimes) != 1 {
+			t.Fatalf("synced = %+v, want one busy time", s.synced)
+		}
+		if next := time.Until(s.synced.NextSyncAt); next < 23*time.Hour {
+			t.Fatalf("uploaded calendar syncs again in %v, want a day", next)
+		}
+	})
+}
+
+func TestNormalizeCalendarURL(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{input: "https://calendar.example.com/a.ics", want: "https://calendar.example.com/a.ics"},
+		{input: "webcal://calendar.example.com/a.ics", want: "https://calendar.example.com/a.ics"},
+		{input: " http://calendar.example.com/a.ics ", want: "http://calendar.example.com/a.ics"},
+		{input: "ftp://calendar.example.com/a.ics", wantErr: true},
+		{input: "file:///etc/passwd", wantErr: true},
+		{input: "https:///a.ics", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := normalizeCalendarURL(tt.input)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("normalizeCalendarURL(%q) = %q, want an error", tt.input, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("normalizeCalendarURL(%q) = %q, %v, want %q", tt.input, got, err, tt.want)
+		}
+	}
+}
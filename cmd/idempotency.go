@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/georgifotev1/bms/internal/store"
+)
+
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
+	maxIdempotencyKeyLen = 255
+	// idempotencyLockTimeout is how long a key stays claimed by a request
+	// that never finished, e.g. because the server stopped
+	idempotencyLockTimeout = 2 * time.Minute
+)
+
+var (
+	ErrIdempotencyKeyReused     = errors.New("the Idempotency-Key was already used for a different request")
+	ErrIdempotencyKeyInProgress = errors.New("a request with this Idempotency-Key is still being processed")
+)
+
+// idempotencyRecorder keeps a copy of the response so it can be replayed
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *idempotencyRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *idempotencyRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// IdempotencyMiddleware makes a request safe to retry when it carries an
+// Idempotency-Key header. The first response for the key is stored and
+// replayed for retries with the same body, reusing the key for another body
+// is rejected. Server errors are not stored so the request can be retried.
+// Must run after the auth middleware so keys are scoped to the caller.
+func (app *application) IdempotencyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLen {
+			app.badRequestResponse(w, r, fmt.Errorf("%s must be at most %d characters", idempotencyKeyHeader, maxIdempotencyKeyLen))
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1_048_578))
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := r.Context()
+		fingerprint := requestFingerprint(r.Method, r.URL.Path, body)
+		scope := idempotencyScope(ctx, fingerprint)
+
+		now := time.Now()
+		claimID, err := app.store.ClaimIdempotencyKey(ctx, store.ClaimIdempotencyKeyParams{
+			Scope:           scope,
+			IdempotencyKey:  key,
+			Method:          r.Method,
+			Path:            r.URL.Path,
+			Fingerprint:     fingerprint,
+			ExpiresAt:       now.Add(app.config.idempotency.retention),
+			AbandonedBefore: now.Add(-idempotencyLockTimeout),
+		})
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				app.replayIdempotentResponse(w, r, scope, key, fingerprint)
+				return
+			}
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		rec := &idempotencyRecorder{ResponseWriter: w}
+		defer func() {
+			// A panic or a server error leaves the key free for a retry. Cookies
+			// aren't stored so a response setting them can't be replayed either.
+			if rec.status == 0 || rec.status >= http.StatusInternalServerError || rec.status == http.StatusTooManyRequests ||
+				len(rec.Header().Values("Set-Cookie")) > 0 {
+				if err := app.store.ReleaseIdempotencyKey(context.WithoutCancel(ctx), claimID); err != nil {
+					app.logger.Errorw("failed to release idempotency key", "error", err)
+				}
+				return
+			}
+
+			err := app.store.CompleteIdempotencyKey(context.WithoutCancel(ctx), store.CompleteIdempotencyKeyParams{
+				ID:           claimID,
+				StatusCode:   sql.NullInt32{Int32: int32(rec.status), Valid: true},
+				ContentType:  rec.Header().Get("Content-Type"),
+				ResponseBody: rec.body.Bytes(),
+			})
+			if err != nil {
+				app.logger.Errorw("failed to store idempotent response", "error", err)
+			}
+		}()
+
+		next.ServeHTTP(rec, r)
+	})
+}
+
+func (app *application) replayIdempotentResponse(w http.ResponseWriter, r *http.Request, scope, key, fingerprint string) {
+	stored, err := app.store.GetIdempotencyKey(r.Context(), store.GetIdempotencyKeyParams{
+		Scope:          scope,
+		IdempotencyKey: key,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Released by a failed request between the claim and the read
+			app.conflictRespone(w, r, ErrIdempotencyKeyInProgress)
+			return
+		}
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if stored.Fingerprint != fingerprint {
+		app.logger.Warnw("idempotency key reused", "method", r.Method, "path", r.URL.Path, "error", ErrIdempotencyKeyReused.Error())
+		writeJSONError(w, http.StatusUnprocessableEntity, ErrIdempotencyKeyReused.Error())
+		return
+	}
+
+	if !stored.StatusCode.Valid {
+		app.conflictRespone(w, r, ErrIdempotencyKeyInProgress)
+		return
+	}
+
+	if stored.ContentType != "" {
+		w.Header().Set("Content-Type", stored.ContentType)
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(int(stored.StatusCode.Int32))
+	w.Write(stored.ResponseBody)
+}
+
+// idempotencyScope identifies the caller. Anonymous callers of a brand can't
+// be told apart, so their keys are scoped to the request as well and a key
+// only replays the response to the very same request.
+func idempotencyScope(ctx context.Context, fingerprint string) string {
+	actor, _ := store.ActorFromContext(ctx)
+	if actor.Type == "" || actor.Type == store.ActorAnonymous {
+		brandID, _ := getBrandIDFromCtx(ctx)
+		return fmt.Sprintf("%s:%d:%s", store.ActorAnonymous, brandID, fingerprint)
+	}
+	return fmt.Sprintf("%s:%d", actor.Type, actor.ID)
+}
+
+func requestFingerprint(method, path string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method + " " + path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// purgeIdempotencyKeys deletes the stored responses past the retention
+func (app *application) purgeIdempotencyKeys(ctx context.Context) error {
+	count, err := app.store.DeleteExpiredIdempotencyKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		app.logger.Infow("purged idempotency keys", "count", count)
+	}
+	return nil
+}
@@ -0,0 +1,754 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/georgifotev1/bms/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	importKindCustomers = "customers"
+	importKindEvents    = "events"
+
+	importJobCompleted = "completed"
+	importJobFailed    = "failed"
+
+	maxImportFileSize = 20 << 20
+	maxImportRows     = 50000
+	// Imports up to this many rows are processed within the request
+	importSyncRows = 500
+	// Number of rows written in a single transaction
+	importBatchSize = 200
+	// Number of recent import jobs returned by the list endpoint
+	importJobsLimit = 50
+)
+
+var (
+	customerImportFields = []string{"name", "email", "phone", "tags"}
+	eventImportFields    = []string{"customerName", "customerEmail", "customerPhone", "service", "staff", "startTime", "endTime", "comment"}
+
+	// Layouts accepted for event times. Times without an offset are in the brand location.
+	importTimeLayouts = []string{"2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02T15:04:05"}
+
+	ErrImportJobNotFound = errors.New("import not found")
+)
+
+type ImportJobResponse struct {
+	ID            int64                   `json:"id"`
+	Kind          string                  `json:"kind"`
+	FileName      string                  `json:"fileName"`
+	DryRun        bool                    `json:"dryRun"`
+	Status        string                  `json:"status"`
+	TotalRows     int32                   `json:"totalRows"`
+	ProcessedRows int32                   `json:"processedRows"`
+	CreatedRows   int32                   `json:"createdRows"`
+	DuplicateRows int32                   `json:"duplicateRows"`
+	InvalidRows   int32                   `json:"invalidRows"`
+	Report        []store.ImportRowResult `json:"report,omitempty"`
+	Error         string                  `json:"error,omitempty"`
+	CreatedAt     time.Time               `json:"createdAt"`
+	FinishedAt    *time.Time              `json:"finishedAt,omitempty"`
+}
+
+// importRecord is a row of the uploaded file keyed by import field
+type importRecord map[string]string
+
+type importRequest struct {
+	user     *store.User
+	fileName string
+	dryRun   bool
+	records  []importRecord
+}
+
+type importBatchFunc func(ctx context.Context, start, end int) ([]store.ImportRowResult, error)
+
+// @Summary		Import customers
+// @Description	Imports customers from a CSV file with a header row or a JSON array of objects. Columns are matched to the fields name, email, phone and tags by name unless a mapping is given. Customers matching an existing customer by phone or email are reported as duplicates. Files with more than 500 rows are processed in the background.
+// @Tags			imports
+// @Accept			mpfd
+// @Produce		json
+// @Param			file	formData	file	true	"CSV or JSON file"
+// @Param			format	formData	string	false	"csv or json, detected from the file extension by default"
+// @Param			mapping	formData	string	false	"JSON object mapping fields to source columns, e.g. {&quot;name&quot;:&quot;Full name&quot;}"
+// @Param			dryRun	formData	bool	false	"Validate the file without importing"
+// @Success		201		{object}	ImportJobResponse	"import finished"
+// @Success		202		{object}	ImportJobResponse	"import running in the background"
+// @Failure		400		{object}	error
+// @Failure		403		{object}	error
+// @Failure		500		{object}	error
+// @Security		CookieAuth
+// @Router			/imports/customers [post]
+func (app *application) importCustomersHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := app.readImportFile(w, r, customerImportFields)
+	if !ok {
+		return
+	}
+
+	brandID := req.user.BrandID.Int32
+	report := make([]store.ImportRowResult, 0, len(req.records))
+	var rows []store.ImportCustomerRow
+	seen := make(map[string]int)
+
+	for i, record := range req.records {
+		rowNumber := i + 1
+		row, errs := validateImportedCustomer(rowNumber, record)
+		if len(errs) == 0 {
+			errs = checkImportDuplicate(seen, rowNumber, row.PhoneE164, row.Email)
+		}
+		if len(errs) > 0 {
+			report = append(report, store.ImportRowResult{Row: rowNumber, Status: store.ImportStatusInvalid, Errors: errs})
+			continue
+		}
+		rows = append(rows, row)
+	}
+
+	app.startImport(w, r, req, importKindCustomers, report, len(rows), func(ctx context.Context, start, end int) ([]store.ImportRowResult, error) {
+		return app.store.ImportCustomersTx(ctx, store.ImportCustomersTxParams{
+			BrandID: brandID,
+			DryRun:  req.dryRun,
+			Rows:    rows[start:end],
+		})
+	})
+}
+
+// @Summary		Import events
+// @Description	Imports appointments from a CSV file with a header row or a JSON array of objects. Columns are matched to the fields customerName, customerEmail, customerPhone, service, staff, startTime, endTime and comment by name unless a mapping is given. Services are matched by title or ID and staff by email or name. Customers are matched by phone or email and created when missing. Files with more than 500 rows are processed in the background.
+// @Tags			imports
+// @Accept			mpfd
+// @Produce		json
+// @Param			file	formData	file	true	"CSV or JSON file"
+// @Param			format	formData	string	false	"csv or json, detected from the file extension by default"
+// @Param			mapping	formData	string	false	"JSON object mapping fields to source columns, e.g. {&quot;startTime&quot;:&quot;Date&quot;}"
+// @Param			dryRun	formData	bool	false	"Validate the file without importing"
+// @Success		201		{object}	ImportJobResponse	"import finished"
+// @Success		202		{object}	ImportJobResponse	"import running in the background"
+// @Failure		400		{object}	error
+// @Failure		403		{object}	error
+// @Failure		500		{object}	error
+// @Security		CookieAuth
+// @Router			/imports/events [post]
+func (app *application) importEventsHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := app.readImportFile(w, r, eventImportFields)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	brandID := req.user.BrandID.Int32
+
+	services, err := app.store.ListServicesWithProviders(ctx, brandID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	users, err := app.store.GetUsersByBrand(ctx, req.user.BrandID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	location, err := time.LoadLocation(LOCATION_FORMAT)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	report := make([]store.ImportRowResult, 0, len(req.records))
+	var rows []store.ImportEventRow
+
+	for i, record := range req.records {
+		rowNumber := i + 1
+		row, errs := validateImportedEvent(rowNumber, record, services, users, location)
+		if len(errs) > 0 {
+			report = append(report, store.ImportRowResult{Row: rowNumber, Status: store.ImportStatusInvalid, Errors: errs})
+			continue
+		}
+		rows = append(rows, row)
+	}
+
+	app.startImport(w, r, req, importKindEvents, report, len(rows), func(ctx context.Context, start, end int) ([]store.ImportRowResult, error) {
+		return app.store.ImportEventsTx(ctx, store.ImportEventsTxParams{
+			BrandID: brandID,
+			DryRun:  req.dryRun,
+			Rows:    rows[start:end],
+		})
+	})
+}
+
+// @Summary		List imports
+// @Description	Fetches the most recent imports of the brand without their row reports
+// @Tags			imports
+// @Produce		json
+// @Success		200	{array}		ImportJobResponse
+// @Failure		403	{object}	error
+// @Failure		500	{object}	error
+// @Security		CookieAuth
+// @Router			/imports [get]
+func (app *application) getImportJobsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxUser, err := getUserFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if ctxUser.Role == userRole || !ctxUser.BrandID.Valid {
+		app.forbiddenResponse(w, r, ErrAccessDenied)
+		return
+	}
+
+	jobs, err := app.store.ListImportJobs(ctx, store.ListImportJobsParams{
+		BrandID: ctxUser.BrandID.Int32,
+		Limit:   importJobsLimit,
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	result := []ImportJobResponse{}
+	for _, job := range jobs {
+		response := importJobResponseMapper(job)
+		response.Report = nil
+		result = append(result, response)
+	}
+
+	if err := writeJSON(w, http.StatusOK, result); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// @Summary		Get an import
+// @Description	Fetches the progress of an import together with the validation report of every processed row
+// @Tags			imports
+// @Produce		json
+// @Param			importId	path		int	true	"Import ID"
+// @Success		200			{object}	ImportJobResponse
+// @Failure		400			{object}	error
+// @Failure		403			{object}	error
+// @Failure		404			{object}	error
+// @Failure		500			{object}	error
+// @Security		CookieAuth
+// @Router			/imports/{importId} [get]
+func (app *application) getImportJobHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxUser, err := getUserFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if ctxUser.Role == userRole || !ctxUser.BrandID.Valid {
+		app.forbiddenResponse(w, r, ErrAccessDenied)
+		return
+	}
+
+	jobID, err := strconv.ParseInt(chi.URLParam(r, "importId"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	job, err := app.store.GetImportJob(ctx, jobID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			app.notFoundResponse(w, r, ErrImportJobNotFound)
+			return
+		}
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if job.BrandID != ctxUser.BrandID.Int32 {
+		app.notFoundResponse(w, r, ErrImportJobNotFound)
+		return
+	}
+
+	if err := writeJSON(w, http.StatusOK, importJobResponseMapper(job)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// readImportFile authorizes the request and parses the uploaded file into
+// records keyed by import field. It writes the error response itself and
+// reports whether the handler may continue.
+func (app *application) readImportFile(w http.ResponseWriter, r *http.Request, fields []string) (*importRequest, bool) {
+	ctxUser, err := getUserFromCtx(r.Context())
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return nil, false
+	}
+
+	if ctxUser.Role == userRole || !ctxUser.BrandID.Valid {
+		app.forbiddenResponse(w, r, ErrAccessDenied)
+		return nil, false
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportFileSize)
+	if err := r.ParseMultipartForm(maxImportFileSize); err != nil {
+		app.badRequestResponse(w, r, err)
+		return nil, false
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		app.badRequestResponse(w, r, errors.New("file is required"))
+		return nil, false
+	}
+	defer file.Close()
+
+	mapping := map[string]string{}
+	if value := r.FormValue("mapping"); value != "" {
+		if err := json.Unmarshal([]byte(value), &mapping); err != nil {
+			app.badRequestResponse(w, r, errors.New("mapping must be a JSON object of field names to column names"))
+			return nil, false
+		}
+		for field := range mapping {
+			if !slices.Contains(fields, field) {
+				app.badRequestResponse(w, r, fmt.Errorf("unknown field %q, expected one of %s", field, strings.Join(fields, ", ")))
+				return nil, false
+			}
+		}
+	}
+
+	format := strings.ToLower(r.FormValue("format"))
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), ".")
+	}
+
+	var rows []map[string]string
+	switch format {
+	case "csv":
+		rows, err = parseImportCSV(file)
+	case "json":
+		rows, err = parseImportJSON(file)
+	default:
+		err = errors.New("format must be csv or json")
+	}
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return nil, false
+	}
+
+	if len(rows) == 0 {
+		app.badRequestResponse(w, r, errors.New("the file has no rows"))
+		return nil, false
+	}
+
+	if len(rows) > maxImportRows {
+		app.badRequestResponse(w, r, fmt.Errorf("the file has more than %d rows", maxImportRows))
+		return nil, false
+	}
+
+	records := make([]importRecord, 0, len(rows))
+	for _, row := range rows {
+		record := importRecord{}
+		for _, field := range fields {
+			column, ok := mapping[field]
+			if !ok {
+				column = field
+			}
+			record[field] = strings.TrimSpace(row[strings.ToLower(strings.TrimSpace(column))])
+		}
+		records = append(records, record)
+	}
+
+	return &importRequest{
+		user:     ctxUser,
+		fileName: header.Filename,
+		dryRun:   r.FormValue("dryRun") == "true",
+		records:  records,
+	}, true
+}
+
+// startImport records the import job and writes the valid rows in batches.
+// Small files are imported within the request, larger ones in the background.
+func (app *application) startImport(w http.ResponseWriter, r *http.Request, req *importRequest, kind string, report []store.ImportRowResult, validRows int, process importBatchFunc) {
+	ctx := r.Context()
+	brandID := req.user.BrandID.Int32
+	totalRows := len(req.records)
+
+	job, err := app.store.CreateImportJob(ctx, store.CreateImportJobParams{
+		BrandID: brandID,
+		CreatedBy: sql.NullInt64{
+			Int64: req.user.ID,
+			Valid: true,
+		},
+		Kind:      kind,
+		FileName:  req.fileName,
+		DryRun:    req.dryRun,
+		TotalRows: int32(totalRows),
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if totalRows > importSyncRows {
+		app.background(func() {
+			ctx := store.WithBrandID(context.Background(), brandID)
+			if _, err := app.runImport(ctx, job.ID, report, validRows, process); err != nil {
+				app.logger.Errorw("import failed", "import", job.ID, "error", err.Error())
+			}
+		})
+
+		if err := writeJSON(w, http.StatusAccepted, importJobResponseMapper(job)); err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	job, err = app.runImport(ctx, job.ID, report, validRows, process)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := writeJSON(w, http.StatusCreated, importJobResponseMapper(job)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// runImport processes the valid rows in batches, recording the progress and the
+// row report after every batch. A failing batch stops the import and marks the
+// job as failed, batches written before it are kept.
+func (app *application) runImport(ctx context.Context, jobID int64, report []store.ImportRowResult, validRows int, process importBatchFunc) (*store.ImportJob, error) {
+	fail := func(err error) (*store.ImportJob, error) {
+		job, finishErr := app.store.FinishImportJob(ctx, store.FinishImportJobParams{
+			ID:     jobID,
+			Status: importJobFailed,
+			Error:  sql.NullString{String: err.Error(), Valid: true},
+		})
+		if finishErr != nil {
+			return nil, finishErr
+		}
+		return job, err
+	}
+
+	for start := 0; ; start += importBatchSize {
+		end := min(start+importBatchSize, validRows)
+
+		if end > start {
+			results, err := process(ctx, start, end)
+			if err != nil {
+				return fail(err)
+			}
+			report = append(report, results...)
+		}
+
+		slices.SortFunc(report, func(a, b store.ImportRowResult) int {
+			return a.Row - b.Row
+		})
+
+		data, err := json.Marshal(report)
+		if err != nil {
+			return fail(err)
+		}
+
+		var created, duplicates, invalid int32
+		for _, result := range report {
+			switch result.Status {
+			case store.ImportStatusCreated, store.ImportStatusValid:
+				created++
+			case store.ImportStatusDuplicate:
+				duplicates++
+			case store.ImportStatusInvalid:
+				invalid++
+			}
+		}
+
+		if _, err := app.store.UpdateImportJobProgress(ctx, store.UpdateImportJobProgressParams{
+			ID:            jobID,
+			ProcessedRows: int32(len(report)),
+			CreatedRows:   created,
+			DuplicateRows: duplicates,
+			InvalidRows:   invalid,
+			Report:        data,
+		}); err != nil {
+			return fail(err)
+		}
+
+		if end >= validRows {
+			break
+		}
+	}
+
+	return app.store.FinishImportJob(ctx, store.FinishImportJobParams{
+		ID:     jobID,
+		Status: importJobCompleted,
+	})
+}
+
+// failInterruptedImports marks imports that stopped making progress as failed.
+// Their rows only live in memory, so they cannot resume after a restart.
+func (app *application) failInterruptedImports() {
+	ctx := store.WithPrivileges(context.Background())
+	count, err := app.store.FailStaleImportJobs(ctx)
+	if err != nil {
+		app.logger.Errorw("failing interrupted imports", "error", err.Error())
+		return
+	}
+	if count > 0 {
+		app.logger.Infow("marked interrupted imports as failed", "count", count)
+	}
+}
+
+func validateImportedCustomer(rowNumber int, record importRecord) (store.ImportCustomerRow, []string) {
+	var errs []string
+	row := store.ImportCustomerRow{
+		Row:         rowNumber,
+		Name:        record["name"],
+		Email:       normalizeEmail(record["email"]),
+		PhoneNumber: record["phone"],
+		PhoneE164:   normalizePhoneNumber(record["phone"]),
+	}
+
+	if row.Name == "" {
+		errs = append(errs, "name is required")
+	} else if len(row.Name) > 50 {
+		errs = append(errs, "name must be at most 50 characters")
+	}
+
+	if row.PhoneNumber == "" {
+		errs = append(errs, "phone is required")
+	} else if row.PhoneE164 == "" || len(row.PhoneNumber) > 20 {
+		errs = append(errs, "phone is not a valid phone number")
+	}
+
+	if row.Email != "" && Validate.Var(row.Email, "email") != nil {
+		errs = append(errs, "email is not a valid email address")
+	}
+
+	for _, tag := range strings.FieldsFunc(record["tags"], func(r rune) bool { return r == ';' || r == ',' }) {
+		if tag = normalizeTag(tag); tag != "" && !slices.Contains(row.Tags, tag) {
+			row.Tags = append(row.Tags, tag)
+		}
+	}
+
+	return row, errs
+}
+
+func validateImportedEvent(rowNumber int, record importRecord, services []*store.ListServicesWithProvidersRow, users []*store.User, location *time.Location) (store.ImportEventRow, []string) {
+	customer, errs := validateImportedCustomer(rowNumber, importRecord{
+		"name":  record["customerName"],
+		"email": record["customerEmail"],
+		"phone": record["customerPhone"],
+	})
+	for i, err := range errs {
+		errs[i] = "customer " + err
+	}
+
+	row := store.ImportEventRow{
+		Row:           rowNumber,
+		CustomerName:  customer.Name,
+		CustomerEmail: customer.Email,
+		CustomerPhone: customer.PhoneNumber,
+		CustomerE164:  customer.PhoneE164,
+		Comment:       record["comment"],
+	}
+
+	var service *store.ListServicesWithProvidersRow
+	if name := record["service"]; name == "" {
+		errs = append(errs, "service is required")
+	} else {
+		for _, s := range services {
+			if strings.EqualFold(s.Title, name) || s.ID.String() == name {
+				service = s
+				break
+			}
+		}
+		if service == nil {
+			errs = append(errs, fmt.Sprintf("service %q does not exist", name))
+		}
+	}
+
+	var user *store.User
+	if staff := record["staff"]; staff == "" {
+		errs = append(errs, "staff is required")
+	} else {
+		for _, u := range users {
+			if strings.EqualFold(u.Email, staff) || strings.EqualFold(u.Name, staff) {
+				user = u
+				break
+			}
+		}
+		if user == nil {
+			errs = append(errs, fmt.Sprintf("staff member %q does not exist", staff))
+		}
+	}
+
+	start, err := parseImportTime(record["startTime"], location)
+	if err != nil {
+		errs = append(errs, "startTime "+err.Error())
+	}
+
+	end := time.Time{}
+	if record["endTime"] != "" {
+		end, err = parseImportTime(record["endTime"], location)
+		if err != nil {
+			errs = append(errs, "endTime "+err.Error())
+		}
+	} else if service != nil {
+		end = start.Add(time.Duration(service.Duration) * time.Minute)
+	}
+
+	if len(errs) > 0 {
+		return row, errs
+	}
+
+	if !end.After(start) {
+		return row, []string{"endTime must be after startTime"}
+	}
+
+	row.ServiceID = service.ID
+	row.ServiceName = service.Title
+	row.Cost = service.Cost
+	row.BufferTime = service.BufferTime
+	row.UserID = user.ID
+	row.UserName = user.Name
+	row.StartTime = start.UTC()
+	row.EndTime = end.UTC()
+
+	return row, nil
+}
+
+// checkImportDuplicate reports rows repeating the phone number or email of an
+// earlier row of the same file.
+func checkImportDuplicate(seen map[string]int, rowNumber int, phone, email string) []string {
+	for _, key := range []string{"phone:" + phone, "email:" + email} {
+		if strings.HasSuffix(key, ":") {
+			continue
+		}
+		if previous, ok := seen[key]; ok {
+			return []string{fmt.Sprintf("duplicate of row %d", previous)}
+		}
+	}
+
+	seen["phone:"+phone] = rowNumber
+	if email != "" {
+		seen["email:"+email] = rowNumber
+	}
+	return nil
+}
+
+func parseImportTime(value string, location *time.Location) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, errors.New("is required")
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	for _, layout := range importTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, location); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, errors.New("must be a date and time like 2025-05-19 14:30 or in RFC 3339 format")
+}
+
+// parseImportCSV reads a CSV file with a header row. Column names are
+// lowercased so that the mapping is case insensitive.
+func parseImportCSV(file io.Reader) ([]map[string]string, error) {
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading CSV header: %w", err)
+	}
+
+	for i, column := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+	}
+
+	var rows []map[string]string
+	for {
+		values, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading CSV: %w", err)
+		}
+
+		row := make(map[string]string, len(header))
+		for i, column := range header {
+			if i < len(values) {
+				row[column] = values[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// parseImportJSON reads a JSON array of flat objects. Numbers and booleans are
+// converted to text and arrays of scalars are joined with semicolons.
+func parseImportJSON(file io.Reader) ([]map[string]string, error) {
+	var objects []map[string]any
+	if err := json.NewDecoder(file).Decode(&objects); err != nil {
+		return nil, errors.New("the JSON file must contain an array of objects")
+	}
+
+	rows := make([]map[string]string, 0, len(objects))
+	for i, object := range objects {
+		row := make(map[string]string, len(object))
+		for key, value := range object {
+			text, err := importJSONValue(value)
+			if err != nil {
+				return nil, fmt.Errorf("row %d, %s: %w", i+1, key, err)
+			}
+			row[strings.ToLower(strings.TrimSpace(key))] = text
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func importJSONValue(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case []any:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			text, err := importJSONValue(item)
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, text)
+		}
+		return strings.Join(parts, ";"), nil
+	default:
+		return "", errors.New("nested objects are not supported")
+	}
+}
@@ -1,8 +1,12 @@
 package main
 
 import (
+	"encoding/json"
+
 	"github.com/cloudinary/cloudinary-go/v2/api/admin"
+	"github.com/georgifotev1/bms/internal/realtime"
 	"github.com/georgifotev1/bms/internal/store"
+	"github.com/google/uuid"
 )
 
 // Mappers
@@ -56,24 +60,25 @@ func brandResponseMapper(brand *store.Brand, links []*store.BrandSocialLink, hou
 	}
 
 	return store.BrandResponse{
-		ID:           brand.ID,
-		Name:         brand.Name,
-		PageUrl:      brand.PageUrl,
-		Description:  brand.Description.String,
-		Email:        brand.Email.String,
-		Phone:        brand.Phone.String,
-		Country:      brand.Country.String,
-		State:        brand.State.String,
-		ZipCode:      brand.ZipCode.String,
-		City:         brand.City.String,
-		Address:      brand.Address.String,
-		LogoUrl:      brand.LogoUrl.String,
-		BannerUrl:    brand.BannerUrl.String,
-		Currency:     brand.Currency.String,
-		CreatedAt:    brand.CreatedAt,
-		UpdatedAt:    brand.UpdatedAt,
-		SocialLinks:  socialLinks,
-		WorkingHours: workingHours,
+		ID:               brand.ID,
+		Name:             brand.Name,
+		PageUrl:          brand.PageUrl,
+		Description:      brand.Description.String,
+		Email:            brand.Email.String,
+		Phone:            brand.Phone.String,
+		Country:          brand.Country.String,
+		State:            brand.State.String,
+		ZipCode:          brand.ZipCode.String,
+		City:             brand.City.String,
+		Address:          brand.Address.String,
+		LogoUrl:          brand.LogoUrl.String,
+		BannerUrl:        brand.BannerUrl.String,
+		Currency:         brand.Currency.String,
+		RequireTwoFactor: brand.RequireTwoFactor,
+		CreatedAt:        brand.CreatedAt,
+		UpdatedAt:        brand.UpdatedAt,
+		SocialLinks:      socialLinks,
+		WorkingHours:     workingHours,
 	}
 }
 
@@ -101,6 +106,8 @@ func customerResponseMapper(customer *store.Customer) CustomerResponse {
 		Email:       customer.Email.String,
 		BrandId:     customer.BrandID,
 		PhoneNumber: customer.PhoneNumber,
+		Tags:        customer.Tags,
+		Verified:    customer.Verified,
 	}
 }
 
@@ -111,26 +118,52 @@ func customersResponseMapper(customer *store.Customer) CustomerResponse {
 		Email:       customer.Email.String,
 		BrandId:     customer.BrandID,
 		PhoneNumber: customer.PhoneNumber,
+		Tags:        customer.Tags,
+		Verified:    customer.Verified,
+	}
+}
+
+func duplicateCustomerResponseMapper(row *store.ListDuplicateCustomersRow) DuplicateCustomerResponse {
+	return DuplicateCustomerResponse{
+		Customer: CustomerResponse{
+			ID:          row.CustomerID,
+			Name:        row.CustomerName,
+			Email:       row.CustomerEmail.String,
+			PhoneNumber: row.CustomerPhoneNumber,
+		},
+		Duplicate: CustomerResponse{
+			ID:          row.DuplicateID,
+			Name:        row.DuplicateName,
+			Email:       row.DuplicateEmail.String,
+			PhoneNumber: row.DuplicatePhoneNumber,
+		},
+		PhoneMatch:     row.PhoneMatch,
+		EmailMatch:     row.EmailMatch,
+		NameSimilarity: row.NameSimilarity,
 	}
 }
 
 func eventResponseMapper(event *store.Event) EventResponse {
 	return EventResponse{
-		ID:           event.ID,
-		CustomerID:   event.CustomerID,
-		ServiceID:    event.ServiceID,
-		UserID:       event.UserID,
-		BrandID:      event.BrandID,
-		StartTime:    event.StartTime,
-		EndTime:      event.EndTime,
-		CustomerName: event.CustomerName,
-		UserName:     event.UserName,
-		ServiceName:  event.ServiceName,
-		BufferTime:   event.BufferTime.Int32,
-		Cost:         event.Cost.String,
-		Comment:      event.Comment.String,
-		CreatedAt:    event.CreatedAt,
-		UpdatedAt:    event.UpdatedAt,
+		ID:            event.ID,
+		CustomerID:    event.CustomerID.Int64,
+		ServiceID:     event.ServiceID,
+		UserID:        event.UserID,
+		BrandID:       event.BrandID,
+		StartTime:     event.StartTime,
+		EndTime:       event.EndTime,
+		CustomerName:  event.CustomerName,
+		UserName:      event.UserName,
+		ServiceName:   event.ServiceName,
+		BufferTime:    event.BufferTime.Int32,
+		Cost:          event.Cost.String,
+		Comment:       event.Comment.String,
+		NoShow:        event.NoShow,
+		DependentID:   event.DependentID.Int64,
+		DependentName: event.DependentName.String,
+		Version:       event.Version,
+		CreatedAt:     event.CreatedAt,
+		UpdatedAt:     event.UpdatedAt,
 	}
 }
 
@@ -141,3 +174,374 @@ func brandImagesResponseMapper(i *admin.AssetsResult) []string {
 	}
 	return response
 }
+
+func customerNoteResponseMapper(note *store.CustomerNote) CustomerNoteResponse {
+	return CustomerNoteResponse{
+		ID:         note.ID,
+		CustomerID: note.CustomerID,
+		AuthorID:   note.AuthorID.Int64,
+		AuthorName: note.AuthorName,
+		Body:       note.Body,
+		Pinned:     note.Pinned,
+		CreatedAt:  note.CreatedAt,
+	}
+}
+
+func customerFieldResponseMapper(field *store.CustomerField) CustomerFieldResponse {
+	return CustomerFieldResponse{
+		ID:        field.ID,
+		Label:     field.Label,
+		FieldType: field.FieldType,
+		Options:   field.Options,
+	}
+}
+
+func customerFieldValueResponseMapper(row *store.ListCustomerFieldValuesRow) CustomerFieldValueResponse {
+	return CustomerFieldValueResponse{
+		FieldID:   row.FieldID,
+		Label:     row.Label,
+		FieldType: row.FieldType,
+		Value:     row.Value,
+	}
+}
+
+func customerListResponseMapper(row *store.ListCustomersRow) CustomerResponse {
+	response := CustomerResponse{
+		ID:          row.ID,
+		Name:        row.Name,
+		Email:       row.Email.String,
+		BrandId:     row.BrandID,
+		PhoneNumber: row.PhoneNumber,
+		Tags:        row.Tags,
+		Verified:    row.Verified,
+		NoShowCount: row.NoShowCount,
+		CreatedAt:   row.CreatedAt,
+		UpdatedAt:   row.UpdatedAt,
+	}
+	if row.LastVisit.Valid {
+		response.LastVisit = &row.LastVisit.Time
+	}
+	return response
+}
+
+func userListResponseMapper(row *store.ListUsersRow) UserResponse {
+	return UserResponse{
+		ID:        row.ID,
+		Name:      row.Name,
+		Email:     row.Email,
+		Avatar:    row.Avatar.String,
+		Verified:  row.Verified,
+		BrandId:   row.BrandID.Int32,
+		Role:      row.Role,
+		CreatedAt: row.CreatedAt,
+		UpdatedAt: row.UpdatedAt,
+	}
+}
+
+func serviceListResponseMapper(row *store.ListServicesRow, providers []int64) ServiceResponse {
+	if providers == nil {
+		providers = []int64{}
+	}
+	return ServiceResponse{
+		ID:          row.ID,
+		Title:       row.Title,
+		Description: row.Description.String,
+		Duration:    row.Duration,
+		BufferTime:  row.BufferTime.Int32,
+		Cost:        row.Cost.String,
+		IsVisible:   row.IsVisible,
+		ImageUrl:    row.ImageUrl.String,
+		BrandID:     row.BrandID,
+		Providers:   providers,
+		CreatedAt:   row.CreatedAt,
+		UpdatedAt:   row.UpdatedAt,
+	}
+}
+
+func eventListResponseMapper(row *store.ListEventsRow) EventResponse {
+	return EventResponse{
+		ID:            row.ID,
+		CustomerID:    row.CustomerID.Int64,
+		ServiceID:     row.ServiceID,
+		UserID:        row.UserID,
+		BrandID:       row.BrandID,
+		StartTime:     row.StartTime,
+		EndTime:       row.EndTime,
+		CustomerName:  row.CustomerName,
+		UserName:      row.UserName,
+		ServiceName:   row.ServiceName,
+		BufferTime:    row.BufferTime.Int32,
+		Cost:          row.Cost.String,
+		Comment:       row.Comment.String,
+		NoShow:        row.NoShow,
+		DependentID:   row.DependentID.Int64,
+		DependentName: row.DependentName.String,
+		Version:       row.Version,
+		CreatedAt:     row.CreatedAt,
+		UpdatedAt:     row.UpdatedAt,
+	}
+}
+
+func eventHistoryResponseMapper(entry *store.EventHistory) EventHistoryResponse {
+	response := EventHistoryResponse{
+		Version:   entry.Version,
+		Action:    entry.Action,
+		ActorType: entry.ActorType,
+		ActorName: entry.ActorName,
+		Changes:   entry.Changes,
+		CreatedAt: entry.CreatedAt,
+	}
+	if entry.ActorID.Valid {
+		response.ActorID = &entry.ActorID.Int64
+	}
+	return response
+}
+
+func importJobResponseMapper(job *store.ImportJob) ImportJobResponse {
+	response := ImportJobResponse{
+		ID:            job.ID,
+		Kind:          job.Kind,
+		FileName:      job.FileName,
+		DryRun:        job.DryRun,
+		Status:        job.Status,
+		TotalRows:     job.TotalRows,
+		ProcessedRows: job.ProcessedRows,
+		CreatedRows:   job.CreatedRows,
+		DuplicateRows: job.DuplicateRows,
+		InvalidRows:   job.InvalidRows,
+		Error:         job.Error.String,
+		CreatedAt:     job.CreatedAt,
+	}
+	// The report was written by the API itself, a malformed one is left out
+	_ = json.Unmarshal(job.Report, &response.Report)
+	if job.FinishedAt.Valid {
+		response.FinishedAt = &job.FinishedAt.Time
+	}
+	return response
+}
+
+func customerErasureResponseMapper(erasure *store.CustomerErasure) CustomerErasureResponse {
+	return CustomerErasureResponse{
+		CustomerID:       erasure.CustomerID,
+		EventsAnonymized: erasure.EventsAnonymized,
+		ErasedAt:         erasure.CreatedAt,
+	}
+}
+
+func customerConsentResponseMapper(consent *store.CustomerConsent) ConsentResponse {
+	return ConsentResponse{
+		Channel:   consent.Channel,
+		Purpose:   consent.Purpose,
+		Granted:   consent.Granted,
+		Source:    consent.Source,
+		UpdatedAt: &consent.UpdatedAt,
+	}
+}
+
+// consentResponseMapper lists every channel and purpose, falling back to the
+// default for the ones the customer has not stated a preference for
+func consentResponseMapper(consents []*store.CustomerConsent) []ConsentResponse {
+	recorded := make(map[string]*store.CustomerConsent, len(consents))
+	for _, consent := range consents {
+		recorded[consent.Channel+":"+consent.Purpose] = consent
+	}
+
+	response := make([]ConsentResponse, 0, len(consentChannels)*len(consentPurposes))
+	for _, channel := range consentChannels {
+		for _, purpose := range consentPurposes {
+			if consent, ok := recorded[channel+":"+purpose]; ok {
+				response = append(response, customerConsentResponseMapper(consent))
+				continue
+			}
+			response = append(response, ConsentResponse{
+				Channel: channel,
+				Purpose: purpose,
+				Granted: defaultConsent(purpose),
+			})
+		}
+	}
+	return response
+}
+
+func customerDependentResponseMapper(dependent *store.CustomerDependent) CustomerDependentResponse {
+	response := CustomerDependentResponse{
+		ID:         dependent.ID,
+		CustomerID: dependent.CustomerID,
+		Name:       dependent.Name,
+		Notes:      dependent.Notes.String,
+		CreatedAt:  dependent.CreatedAt,
+		UpdatedAt:  dependent.UpdatedAt,
+	}
+	if dependent.BirthDate.Valid {
+		response.BirthDate = dependent.BirthDate.Time.Format(dateLayout)
+	}
+	return response
+}
+
+func userSessionResponseMapper(session *store.UserSession, currentID uuid.UUID) SessionResponse {
+	return SessionResponse{
+		ID:         session.ID,
+		UserAgent:  session.UserAgent,
+		IPAddress:  session.IpAddress,
+		Current:    session.ID == currentID,
+		Remember:   session.Remember,
+		CreatedAt:  session.CreatedAt,
+		LastSeenAt: session.LastSeenAt,
+		ExpiresAt:  session.ExpiresAt,
+	}
+}
+
+func customerSessionResponseMapper(session *store.CustomerSession, currentID uuid.UUID) SessionResponse {
+	return SessionResponse{
+		ID:         session.ID,
+		UserAgent:  session.UserAgent,
+		IPAddress:  session.IpAddress,
+		Current:    session.ID == currentID,
+		Remember:   session.Remember,
+		CreatedAt:  session.CreatedAt,
+		LastSeenAt: session.LastSeenAt,
+		ExpiresAt:  session.ExpiresAt,
+	}
+}
+
+func apiKeyResponseMapper(key *store.ApiKey) APIKeyResponse {
+	response := APIKeyResponse{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.Scopes,
+		CreatedBy:  key.CreatedBy,
+		LastUsedIP: key.LastUsedIp.String,
+		CreatedAt:  key.CreatedAt,
+	}
+	if key.ExpiresAt.Valid {
+		response.ExpiresAt = &key.ExpiresAt.Time
+	}
+	if key.LastUsedAt.Valid {
+		response.LastUsedAt = &key.LastUsedAt.Time
+	}
+	if key.RevokedAt.Valid {
+		response.RevokedAt = &key.RevokedAt.Time
+	}
+	return response
+}
+
+func auditLogResponseMapper(entry *store.AuditLog) AuditLogResponse {
+	response := AuditLogResponse{
+		ID:         entry.ID,
+		ActorType:  entry.ActorType,
+		ActorName:  entry.ActorName,
+		Action:     entry.Action,
+		EntityType: entry.EntityType,
+		EntityID:   entry.EntityID,
+		Changes:    entry.Changes,
+		RequestID:  entry.RequestID,
+		IPAddress:  entry.IpAddress,
+		CreatedAt:  entry.CreatedAt,
+	}
+	if entry.ActorID.Valid {
+		response.ActorID = &entry.ActorID.Int64
+	}
+	if entry.ApiKeyID.Valid {
+		response.APIKeyID = &entry.ApiKeyID.Int64
+	}
+	return response
+}
+
+func calendarChangeMapper(change *store.CalendarChange) realtime.Change {
+	response := realtime.Change{
+		ID:        change.ID,
+		BrandID:   change.BrandID,
+		EventID:   change.EventID,
+		Kind:      change.Kind,
+		UserID:    change.UserID,
+		StartTime: change.StartTime,
+		EndTime:   change.EndTime,
+	}
+	if change.PreviousUserID.Valid {
+		response.PreviousUserID = &change.PreviousUserID.Int64
+	}
+	return response
+}
+
+func webhookResponseMapper(subscription *store.WebhookSubscription) WebhookResponse {
+	response := WebhookResponse{
+		ID:         subscription.ID,
+		URL:        subscription.Url,
+		EventTypes: subscription.EventTypes,
+		Active:     subscription.Active,
+		CreatedAt:  subscription.CreatedAt,
+		UpdatedAt:  subscription.UpdatedAt,
+	}
+	if subscription.CreatedBy.Valid {
+		response.CreatedBy = &subscription.CreatedBy.Int64
+	}
+	return response
+}
+
+func webhookDeliveryResponseMapper(delivery *store.WebhookDelivery) WebhookDeliveryResponse {
+	response := WebhookDeliveryResponse{
+		ID:        delivery.ID,
+		EventType: delivery.EventType,
+		Payload:   delivery.Payload,
+		Status:    delivery.Status,
+		Attempts:  delivery.Attempts,
+		LastError: delivery.LastError,
+		CreatedAt: delivery.CreatedAt,
+	}
+	if delivery.Status == "pending" {
+		response.NextAttemptAt = &delivery.NextAttemptAt
+	}
+	if delivery.LastAttemptAt.Valid {
+		response.LastAttemptAt = &delivery.LastAttemptAt.Time
+	}
+	if delivery.LastStatusCode.Valid {
+		response.LastStatusCode = &delivery.LastStatusCode.Int32
+	}
+	if delivery.DeliveredAt.Valid {
+		response.DeliveredAt = &delivery.DeliveredAt.Time
+	}
+	if delivery.RedeliveryOf.Valid {
+		response.RedeliveryOf = &delivery.RedeliveryOf.Int64
+	}
+	return response
+}
+
+func calendarFeedResponseMapper(feed *store.CalendarFeed) CalendarFeedResponse {
+	response := CalendarFeedResponse{CreatedAt: feed.CreatedAt}
+	if feed.LastFetchedAt.Valid {
+		response.LastFetchedAt = &feed.LastFetchedAt.Time
+	}
+	return response
+}
+
+func externalCalendarResponseMapper(calendar *store.ExternalCalendar) ExternalCalendarResponse {
+	response := ExternalCalendarResponse{
+		ID:         calendar.ID,
+		Name:       calendar.Name,
+		URL:        calendar.Url.String,
+		Source:     "upload",
+		LastError:  calendar.LastError,
+		NextSyncAt: calendar.NextSyncAt,
+		CreatedAt:  calendar.CreatedAt,
+	}
+	if calendar.Url.Valid {
+		response.Source = "url"
+	}
+	if calendar.LastSyncedAt.Valid {
+		response.LastSyncedAt = &calendar.LastSyncedAt.Time
+	}
+	return response
+}
+
+func externalCalendarRowMapper(row *store.ListExternalCalendarsRow) ExternalCalendarResponse {
+	return externalCalendarResponseMapper(&store.ExternalCalendar{
+		ID:           row.ID,
+		Name:         row.Name,
+		Url:          row.Url,
+		LastSyncedAt: row.LastSyncedAt,
+		LastError:    row.LastError,
+		NextSyncAt:   row.NextSyncAt,
+		CreatedAt:    row.CreatedAt,
+	})
+}
@@ -12,6 +12,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/georgifotev1/bms/internal/store"
+	"github.com/go-chi/chi/middleware"
 	"github.com/google/uuid"
 )
 
@@ -68,6 +70,7 @@ func (app *application) AuthUserMiddleware(next http.Handler) http.Handler {
 
 		session, err := app.store.GetUserSessionById(ctx, sessionId)
 		if err != nil {
+			app.ClearCookie(w, SESSION_TOKEN)
 			app.unauthorizedErrorResponse(w, r, fmt.Errorf("session not found"))
 			return
 		}
@@ -84,7 +87,22 @@ func (app *application) AuthUserMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		if user.BrandID.Valid {
+			ctx = store.WithBrandID(ctx, user.BrandID.Int32)
+		}
+
+		if time.Since(session.LastSeenAt) > sessionTouchInterval {
+			if err := app.store.TouchUserSession(ctx, store.TouchUserSessionParams{
+				ExpiresAt: time.Now().UTC().Add(app.sessionExp(session.Remember)),
+				ID:        session.ID,
+			}); err != nil {
+				app.logger.Warnw("error updating session activity", "session", session.ID, "error", err)
+			}
+		}
+
+		ctx = withAuditActor(ctx, store.ActorUser, user.ID, user.Name)
 		ctx = context.WithValue(ctx, userCtx, user)
+		ctx = context.WithValue(ctx, userSessionCtx, session)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -107,6 +125,7 @@ func (app *application) AuthCustomerMiddleware(next http.Handler) http.Handler {
 
 		session, err := app.store.GetCustomerSessionById(ctx, sessionId)
 		if err != nil {
+			app.ClearCookie(w, CUSTOMER_SESSION_TOKEN)
 			app.unauthorizedErrorResponse(w, r, fmt.Errorf("session not found"))
 			return
 		}
@@ -123,7 +142,23 @@ func (app *application) AuthCustomerMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		ctx = context.WithValue(ctx, userCtx, customer)
+		if brandID, ok := store.BrandIDFromContext(ctx); ok && brandID != customer.BrandID {
+			app.unauthorizedErrorResponse(w, r, fmt.Errorf("session belongs to another brand"))
+			return
+		}
+
+		if time.Since(session.LastSeenAt) > sessionTouchInterval {
+			if err := app.store.TouchCustomerSession(ctx, store.TouchCustomerSessionParams{
+				ExpiresAt: time.Now().UTC().Add(app.sessionExp(session.Remember)),
+				ID:        session.ID,
+			}); err != nil {
+				app.logger.Warnw("error updating session activity", "session", session.ID, "error", err)
+			}
+		}
+
+		ctx = withAuditActor(ctx, store.ActorCustomer, customer.ID, customer.Name)
+		ctx = context.WithValue(ctx, customerIdCtx, customer)
+		ctx = context.WithValue(ctx, customerSessionCtx, session)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -141,6 +176,21 @@ func (app *application) RateLimiterMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// PasswordResetRateLimiterMiddleware limits the requests that send account
+// emails separately so they can't be used to flood a mailbox
+func (app *application) PasswordResetRateLimiterMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.config.passwordResetLimiter.Enabled {
+			if allow, retryAfter := app.passwordResetLimiter.Allow(r.RemoteAddr); !allow {
+				app.rateLimitExceededResponse(w, r, retryAfter.String())
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (app *application) BrandMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if app.config.env == "development" {
@@ -149,6 +199,7 @@ func (app *application) BrandMiddleware(next http.Handler) http.Handler {
 				id, err := strconv.ParseInt(brandIDHeader, 10, 32)
 				if err == nil {
 					ctx := context.WithValue(r.Context(), brandIDCtx, int32(id))
+					ctx = store.WithBrandID(ctx, int32(id))
 					next.ServeHTTP(w, r.WithContext(ctx))
 					return
 				}
@@ -188,6 +239,23 @@ func (app *application) BrandMiddleware(next http.Handler) http.Handler {
 		}
 
 		ctx := context.WithValue(r.Context(), brandIDCtx, id)
+		ctx = store.WithBrandID(ctx, id)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// timeoutUnlessStreaming cancels requests that take longer than timeout.
+// Event streams are left out, they stay open until the client leaves.
+func timeoutUnlessStreaming(timeout time.Duration) func(http.Handler) http.Handler {
+	withTimeout := middleware.Timeout(timeout)
+	return func(next http.Handler) http.Handler {
+		timed := withTimeout(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Accept") == "text/event-stream" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			timed.ServeHTTP(w, r)
+		})
+	}
+}
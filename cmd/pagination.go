@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// PageResponse is the envelope of every paginated list. NextCursor is empty on
+// the last page, otherwise it is passed back as the cursor query parameter to
+// fetch the following page.
+type PageResponse[T any] struct {
+	Data       []T    `json:"data"`
+	NextCursor string `json:"nextCursor"`
+}
+
+// pageCursor points at the last row of a page. Rows are ordered by the sort
+// key and then by ID, so the pair is unique and the order stays stable while
+// rows are inserted or deleted between requests.
+type pageCursor struct {
+	Sort string `json:"s"`
+	Key  string `json:"k"`
+	ID   string `json:"i"`
+}
+
+type pageParams struct {
+	Limit      int32
+	Sort       string
+	Descending bool
+	Cursor     *pageCursor
+}
+
+// parsePageParams reads the limit, sort and cursor query parameters shared by
+// all list endpoints. Sorting is given as a field name, prefixed with "-" for
+// descending order, and must be one of the allowed fields.
+func parsePageParams(r *http.Request, allowedSorts []string, defaultSort string) (pageParams, error) {
+	query := r.URL.Query()
+	params := pageParams{
+		Limit: defaultPageLimit,
+		Sort:  defaultSort,
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return params, fmt.Errorf("limit must be a number between 1 and %d", maxPageLimit)
+		}
+		params.Limit = int32(limit)
+	}
+
+	if value := query.Get("sort"); value != "" {
+		params.Descending = strings.HasPrefix(value, "-")
+		params.Sort = strings.TrimPrefix(value, "-")
+		if !slices.Contains(allowedSorts, params.Sort) {
+			return params, fmt.Errorf("sort must be one of %s", strings.Join(allowedSorts, ", "))
+		}
+	}
+
+	if value := query.Get("cursor"); value != "" {
+		cursor, err := decodeCursor(value)
+		if err != nil {
+			return params, err
+		}
+		if cursor.Sort != sortParam(params.Sort, params.Descending) {
+			return params, errors.New("cursor does not match the requested sort order")
+		}
+		params.Cursor = cursor
+	}
+
+	return params, nil
+}
+
+// fetchLimit is the number of rows requested from the store. One extra row
+// tells whether another page follows.
+func (p pageParams) fetchLimit() int32 {
+	return p.Limit + 1
+}
+
+func (p pageParams) cursorKey() string {
+	if p.Cursor == nil {
+		return ""
+	}
+	return p.Cursor.Key
+}
+
+func (p pageParams) cursorInt64() (int64, error) {
+	if p.Cursor == nil {
+		return 0, nil
+	}
+	id, err := strconv.ParseInt(p.Cursor.ID, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	return id, nil
+}
+
+// paginate trims the extra row fetched by fetchLimit and builds the cursor of
+// the next page from the last returned row.
+func paginate[R any, T any](p pageParams, rows []R, cursor func(R) (string, string), mapper func(R) T) PageResponse[T] {
+	page := PageResponse[T]{Data: []T{}}
+
+	hasMore := len(rows) > int(p.Limit)
+	if hasMore {
+		rows = rows[:p.Limit]
+	}
+
+	for _, row := range rows {
+		page.Data = append(page.Data, mapper(row))
+	}
+
+	if hasMore {
+		key, id := cursor(rows[len(rows)-1])
+		page.NextCursor = encodeCursor(pageCursor{
+			Sort: sortParam(p.Sort, p.Descending),
+			Key:  key,
+			ID:   id,
+		})
+	}
+
+	return page
+}
+
+func sortParam(sort string, descending bool) string {
+	if descending {
+		return "-" + sort
+	}
+	return sort
+}
+
+func encodeCursor(cursor pageCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(value string) (*pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor pageCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &cursor, nil
+}
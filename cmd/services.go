@@ -1,9 +1,9 @@
 package main
 
 import (
-	"database/sql"
 	"errors"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/georgifotev1/bms/internal/store"
@@ -201,59 +201,73 @@ func (app *application) handleServicesRetrieval(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	servicesWithProviders, err := app.store.ListServicesWithProviders(ctx, brandID)
+	page, err := parsePageParams(r, []string{"title", "createdAt"}, "title")
 	if err != nil {
-		switch err {
-		case sql.ErrNoRows:
-			if err := writeJSON(w, http.StatusOK, []ServiceResponse{}); err != nil {
-				app.internalServerError(w, r, err)
-			}
-		default:
-			app.internalServerError(w, r, err)
-		}
+		app.badRequestResponse(w, r, err)
 		return
 	}
 
-	serviceMap := make(map[uuid.UUID]*ServiceResponse)
-	for _, row := range servicesWithProviders {
-		serviceID := row.ID
-		if _, exists := serviceMap[serviceID]; !exists {
-			serviceMap[serviceID] = &ServiceResponse{
-				ID:          row.ID,
-				Title:       row.Title,
-				Description: row.Description.String,
-				Duration:    row.Duration,
-				BufferTime:  row.BufferTime.Int32,
-				Cost:        row.Cost.String,
-				IsVisible:   row.IsVisible,
-				ImageUrl:    row.ImageUrl.String,
-				BrandID:     row.BrandID,
-				CreatedAt:   row.CreatedAt,
-				UpdatedAt:   row.UpdatedAt,
-				Providers:   []int64{},
-			}
-		}
-		if row.ProviderID.Valid {
-			serviceMap[serviceID].Providers = append(serviceMap[serviceID].Providers, row.ProviderID.Int64)
+	var cursorID uuid.UUID
+	if page.Cursor != nil {
+		cursorID, err = uuid.Parse(page.Cursor.ID)
+		if err != nil {
+			app.badRequestResponse(w, r, ErrInvalidCursor)
+			return
 		}
 	}
 
-	result := make([]ServiceResponse, 0, len(serviceMap))
-	for _, service := range serviceMap {
-		result = append(result, *service)
+	services, err := app.store.ListServices(ctx, store.ListServicesParams{
+		BrandID:    brandID,
+		Sort:       page.Sort,
+		Search:     strings.TrimSpace(r.URL.Query().Get("search")),
+		HasCursor:  page.Cursor != nil,
+		Descending: page.Descending,
+		CursorKey:  page.cursorKey(),
+		CursorID:   cursorID,
+		PageLimit:  page.fetchLimit(),
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	serviceIDs := make([]uuid.UUID, 0, len(services))
+	for _, service := range services {
+		serviceIDs = append(serviceIDs, service.ID)
+	}
+
+	rows, err := app.store.ListServiceProviders(ctx, serviceIDs)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	providers := make(map[uuid.UUID][]int64)
+	for _, row := range rows {
+		providers[row.ServiceID] = append(providers[row.ServiceID], row.UserID)
 	}
 
+	result := paginate(page, services, func(row *store.ListServicesRow) (string, string) {
+		return row.SortKey, row.ID.String()
+	}, func(row *store.ListServicesRow) ServiceResponse {
+		return serviceListResponseMapper(row, providers[row.ID])
+	})
+
 	if err := writeJSON(w, http.StatusOK, result); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
 
 // @Summary		Get services by brand
-// @Description	Fetches all services of a brand
+// @Description	Fetches the services of a brand with their providers. Results are cursor paginated.
 // @Tags			service
 // @Accept			json
 // @Produce		json
-// @Success		200	{object}	[]ServiceResponse
+// @Param			search	query		string	false	"Title prefix. Titles also match by similarity"
+// @Param			sort	query		string	false	"title or createdAt, prefixed with - for descending order"	default(title)
+// @Param			limit	query		int		false	"Page size"	default(50)	maximum(100)
+// @Param			cursor	query		string	false	"Cursor of the next page"
+// @Success		200		{object}	PageResponse[ServiceResponse]
 // @Failure		400	{object}	error
 // @Failure		404	{object}	error
 // @Failure		500	{object}	error
@@ -269,11 +283,15 @@ func (app *application) getServicesHandler(w http.ResponseWriter, r *http.Reques
 }
 
 // @Summary		Get services by brand (public)
-// @Description	Fetches all services of a brand for public access
+// @Description	Fetches the services of a brand with their providers for public access. Results are cursor paginated.
 // @Tags			service
 // @Accept			json
 // @Produce		json
-// @Success		200	{object}	[]ServiceResponse
+// @Param			search	query		string	false	"Title prefix. Titles also match by similarity"
+// @Param			sort	query		string	false	"title or createdAt, prefixed with - for descending order"	default(title)
+// @Param			limit	query		int		false	"Page size"	default(50)	maximum(100)
+// @Param			cursor	query		string	false	"Cursor of the next page"
+// @Success		200		{object}	PageResponse[ServiceResponse]
 // @Failure		400	{object}	error
 // @Failure		404	{object}	error
 // @Failure		500	{object}	error
@@ -0,0 +1,427 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/georgifotev1/bms/internal/store"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// sessionTouchInterval is how often the last seen time of a session is
+// written, so busy sessions don't cause a write on every request
+const sessionTouchInterval = time.Minute
+
+var ErrSessionNotFound = errors.New("session not found")
+
+type SessionResponse struct {
+	ID         uuid.UUID `json:"id"`
+	UserAgent  string    `json:"userAgent"`
+	IPAddress  string    `json:"ipAddress"`
+	Current    bool      `json:"current"`
+	Remember   bool      `json:"remember"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+type RevokedSessionsResponse struct {
+	Revoked int64 `json:"revoked"`
+}
+
+// @Summary		List my sessions
+// @Description	Lists the active sessions of the signed in user across devices
+// @Tags			users
+// @Produce		json
+// @Success		200	{array}		SessionResponse
+// @Failure		401	{object}	error
+// @Failure		500	{object}	error
+// @Security		CookieAuth
+// @Router			/users/me/sessions [get]
+func (app *application) getMySessionsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	current, err := getUserSessionFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	sessions, err := app.store.ListUserSessions(ctx, current.UserID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	now := time.Now()
+	response := []SessionResponse{}
+	for _, session := range sessions {
+		if now.Before(session.ExpiresAt) {
+			response = append(response, userSessionResponseMapper(session, current.ID))
+		}
+	}
+
+	if err := writeJSON(w, http.StatusOK, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// @Summary		Revoke my session
+// @Description	Signs out one session of the signed in user. Revoking the current session logs the user out
+// @Tags			users
+// @Param			sessionId	path	string	true	"Session ID"
+// @Success		204
+// @Failure		400	{object}	error
+// @Failure		401	{object}	error
+// @Failure		404	{object}	error
+// @Failure		500	{object}	error
+// @Security		CookieAuth
+// @Router			/users/me/sessions/{sessionId} [delete]
+func (app *application) revokeMySessionHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	current, err := getUserSessionFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	sessionID, err := uuid.Parse(chi.URLParam(r, "sessionId"))
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	revoked, err := app.store.RevokeUserSession(ctx, store.RevokeUserSessionParams{
+		ID:     sessionID,
+		UserID: current.UserID,
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if revoked == 0 {
+		app.notFoundResponse(w, r, ErrSessionNotFound)
+		return
+	}
+
+	if sessionID == current.ID {
+		app.ClearCookie(w, SESSION_TOKEN)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary		Revoke my other sessions
+// @Description	Signs out every session of the signed in user except the current one
+// @Tags			users
+// @Produce		json
+// @Success		200	{object}	RevokedSessionsResponse
+// @Failure		401	{object}	error
+// @Failure		500	{object}	error
+// @Security		CookieAuth
+// @Router			/users/me/sessions [delete]
+func (app *application) revokeMyOtherSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	current, err := getUserSessionFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	revoked, err := app.store.RevokeOtherUserSessions(ctx, store.RevokeOtherUserSessionsParams{
+		UserID: current.UserID,
+		ID:     current.ID,
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := writeJSON(w, http.StatusOK, RevokedSessionsResponse{Revoked: revoked}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// @Summary		List my customer sessions
+// @Description	Lists the active sessions of the signed in customer across devices
+// @Tags			customers
+// @Produce		json
+// @Param			X-Brand-ID	header		string	false	"Brand ID header for development. In production this header is ignored"	default(1)
+// @Success		200			{array}		SessionResponse
+// @Failure		401			{object}	error
+// @Failure		500			{object}	error
+// @Router			/customers/me/sessions [get]
+func (app *application) getMyCustomerSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	current, err := getCustomerSessionFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	sessions, err := app.store.ListCustomerSessions(ctx, current.CustomerID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	now := time.Now()
+	response := []SessionResponse{}
+	for _, session := range sessions {
+		if now.Before(session.ExpiresAt) {
+			response = append(response, customerSessionResponseMapper(session, current.ID))
+		}
+	}
+
+	if err := writeJSON(w, http.StatusOK, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// @Summary		Revoke my customer session
+// @Description	Signs out one session of the signed in customer. Revoking the current session logs the customer out
+// @Tags			customers
+// @Param			sessionId	path	string	true	"Session ID"
+// @Param			X-Brand-ID	header	string	false	"Brand ID header for development. In production this header is ignored"	default(1)
+// @Success		204
+// @Failure		400	{object}	error
+// @Failure		401	{object}	error
+// @Failure		404	{object}	error
+// @Failure		500	{object}	error
+// @Router			/customers/me/sessions/{sessionId} [delete]
+func (app *application) revokeMyCustomerSessionHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	current, err := getCustomerSessionFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	sessionID, err := uuid.Parse(chi.URLParam(r, "sessionId"))
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	revoked, err := app.store.RevokeCustomerSession(ctx, store.RevokeCustomerSessionParams{
+		ID:         sessionID,
+		CustomerID: current.CustomerID,
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if revoked == 0 {
+		app.notFoundResponse(w, r, ErrSessionNotFound)
+		return
+	}
+
+	if sessionID == current.ID {
+		app.ClearCookie(w, CUSTOMER_SESSION_TOKEN)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary		Revoke my other customer sessions
+// @Description	Signs out every session of the signed in customer except the current one
+// @Tags			customers
+// @Produce		json
+// @Param			X-Brand-ID	header		string	false	"Brand ID header for development. In production this header is ignored"	default(1)
+// @Success		200			{object}	RevokedSessionsResponse
+// @Failure		401			{object}	error
+// @Failure		500			{object}	error
+// @Router			/customers/me/sessions [delete]
+func (app *application) revokeMyOtherCustomerSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	current, err := getCustomerSessionFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	revoked, err := app.store.RevokeOtherCustomerSessions(ctx, store.RevokeOtherCustomerSessionsParams{
+		CustomerID: current.CustomerID,
+		ID:         current.ID,
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := writeJSON(w, http.StatusOK, RevokedSessionsResponse{Revoked: revoked}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) userSessionParams(r *http.Request, userID int64, remember bool) store.CreateUserSessionParams {
+	now := time.Now().UTC()
+	return store.CreateUserSessionParams{
+		UserID:            userID,
+		ExpiresAt:         now.Add(app.sessionExp(remember)),
+		AbsoluteExpiresAt: now.Add(app.sessionMaxAge(remember)),
+		Remember:          remember,
+		UserAgent:         r.UserAgent(),
+		IpAddress:         clientIP(r),
+	}
+}
+
+func (app *application) customerSessionParams(r *http.Request, customerID int64, remember bool) store.CreateCustomerSessionParams {
+	now := time.Now().UTC()
+	return store.CreateCustomerSessionParams{
+		CustomerID:        customerID,
+		ExpiresAt:         now.Add(app.sessionExp(remember)),
+		AbsoluteExpiresAt: now.Add(app.sessionMaxAge(remember)),
+		Remember:          remember,
+		UserAgent:         r.UserAgent(),
+		IpAddress:         clientIP(r),
+	}
+}
+
+// sessionExp is how long a session lasts without activity
+func (app *application) sessionExp(remember bool) time.Duration {
+	if remember {
+		return app.config.auth.session.rememberExp
+	}
+	return app.config.auth.session.exp
+}
+
+// sessionMaxAge is how long a session lasts however active it is
+func (app *application) sessionMaxAge(remember bool) time.Duration {
+	if remember {
+		return app.config.auth.session.rememberMaxAge
+	}
+	return app.config.auth.session.maxAge
+}
+
+// setUserSessionCookie keeps remembered sessions across browser restarts.
+// Other sessions get a cookie that is dropped when the browser closes.
+func (app *application) setUserSessionCookie(w http.ResponseWriter, session *store.UserSession) {
+	var expires time.Time
+	if session.Remember {
+		expires = session.AbsoluteExpiresAt
+	}
+	app.SetCookie(w, SESSION_TOKEN, session.ID.String(), expires)
+}
+
+func (app *application) setCustomerSessionCookie(w http.ResponseWriter, session *store.CustomerSession) {
+	var expires time.Time
+	if session.Remember {
+		expires = session.AbsoluteExpiresAt
+	}
+	app.SetCookie(w, CUSTOMER_SESSION_TOKEN, session.ID.String(), expires)
+}
+
+// rotateUserSession gives the current session a new ID after the privileges
+// of the user changed
+func (app *application) rotateUserSession(w http.ResponseWriter, r *http.Request) error {
+	current, err := getUserSessionFromCtx(r.Context())
+	if err != nil {
+		return err
+	}
+
+	session, err := app.store.RotateUserSessionTx(r.Context(), store.RotateSessionTxParams{
+		ID:        current.ID,
+		UserAgent: r.UserAgent(),
+		IpAddress: clientIP(r),
+	})
+	if err != nil {
+		return err
+	}
+
+	app.setUserSessionCookie(w, session)
+	return nil
+}
+
+func (app *application) rotateCustomerSession(w http.ResponseWriter, r *http.Request) error {
+	current, err := getCustomerSessionFromCtx(r.Context())
+	if err != nil {
+		return err
+	}
+
+	session, err := app.store.RotateCustomerSessionTx(r.Context(), store.RotateSessionTxParams{
+		ID:        current.ID,
+		UserAgent: r.UserAgent(),
+		IpAddress: clientIP(r),
+	})
+	if err != nil {
+		return err
+	}
+
+	app.setCustomerSessionCookie(w, session)
+	return nil
+}
+
+// revokeUserSessionFromCookie ends the session the request was made with, if
+// any, so a sign in never keeps using an ID that existed before it
+func (app *application) revokeUserSessionFromCookie(r *http.Request) {
+	cookie, err := r.Cookie(SESSION_TOKEN)
+	if err != nil {
+		return
+	}
+
+	sessionID, err := uuid.Parse(cookie.Value)
+	if err != nil {
+		return
+	}
+
+	if _, err := app.store.UpdateUserSession(r.Context(), store.UpdateUserSessionParams{
+		ID:        sessionID,
+		ExpiresAt: time.Unix(0, 0),
+	}); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		app.logger.Warnw("error revoking previous session", "session", sessionID, "error", err)
+	}
+}
+
+func (app *application) revokeCustomerSessionFromCookie(r *http.Request) {
+	cookie, err := r.Cookie(CUSTOMER_SESSION_TOKEN)
+	if err != nil {
+		return
+	}
+
+	sessionID, err := uuid.Parse(cookie.Value)
+	if err != nil {
+		return
+	}
+
+	if _, err := app.store.UpdateCustomerSession(r.Context(), store.UpdateCustomerSessionParams{
+		ID:        sessionID,
+		ExpiresAt: time.Unix(0, 0),
+	}); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		app.logger.Warnw("error revoking previous session", "session", sessionID, "error", err)
+	}
+}
+
+// purgeExpiredSessions deletes the sessions that can no longer be used
+func (app *application) purgeExpiredSessions(ctx context.Context) error {
+	users, err := app.store.DeleteExpiredUserSessions(ctx)
+	if err != nil {
+		return err
+	}
+
+	customers, err := app.store.DeleteExpiredCustomerSessions(ctx)
+	if err != nil {
+		return err
+	}
+
+	if users > 0 || customers > 0 {
+		app.logger.Infow("purged expired sessions", "users", users, "customers", customers)
+	}
+	return nil
+}
+
+// clientIP returns the address of the client. The RealIP middleware has
+// already replaced RemoteAddr with the forwarded address when there is one.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/georgifotev1/bms/internal/mailer"
+	"github.com/georgifotev1/bms/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	principalUser     = "user"
+	principalCustomer = "customer"
+)
+
+// Reasons recorded with sign in attempts
+const (
+	signInReasonUnknownAccount     = "unknown_account"
+	signInReasonWrongPassword      = "wrong_password"
+	signInReasonWrongCode          = "wrong_two_factor_code"
+	signInReasonBlocked            = "blocked"
+	signInReasonTwoFactorChallenge = "two_factor_challenge"
+)
+
+// signInAttempt is a sign in being checked against the lockout and recorded
+// for the audit trail
+type signInAttempt struct {
+	store.CreateSignInAttemptParams
+	// key identifies the account for the lockout, whether it exists or not
+	key string
+}
+
+func newUserSignInAttempt(r *http.Request, email string) *signInAttempt {
+	email = normalizeEmail(email)
+	return &signInAttempt{
+		CreateSignInAttemptParams: store.CreateSignInAttemptParams{
+			Principal: principalUser,
+			Email:     email,
+			IpAddress: clientIP(r),
+			UserAgent: r.UserAgent(),
+		},
+		key: userLockoutKey(email),
+	}
+}
+
+func newCustomerSignInAttempt(r *http.Request, brandID int32, email string) *signInAttempt {
+	email = normalizeEmail(email)
+	return &signInAttempt{
+		CreateSignInAttemptParams: store.CreateSignInAttemptParams{
+			Principal: principalCustomer,
+			BrandID:   sql.NullInt32{Int32: brandID, Valid: true},
+			Email:     email,
+			IpAddress: clientIP(r),
+			UserAgent: r.UserAgent(),
+		},
+		key: customerLockoutKey(brandID, email),
+	}
+}
+
+func userLockoutKey(email string) string {
+	return "user:" + normalizeEmail(email)
+}
+
+func customerLockoutKey(brandID int32, email string) string {
+	return fmt.Sprintf("customer:%d:%s", brandID, normalizeEmail(email))
+}
+
+func ipLockoutKey(ip string) string {
+	return "ip:" + ip
+}
+
+// signInBlocked writes a 429 response when the account or the IP address has
+// to wait before trying again
+func (app *application) signInBlocked(w http.ResponseWriter, r *http.Request, attempt *signInAttempt) bool {
+	wait := app.signInWait(r.Context(), attempt)
+	if wait == 0 {
+		return false
+	}
+
+	app.rateLimitExceededResponse(w, r, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	return true
+}
+
+// signInWait returns how long the account or the IP address has to wait
+// before trying again. A blocked attempt is recorded.
+func (app *application) signInWait(ctx context.Context, attempt *signInAttempt) time.Duration {
+	var wait time.Duration
+	for _, key := range []string{attempt.key, ipLockoutKey(attempt.IpAddress)} {
+		keyWait, err := app.signInGuard.Wait(ctx, key)
+		if err != nil {
+			// Signing in keeps working when the lockout store is down
+			app.logger.Errorw("error checking sign in lockout", "key", key, "error", err)
+			continue
+		}
+		wait = max(wait, keyWait)
+	}
+
+	if wait > 0 {
+		attempt.Reason = signInReasonBlocked
+		app.recordSignInAttempt(ctx, attempt)
+	}
+	return wait
+}
+
+// signInFailed counts the failure against the account and the IP address. It
+// returns true when the failure locked the account.
+func (app *application) signInFailed(ctx context.Context, attempt *signInAttempt, reason string) bool {
+	attempt.Reason = reason
+	app.recordSignInAttempt(ctx, attempt)
+
+	policies := app.config.auth.lockout
+	if _, err := app.signInGuard.Fail(ctx, ipLockoutKey(attempt.IpAddress), policies.ip); err != nil {
+		app.logger.Errorw("error recording sign in failure", "ip", attempt.IpAddress, "error", err)
+	}
+
+	locked, err := app.signInGuard.Fail(ctx, attempt.key, policies.account)
+	if err != nil {
+		app.logger.Errorw("error recording sign in failure", "key", attempt.key, "error", err)
+		return false
+	}
+
+	if locked {
+		app.logger.Warnw("account locked after failed sign ins", "key", attempt.key, "ip", attempt.IpAddress)
+	}
+	return locked
+}
+
+func (app *application) signInSucceeded(ctx context.Context, attempt *signInAttempt) {
+	attempt.Success = true
+	app.recordSignInAttempt(ctx, attempt)
+
+	if err := app.signInGuard.Reset(ctx, attempt.key); err != nil {
+		app.logger.Errorw("error resetting sign in failures", "key", attempt.key, "error", err)
+	}
+}
+
+// recordSignInAttempt writes the audit row. A failed write is logged and
+// doesn't fail the sign in.
+func (app *application) recordSignInAttempt(ctx context.Context, attempt *signInAttempt) {
+	if err := app.store.CreateSignInAttempt(ctx, attempt.CreateSignInAttemptParams); err != nil {
+		app.logger.Errorw("error recording sign in attempt", "key", attempt.key, "error", err)
+	}
+}
+
+type accountLockedVars struct {
+	Username  string
+	BrandName string
+	LockedFor string
+	IPAddress string
+}
+
+func (app *application) sendUserLockedEmail(user *store.User, ip string) {
+	vars := accountLockedVars{
+		Username:  user.Name,
+		BrandName: mailer.FromName,
+		LockedFor: expiryText(app.config.auth.lockout.account.LockDuration),
+		IPAddress: ip,
+	}
+	app.sendAccountEmail(context.Background(), mailer.AccountLockedTemplate, user.Name, user.Email, vars)
+}
+
+func (app *application) sendCustomerLockedEmail(ctx context.Context, customer *store.Customer, ip string) {
+	brand, err := app.store.GetBrandById(ctx, customer.BrandID)
+	if err != nil {
+		app.logger.Errorw("error sending account locked email", "customer", customer.ID, "error", err)
+		return
+	}
+
+	vars := accountLockedVars{
+		Username:  customer.Name,
+		BrandName: brand.Name,
+		LockedFor: expiryText(app.config.auth.lockout.account.LockDuration),
+		IPAddress: ip,
+	}
+
+	mailCtx := store.WithBrandID(context.Background(), customer.BrandID)
+	app.sendAccountEmail(mailCtx, mailer.AccountLockedTemplate, customer.Name, customer.Email.String, vars)
+}
+
+// @Summary		Unlock a user
+// @Description	Lifts the sign in lockout of a user of the brand and forgets their failed attempts
+// @Tags			users
+// @Param			id	path	int	true	"User ID"
+// @Success		204
+// @Failure		400	{object}	error
+// @Failure		403	{object}	error
+// @Failure		404	{object}	error
+// @Failure		500	{object}	error
+// @Security		CookieAuth
+// @Router			/users/{id}/unlock [post]
+func (app *application) unlockUserHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxUser, err := getUserFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if ctxUser.Role != ownerRole || !ctxUser.BrandID.Valid {
+		app.forbiddenResponse(w, r, ErrAccessDenied)
+		return
+	}
+
+	userID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user, err := app.store.GetUserById(ctx, userID)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			app.notFoundResponse(w, r, ErrUserNotFound)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if user.BrandID != ctxUser.BrandID {
+		app.notFoundResponse(w, r, ErrUserNotFound)
+		return
+	}
+
+	if err := app.signInGuard.Reset(ctx, userLockoutKey(user.Email)); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.logger.Infow("user unlocked", "user", user.ID, "by", ctxUser.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary		Unlock a customer
+// @Description	Lifts the sign in lockout of a customer and forgets their failed attempts
+// @Tags			customers
+// @Param			customerId	path	int	true	"Customer ID"
+// @Success		204
+// @Failure		400	{object}	error
+// @Failure		403	{object}	error
+// @Failure		404	{object}	error
+// @Failure		500	{object}	error
+// @Security		CookieAuth
+// @Router			/customers/{customerId}/unlock [post]
+func (app *application) unlockCustomerHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxUser, err := getUserFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if ctxUser.Role == userRole {
+		app.forbiddenResponse(w, r, ErrAccessDenied)
+		return
+	}
+
+	customer, ok := app.brandCustomerFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := app.signInGuard.Reset(ctx, customerLockoutKey(customer.BrandID, customer.Email.String)); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.logger.Infow("customer unlocked", "customer", customer.ID, "by", ctxUser.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// purgeSignInThrottles deletes the expired failure counters kept in Postgres
+func (app *application) purgeSignInThrottles(ctx context.Context) error {
+	count, err := app.store.DeleteExpiredSignInThrottles(ctx)
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		app.logger.Infow("purged expired sign in throttles", "count", count)
+	}
+	return nil
+}
@@ -0,0 +1,569 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/georgifotev1/bms/internal/oidc"
+	"github.com/georgifotev1/bms/internal/store"
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const signInReasonSso = "sso"
+
+// SSO_STATE_COOKIE binds a single sign-on to the browser that started it, so
+// a callback URL sent to someone else doesn't sign them in
+const SSO_STATE_COOKIE = "sso_state"
+
+// Error codes added to the login page URL when single sign-on fails
+const (
+	ssoErrorUnavailable     = "sso_unavailable"
+	ssoErrorDenied          = "access_denied"
+	ssoErrorInvalidState    = "invalid_state"
+	ssoErrorInvalidToken    = "invalid_token"
+	ssoErrorEmailNotAllowed = "email_not_allowed"
+	ssoErrorAccountConflict = "account_conflict"
+	ssoErrorLocked          = "account_locked"
+	ssoErrorServer          = "server_error"
+)
+
+var (
+	ErrSsoNotConfigured = errors.New("single sign-on is not set up for the brand")
+	ErrSsoInvalidIssuer = errors.New("the issuer could not be discovered")
+	ErrSsoSecretChanged = errors.New("a new clientSecret is required when the issuer or clientId changes")
+)
+
+type UpdateSsoPayload struct {
+	Issuer   string `json:"issuer" validate:"required,url,max=255"`
+	ClientID string `json:"clientId" validate:"required,max=255"`
+	// ClientSecret keeps the stored secret when empty, unless the issuer or
+	// client ID changes. Public clients that only use PKCE don't have one.
+	ClientSecret   string   `json:"clientSecret" validate:"max=1024"`
+	AllowedDomains []string `json:"allowedDomains" validate:"dive,required,fqdn"`
+	DefaultRole    string   `json:"defaultRole" validate:"required,oneof=admin user"`
+	Enabled        bool     `json:"enabled"`
+}
+
+type SsoResponse struct {
+	Issuer          string    `json:"issuer"`
+	ClientID        string    `json:"clientId"`
+	HasClientSecret bool      `json:"hasClientSecret"`
+	AllowedDomains  []string  `json:"allowedDomains"`
+	DefaultRole     string    `json:"defaultRole"`
+	Enabled         bool      `json:"enabled"`
+	RedirectURL     string    `json:"redirectUrl"`
+	UpdatedAt       time.Time `json:"updatedAt"`
+}
+
+// @Summary		Start single sign-on
+// @Description	Redirects the browser to the identity provider of the brand. The provider sends it back to /auth/sso/callback
+// @Tags			auth
+// @Param			pageUrl		path	string	true	"Page URL of the brand"
+// @Param			remember	query	bool	false	"Keep the session for longer"
+// @Success		303
+// @Failure		404	{object}	error
+// @Failure		500	{object}	error
+// @Router			/auth/sso/{pageUrl}/start [get]
+func (app *application) startSsoHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	brandID, err := app.store.GetBrandByUrl(ctx, chi.URLParam(r, "pageUrl"))
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	settings, err := app.store.GetBrandOidcProvider(ctx, brandID)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			app.notFoundResponse(w, r, ErrSsoNotConfigured)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if !settings.Enabled {
+		app.notFoundResponse(w, r, ErrSsoNotConfigured)
+		return
+	}
+
+	provider, err := app.oidc.Discover(ctx, settings.Issuer)
+	if err != nil {
+		app.logger.Errorw("error discovering identity provider", "brand", brandID, "issuer", settings.Issuer, "error", err)
+		app.ssoFailed(w, r, ssoErrorUnavailable)
+		return
+	}
+
+	var values [3]string
+	for i := range values {
+		values[i], err = oidc.GenerateVerifier()
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+
+	remember, _ := strconv.ParseBool(r.URL.Query().Get("remember"))
+
+	err = app.store.CreateOidcLoginState(ctx, store.CreateOidcLoginStateParams{
+		State:        hashToken(state),
+		BrandID:      brandID,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		Remember:     remember,
+		Expiry:       time.Now().Add(app.config.auth.oidc.stateExp),
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.setSsoStateCookie(w, hashToken(state), app.config.auth.oidc.stateExp)
+
+	http.Redirect(w, r, oidc.AuthCodeURL(provider, app.oidcConfig(settings), state, nonce, verifier), http.StatusSeeOther)
+}
+
+// @Summary		Finish single sign-on
+// @Description	The identity provider redirects here. The user is linked by a verified email or created with the default role of the brand, gets a session cookie and is sent to the app. Users with 2FA, or of a brand requiring it, are sent to the login page with challenge_token and setup_required in the URL fragment to finish the second step. Failures redirect to the login page with an sso_error parameter
+// @Tags			auth
+// @Param			state	query	string	true	"State from the start of the sign in"
+// @Param			code	query	string	false	"Authorization code"
+// @Param			error	query	string	false	"Error from the identity provider"
+// @Success		303
+// @Router			/auth/sso/callback [get]
+func (app *application) ssoCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := r.URL.Query()
+
+	state := hashToken(query.Get("state"))
+	cookie, err := r.Cookie(SSO_STATE_COOKIE)
+	app.setSsoStateCookie(w, "", 0)
+	if err != nil || !hmac.Equal([]byte(cookie.Value), []byte(state)) {
+		app.ssoFailed(w, r, ssoErrorInvalidState)
+		return
+	}
+
+	// The state is used once even when the provider reports an error
+	loginState, err := app.store.ConsumeOidcLoginState(ctx, state)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			app.ssoFailed(w, r, ssoErrorInvalidState)
+		default:
+			app.logger.Errorw("error reading sso login state", "error", err)
+			app.ssoFailed(w, r, ssoErrorServer)
+		}
+		return
+	}
+
+	if providerErr := query.Get("error"); providerErr != "" {
+		app.logger.Infow("identity provider denied sign in", "brand", loginState.BrandID, "error", providerErr, "description", query.Get("error_description"))
+		app.ssoFailed(w, r, ssoErrorDenied)
+		return
+	}
+
+	settings, err := app.store.GetBrandOidcProvider(ctx, loginState.BrandID)
+	if err != nil || !settings.Enabled {
+		app.ssoFailed(w, r, ssoErrorUnavailable)
+		return
+	}
+
+	provider, err := app.oidc.Discover(ctx, settings.Issuer)
+	if err != nil {
+		app.logger.Errorw("error discovering identity provider", "brand", settings.BrandID, "issuer", settings.Issuer, "error", err)
+		app.ssoFailed(w, r, ssoErrorUnavailable)
+		return
+	}
+
+	rawIDToken, err := app.oidc.Exchange(ctx, provider, app.oidcConfig(settings), query.Get("code"), loginState.CodeVerifier)
+	if err != nil {
+		app.logger.Errorw("error exchanging sso code", "brand", settings.BrandID, "error", err)
+		app.ssoFailed(w, r, ssoErrorInvalidToken)
+		return
+	}
+
+	claims, err := app.oidc.Verify(ctx, provider, settings.ClientID, rawIDToken, loginState.Nonce)
+	if err != nil {
+		app.logger.Warnw("invalid sso id token", "brand", settings.BrandID, "error", err)
+		app.ssoFailed(w, r, ssoErrorInvalidToken)
+		return
+	}
+
+	email := normalizeEmail(claims.Email)
+
+	attempt := newUserSignInAttempt(r, email)
+	attempt.BrandID = sql.NullInt32{Int32: settings.BrandID, Valid: true}
+
+	if !claims.EmailVerified || !emailDomainAllowed(email, settings.AllowedDomains) {
+		attempt.Reason = ssoErrorEmailNotAllowed
+		app.recordSignInAttempt(ctx, attempt)
+		app.ssoFailed(w, r, ssoErrorEmailNotAllowed)
+		return
+	}
+
+	if app.signInWait(ctx, attempt) > 0 {
+		app.ssoFailed(w, r, ssoErrorLocked)
+		return
+	}
+
+	plainPassword, err := generateRandomPassword()
+	if err != nil {
+		app.ssoFailed(w, r, ssoErrorServer)
+		return
+	}
+
+	password, err := bcrypt.GenerateFromPassword([]byte(plainPassword), bcrypt.DefaultCost)
+	if err != nil {
+		app.ssoFailed(w, r, ssoErrorServer)
+		return
+	}
+
+	name := claims.Name
+	if name == "" {
+		name = strings.Split(email, "@")[0]
+	}
+
+	result, err := app.store.ProvisionOidcUserTx(ctx, store.ProvisionOidcUserTxParams{
+		BrandID:  settings.BrandID,
+		Issuer:   settings.Issuer,
+		Subject:  claims.Subject,
+		Email:    email,
+		Name:     name,
+		Role:     settings.DefaultRole,
+		Password: password,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrSsoEmailTaken):
+			attempt.Reason = ssoErrorAccountConflict
+			app.recordSignInAttempt(ctx, attempt)
+			app.ssoFailed(w, r, ssoErrorAccountConflict)
+		default:
+			app.logger.Errorw("error provisioning sso user", "brand", settings.BrandID, "error", err)
+			app.ssoFailed(w, r, ssoErrorServer)
+		}
+		return
+	}
+
+	user := result.User
+	attempt.AccountID = toNullInt64(user.ID)
+
+	if result.Created {
+		app.logger.Infow("user created by single sign-on", "user", user.ID, "brand", settings.BrandID, "role", user.Role)
+	}
+
+	// Users with 2FA, or of a brand requiring it, finish the sign in with the
+	// second step on the login page
+	challenge, err := app.userSignInChallenge(ctx, user, loginState.Remember)
+	if err != nil {
+		app.logger.Errorw("error creating sso two-factor challenge", "user", user.ID, "error", err)
+		app.ssoFailed(w, r, ssoErrorServer)
+		return
+	}
+
+	if challenge != nil {
+		attempt.Reason = signInReasonTwoFactorChallenge
+		app.recordSignInAttempt(ctx, attempt)
+
+		// The fragment keeps the challenge token out of server logs and
+		// Referer headers
+		fragment := url.Values{
+			"challenge_token": {challenge.ChallengeToken},
+			"setup_required":  {strconv.FormatBool(challenge.SetupRequired)},
+		}
+		http.Redirect(w, r, app.staffAppURL()+"/login#"+fragment.Encode(), http.StatusSeeOther)
+		return
+	}
+
+	app.revokeUserSessionFromCookie(r)
+
+	session, err := app.store.CreateUserSession(ctx, app.userSessionParams(r, user.ID, loginState.Remember))
+	if err != nil {
+		app.logger.Errorw("error creating sso session", "user", user.ID, "error", err)
+		app.ssoFailed(w, r, ssoErrorServer)
+		return
+	}
+
+	attempt.Reason = signInReasonSso
+	app.signInSucceeded(ctx, attempt)
+	app.setUserSessionCookie(w, session)
+
+	http.Redirect(w, r, app.staffAppURL(), http.StatusSeeOther)
+}
+
+// @Summary		Get the single sign-on settings
+// @Description	Returns the identity provider of the brand. The client secret is never returned
+// @Tags			brand
+// @Produce		json
+// @Param			id	path		int	true	"Brand ID"
+// @Success		200	{object}	SsoResponse
+// @Failure		403	{object}	error
+// @Failure		404	{object}	error
+// @Failure		500	{object}	error
+// @Security		CookieAuth
+// @Router			/brand/{id}/sso [get]
+func (app *application) getBrandSsoHandler(w http.ResponseWriter, r *http.Request) {
+	brandID, ok := app.ssoBrandFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	settings, err := app.store.GetBrandOidcProvider(r.Context(), brandID)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			app.notFoundResponse(w, r, ErrSsoNotConfigured)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := writeJSON(w, http.StatusOK, app.ssoResponseMapper(settings)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// @Summary		Set up single sign-on
+// @Description	Sets the OpenID Connect provider staff of the brand sign in with. The issuer has to serve a discovery document. Register the redirectUrl of the response with the provider
+// @Tags			brand
+// @Accept			json
+// @Produce		json
+// @Param			id		path		int					true	"Brand ID"
+// @Param			payload	body		UpdateSsoPayload	true	"Provider settings"
+// @Success		200		{object}	SsoResponse
+// @Failure		400		{object}	error
+// @Failure		403		{object}	error
+// @Failure		500		{object}	error
+// @Security		CookieAuth
+// @Router			/brand/{id}/sso [put]
+func (app *application) updateBrandSsoHandler(w http.ResponseWriter, r *http.Request) {
+	brandID, ok := app.ssoBrandFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var payload UpdateSsoPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	if _, err := app.oidc.Discover(ctx, payload.Issuer); err != nil {
+		app.badRequestResponse(w, r, fmt.Errorf("%w: %v", ErrSsoInvalidIssuer, err))
+		return
+	}
+
+	issuer := strings.TrimSuffix(payload.Issuer, "/")
+	secret := payload.ClientSecret
+	if secret == "" {
+		current, err := app.store.GetBrandOidcProvider(ctx, brandID)
+		switch err {
+		case nil:
+			// The secret of one provider is never sent to another
+			if current.ClientSecret != "" && (current.Issuer != issuer || current.ClientID != payload.ClientID) {
+				app.badRequestResponse(w, r, ErrSsoSecretChanged)
+				return
+			}
+			secret = current.ClientSecret
+		case sql.ErrNoRows:
+		default:
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	domains := make([]string, 0, len(payload.AllowedDomains))
+	for _, domain := range payload.AllowedDomains {
+		domains = append(domains, strings.ToLower(domain))
+	}
+
+	settings, err := app.store.UpsertBrandOidcProvider(ctx, store.UpsertBrandOidcProviderParams{
+		BrandID:        brandID,
+		Issuer:         issuer,
+		ClientID:       payload.ClientID,
+		ClientSecret:   secret,
+		AllowedDomains: domains,
+		DefaultRole:    payload.DefaultRole,
+		Enabled:        payload.Enabled,
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.logger.Infow("brand sso updated", "brand", brandID, "issuer", settings.Issuer, "enabled", settings.Enabled)
+
+	if err := writeJSON(w, http.StatusOK, app.ssoResponseMapper(settings)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// @Summary		Remove single sign-on
+// @Description	Deletes the identity provider of the brand. Users keep their accounts and can reset their password to sign in
+// @Tags			brand
+// @Param			id	path	int	true	"Brand ID"
+// @Success		204
+// @Failure		403	{object}	error
+// @Failure		404	{object}	error
+// @Failure		500	{object}	error
+// @Security		CookieAuth
+// @Router			/brand/{id}/sso [delete]
+func (app *application) deleteBrandSsoHandler(w http.ResponseWriter, r *http.Request) {
+	brandID, ok := app.ssoBrandFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	count, err := app.store.DeleteBrandOidcProvider(r.Context(), brandID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if count == 0 {
+		app.notFoundResponse(w, r, ErrSsoNotConfigured)
+		return
+	}
+
+	app.logger.Infow("brand sso removed", "brand", brandID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ssoBrandFromRequest returns the brand of the URL when the user owns it
+func (app *application) ssoBrandFromRequest(w http.ResponseWriter, r *http.Request) (int32, bool) {
+	ctxUser, err := getUserFromCtx(r.Context())
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return 0, false
+	}
+
+	brandID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return 0, false
+	}
+
+	if ctxUser.Role != ownerRole || ctxUser.BrandID.Int32 != int32(brandID) {
+		app.forbiddenResponse(w, r, ErrAccessDenied)
+		return 0, false
+	}
+
+	return int32(brandID), true
+}
+
+func (app *application) oidcConfig(settings *store.BrandOidcProvider) oidc.Config {
+	return oidc.Config{
+		ClientID:     settings.ClientID,
+		ClientSecret: settings.ClientSecret,
+		RedirectURL:  app.config.auth.oidc.redirectURL,
+	}
+}
+
+func (app *application) ssoResponseMapper(settings *store.BrandOidcProvider) SsoResponse {
+	return SsoResponse{
+		Issuer:          settings.Issuer,
+		ClientID:        settings.ClientID,
+		HasClientSecret: settings.ClientSecret != "",
+		AllowedDomains:  settings.AllowedDomains,
+		DefaultRole:     settings.DefaultRole,
+		Enabled:         settings.Enabled,
+		RedirectURL:     app.config.auth.oidc.redirectURL,
+		UpdatedAt:       settings.UpdatedAt,
+	}
+}
+
+// emailDomainAllowed tells whether the email is in one of the domains. No
+// domains allows every email the provider vouches for.
+func emailDomainAllowed(email string, domains []string) bool {
+	if len(domains) == 0 {
+		return true
+	}
+
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+
+	domain := email[at+1:]
+	for _, allowed := range domains {
+		if domain == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+func (app *application) staffAppURL() string {
+	return fmt.Sprintf("http://app.%s", app.config.clientUrl)
+}
+
+// ssoFailed sends the browser back to the login page. The flow runs in
+// top level navigations, so a JSON error would leave the user on a blank page.
+func (app *application) ssoFailed(w http.ResponseWriter, r *http.Request, code string) {
+	http.Redirect(w, r, app.staffAppURL()+"/login?sso_error="+url.QueryEscape(code), http.StatusSeeOther)
+}
+
+// setSsoStateCookie sets the cookie with the hashed state of the sign in
+// started in this browser, or clears it when maxAge is 0. It is sent back on
+// the redirect from the identity provider, which a strict SameSite cookie
+// isn't.
+func (app *application) setSsoStateCookie(w http.ResponseWriter, value string, maxAge time.Duration) {
+	sameSite := http.SameSiteLaxMode
+	if app.config.env == "development" {
+		sameSite = http.SameSiteNoneMode
+	}
+
+	cookie := &http.Cookie{
+		Name:     SSO_STATE_COOKIE,
+		Value:    value,
+		Path:     "/v1/auth/sso",
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: sameSite,
+		MaxAge:   int(maxAge.Seconds()),
+	}
+	if maxAge == 0 {
+		cookie.MaxAge = -1
+	}
+
+	http.SetCookie(w, cookie)
+}
+
+// purgeOidcLoginStates deletes the logins that were never finished
+func (app *application) purgeOidcLoginStates(ctx context.Context) error {
+	count, err := app.store.DeleteExpiredOidcLoginStates(ctx)
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		app.logger.Infow("purged expired sso login states", "count", count)
+	}
+	return nil
+}
@@ -99,15 +99,26 @@ func (app *application) getAvailableTimeslotsHandler(w http.ResponseWriter, r *h
 		return
 	}
 
+	// Busy times from the user's external calendars block slots too
+	busyTimes, err := app.store.ListUserBusyTimes(ctx, store.ListUserBusyTimesParams{
+		UserID:     userId,
+		RangeStart: date,
+		RangeEnd:   date.AddDate(0, 0, 1),
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
 	// Generate available timeslots
-	timeslots := generateTimeslots(dayWorkingHours, service, userEvents, date)
+	timeslots := generateTimeslots(dayWorkingHours, service, userEvents, busyTimes, date)
 
 	if err := writeJSON(w, http.StatusOK, map[string]interface{}{"timeslots": timeslots}); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
 
-func generateTimeslots(workingHours *store.BrandWorkingHour, service *store.Service, existingEvents []*store.Event, targetDate time.Time) []string {
+func generateTimeslots(workingHours *store.BrandWorkingHour, service *store.Service, existingEvents []*store.Event, busyTimes []*store.ExternalBusyTime, targetDate time.Time) []string {
 	if !workingHours.OpenTime.Valid || !workingHours.CloseTime.Valid {
 		return []string{}
 	}
@@ -148,6 +159,13 @@ func generateTimeslots(workingHours *store.BrandWorkingHour, service *store.Serv
 		fmt.Printf("Blocked period: %v to %v\n", eventStart.Format("15:04"), eventEnd.Format("15:04"))
 	}
 
+	for _, busy := range busyTimes {
+		blockedPeriods = append(blockedPeriods, struct{ start, end time.Time }{
+			start: busy.StartTime,
+			end:   busy.EndTime,
+		})
+	}
+
 	slotInterval := 15 * time.Minute
 	var availableSlots []string
 
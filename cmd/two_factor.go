@@ -0,0 +1,797 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/georgifotev1/bms/internal/store"
+	"github.com/georgifotev1/bms/internal/totp"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxTwoFactorAttempts is how many wrong codes a sign in challenge takes
+	// before it stops working
+	maxTwoFactorAttempts = 5
+	recoveryCodeCount    = 10
+)
+
+var (
+	ErrTwoFactorRequired    = errors.New("the brand requires two-factor authentication")
+	ErrTwoFactorNotEnabled  = errors.New("two-factor authentication is not enabled")
+	ErrOwnerTwoFactorNeeded = errors.New("enable two-factor authentication on your account before requiring it")
+)
+
+type TwoFactorChallengeResponse struct {
+	ChallengeToken string `json:"challengeToken"`
+	// SetupRequired is set when the brand requires 2FA and the user has not
+	// set it up yet. The secret is requested with the challenge token.
+	SetupRequired bool      `json:"setupRequired"`
+	ExpiresAt     time.Time `json:"expiresAt"`
+}
+
+type TwoFactorSetupResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioningUri"`
+}
+
+type TwoFactorStatusResponse struct {
+	Enabled           bool  `json:"enabled"`
+	Required          bool  `json:"required"`
+	RecoveryCodesLeft int64 `json:"recoveryCodesLeft"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+type TwoFactorSignInResponse struct {
+	User UserResponse `json:"user"`
+	// RecoveryCodes are only returned when 2FA was set up during the sign in
+	RecoveryCodes []string `json:"recoveryCodes,omitempty"`
+}
+
+type TwoFactorPolicyResponse struct {
+	RequireTwoFactor bool  `json:"requireTwoFactor"`
+	SessionsRevoked  int64 `json:"sessionsRevoked"`
+}
+
+type TwoFactorChallengePayload struct {
+	ChallengeToken string `json:"challengeToken" validate:"required"`
+}
+
+type TwoFactorSignInPayload struct {
+	ChallengeToken string `json:"challengeToken" validate:"required"`
+	// Code is a 6 digit code from the authenticator app or a recovery code
+	Code string `json:"code" validate:"required,max=20"`
+}
+
+type TwoFactorCodePayload struct {
+	Code string `json:"code" validate:"required,max=20"`
+}
+
+type TwoFactorPolicyPayload struct {
+	Required bool `json:"required"`
+}
+
+// @Summary		Set up two-factor authentication during sign in
+// @Description	Starts 2FA enrollment for a user whose brand requires it. The challenge comes from the sign in response
+// @Tags			auth
+// @Accept			json
+// @Produce		json
+// @Param			payload	body		TwoFactorChallengePayload	true	"Sign in challenge"
+// @Success		200		{object}	TwoFactorSetupResponse
+// @Failure		400		{object}	error
+// @Failure		401		{object}	error
+// @Failure		409		{object}	error
+// @Failure		500		{object}	error
+// @Router			/auth/two-factor/setup [post]
+func (app *application) setupTwoFactorSignInHandler(w http.ResponseWriter, r *http.Request) {
+	var payload TwoFactorChallengePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	challenge, err := app.store.GetUserSignInChallenge(ctx, store.GetUserSignInChallengeParams{
+		Token:    hashToken(payload.ChallengeToken),
+		Attempts: maxTwoFactorAttempts,
+	})
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			app.unauthorizedErrorResponse(w, r, store.ErrInvalidSignInChallenge)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	user, err := app.store.GetUserById(ctx, challenge.UserID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.startTwoFactorSetup(w, r, user)
+}
+
+// @Summary		Complete sign in with a second factor
+// @Description	Signs in a user with the challenge from the sign in response and a code from the authenticator app or a recovery code. When 2FA was set up during the sign in the code confirms it and the recovery codes are returned once. A challenge stops working after it is used, after it expires or after 5 wrong codes
+// @Tags			auth
+// @Accept			json
+// @Produce		json
+// @Param			payload	body		TwoFactorSignInPayload	true	"Sign in challenge and code"
+// @Success		200		{object}	TwoFactorSignInResponse
+// @Failure		400		{object}	error
+// @Failure		401		{object}	error
+// @Failure		429		{object}	error
+// @Failure		500		{object}	error
+// @Router			/auth/two-factor [post]
+func (app *application) signInUserWithTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	var payload TwoFactorSignInPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	token := hashToken(payload.ChallengeToken)
+
+	challenge, err := app.store.GetUserSignInChallenge(ctx, store.GetUserSignInChallengeParams{
+		Token:    token,
+		Attempts: maxTwoFactorAttempts,
+	})
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			app.unauthorizedErrorResponse(w, r, store.ErrInvalidSignInChallenge)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	user, err := app.store.GetUserById(ctx, challenge.UserID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// Wrong codes count towards the lockout of the account like wrong
+	// passwords so new challenges don't allow guessing codes endlessly
+	attempt := newUserSignInAttempt(r, user.Email)
+	attempt.AccountID = toNullInt64(user.ID)
+	attempt.BrandID = user.BrandID
+	if app.signInBlocked(w, r, attempt) {
+		return
+	}
+
+	userTotp, err := app.store.GetUserTotp(ctx, challenge.UserID)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			app.badRequestResponse(w, r, store.ErrTwoFactorNotPending)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	params := store.CompleteUserSignInTxParams{
+		Token:       token,
+		MaxAttempts: maxTwoFactorAttempts,
+		Session:     app.userSessionParams(r, challenge.UserID, challenge.Remember),
+	}
+
+	var recoveryCodes []string
+	if isRecoveryCode(payload.Code) && userTotp.ConfirmedAt.Valid {
+		params.RecoveryCodeHash = hashRecoveryCode(challenge.UserID, payload.Code)
+	} else {
+		step, ok := totp.Validate(userTotp.Secret, payload.Code, time.Now(), userTotp.LastUsedStep)
+		if !ok {
+			app.failTwoFactorAttempt(w, r, token, attempt, user)
+			return
+		}
+		params.Step = step
+
+		// The first valid code of a pending secret enables 2FA
+		if !userTotp.ConfirmedAt.Valid {
+			recoveryCodes, params.RecoveryCodeHashes, err = generateRecoveryCodes(challenge.UserID)
+			if err != nil {
+				app.internalServerError(w, r, err)
+				return
+			}
+			params.Enable = true
+		}
+	}
+
+	app.revokeUserSessionFromCookie(r)
+
+	session, err := app.store.CompleteUserSignInTx(ctx, params)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidTwoFactorCode):
+			app.failTwoFactorAttempt(w, r, token, attempt, user)
+		case errors.Is(err, store.ErrInvalidSignInChallenge), errors.Is(err, store.ErrTwoFactorNotPending):
+			app.unauthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.signInSucceeded(ctx, attempt)
+	app.setUserSessionCookie(w, session)
+
+	response := TwoFactorSignInResponse{
+		User:          userResponseMapper(user),
+		RecoveryCodes: recoveryCodes,
+	}
+
+	if err := writeJSON(w, http.StatusOK, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// @Summary		Get my two-factor status
+// @Description	Shows whether 2FA is enabled for the signed in user, whether their brand requires it and how many recovery codes are left
+// @Tags			users
+// @Produce		json
+// @Success		200	{object}	TwoFactorStatusResponse
+// @Failure		401	{object}	error
+// @Failure		500	{object}	error
+// @Security		CookieAuth
+// @Router			/users/me/two-factor [get]
+func (app *application) getMyTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxUser, err := getUserFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	enabled, err := app.twoFactorEnabled(ctx, ctxUser.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	required, err := app.twoFactorRequired(ctx, ctxUser)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	response := TwoFactorStatusResponse{
+		Enabled:  enabled,
+		Required: required,
+	}
+
+	if enabled {
+		response.RecoveryCodesLeft, err = app.store.CountUserRecoveryCodes(ctx, ctxUser.ID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	if err := writeJSON(w, http.StatusOK, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// @Summary		Set up two-factor authentication
+// @Description	Creates a new TOTP secret for the signed in user. The provisioning URI is shown as a QR code for the authenticator app. 2FA is enabled once a code is confirmed
+// @Tags			users
+// @Produce		json
+// @Success		200	{object}	TwoFactorSetupResponse
+// @Failure		401	{object}	error
+// @Failure		409	{object}	error
+// @Failure		500	{object}	error
+// @Security		CookieAuth
+// @Router			/users/me/two-factor [post]
+func (app *application) setupMyTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	ctxUser, err := getUserFromCtx(r.Context())
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	app.startTwoFactorSetup(w, r, ctxUser)
+}
+
+// @Summary		Confirm two-factor authentication
+// @Description	Enables 2FA with a code from the authenticator app. The recovery codes are only shown in this response
+// @Tags			users
+// @Accept			json
+// @Produce		json
+// @Param			payload	body		TwoFactorCodePayload	true	"Code from the authenticator app"
+// @Success		200		{object}	RecoveryCodesResponse
+// @Failure		400		{object}	error
+// @Failure		401		{object}	error
+// @Failure		500		{object}	error
+// @Security		CookieAuth
+// @Router			/users/me/two-factor/confirm [post]
+func (app *application) confirmMyTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxUser, err := getUserFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	var payload TwoFactorCodePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	userTotp, err := app.store.GetUserTotp(ctx, ctxUser.ID)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			app.badRequestResponse(w, r, store.ErrTwoFactorNotPending)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if userTotp.ConfirmedAt.Valid {
+		app.badRequestResponse(w, r, store.ErrTwoFactorAlreadyEnabled)
+		return
+	}
+
+	step, ok := totp.Validate(userTotp.Secret, payload.Code, time.Now(), userTotp.LastUsedStep)
+	if !ok {
+		app.badRequestResponse(w, r, store.ErrInvalidTwoFactorCode)
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes(ctxUser.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.store.EnableUserTotpTx(ctx, store.EnableUserTotpTxParams{
+		UserID:             ctxUser.ID,
+		Step:               step,
+		RecoveryCodeHashes: hashes,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrTwoFactorNotPending):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.rotateUserSession(w, r); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.logger.Infow("two-factor authentication enabled", "user", ctxUser.ID)
+
+	if err := writeJSON(w, http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// @Summary		Regenerate my recovery codes
+// @Description	Replaces the recovery codes of the signed in user. The old codes stop working
+// @Tags			users
+// @Accept			json
+// @Produce		json
+// @Param			payload	body		TwoFactorCodePayload	true	"Code from the authenticator app"
+// @Success		200		{object}	RecoveryCodesResponse
+// @Failure		400		{object}	error
+// @Failure		401		{object}	error
+// @Failure		500		{object}	error
+// @Security		CookieAuth
+// @Router			/users/me/two-factor/recovery-codes [post]
+func (app *application) regenerateMyRecoveryCodesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxUser, err := getUserFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	var payload TwoFactorCodePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := app.checkTwoFactorCode(ctx, ctxUser.ID, payload.Code); err != nil {
+		switch {
+		case errors.Is(err, ErrTwoFactorNotEnabled), errors.Is(err, store.ErrInvalidTwoFactorCode):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes(ctxUser.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.store.ReplaceUserRecoveryCodesTx(ctx, store.ReplaceUserRecoveryCodesTxParams{
+		UserID:             ctxUser.ID,
+		RecoveryCodeHashes: hashes,
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := writeJSON(w, http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// @Summary		Disable two-factor authentication
+// @Description	Turns off 2FA for the signed in user with a code from the authenticator app or a recovery code. Users of a brand that requires 2FA can't turn it off
+// @Tags			users
+// @Accept			json
+// @Param			payload	body	TwoFactorCodePayload	true	"Code from the authenticator app or a recovery code"
+// @Success		204
+// @Failure		400	{object}	error
+// @Failure		401	{object}	error
+// @Failure		403	{object}	error
+// @Failure		500	{object}	error
+// @Security		CookieAuth
+// @Router			/users/me/two-factor [delete]
+func (app *application) disableMyTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxUser, err := getUserFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	var payload TwoFactorCodePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	required, err := app.twoFactorRequired(ctx, ctxUser)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if required {
+		app.forbiddenResponse(w, r, ErrTwoFactorRequired)
+		return
+	}
+
+	if err := app.checkTwoFactorCode(ctx, ctxUser.ID, payload.Code); err != nil {
+		switch {
+		case errors.Is(err, ErrTwoFactorNotEnabled), errors.Is(err, store.ErrInvalidTwoFactorCode):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.store.DisableUserTotpTx(ctx, ctxUser.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.logger.Infow("two-factor authentication disabled", "user", ctxUser.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary		Require two-factor authentication
+// @Description	Makes every user of the brand sign in with 2FA. The owner needs 2FA enabled first. Turning it on signs out users without 2FA so they set it up on their next sign in
+// @Tags			brand
+// @Accept			json
+// @Produce		json
+// @Param			id		path		int						true	"Brand ID"
+// @Param			payload	body		TwoFactorPolicyPayload	true	"Whether 2FA is required"
+// @Success		200		{object}	TwoFactorPolicyResponse
+// @Failure		400		{object}	error
+// @Failure		401		{object}	error
+// @Failure		403		{object}	error
+// @Failure		500		{object}	error
+// @Security		CookieAuth
+// @Router			/brand/{id}/two-factor [put]
+func (app *application) updateBrandTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxUser, err := getUserFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	brandID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if ctxUser.Role != ownerRole || ctxUser.BrandID.Int32 != int32(brandID) {
+		app.forbiddenResponse(w, r, ErrAccessDenied)
+		return
+	}
+
+	var payload TwoFactorPolicyPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if payload.Required {
+		enabled, err := app.twoFactorEnabled(ctx, ctxUser.ID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		if !enabled {
+			app.badRequestResponse(w, r, ErrOwnerTwoFactorNeeded)
+			return
+		}
+	}
+
+	brand, err := app.store.SetBrandRequireTwoFactor(ctx, store.SetBrandRequireTwoFactorParams{
+		ID:               int32(brandID),
+		RequireTwoFactor: payload.Required,
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	response := TwoFactorPolicyResponse{RequireTwoFactor: brand.RequireTwoFactor}
+
+	if brand.RequireTwoFactor {
+		response.SessionsRevoked, err = app.store.ExpireBrandUserSessionsWithoutTwoFactor(ctx, ctxUser.BrandID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	app.logger.Infow("brand two-factor policy updated", "brand", brand.ID, "required", brand.RequireTwoFactor, "sessionsRevoked", response.SessionsRevoked)
+
+	if err := writeJSON(w, http.StatusOK, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// userSignInChallenge returns the challenge for the second sign in step, or
+// nil when the user can sign in with the password alone
+func (app *application) userSignInChallenge(ctx context.Context, user *store.User, remember bool) (*TwoFactorChallengeResponse, error) {
+	enabled, err := app.twoFactorEnabled(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	required := false
+	if !enabled {
+		required, err = app.twoFactorRequired(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !enabled && !required {
+		return nil, nil
+	}
+
+	// Only the latest challenge of a user works
+	if err := app.store.DeleteUserSignInChallenges(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	token := uuid.New().String()
+	expiresAt := time.Now().Add(app.config.auth.twoFactor.challengeExp)
+
+	if err := app.store.CreateUserSignInChallenge(ctx, store.CreateUserSignInChallengeParams{
+		Token:    hashToken(token),
+		UserID:   user.ID,
+		Remember: remember,
+		Expiry:   expiresAt,
+	}); err != nil {
+		return nil, err
+	}
+
+	return &TwoFactorChallengeResponse{
+		ChallengeToken: token,
+		SetupRequired:  !enabled,
+		ExpiresAt:      expiresAt,
+	}, nil
+}
+
+func (app *application) startTwoFactorSetup(w http.ResponseWriter, r *http.Request, user *store.User) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// A confirmed secret is never overwritten so no row comes back
+	_, err = app.store.UpsertUserTotp(r.Context(), store.UpsertUserTotpParams{
+		UserID: user.ID,
+		Secret: secret,
+	})
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			app.conflictRespone(w, r, store.ErrTwoFactorAlreadyEnabled)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	response := TwoFactorSetupResponse{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(app.config.auth.twoFactor.issuer, user.Email, secret),
+	}
+
+	if err := writeJSON(w, http.StatusOK, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// checkTwoFactorCode uses a code from the authenticator app or a recovery
+// code of a user with 2FA enabled
+func (app *application) checkTwoFactorCode(ctx context.Context, userID int64, code string) error {
+	userTotp, err := app.store.GetUserTotp(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrTwoFactorNotEnabled
+		}
+		return err
+	}
+
+	if !userTotp.ConfirmedAt.Valid {
+		return ErrTwoFactorNotEnabled
+	}
+
+	var used int64
+	if isRecoveryCode(code) {
+		used, err = app.store.UseUserRecoveryCode(ctx, store.UseUserRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: hashRecoveryCode(userID, code),
+		})
+	} else {
+		step, ok := totp.Validate(userTotp.Secret, code, time.Now(), userTotp.LastUsedStep)
+		if !ok {
+			return store.ErrInvalidTwoFactorCode
+		}
+		used, err = app.store.UseUserTotpStep(ctx, store.UseUserTotpStepParams{
+			UserID:       userID,
+			LastUsedStep: step,
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	if used == 0 {
+		return store.ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+func (app *application) failTwoFactorAttempt(w http.ResponseWriter, r *http.Request, token string, attempt *signInAttempt, user *store.User) {
+	if err := app.store.IncrementUserSignInChallengeAttempts(r.Context(), token); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if app.signInFailed(r.Context(), attempt, signInReasonWrongCode) {
+		app.sendUserLockedEmail(user, attempt.IpAddress)
+	}
+
+	app.unauthorizedErrorResponse(w, r, store.ErrInvalidTwoFactorCode)
+}
+
+func (app *application) twoFactorEnabled(ctx context.Context, userID int64) (bool, error) {
+	userTotp, err := app.store.GetUserTotp(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return userTotp.ConfirmedAt.Valid, nil
+}
+
+func (app *application) twoFactorRequired(ctx context.Context, user *store.User) (bool, error) {
+	if !user.BrandID.Valid {
+		return false, nil
+	}
+
+	brand, err := app.store.GetBrandById(ctx, user.BrandID.Int32)
+	if err != nil {
+		return false, err
+	}
+
+	return brand.RequireTwoFactor, nil
+}
+
+// generateRecoveryCodes returns the codes to show to the user and the hashes
+// to store
+func generateRecoveryCodes(userID int64) ([]string, []string, error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for range recoveryCodeCount {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(encoding.EncodeToString(b)[:10])
+		code = code[:5] + "-" + code[5:]
+
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(userID, code))
+	}
+
+	return codes, hashes, nil
+}
+
+// isRecoveryCode tells recovery codes apart from the 6 digit TOTP codes
+func isRecoveryCode(code string) bool {
+	return len(code) != totp.Digits
+}
+
+// hashRecoveryCode salts the code with the user and ignores case and dashes
+// so the code can be typed the way it was shown
+func hashRecoveryCode(userID int64, code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return hashToken(fmt.Sprintf("%d:%s", userID, code))
+}
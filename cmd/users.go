@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/georgifotev1/bms/internal/mailer"
@@ -19,7 +20,8 @@ import (
 type userKey string
 
 const (
-	userCtx userKey = "user"
+	userCtx        userKey = "user"
+	userSessionCtx userKey = "userSession"
 )
 
 type UserResponse struct {
@@ -137,7 +139,7 @@ func (app *application) inviteUserHandler(w http.ResponseWriter, r *http.Request
 		ActivationUrl: activationURL,
 	}
 
-	status, err := app.mailer.Send(mailer.UserInvitationTemplate, user.Name, user.Email, vars)
+	status, err := app.mailer.Send(ctx, mailer.UserInvitationTemplate, user.Name, user.Email, vars)
 	if err != nil {
 		app.logger.Errorw("error sending welcome email", "error", err)
 
@@ -282,19 +284,41 @@ func (app *application) handleUsersRetrieval(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	users, err := app.store.GetUsersByBrand(ctx, sql.NullInt32{
-		Valid: true,
-		Int32: brandID,
+	page, err := parsePageParams(r, []string{"name", "createdAt"}, "name")
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	cursorID, err := page.cursorInt64()
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	query := r.URL.Query()
+	users, err := app.store.ListUsers(ctx, store.ListUsersParams{
+		BrandID: sql.NullInt32{
+			Valid: true,
+			Int32: brandID,
+		},
+		Sort:       page.Sort,
+		Search:     strings.TrimSpace(query.Get("search")),
+		Role:       query.Get("role"),
+		HasCursor:  page.Cursor != nil,
+		Descending: page.Descending,
+		CursorKey:  page.cursorKey(),
+		CursorID:   cursorID,
+		PageLimit:  page.fetchLimit(),
 	})
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	var result []UserResponse
-	for _, v := range users {
-		result = append(result, userResponseMapper(v))
-	}
+	result := paginate(page, users, func(row *store.ListUsersRow) (string, string) {
+		return row.SortKey, strconv.FormatInt(row.ID, 10)
+	}, userListResponseMapper)
 
 	if err = writeJSON(w, http.StatusOK, result); err != nil {
 		app.internalServerError(w, r, err)
@@ -302,11 +326,16 @@ func (app *application) handleUsersRetrieval(w http.ResponseWriter, r *http.Requ
 }
 
 // @Summary		Get users by brand
-// @Description	Fetches all users of a brand
+// @Description	Fetches the users of a brand. Results are cursor paginated.
 // @Tags			users
 // @Accept			json
 // @Produce		json
-// @Success		200	{object}	[]UserResponse
+// @Param			search	query		string	false	"Name or email prefix"
+// @Param			role	query		string	false	"Only users with the role"
+// @Param			sort	query		string	false	"name or createdAt, prefixed with - for descending order"	default(name)
+// @Param			limit	query		int		false	"Page size"	default(50)	maximum(100)
+// @Param			cursor	query		string	false	"Cursor of the next page"
+// @Success		200		{object}	PageResponse[UserResponse]
 // @Failure		400	{object}	error
 // @Failure		404	{object}	error
 // @Failure		500	{object}	error
@@ -325,11 +354,15 @@ func (app *application) getUsersHandler(w http.ResponseWriter, r *http.Request)
 }
 
 // @Summary		Get users by brand (public)
-// @Description	Fetches all users of a brand for public access
+// @Description	Fetches the users of a brand for public access. Results are cursor paginated.
 // @Tags			users
 // @Accept			json
 // @Produce		json
-// @Success		200	{object}	[]UserResponse
+// @Param			search	query		string	false	"Name or email prefix"
+// @Param			sort	query		string	false	"name or createdAt, prefixed with - for descending order"	default(name)
+// @Param			limit	query		int		false	"Page size"	default(50)	maximum(100)
+// @Param			cursor	query		string	false	"Cursor of the next page"
+// @Success		200		{object}	PageResponse[UserResponse]
 // @Failure		400	{object}	error
 // @Failure		404	{object}	error
 // @Failure		500	{object}	error
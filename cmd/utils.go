@@ -10,6 +10,7 @@ import (
 	"errors"
 	"math/big"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/georgifotev1/bms/internal/store"
@@ -93,14 +94,16 @@ func generateSubstring(length int) string {
 	return string(result)
 }
 
-func (app *application) SetCookie(w http.ResponseWriter, name, value string) {
+// SetCookie sets a cookie that lasts until expires. A zero expires makes a
+// browser session cookie.
+func (app *application) SetCookie(w http.ResponseWriter, name, value string, expires time.Time) {
 	isDev := app.config.env == "development"
 	sameSite := http.SameSiteStrictMode
 	if isDev {
 		sameSite = http.SameSiteNoneMode
 	}
 
-	http.SetCookie(w, &http.Cookie{
+	cookie := &http.Cookie{
 		Name:     name,
 		Value:    value,
 		Path:     "/",
@@ -108,8 +111,13 @@ func (app *application) SetCookie(w http.ResponseWriter, name, value string) {
 		Secure:   true,
 		SameSite: sameSite,
 		Domain:   app.config.clientHost,
-		MaxAge:   60 * 60 * 24 * 30,
-	})
+	}
+	if !expires.IsZero() {
+		cookie.Expires = expires
+		cookie.MaxAge = int(time.Until(expires).Seconds())
+	}
+
+	http.SetCookie(w, cookie)
 }
 
 func (app *application) ClearCookie(w http.ResponseWriter, name string) {
@@ -149,6 +157,35 @@ func getUserFromCtx(ctx context.Context) (*store.User, error) {
 
 }
 
+func getCustomerFromCtx(ctx context.Context) (*store.Customer, error) {
+	ctxValue := ctx.Value(customerIdCtx)
+	if ctxValue == nil {
+		return nil, errors.New("Context is missing")
+	}
+	return ctxValue.(*store.Customer), nil
+}
+
+func getUserSessionFromCtx(ctx context.Context) (*store.UserSession, error) {
+	ctxValue := ctx.Value(userSessionCtx)
+	if ctxValue == nil {
+		return nil, errors.New("Context is missing")
+	}
+	return ctxValue.(*store.UserSession), nil
+}
+
+func getCustomerSessionFromCtx(ctx context.Context) (*store.CustomerSession, error) {
+	ctxValue := ctx.Value(customerSessionCtx)
+	if ctxValue == nil {
+		return nil, errors.New("Context is missing")
+	}
+	return ctxValue.(*store.CustomerSession), nil
+}
+
+func getAPIKeyFromCtx(ctx context.Context) (*store.ApiKey, bool) {
+	key, ok := ctx.Value(apiKeyCtx).(*store.ApiKey)
+	return key, ok
+}
+
 func toNullString(s string) sql.NullString {
 	return sql.NullString{
 		Valid:  s != "",
@@ -156,8 +193,50 @@ func toNullString(s string) sql.NullString {
 	}
 }
 
+func toNullInt64(i int64) sql.NullInt64 {
+	return sql.NullInt64{
+		Valid: i != 0,
+		Int64: i,
+	}
+}
+
 var LOCATION_FORMAT = "Europe/Sofia"
 
+// Calling code assumed for phone numbers written in national format
+const defaultCallingCode = "359"
+
+// normalizePhoneNumber formats a phone number as E.164. It returns an empty
+// string when the input does not look like a valid phone number.
+func normalizePhoneNumber(phone string) string {
+	phone = strings.TrimSpace(phone)
+
+	var digits strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		}
+	}
+
+	number := digits.String()
+	switch {
+	case strings.HasPrefix(phone, "+"):
+	case strings.HasPrefix(number, "00"):
+		number = number[2:]
+	case strings.HasPrefix(number, "0"):
+		number = defaultCallingCode + number[1:]
+	}
+
+	if len(number) < 8 || len(number) > 15 {
+		return ""
+	}
+
+	return "+" + number
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
 func parseTimeStringFromUserLoacation(format, timeString string) time.Time {
 	location, _ := time.LoadLocation(format)
 
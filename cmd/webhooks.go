@@ -0,0 +1,610 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/georgifotev1/bms/internal/store"
+	"github.com/georgifotev1/bms/internal/webhook"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	webhookMaxPerBrand = 20
+	webhookBatchSize   = 50
+	webhookWorkers     = 8
+)
+
+// Types of the changes brands can subscribe to. Cancelled events are the
+// deleted ones.
+var webhookEventTypes = []string{
+	"event.created",
+	"event.updated",
+	"event.cancelled",
+	"customer.created",
+	"customer.updated",
+	"customer.deleted",
+	"service.created",
+	"service.updated",
+	"service.deleted",
+}
+
+var (
+	ErrWebhookNotFound         = errors.New("webhook not found")
+	ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+	ErrWebhookLimitReached     = fmt.Errorf("a brand can have at most %d webhooks", webhookMaxPerBrand)
+)
+
+type WebhookPayload struct {
+	URL        string   `json:"url" validate:"required,url,max=2048"`
+	EventTypes []string `json:"eventTypes" validate:"required,min=1,dive,required"`
+	// Active is only read on update, new webhooks are active
+	Active *bool `json:"active"`
+}
+
+type WebhookResponse struct {
+	ID         int64     `json:"id"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"eventTypes"`
+	Active     bool      `json:"active"`
+	CreatedBy  *int64    `json:"createdBy"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+type CreateWebhookResponse struct {
+	WebhookResponse
+	// Secret signs the deliveries. It is only returned once.
+	Secret string `json:"secret"`
+}
+
+type WebhookDeliveryResponse struct {
+	ID             int64           `json:"id"`
+	EventType      string          `json:"eventType"`
+	Payload        json.RawMessage `json:"payload" swaggertype:"object"`
+	Status         string          `json:"status"`
+	Attempts       int32           `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"nextAttemptAt"`
+	LastAttemptAt  *time.Time      `json:"lastAttemptAt"`
+	LastStatusCode *int32          `json:"lastStatusCode"`
+	LastError      string          `json:"lastError"`
+	DeliveredAt    *time.Time      `json:"deliveredAt"`
+	RedeliveryOf   *int64          `json:"redeliveryOf"`
+	CreatedAt      time.Time       `json:"createdAt"`
+}
+
+// webhookBody is what receivers get
+type webhookBody struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// @Summary		List webhooks
+// @Description	Lists the webhook subscriptions of the brand
+// @Tags			webhooks
+// @Produce		json
+// @Success		200	{array}		WebhookResponse
+// @Failure		403	{object}	error
+// @Failure		500	{object}	error
+// @Security		CookieAuth
+// @Router			/webhooks [get]
+func (app *application) getWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxUser, err := getUserFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if ctxUser.Role == userRole || !ctxUser.BrandID.Valid {
+		app.forbiddenResponse(w, r, ErrAccessDenied)
+		return
+	}
+
+	subscriptions, err := app.store.ListWebhookSubscriptions(ctx, ctxUser.BrandID.Int32)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	response := make([]WebhookResponse, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		response = append(response, webhookResponseMapper(subscription))
+	}
+
+	if err := writeJSON(w, http.StatusOK, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// @Summary		Create a webhook
+// @Description	Subscribes a URL to changes of events, customers and services. Every delivery is a JSON POST signed
+// @Description	with the secret: Webhook-Signature is v1=<hex HMAC-SHA256 of "<Webhook-Timestamp>.<body>">. Failed
+// @Description	deliveries are retried with exponential backoff. The secret is only shown in this response
+// @Tags			webhooks
+// @Accept			json
+// @Produce		json
+// @Param			payload	body		WebhookPayload	true	"URL and event types"
+// @Success		201		{object}	CreateWebhookResponse
+// @Failure		400		{object}	error
+// @Failure		403		{object}	error
+// @Failure		500		{object}	error
+// @Security		CookieAuth
+// @Router			/webhooks [post]
+func (app *application) createWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxUser, err := getUserFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if ctxUser.Role == userRole || !ctxUser.BrandID.Valid {
+		app.forbiddenResponse(w, r, ErrAccessDenied)
+		return
+	}
+
+	var payload WebhookPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	eventTypes, err := app.validateWebhookPayload(payload)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	existing, err := app.store.ListWebhookSubscriptions(ctx, ctxUser.BrandID.Int32)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if len(existing) >= webhookMaxPerBrand {
+		app.badRequestResponse(w, r, ErrWebhookLimitReached)
+		return
+	}
+
+	secret, err := webhook.GenerateSecret()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	subscription, err := app.store.CreateWebhookSubscription(ctx, store.CreateWebhookSubscriptionParams{
+		BrandID:    ctxUser.BrandID.Int32,
+		Url:        payload.URL,
+		EventTypes: eventTypes,
+		Secret:     secret,
+		CreatedBy:  sql.NullInt64{Int64: ctxUser.ID, Valid: true},
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.logger.Infow("webhook created", "webhook", subscription.ID, "brand", subscription.BrandID, "by", ctxUser.ID)
+
+	response := CreateWebhookResponse{
+		WebhookResponse: webhookResponseMapper(subscription),
+		Secret:          secret,
+	}
+
+	if err := writeJSON(w, http.StatusCreated, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// @Summary		Update a webhook
+// @Description	Changes the URL and event types of the webhook, or pauses it with active set to false
+// @Tags			webhooks
+// @Accept			json
+// @Produce		json
+// @Param			webhookId	path		int				true	"Webhook ID"
+// @Param			payload		body		WebhookPayload	true	"URL, event types and state"
+// @Success		200			{object}	WebhookResponse
+// @Failure		400			{object}	error
+// @Failure		403			{object}	error
+// @Failure		404			{object}	error
+// @Failure		500			{object}	error
+// @Security		CookieAuth
+// @Router			/webhooks/{webhookId} [put]
+func (app *application) updateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxUser, err := getUserFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if ctxUser.Role == userRole || !ctxUser.BrandID.Valid {
+		app.forbiddenResponse(w, r, ErrAccessDenied)
+		return
+	}
+
+	webhookID, err := strconv.ParseInt(chi.URLParam(r, "webhookId"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	var payload WebhookPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	eventTypes, err := app.validateWebhookPayload(payload)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	active := true
+	if payload.Active != nil {
+		active = *payload.Active
+	}
+
+	subscription, err := app.store.UpdateWebhookSubscription(ctx, store.UpdateWebhookSubscriptionParams{
+		ID:         webhookID,
+		BrandID:    ctxUser.BrandID.Int32,
+		Url:        payload.URL,
+		EventTypes: eventTypes,
+		Active:     active,
+	})
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			app.notFoundResponse(w, r, ErrWebhookNotFound)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := writeJSON(w, http.StatusOK, webhookResponseMapper(subscription)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// @Summary		Delete a webhook
+// @Description	Deletes the webhook with its delivery log. Pending deliveries are dropped
+// @Tags			webhooks
+// @Param			webhookId	path	int	true	"Webhook ID"
+// @Success		204
+// @Failure		400	{object}	error
+// @Failure		403	{object}	error
+// @Failure		404	{object}	error
+// @Failure		500	{object}	error
+// @Security		CookieAuth
+// @Router			/webhooks/{webhookId} [delete]
+func (app *application) deleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxUser, err := getUserFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if ctxUser.Role == userRole || !ctxUser.BrandID.Valid {
+		app.forbiddenResponse(w, r, ErrAccessDenied)
+		return
+	}
+
+	webhookID, err := strconv.ParseInt(chi.URLParam(r, "webhookId"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	count, err := app.store.DeleteWebhookSubscription(ctx, store.DeleteWebhookSubscriptionParams{
+		ID:      webhookID,
+		BrandID: ctxUser.BrandID.Int32,
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if count == 0 {
+		app.notFoundResponse(w, r, ErrWebhookNotFound)
+		return
+	}
+
+	app.logger.Infow("webhook deleted", "webhook", webhookID, "brand", ctxUser.BrandID.Int32, "by", ctxUser.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary		List webhook deliveries
+// @Description	Lists the deliveries of the webhook with the result of their last attempt, newest first unless sorted by createdAt
+// @Tags			webhooks
+// @Produce		json
+// @Param			webhookId	path		int		true	"Webhook ID"
+// @Param			sort		query		string	false	"createdAt, prefixed with - for descending order"	default(-createdAt)
+// @Param			limit		query		int		false	"Page size"											default(50)	maximum(100)
+// @Param			cursor		query		string	false	"Cursor of the next page"
+// @Success		200			{object}	PageResponse[WebhookDeliveryResponse]
+// @Failure		400			{object}	error
+// @Failure		403			{object}	error
+// @Failure		404			{object}	error
+// @Failure		500			{object}	error
+// @Security		CookieAuth
+// @Router			/webhooks/{webhookId}/deliveries [get]
+func (app *application) getWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	subscription, ok := app.webhookFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	if query.Get("sort") == "" {
+		query.Set("sort", "-createdAt")
+		r.URL.RawQuery = query.Encode()
+	}
+
+	page, err := parsePageParams(r, []string{"createdAt"}, "createdAt")
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	cursorID, err := page.cursorInt64()
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	deliveries, err := app.store.ListWebhookDeliveries(ctx, store.ListWebhookDeliveriesParams{
+		SubscriptionID: subscription.ID,
+		HasCursor:      page.Cursor != nil,
+		Descending:     page.Descending,
+		CursorID:       cursorID,
+		PageLimit:      page.fetchLimit(),
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	response := paginate(page, deliveries, func(delivery *store.WebhookDelivery) (string, string) {
+		return "", strconv.FormatInt(delivery.ID, 10)
+	}, webhookDeliveryResponseMapper)
+
+	if err := writeJSON(w, http.StatusOK, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// @Summary		Redeliver a webhook delivery
+// @Description	Queues the payload of the delivery again as a new delivery, which is sent right away
+// @Tags			webhooks
+// @Produce		json
+// @Param			webhookId	path		int	true	"Webhook ID"
+// @Param			deliveryId	path		int	true	"Delivery ID"
+// @Success		201			{object}	WebhookDeliveryResponse
+// @Failure		400			{object}	error
+// @Failure		403			{object}	error
+// @Failure		404			{object}	error
+// @Failure		500			{object}	error
+// @Security		CookieAuth
+// @Router			/webhooks/{webhookId}/deliveries/{deliveryId}/redeliver [post]
+func (app *application) redeliverWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	subscription, ok := app.webhookFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	deliveryID, err := strconv.ParseInt(chi.URLParam(r, "deliveryId"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	delivery, err := app.store.RedeliverWebhookDelivery(ctx, store.RedeliverWebhookDeliveryParams{
+		ID:             deliveryID,
+		SubscriptionID: subscription.ID,
+	})
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			app.notFoundResponse(w, r, ErrWebhookDeliveryNotFound)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := writeJSON(w, http.StatusCreated, webhookDeliveryResponseMapper(delivery)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// webhookFromRequest loads the webhook of the path for an owner or admin of
+// its brand. It writes the error response when it returns false.
+func (app *application) webhookFromRequest(w http.ResponseWriter, r *http.Request) (*store.WebhookSubscription, bool) {
+	ctx := r.Context()
+	ctxUser, err := getUserFromCtx(ctx)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return nil, false
+	}
+
+	if ctxUser.Role == userRole || !ctxUser.BrandID.Valid {
+		app.forbiddenResponse(w, r, ErrAccessDenied)
+		return nil, false
+	}
+
+	webhookID, err := strconv.ParseInt(chi.URLParam(r, "webhookId"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return nil, false
+	}
+
+	subscription, err := app.store.GetWebhookSubscription(ctx, store.GetWebhookSubscriptionParams{
+		ID:      webhookID,
+		BrandID: ctxUser.BrandID.Int32,
+	})
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			app.notFoundResponse(w, r, ErrWebhookNotFound)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return nil, false
+	}
+
+	return subscription, true
+}
+
+// validateWebhookPayload checks the payload and returns its event types
+// sorted and without duplicates
+func (app *application) validateWebhookPayload(payload WebhookPayload) ([]string, error) {
+	if err := Validate.Struct(payload); err != nil {
+		return nil, err
+	}
+
+	target, err := url.Parse(payload.URL)
+	if err != nil {
+		return nil, err
+	}
+	if target.Scheme != "https" && !(app.config.env == "development" && target.Scheme == "http") {
+		return nil, errors.New("the webhook URL must use https")
+	}
+
+	for _, eventType := range payload.EventTypes {
+		if !slices.Contains(webhookEventTypes, eventType) {
+			return nil, fmt.Errorf("unknown event type %q, the types are %s", eventType, strings.Join(webhookEventTypes, ", "))
+		}
+	}
+
+	eventTypes := slices.Clone(payload.EventTypes)
+	slices.Sort(eventTypes)
+	return slices.Compact(eventTypes), nil
+}
+
+// dispatchWebhooks sends the deliveries that are due. Claimed deliveries are
+// locked for the send timeout so other instances skip them.
+func (app *application) dispatchWebhooks(ctx context.Context) error {
+	for {
+		deliveries, err := app.store.ClaimWebhookDeliveries(ctx, store.ClaimWebhookDeliveriesParams{
+			BatchSize:   webhookBatchSize,
+			LockedUntil: time.Now().UTC().Add(2 * app.config.webhooks.timeout),
+		})
+		if err != nil {
+			return err
+		}
+
+		var wg sync.WaitGroup
+		work := make(chan *store.ClaimWebhookDeliveriesRow)
+		for range min(webhookWorkers, len(deliveries)) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for delivery := range work {
+					app.sendWebhook(ctx, delivery)
+				}
+			}()
+		}
+		for _, delivery := range deliveries {
+			work <- delivery
+		}
+		close(work)
+		wg.Wait()
+
+		if len(deliveries) < webhookBatchSize {
+			return nil
+		}
+	}
+}
+
+func (app *application) sendWebhook(ctx context.Context, delivery *store.ClaimWebhookDeliveriesRow) {
+	body, err := json.Marshal(webhookBody{
+		ID:        delivery.ID,
+		Type:      delivery.EventType,
+		CreatedAt: delivery.CreatedAt,
+		Data:      camelCaseKeys(delivery.Payload),
+	})
+
+	result := webhook.Result{Err: err}
+	if err == nil {
+		result = app.webhooks.Send(ctx, delivery.Url, delivery.Secret, strconv.FormatInt(delivery.ID, 10), body)
+	}
+
+	params := store.RecordWebhookAttemptParams{
+		ID:     delivery.ID,
+		Status: "succeeded",
+		// Not read once the delivery is done
+		NextAttemptAt: time.Now().UTC(),
+	}
+	if result.StatusCode != 0 {
+		params.LastStatusCode = sql.NullInt32{Int32: int32(result.StatusCode), Valid: true}
+	}
+	if !result.OK() {
+		params.LastError = result.Err.Error()
+		params.Status = "pending"
+		params.NextAttemptAt = time.Now().UTC().Add(webhook.Backoff(int(delivery.Attempts) + 1))
+		if delivery.Attempts+1 >= app.config.webhooks.maxAttempts {
+			params.Status = "failed"
+		}
+	}
+
+	if err := app.store.RecordWebhookAttempt(ctx, params); err != nil {
+		app.logger.Errorw("failed to record webhook attempt", "delivery", delivery.ID, "error", err)
+	}
+}
+
+// camelCaseKeys renames the columns of a row to the JSON names the API uses
+func camelCaseKeys(row json.RawMessage) json.RawMessage {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(row, &fields); err != nil {
+		return row
+	}
+
+	renamed := make(map[string]json.RawMessage, len(fields))
+	for key, value := range fields {
+		parts := strings.Split(key, "_")
+		for i := 1; i < len(parts); i++ {
+			if parts[i] != "" {
+				parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+			}
+		}
+		renamed[strings.Join(parts, "")] = value
+	}
+
+	data, err := json.Marshal(renamed)
+	if err != nil {
+		return row
+	}
+	return data
+}
+
+// purgeWebhookDeliveries deletes the finished deliveries past the retention
+func (app *application) purgeWebhookDeliveries(ctx context.Context) error {
+	count, err := app.store.DeleteWebhookDeliveriesBefore(ctx, time.Now().Add(-app.config.webhooks.retention))
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		app.logger.Infow("purged webhook deliveries", "count", count)
+	}
+	return nil
+}
@@ -4,14 +4,19 @@ import (
 	"context"
 	"database/sql"
 	"time"
+
+	"github.com/georgifotev1/bms/internal/store"
+	"github.com/lib/pq"
 )
 
 func New(addr string, maxOpenConns, maxIdleConns int, maxIdleTime string) (*sql.DB, error) {
-	db, err := sql.Open("postgres", addr)
+	connector, err := pq.NewConnector(addr)
 	if err != nil {
 		return nil, err
 	}
 
+	db := sql.OpenDB(store.NewTenantConnector(connector))
+
 	db.SetMaxOpenConns(maxOpenConns)
 	db.SetMaxIdleConns(maxIdleConns)
 
@@ -30,3 +35,13 @@ func New(addr string, maxOpenConns, maxIdleConns int, maxIdleTime string) (*sql.
 
 	return db, nil
 }
+
+// BypassesRowSecurity tells if the connected role skips the row level security
+// policies, which superusers and BYPASSRLS roles do
+func BypassesRowSecurity(ctx context.Context, db *sql.DB) (bool, error) {
+	var bypasses bool
+	err := db.QueryRowContext(ctx, `
+		SELECT rolsuper OR rolbypassrls FROM pg_roles WHERE rolname = current_user
+	`).Scan(&bypasses)
+	return bypasses, err
+}
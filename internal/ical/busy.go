@@ -0,0 +1,331 @@
+package ical
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxPeriods bounds the busy periods read from one calendar
+const maxPeriods = 20000
+
+// Period is a time a calendar is busy. UID is the event it comes from, so
+// callers can leave out events they published themselves.
+type Period struct {
+	UID   string
+	Start time.Time
+	End   time.Time
+}
+
+// EventError is an event BusyPeriods could not read
+type EventError struct {
+	UID string
+	Err error
+}
+
+func (e *EventError) Error() string {
+	if e.UID == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("event %s: %v", e.UID, e.Err)
+}
+
+func (e *EventError) Unwrap() error {
+	return e.Err
+}
+
+// BusyPeriods returns the times the events of cal overlapping [from, to)
+// are busy, in order of their start. Recurring events are expanded with
+// their exceptions and modified instances. Cancelled and transparent events
+// are left out. Floating times and all day events are read in floating.
+//
+// An event that can't be read is skipped and reported, the others are still
+// returned.
+func BusyPeriods(cal *Component, from, to time.Time, floating *time.Location) ([]Period, []error) {
+	z := newZones(cal, floating)
+
+	var (
+		masters   []*Component
+		overrides = make(map[string][]*Component)
+		periods   []Period
+		skipped   []error
+	)
+	for _, event := range cal.Children("VEVENT") {
+		if event.Property("RECURRENCE-ID") != nil {
+			uid := eventUID(event)
+			overrides[uid] = append(overrides[uid], event)
+		} else {
+			masters = append(masters, event)
+		}
+	}
+
+	add := func(period Period) bool {
+		if period.End.After(period.Start) && period.End.After(from) && period.Start.Before(to) {
+			periods = append(periods, period)
+		}
+		return len(periods) < maxPeriods
+	}
+
+	for _, event := range masters {
+		uid := eventUID(event)
+		if err := z.expand(event, overrides[uid], to, add); err != nil {
+			skipped = append(skipped, &EventError{UID: uid, Err: err})
+		}
+	}
+
+	// Modified instances are busy on their own times, also when their
+	// recurring event is missing
+	for uid, events := range overrides {
+		for _, event := range events {
+			if !isBusy(event) {
+				continue
+			}
+			period, _, _, err := z.eventTimes(event)
+			if err != nil {
+				skipped = append(skipped, &EventError{UID: uid, Err: err})
+				continue
+			}
+			period.UID = uid
+			add(period.Period)
+		}
+	}
+
+	sort.Slice(periods, func(i, j int) bool { return periods[i].Start.Before(periods[j].Start) })
+	return periods, skipped
+}
+
+// expand adds the busy occurrences of a recurring or single event until add
+// returns false or they start after to
+func (z *zones) expand(event *Component, overrides []*Component, to time.Time, add func(Period) bool) error {
+	first, startWall, startZone, err := z.eventTimes(event)
+	if err != nil {
+		return err
+	}
+	first.UID = eventUID(event)
+	length := first.End.Sub(first.Start)
+
+	rule := event.Property("RRULE")
+	if rule == nil && len(event.PropertyValues("RDATE")) == 0 {
+		if isBusy(event) {
+			add(first.Period)
+		}
+		return nil
+	}
+
+	// Instances replaced by a modified one or excluded
+	skip := make(map[int64]bool)
+	for _, override := range overrides {
+		id := override.Property("RECURRENCE-ID")
+		wall, zone, _, err := z.parseTime(id)
+		if err != nil {
+			return err
+		}
+		skip[zone.fromWall(wall).Unix()] = true
+	}
+	for _, exdate := range event.PropertyValues("EXDATE") {
+		times, err := z.parseTimes(exdate)
+		if err != nil {
+			return err
+		}
+		for _, t := range times {
+			skip[t.Unix()] = true
+		}
+	}
+
+	if !isBusy(event) {
+		return nil
+	}
+
+	occurrence := func(start time.Time) Period {
+		return Period{UID: first.UID, Start: start, End: start.Add(length)}
+	}
+
+	more := true
+	if rule != nil {
+		recur, err := parseRecurrence(rule.Value, z)
+		if err != nil {
+			return err
+		}
+
+		// Days of all day events stay whole across daylight saving changes
+		days := 0
+		if first.allDay {
+			days = int(length.Round(24*time.Hour) / (24 * time.Hour))
+		}
+
+		// Walls run at most a day off the instants, whatever the zone
+		limit := to.UTC().AddDate(0, 0, 1)
+		recur.each(startWall, startZone, limit, func(wall time.Time) bool {
+			start := startZone.fromWall(wall)
+			if !start.Before(to) {
+				return false
+			}
+			if skip[start.Unix()] {
+				return true
+			}
+
+			period := occurrence(start)
+			if days > 0 {
+				period.End = startZone.fromWall(wall.AddDate(0, 0, days))
+			}
+			more = add(period)
+			return more
+		})
+	} else if !skip[first.Start.Unix()] {
+		more = add(first.Period)
+	}
+
+	for _, rdate := range event.PropertyValues("RDATE") {
+		if !more {
+			break
+		}
+		times, err := z.parseTimes(rdate)
+		if err != nil {
+			return err
+		}
+		for _, start := range times {
+			if skip[start.Unix()] || !start.Before(to) || start.Equal(first.Start) {
+				continue
+			}
+			if more = add(occurrence(start)); !more {
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+type eventPeriod struct {
+	Period
+	allDay bool
+}
+
+// eventTimes reads the start and end of an event. Without DTEND or DURATION
+// an all day event lasts a day and a timed one takes no time.
+func (z *zones) eventTimes(event *Component) (eventPeriod, time.Time, zone, error) {
+	dtstart := event.Property("DTSTART")
+	if dtstart == nil {
+		return eventPeriod{}, time.Time{}, nil, fmt.Errorf("missing DTSTART")
+	}
+
+	wall, startZone, allDay, err := z.parseTime(dtstart)
+	if err != nil {
+		return eventPeriod{}, time.Time{}, nil, err
+	}
+
+	period := eventPeriod{allDay: allDay}
+	period.Start = startZone.fromWall(wall)
+
+	switch {
+	case event.Property("DTEND") != nil:
+		endWall, endZone, _, err := z.parseTime(event.Property("DTEND"))
+		if err != nil {
+			return eventPeriod{}, time.Time{}, nil, err
+		}
+		period.End = endZone.fromWall(endWall)
+	case event.Property("DURATION") != nil:
+		days, length, err := parseDuration(event.Property("DURATION").Value)
+		if err != nil {
+			return eventPeriod{}, time.Time{}, nil, err
+		}
+		period.End = startZone.fromWall(wall.AddDate(0, 0, days)).Add(length)
+	case allDay:
+		period.End = startZone.fromWall(wall.AddDate(0, 0, 1))
+	default:
+		period.End = period.Start
+	}
+
+	if period.End.Before(period.Start) {
+		return eventPeriod{}, time.Time{}, nil, fmt.Errorf("ends before it starts")
+	}
+	return period, wall, startZone, nil
+}
+
+func eventUID(event *Component) string {
+	if uid := event.Property("UID"); uid != nil {
+		return uid.Text()
+	}
+	return ""
+}
+
+// isBusy tells whether an event blocks time: it is neither cancelled nor
+// marked transparent
+func isBusy(event *Component) bool {
+	if status := event.Property("STATUS"); status != nil && strings.EqualFold(status.Value, "CANCELLED") {
+		return false
+	}
+	if transp := event.Property("TRANSP"); transp != nil && strings.EqualFold(transp.Value, "TRANSPARENT") {
+		return false
+	}
+	return true
+}
+
+// parseDuration reads a DURATION such as PT1H30M, P1D or P2W. Days and
+// weeks are returned apart since they follow the calendar.
+func parseDuration(value string) (int, time.Duration, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	negative := false
+	switch {
+	case strings.HasPrefix(s, "-"):
+		negative = true
+		s = s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+	if !strings.HasPrefix(s, "P") || len(s) < 3 {
+		return 0, 0, fmt.Errorf("invalid DURATION %q", value)
+	}
+	s = s[1:]
+
+	var (
+		days   int
+		length time.Duration
+		inTime bool
+		number string
+	)
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+			number += string(c)
+			continue
+		case c == 'T':
+			if inTime || number != "" {
+				return 0, 0, fmt.Errorf("invalid DURATION %q", value)
+			}
+			inTime = true
+			continue
+		}
+
+		n, err := strconv.Atoi(number)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid DURATION %q", value)
+		}
+		number = ""
+
+		switch {
+		case c == 'W' && !inTime:
+			days += 7 * n
+		case c == 'D' && !inTime:
+			days += n
+		case c == 'H' && inTime:
+			length += time.Duration(n) * time.Hour
+		case c == 'M' && inTime:
+			length += time.Duration(n) * time.Minute
+		case c == 'S' && inTime:
+			length += time.Duration(n) * time.Second
+		default:
+			return 0, 0, fmt.Errorf("invalid DURATION %q", value)
+		}
+	}
+	if number != "" {
+		return 0, 0, fmt.Errorf("invalid DURATION %q", value)
+	}
+
+	if negative {
+		return -days, -length, nil
+	}
+	return days, length, nil
+}
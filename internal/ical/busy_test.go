@@ -0,0 +1,456 @@
+package ical
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+// calendar wraps the components in a VCALENDAR with CRLF line endings
+func calendar(components ...string) string {
+	lines := []string{"BEGIN:VCALENDAR", "VERSION:2.0", "PRODID:-//test//EN"}
+	for _, component := range components {
+		lines = append(lines, strings.Split(strings.TrimSpace(component), "\n")...)
+	}
+	lines = append(lines, "END:VCALENDAR")
+	for i := range lines {
+		lines[i] = strings.TrimSpace(lines[i])
+	}
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
+
+// outlookZone is how Outlook writes Europe/Sofia, with a Windows name and
+// rules from 1601
+const outlookZone = `
+BEGIN:VTIMEZONE
+TZID:FLE Standard Time
+BEGIN:STANDARD
+DTSTART:16010101T040000
+TZOFFSETFROM:+0300
+TZOFFSETTO:+0200
+RRULE:FREQ=YEARLY;BYDAY=-1SU;BYMONTH=10
+END:STANDARD
+BEGIN:DAYLIGHT
+DTSTART:16010101T030000
+TZOFFSETFROM:+0200
+TZOFFSETTO:+0300
+RRULE:FREQ=YEARLY;BYDAY=-1SU;BYMONTH=3
+END:DAYLIGHT
+END:VTIMEZONE`
+
+func TestBusyPeriods(t *testing.T) {
+	sofia := mustLoad(t, "Europe/Sofia")
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		components []string
+		from, to   time.Time
+		// want are the periods as "start/end" in UTC
+		want []string
+	}{
+		{
+			name: "single event in UTC",
+			components: []string{`
+				BEGIN:VEVENT
+				UID:single
+				DTSTART:20260310T100000Z
+				DTEND:20260310T113000Z
+				END:VEVENT`},
+			want: []string{"2026-03-10T10:00:00Z/2026-03-10T11:30:00Z"},
+		},
+		{
+			name: "duration instead of an end",
+			components: []string{`
+				BEGIN:VEVENT
+				UID:duration
+				DTSTART;TZID=Europe/Sofia:20260310T100000
+				DURATION:PT45M
+				END:VEVENT`},
+			want: []string{"2026-03-10T08:00:00Z/2026-03-10T08:45:00Z"},
+		},
+		{
+			name: "COUNT",
+			components: []string{`
+				BEGIN:VEVENT
+				UID:count
+				DTSTART:20260105T090000Z
+				DTEND:20260105T100000Z
+				RRULE:FREQ=DAILY;COUNT=3
+				END:VEVENT`},
+			want: []string{
+				"2026-01-05T09:00:00Z/2026-01-05T10:00:00Z",
+				"2026-01-06T09:00:00Z/2026-01-06T10:00:00Z",
+				"2026-01-07T09:00:00Z/2026-01-07T10:00:00Z",
+			},
+		},
+		{
+			name: "COUNT counts occurrences before the range",
+			components: []string{`
+				BEGIN:VEVENT
+				UID:count-before
+				DTSTART:20251230T090000Z
+				DTEND:20251230T100000Z
+				RRULE:FREQ=DAILY;COUNT=4
+				END:VEVENT`},
+			want: []string{
+				"2026-01-01T09:00:00Z/2026-01-01T10:00:00Z",
+				"2026-01-02T09:00:00Z/2026-01-02T10:00:00Z",
+			},
+		},
+		{
+			name: "UNTIL in UTC with a zoned start",
+			components: []string{`
+				BEGIN:VEVENT
+				UID:until-utc
+				DTSTART;TZID=Europe/Sofia:20260105T100000
+				DTEND;TZID=Europe/Sofia:20260105T110000
+				RRULE:FREQ=DAILY;UNTIL=20260107T080000Z
+				END:VEVENT`},
+			want: []string{
+				"2026-01-05T08:00:00Z/2026-01-05T09:00:00Z",
+				"2026-01-06T08:00:00Z/2026-01-06T09:00:00Z",
+				"2026-01-07T08:00:00Z/2026-01-07T09:00:00Z",
+			},
+		},
+		{
+			name: "UNTIL in UTC just before an occurrence",
+			components: []string{`
+				BEGIN:VEVENT
+				UID:until-utc-early
+				DTSTART;TZID=Europe/Sofia:20260105T100000
+				DTEND;TZID=Europe/Sofia:20260105T110000
+				RRULE:FREQ=DAILY;UNTIL=20260107T075959Z
+				END:VEVENT`},
+			want: []string{
+				"2026-01-05T08:00:00Z/2026-01-05T09:00:00Z",
+				"2026-01-06T08:00:00Z/2026-01-06T09:00:00Z",
+			},
+		},
+		{
+			name: "local UNTIL of a floating event",
+			components: []string{`
+				BEGIN:VEVENT
+				UID:until-floating
+				DTSTART:20260105T100000
+				DTEND:20260105T110000
+				RRULE:FREQ=DAILY;UNTIL=20260106T100000
+				END:VEVENT`},
+			want: []string{
+				"2026-01-05T08:00:00Z/2026-01-05T09:00:00Z",
+				"2026-01-06T08:00:00Z/2026-01-06T09:00:00Z",
+			},
+		},
+		{
+			name: "UNTIL as a date includes the whole day",
+			components: []string{`
+				BEGIN:VEVENT
+				UID:until-date
+				DTSTART:20260105T180000
+				DTEND:20260105T190000
+				RRULE:FREQ=DAILY;UNTIL=20260106
+				END:VEVENT`},
+			want: []string{
+				"2026-01-05T16:00:00Z/2026-01-05T17:00:00Z",
+				"2026-01-06T16:00:00Z/2026-01-06T17:00:00Z",
+			},
+		},
+		{
+			name: "EXDATE",
+			components: []string{`
+				BEGIN:VEVENT
+				UID:exdate
+				DTSTART;TZID=Europe/Sofia:20260105T100000
+				DTEND;TZID=Europe/Sofia:20260105T110000
+				RRULE:FREQ=WEEKLY;COUNT=4
+				EXDATE;TZID=Europe/Sofia:20260112T100000,20260126T100000
+				END:VEVENT`},
+			want: []string{
+				"2026-01-05T08:00:00Z/2026-01-05T09:00:00Z",
+				"2026-01-19T08:00:00Z/2026-01-19T09:00:00Z",
+			},
+		},
+		{
+			name: "EXDATE in UTC",
+			components: []string{`
+				BEGIN:VEVENT
+				UID:exdate-utc
+				DTSTART;TZID=Europe/Sofia:20260105T100000
+				DTEND;TZID=Europe/Sofia:20260105T110000
+				RRULE:FREQ=DAILY;COUNT=3
+				EXDATE:20260106T080000Z
+				END:VEVENT`},
+			want: []string{
+				"2026-01-05T08:00:00Z/2026-01-05T09:00:00Z",
+				"2026-01-07T08:00:00Z/2026-01-07T09:00:00Z",
+			},
+		},
+		{
+			name: "modified and cancelled instances",
+			components: []string{`
+				BEGIN:VEVENT
+				UID:override
+				DTSTART;TZID=Europe/Sofia:20260105T100000
+				DTEND;TZID=Europe/Sofia:20260105T110000
+				RRULE:FREQ=DAILY;COUNT=3
+				END:VEVENT`, `
+				BEGIN:VEVENT
+				UID:override
+				RECURRENCE-ID;TZID=Europe/Sofia:20260106T100000
+				DTSTART;TZID=Europe/Sofia:20260106T150000
+				DTEND;TZID=Europe/Sofia:20260106T160000
+				END:VEVENT`, `
+				BEGIN:VEVENT
+				UID:override
+				RECURRENCE-ID;TZID=Europe/Sofia:20260107T100000
+				DTSTART;TZID=Europe/Sofia:20260107T100000
+				DTEND;TZID=Europe/Sofia:20260107T110000
+				STATUS:CANCELLED
+				END:VEVENT`},
+			want: []string{
+				"2026-01-05T08:00:00Z/2026-01-05T09:00:00Z",
+				"2026-01-06T13:00:00Z/2026-01-06T14:00:00Z",
+			},
+		},
+		{
+			name: "RDATE",
+			components: []string{`
+				BEGIN:VEVENT
+				UID:rdate
+				DTSTART:20260105T090000Z
+				DTEND:20260105T100000Z
+				RDATE:20260107T090000Z,20260109T120000Z
+				END:VEVENT`},
+			want: []string{
+				"2026-01-05T09:00:00Z/2026-01-05T10:00:00Z",
+				"2026-01-07T09:00:00Z/2026-01-07T10:00:00Z",
+				"2026-01-09T12:00:00Z/2026-01-09T13:00:00Z",
+			},
+		},
+		{
+			name: "wall clock time kept across the spring DST change",
+			components: []string{`
+				BEGIN:VEVENT
+				UID:dst-spring
+				DTSTART;TZID=Europe/Sofia:20260328T090000
+				DTEND;TZID=Europe/Sofia:20260328T100000
+				RRULE:FREQ=DAILY;COUNT=2
+				END:VEVENT`},
+			want: []string{
+				"2026-03-28T07:00:00Z/2026-03-28T08:00:00Z",
+				"2026-03-29T06:00:00Z/2026-03-29T07:00:00Z",
+			},
+		},
+		{
+			name: "wall clock time kept across the autumn DST change",
+			components: []string{`
+				BEGIN:VEVENT
+				UID:dst-autumn
+				DTSTART;TZID=Europe/Sofia:20261024T090000
+				DTEND;TZID=Europe/Sofia:20261024T100000
+				RRULE:FREQ=WEEKLY;BYDAY=SA,SU;COUNT=2
+				END:VEVENT`},
+			want: []string{
+				"2026-10-24T06:00:00Z/2026-10-24T07:00:00Z",
+				"2026-10-25T07:00:00Z/2026-10-25T08:00:00Z",
+			},
+		},
+		{
+			name: "VTIMEZONE with a Windows name across DST",
+			components: []string{outlookZone, `
+				BEGIN:VEVENT
+				UID:outlook
+				DTSTART;TZID=FLE Standard Time:20260328T090000
+				DTEND;TZID=FLE Standard Time:20260328T100000
+				RRULE:FREQ=DAILY;COUNT=2
+				END:VEVENT`},
+			want: []string{
+				"2026-03-28T07:00:00Z/2026-03-28T08:00:00Z",
+				"2026-03-29T06:00:00Z/2026-03-29T07:00:00Z",
+			},
+		},
+		{
+			name: "all day event",
+			components: []string{`
+				BEGIN:VEVENT
+				UID:all-day
+				DTSTART;VALUE=DATE:20260310
+				END:VEVENT`},
+			want: []string{"2026-03-09T22:00:00Z/2026-03-10T22:00:00Z"},
+		},
+		{
+			name: "all day event over the DST change",
+			components: []string{`
+				BEGIN:VEVENT
+				UID:all-day-dst
+				DTSTART;VALUE=DATE:20260328
+				DTEND;VALUE=DATE:20260330
+				END:VEVENT`},
+			want: []string{"2026-03-27T22:00:00Z/2026-03-29T21:00:00Z"},
+		},
+		{
+			name: "recurring all day event keeps whole days across DST",
+			components: []string{`
+				BEGIN:VEVENT
+				UID:all-day-weekly
+				DTSTART;VALUE=DATE:20260322
+				DTEND;VALUE=DATE:20260323
+				RRULE:FREQ=WEEKLY;COUNT=2
+				END:VEVENT`},
+			want: []string{
+				"2026-03-21T22:00:00Z/2026-03-22T22:00:00Z",
+				"2026-03-28T22:00:00Z/2026-03-29T21:00:00Z",
+			},
+		},
+		{
+			name: "last Friday of the month",
+			components: []string{`
+				BEGIN:VEVENT
+				UID:last-friday
+				DTSTART:20260130T120000Z
+				DTEND:20260130T130000Z
+				RRULE:FREQ=MONTHLY;BYDAY=-1FR;COUNT=3
+				END:VEVENT`},
+			want: []string{
+				"2026-01-30T12:00:00Z/2026-01-30T13:00:00Z",
+				"2026-02-27T12:00:00Z/2026-02-27T13:00:00Z",
+				"2026-03-27T12:00:00Z/2026-03-27T13:00:00Z",
+			},
+		},
+		{
+			name: "last workday of the month with BYSETPOS",
+			components: []string{`
+				BEGIN:VEVENT
+				UID:setpos
+				DTSTART:20260130T120000Z
+				DTEND:20260130T130000Z
+				RRULE:FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1;COUNT=3
+				END:VEVENT`},
+			want: []string{
+				"2026-01-30T12:00:00Z/2026-01-30T13:00:00Z",
+				"2026-02-27T12:00:00Z/2026-02-27T13:00:00Z",
+				"2026-03-31T12:00:00Z/2026-03-31T13:00:00Z",
+			},
+		},
+		{
+			name: "February 29 recurs in leap years only",
+			from: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			to:   time.Date(2029, 1, 1, 0, 0, 0, 0, time.UTC),
+			components: []string{`
+				BEGIN:VEVENT
+				UID:leap
+				DTSTART;VALUE=DATE:20240229
+				RRULE:FREQ=YEARLY
+				END:VEVENT`},
+			want: []string{
+				"2024-02-28T22:00:00Z/2024-02-29T22:00:00Z",
+				"2028-02-28T22:00:00Z/2028-02-29T22:00:00Z",
+			},
+		},
+		{
+			name: "occurrences outside the range are left out",
+			from: time.Date(2026, 1, 6, 0, 0, 0, 0, time.UTC),
+			to:   time.Date(2026, 1, 8, 0, 0, 0, 0, time.UTC),
+			components: []string{`
+				BEGIN:VEVENT
+				UID:range
+				DTSTART:20260105T090000Z
+				DTEND:20260105T100000Z
+				RRULE:FREQ=DAILY
+				END:VEVENT`},
+			want: []string{
+				"2026-01-06T09:00:00Z/2026-01-06T10:00:00Z",
+				"2026-01-07T09:00:00Z/2026-01-07T10:00:00Z",
+			},
+		},
+		{
+			name: "cancelled and transparent events are free",
+			components: []string{`
+				BEGIN:VEVENT
+				UID:cancelled
+				DTSTART:20260105T090000Z
+				DTEND:20260105T100000Z
+				STATUS:CANCELLED
+				END:VEVENT`, `
+				BEGIN:VEVENT
+				UID:transparent
+				DTSTART:20260105T090000Z
+				DTEND:20260105T100000Z
+				TRANSP:TRANSPARENT
+				END:VEVENT`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cal, err := Parse(strings.NewReader(calendar(tt.components...)))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+
+			rangeFrom, rangeTo := from, to
+			if !tt.from.IsZero() {
+				rangeFrom, rangeTo = tt.from, tt.to
+			}
+
+			periods, skipped := BusyPeriods(cal, rangeFrom, rangeTo, sofia)
+			if len(skipped) > 0 {
+				t.Fatalf("BusyPeriods skipped %v", skipped)
+			}
+
+			var got []string
+			for _, period := range periods {
+				got = append(got, period.Start.UTC().Format(time.RFC3339)+"/"+period.End.UTC().Format(time.RFC3339))
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Fatalf("BusyPeriods =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestBusyPeriodsSkipsUnreadableEvents(t *testing.T) {
+	cal, err := Parse(strings.NewReader(calendar(`
+		BEGIN:VEVENT
+		UID:hourly
+		DTSTART:20260105T090000Z
+		DTEND:20260105T100000Z
+		RRULE:FREQ=HOURLY;COUNT=3
+		END:VEVENT`, `
+		BEGIN:VEVENT
+		UID:unknown-zone
+		DTSTART;TZID=Mars/Olympus:20260105T090000
+		END:VEVENT`, `
+		BEGIN:VEVENT
+		UID:fine
+		DTSTART:20260105T090000Z
+		DTEND:20260105T100000Z
+		END:VEVENT`)))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	periods, skipped := BusyPeriods(cal, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), time.UTC)
+	if len(periods) != 1 || periods[0].UID != "fine" {
+		t.Fatalf("BusyPeriods = %v, want only the readable event", periods)
+	}
+	if len(skipped) != 2 {
+		t.Fatalf("BusyPeriods skipped %v, want 2 events", skipped)
+	}
+
+	var eventErr *EventError
+	if !errors.As(skipped[0], &eventErr) || eventErr.UID != "hourly" {
+		t.Fatalf("skipped[0] = %v, want the hourly event", skipped[0])
+	}
+}
@@ -0,0 +1,276 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxLineBytes bounds a single unfolded content line
+const maxLineBytes = 1 << 20
+
+var ErrNoCalendar = errors.New("ical: no VCALENDAR found")
+
+// Property is a content line. Parameter names are upper case, values are
+// left escaped, use Text to read a TEXT value.
+type Property struct {
+	Name   string
+	Params map[string][]string
+	Value  string
+}
+
+// Param returns the first value of a parameter
+func (p *Property) Param(name string) string {
+	if values := p.Params[name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Text returns the value unescaped
+func (p *Property) Text() string {
+	return UnescapeText(p.Value)
+}
+
+// Component is a BEGIN/END block such as VCALENDAR, VEVENT or VTIMEZONE
+type Component struct {
+	Name       string
+	Properties []*Property
+	Components []*Component
+}
+
+// Property returns the first property with the name, or nil
+func (c *Component) Property(name string) *Property {
+	for _, prop := range c.Properties {
+		if prop.Name == name {
+			return prop
+		}
+	}
+	return nil
+}
+
+// PropertyValues returns every property with the name
+func (c *Component) PropertyValues(name string) []*Property {
+	var props []*Property
+	for _, prop := range c.Properties {
+		if prop.Name == name {
+			props = append(props, prop)
+		}
+	}
+	return props
+}
+
+// Children returns the nested components with the name
+func (c *Component) Children(name string) []*Component {
+	var children []*Component
+	for _, child := range c.Components {
+		if child.Name == name {
+			children = append(children, child)
+		}
+	}
+	return children
+}
+
+// Parse reads the first VCALENDAR of r. It accepts LF line endings and
+// ignores anything outside the calendar.
+func Parse(r io.Reader) (*Component, error) {
+	lines := newLineReader(r)
+
+	var stack []*Component
+	for {
+		line, number, err := lines.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		prop, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("ical: line %d: %w", number, err)
+		}
+
+		switch prop.Name {
+		case "BEGIN":
+			name := strings.ToUpper(prop.Value)
+			if len(stack) == 0 && name != "VCALENDAR" {
+				continue
+			}
+			component := &Component{Name: name}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.Components = append(parent.Components, component)
+			}
+			stack = append(stack, component)
+		case "END":
+			if len(stack) == 0 {
+				continue
+			}
+			name := strings.ToUpper(prop.Value)
+			current := stack[len(stack)-1]
+			if current.Name != name {
+				return nil, fmt.Errorf("ical: line %d: END:%s closes %s", number, name, current.Name)
+			}
+			if len(stack) == 1 {
+				return current, nil
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if len(stack) == 0 {
+				continue
+			}
+			current := stack[len(stack)-1]
+			current.Properties = append(current.Properties, prop)
+		}
+	}
+
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("ical: %s is not closed", stack[len(stack)-1].Name)
+	}
+	return nil, ErrNoCalendar
+}
+
+// parseLine splits a content line into its name, parameters and value.
+// Parameter values may be quoted and then contain ':' ';' and ','.
+func parseLine(line string) (*Property, error) {
+	prop := &Property{}
+
+	i := strings.IndexAny(line, ";:")
+	if i <= 0 {
+		return nil, fmt.Errorf("malformed content line %q", truncate(line))
+	}
+	prop.Name = strings.ToUpper(line[:i])
+
+	for line[i] == ';' {
+		rest := line[i+1:]
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("malformed parameter in %q", truncate(line))
+		}
+		name := strings.ToUpper(rest[:eq])
+		i += 1 + eq + 1
+
+		var values []string
+		for {
+			if i >= len(line) {
+				return nil, fmt.Errorf("missing value in %q", truncate(line))
+			}
+			if line[i] == '"' {
+				end := strings.IndexByte(line[i+1:], '"')
+				if end < 0 {
+					return nil, fmt.Errorf("unterminated quote in %q", truncate(line))
+				}
+				values = append(values, line[i+1:i+1+end])
+				i += end + 2
+			} else {
+				end := strings.IndexAny(line[i:], ",;:")
+				if end < 0 {
+					return nil, fmt.Errorf("missing value in %q", truncate(line))
+				}
+				values = append(values, line[i:i+end])
+				i += end
+			}
+			if i >= len(line) {
+				return nil, fmt.Errorf("missing value in %q", truncate(line))
+			}
+			if line[i] != ',' {
+				break
+			}
+			i++
+		}
+
+		if prop.Params == nil {
+			prop.Params = make(map[string][]string)
+		}
+		prop.Params[name] = append(prop.Params[name], values...)
+	}
+
+	if line[i] != ':' {
+		return nil, fmt.Errorf("malformed content line %q", truncate(line))
+	}
+	prop.Value = line[i+1:]
+	return prop, nil
+}
+
+// UnescapeText reverses EscapeText
+func UnescapeText(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+func truncate(s string) string {
+	if len(s) > 40 {
+		return s[:40] + "..."
+	}
+	return s
+}
+
+// lineReader unfolds content lines: a line starting with a space or a tab
+// continues the previous one
+type lineReader struct {
+	scanner *bufio.Scanner
+	pending []byte
+	has     bool
+	number  int
+}
+
+func newLineReader(r io.Reader) *lineReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxLineBytes)
+	return &lineReader{scanner: scanner}
+}
+
+func (l *lineReader) next() (string, int, error) {
+	for l.scanner.Scan() {
+		raw := strings.TrimSuffix(l.scanner.Text(), "\r")
+		l.number++
+		if l.number == 1 {
+			raw = strings.TrimPrefix(raw, "\ufeff")
+		}
+
+		if l.has && raw != "" && (raw[0] == ' ' || raw[0] == '\t') {
+			if len(l.pending)+len(raw) > maxLineBytes {
+				return "", l.number, fmt.Errorf("ical: line %d is too long", l.number)
+			}
+			l.pending = append(l.pending, raw[1:]...)
+			continue
+		}
+
+		line, had := string(l.pending), l.has
+		l.pending, l.has = append(l.pending[:0], raw...), true
+		if had {
+			return line, l.number - 1, nil
+		}
+	}
+	if err := l.scanner.Err(); err != nil {
+		return "", l.number, fmt.Errorf("ical: %w", err)
+	}
+	if l.has {
+		l.has = false
+		return string(l.pending), l.number, nil
+	}
+	return "", l.number, io.EOF
+}
@@ -0,0 +1,74 @@
+package ical
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	input := "\ufeffBEGIN:VCALENDAR\n" +
+		"VERSION:2.0\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:folded-\r\n" +
+		" uid@example.com\r\n" +
+		"SUMMARY:Lunch\\, then\\nmeeting\r\n" +
+		"ATTENDEE;CN=\"Doe, Jane\";ROLE=REQ-PARTICIPANT:mailto:jane@example.com\r\n" +
+		"CATEGORIES;X-LIST=a,b:work\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n" +
+		"trailing garbage\r\n"
+
+	cal, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	events := cal.Children("VEVENT")
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+	event := events[0]
+
+	if got := event.Property("UID").Value; got != "folded-uid@example.com" {
+		t.Errorf("UID = %q", got)
+	}
+	if got := event.Property("SUMMARY").Text(); got != "Lunch, then\nmeeting" {
+		t.Errorf("SUMMARY = %q", got)
+	}
+
+	attendee := event.Property("ATTENDEE")
+	if attendee.Param("CN") != "Doe, Jane" || attendee.Param("ROLE") != "REQ-PARTICIPANT" || attendee.Value != "mailto:jane@example.com" {
+		t.Errorf("ATTENDEE = %+v", attendee)
+	}
+	if got := event.Property("CATEGORIES").Params["X-LIST"]; len(got) != 2 || got[0] != "a" || got[1] != "b" {
+		t.Errorf("X-LIST = %q", got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  error
+	}{
+		{name: "empty", input: "", want: ErrNoCalendar},
+		{name: "no calendar", input: "BEGIN:VCARD\r\nEND:VCARD\r\n", want: ErrNoCalendar},
+		{name: "not closed", input: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\n"},
+		{name: "mismatched end", input: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nEND:VCALENDAR\r\n"},
+		{name: "malformed line", input: "BEGIN:VCALENDAR\r\nno colon here\r\nEND:VCALENDAR\r\n"},
+		{name: "unterminated quote", input: "BEGIN:VCALENDAR\r\nX-A;CN=\"open:value\r\nEND:VCALENDAR\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.input))
+			if err == nil {
+				t.Fatal("Parse succeeded, want an error")
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("Parse error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
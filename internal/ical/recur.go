@@ -0,0 +1,378 @@
+package ical
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// weekdayNum is a BYDAY entry such as MO or -1SU. n is 0 for every matching
+// day of the period.
+type weekdayNum struct {
+	n   int
+	day time.Weekday
+}
+
+// recurrence is an RRULE. Rules with BYHOUR, BYMINUTE, BYSECOND, BYWEEKNO
+// or BYYEARDAY, or with a frequency below a day, are rejected.
+type recurrence struct {
+	freq       string
+	interval   int
+	count      int
+	until      time.Time
+	hasUntil   bool
+	byDay      []weekdayNum
+	byMonthDay []int
+	byMonth    []int
+	bySetPos   []int
+	weekStart  time.Weekday
+}
+
+func parseRecurrence(value string, z *zones) (*recurrence, error) {
+	r := &recurrence{interval: 1, weekStart: time.Monday}
+
+	for _, part := range strings.Split(value, ";") {
+		if part == "" {
+			continue
+		}
+		name, val, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("malformed RRULE part %q", part)
+		}
+
+		var err error
+		switch strings.ToUpper(name) {
+		case "FREQ":
+			r.freq = strings.ToUpper(val)
+		case "INTERVAL":
+			r.interval, err = strconv.Atoi(val)
+			if err == nil && r.interval < 1 {
+				err = fmt.Errorf("INTERVAL must be positive")
+			}
+		case "COUNT":
+			r.count, err = strconv.Atoi(val)
+			if err == nil && r.count < 1 {
+				err = fmt.Errorf("COUNT must be positive")
+			}
+		case "UNTIL":
+			// UNTIL is in UTC or, for floating and all day events, in the
+			// floating time zone. A date includes the whole day.
+			var wall time.Time
+			var zone zone
+			var allDay bool
+			wall, zone, allDay, err = z.parseValue(val, "")
+			if err == nil {
+				if allDay {
+					wall = wall.AddDate(0, 0, 1).Add(-time.Nanosecond)
+				}
+				r.until, r.hasUntil = zone.fromWall(wall), true
+			}
+		case "BYDAY":
+			for _, item := range strings.Split(val, ",") {
+				var num weekdayNum
+				num, err = parseWeekdayNum(item)
+				if err != nil {
+					break
+				}
+				r.byDay = append(r.byDay, num)
+			}
+		case "BYMONTHDAY":
+			r.byMonthDay, err = parseInts(val, 31, true)
+		case "BYMONTH":
+			r.byMonth, err = parseInts(val, 12, false)
+		case "BYSETPOS":
+			r.bySetPos, err = parseInts(val, 366, true)
+		case "WKST":
+			day, ok := weekdays[strings.ToUpper(val)]
+			if !ok {
+				err = fmt.Errorf("invalid WKST %q", val)
+			}
+			r.weekStart = day
+		case "BYHOUR", "BYMINUTE", "BYSECOND", "BYWEEKNO", "BYYEARDAY":
+			return nil, fmt.Errorf("RRULE %s is not supported", strings.ToUpper(name))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("RRULE %s: %w", strings.ToUpper(name), err)
+		}
+	}
+
+	switch r.freq {
+	case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+	case "":
+		return nil, fmt.Errorf("RRULE without FREQ")
+	default:
+		return nil, fmt.Errorf("RRULE FREQ=%s is not supported", r.freq)
+	}
+
+	return r, nil
+}
+
+func parseWeekdayNum(s string) (weekdayNum, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if len(s) < 2 {
+		return weekdayNum{}, fmt.Errorf("invalid weekday %q", s)
+	}
+	day, ok := weekdays[s[len(s)-2:]]
+	if !ok {
+		return weekdayNum{}, fmt.Errorf("invalid weekday %q", s)
+	}
+
+	num := weekdayNum{day: day}
+	if prefix := s[:len(s)-2]; prefix != "" {
+		n, err := strconv.Atoi(prefix)
+		if err != nil || n == 0 || n < -53 || n > 53 {
+			return weekdayNum{}, fmt.Errorf("invalid weekday %q", s)
+		}
+		num.n = n
+	}
+	return num, nil
+}
+
+// parseInts reads a list of numbers up to limit, zero excluded. Negative
+// ones count from the end when allowed.
+func parseInts(s string, limit int, negative bool) ([]int, error) {
+	var values []int
+	for _, item := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 || n > limit || n < -limit || (n < 0 && !negative) {
+			return nil, fmt.Errorf("%d is out of range", n)
+		}
+		values = append(values, n)
+	}
+	return values, nil
+}
+
+// each calls fn with the wall clock times of the occurrences from start on,
+// start included, until fn returns false, the rule ends or a period starts
+// after limit. Times are wall clock readings stored in UTC, z turns them
+// into instants to compare with UNTIL.
+func (r *recurrence) each(start time.Time, z zone, limit time.Time, fn func(wall time.Time) bool) {
+	emitted := 0
+	emit := func(wall time.Time) bool {
+		if r.hasUntil && z.fromWall(wall).After(r.until) {
+			return false
+		}
+		emitted++
+		if !fn(wall) {
+			return false
+		}
+		return r.count == 0 || emitted < r.count
+	}
+
+	// DTSTART is always the first occurrence
+	if !emit(start) {
+		return
+	}
+
+	clock := start.Sub(dateOf(start))
+	for period := r.firstPeriod(start); !period.After(limit); period = r.nextPeriod(period) {
+		for _, day := range r.candidates(period, start) {
+			wall := day.Add(clock)
+			if !wall.After(start) {
+				continue
+			}
+			if !emit(wall) {
+				return
+			}
+		}
+	}
+}
+
+func (r *recurrence) firstPeriod(start time.Time) time.Time {
+	day := dateOf(start)
+	switch r.freq {
+	case "WEEKLY":
+		back := (int(day.Weekday()) - int(r.weekStart) + 7) % 7
+		return day.AddDate(0, 0, -back)
+	case "MONTHLY":
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+	case "YEARLY":
+		return time.Date(day.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return day
+	}
+}
+
+func (r *recurrence) nextPeriod(period time.Time) time.Time {
+	switch r.freq {
+	case "WEEKLY":
+		return period.AddDate(0, 0, 7*r.interval)
+	case "MONTHLY":
+		return period.AddDate(0, r.interval, 0)
+	case "YEARLY":
+		return period.AddDate(r.interval, 0, 0)
+	default:
+		return period.AddDate(0, 0, r.interval)
+	}
+}
+
+// candidates returns the days of the period the rule matches, in order
+func (r *recurrence) candidates(period, start time.Time) []time.Time {
+	var days []time.Time
+
+	switch r.freq {
+	case "DAILY":
+		if r.matchMonth(period) && r.matchMonthDay(period) && r.matchWeekday(period, period, period) {
+			days = append(days, period)
+		}
+
+	case "WEEKLY":
+		for i := 0; i < 7; i++ {
+			day := period.AddDate(0, 0, i)
+			if !r.matchMonth(day) {
+				continue
+			}
+			if len(r.byDay) > 0 {
+				if r.matchWeekday(day, day, day) {
+					days = append(days, day)
+				}
+			} else if day.Weekday() == start.Weekday() {
+				days = append(days, day)
+			}
+		}
+
+	case "MONTHLY":
+		if r.matchMonth(period) {
+			days = r.monthDays(period, start)
+		}
+
+	case "YEARLY":
+		switch {
+		case len(r.byMonth) > 0:
+			for month := time.January; month <= time.December; month++ {
+				first := time.Date(period.Year(), month, 1, 0, 0, 0, 0, time.UTC)
+				if r.matchMonth(first) {
+					days = append(days, r.monthDays(first, start)...)
+				}
+			}
+		case len(r.byMonthDay) > 0:
+			for month := time.January; month <= time.December; month++ {
+				first := time.Date(period.Year(), month, 1, 0, 0, 0, 0, time.UTC)
+				days = append(days, r.monthDays(first, start)...)
+			}
+		case len(r.byDay) > 0:
+			// Ordinals count within the year
+			end := period.AddDate(1, 0, 0)
+			for day := period; day.Before(end); day = day.AddDate(0, 0, 1) {
+				if r.matchWeekday(day, period, end) {
+					days = append(days, day)
+				}
+			}
+		default:
+			day := time.Date(period.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+			// February 29 only recurs in leap years
+			if day.Day() == start.Day() {
+				days = append(days, day)
+			}
+		}
+	}
+
+	return r.setPos(days)
+}
+
+// monthDays returns the matching days of the month starting at first
+func (r *recurrence) monthDays(first, start time.Time) []time.Time {
+	end := first.AddDate(0, 1, 0)
+
+	var days []time.Time
+	for day := first; day.Before(end); day = day.AddDate(0, 0, 1) {
+		switch {
+		case len(r.byMonthDay) == 0 && len(r.byDay) == 0:
+			if day.Day() == start.Day() {
+				days = append(days, day)
+			}
+		case r.matchMonthDay(day) && r.matchWeekday(day, first, end):
+			days = append(days, day)
+		}
+	}
+	return days
+}
+
+func (r *recurrence) matchMonth(day time.Time) bool {
+	if len(r.byMonth) == 0 {
+		return true
+	}
+	for _, month := range r.byMonth {
+		if time.Month(month) == day.Month() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *recurrence) matchMonthDay(day time.Time) bool {
+	if len(r.byMonthDay) == 0 {
+		return true
+	}
+	last := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	for _, n := range r.byMonthDay {
+		if n == day.Day() || (n < 0 && last+1+n == day.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchWeekday checks BYDAY, counting ordinals within [scopeStart, scopeEnd)
+func (r *recurrence) matchWeekday(day, scopeStart, scopeEnd time.Time) bool {
+	if len(r.byDay) == 0 {
+		return true
+	}
+	for _, num := range r.byDay {
+		if num.day != day.Weekday() {
+			continue
+		}
+		if num.n == 0 {
+			return true
+		}
+		fromStart := int(day.Sub(scopeStart).Hours()/24)/7 + 1
+		fromEnd := -(int(scopeEnd.Sub(day).Hours()/24-1)/7 + 1)
+		if num.n == fromStart || num.n == fromEnd {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *recurrence) setPos(days []time.Time) []time.Time {
+	if len(r.bySetPos) == 0 || len(days) == 0 {
+		return days
+	}
+
+	picked := make(map[time.Time]bool)
+	for _, pos := range r.bySetPos {
+		i := pos - 1
+		if pos < 0 {
+			i = len(days) + pos
+		}
+		if i >= 0 && i < len(days) {
+			picked[days[i]] = true
+		}
+	}
+
+	result := make([]time.Time, 0, len(picked))
+	for day := range picked {
+		result = append(result, day)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Before(result[j]) })
+	return result
+}
+
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
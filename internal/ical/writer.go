@@ -0,0 +1,129 @@
+// Package ical reads and writes the iCalendar format of RFC 5545, as far as
+// calendar feeds and busy times from external calendars need it.
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	dateTimeUTC = "20060102T150405Z"
+	// maxLineOctets is the longest content line before it is folded
+	maxLineOctets = 75
+)
+
+// Event is a VEVENT of a feed. Times are written in UTC.
+type Event struct {
+	UID          string
+	Start        time.Time
+	End          time.Time
+	Summary      string
+	Description  string
+	Location     string
+	Sequence     int
+	Created      time.Time
+	LastModified time.Time
+}
+
+// Calendar is a VCALENDAR published as a feed
+type Calendar struct {
+	ProdID string
+	Name   string
+	// RefreshInterval tells clients how often to fetch the feed again
+	RefreshInterval time.Duration
+	Events          []Event
+}
+
+// Write writes the calendar with CRLF line endings and folded lines
+func (c *Calendar) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	line := func(name, value string) {
+		writeFolded(bw, name+":"+value)
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", c.ProdID)
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	if c.Name != "" {
+		line("X-WR-CALNAME", EscapeText(c.Name))
+	}
+	if c.RefreshInterval > 0 {
+		line("REFRESH-INTERVAL;VALUE=DURATION", formatDuration(c.RefreshInterval))
+		line("X-PUBLISHED-TTL", formatDuration(c.RefreshInterval))
+	}
+
+	now := time.Now()
+	for _, event := range c.Events {
+		line("BEGIN", "VEVENT")
+		line("UID", event.UID)
+		line("DTSTAMP", now.UTC().Format(dateTimeUTC))
+		line("DTSTART", event.Start.UTC().Format(dateTimeUTC))
+		line("DTEND", event.End.UTC().Format(dateTimeUTC))
+		line("SUMMARY", EscapeText(event.Summary))
+		if event.Description != "" {
+			line("DESCRIPTION", EscapeText(event.Description))
+		}
+		if event.Location != "" {
+			line("LOCATION", EscapeText(event.Location))
+		}
+		line("SEQUENCE", strconv.Itoa(event.Sequence))
+		line("STATUS", "CONFIRMED")
+		line("TRANSP", "OPAQUE")
+		if !event.Created.IsZero() {
+			line("CREATED", event.Created.UTC().Format(dateTimeUTC))
+		}
+		if !event.LastModified.IsZero() {
+			line("LAST-MODIFIED", event.LastModified.UTC().Format(dateTimeUTC))
+		}
+		line("END", "VEVENT")
+	}
+
+	line("END", "VCALENDAR")
+	return bw.Flush()
+}
+
+// EscapeText escapes a TEXT value
+func EscapeText(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	).Replace(s)
+}
+
+// writeFolded writes a content line, folding it into lines of at most 75
+// octets without splitting a UTF-8 character
+func writeFolded(w *bufio.Writer, line string) {
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		w.WriteString(line[:cut])
+		w.WriteString("\r\n ")
+		line = line[cut:]
+		// The leading space of a continuation line counts
+		limit = maxLineOctets - 1
+	}
+	w.WriteString(line)
+	w.WriteString("\r\n")
+}
+
+// formatDuration writes a duration as PT<n>M or PT<n>S
+func formatDuration(d time.Duration) string {
+	if d%time.Minute == 0 {
+		return "PT" + strconv.Itoa(int(d/time.Minute)) + "M"
+	}
+	return "PT" + strconv.Itoa(int(d/time.Second)) + "S"
+}
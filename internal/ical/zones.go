@@ -0,0 +1,311 @@
+package ical
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	dateLayout     = "20060102"
+	dateTimeLayout = "20060102T150405"
+)
+
+// zone turns a wall clock reading, stored as a UTC time, into an instant
+type zone interface {
+	fromWall(wall time.Time) time.Time
+}
+
+type locationZone struct {
+	loc *time.Location
+}
+
+func (z locationZone) fromWall(wall time.Time) time.Time {
+	return time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), wall.Nanosecond(), z.loc)
+}
+
+type fixedZone time.Duration
+
+func (z fixedZone) fromWall(wall time.Time) time.Time {
+	return wall.Add(-time.Duration(z))
+}
+
+// zones resolves the TZID parameters of a calendar. IANA names are looked up
+// in the time zone database, other names through the VTIMEZONE definitions
+// of the calendar.
+type zones struct {
+	floating    *time.Location
+	definitions map[string]*Component
+	resolved    map[string]zone
+}
+
+func newZones(cal *Component, floating *time.Location) *zones {
+	z := &zones{
+		floating:    floating,
+		definitions: make(map[string]*Component),
+		resolved:    make(map[string]zone),
+	}
+	for _, tz := range cal.Children("VTIMEZONE") {
+		if id := tz.Property("TZID"); id != nil {
+			z.definitions[id.Value] = tz
+		}
+	}
+	return z
+}
+
+func (z *zones) lookup(tzid string) (zone, error) {
+	if resolved, ok := z.resolved[tzid]; ok {
+		return resolved, nil
+	}
+
+	resolved, err := z.resolve(tzid)
+	if err != nil {
+		return nil, err
+	}
+	z.resolved[tzid] = resolved
+	return resolved, nil
+}
+
+func (z *zones) resolve(tzid string) (zone, error) {
+	if loc := loadLocation(tzid); loc != nil {
+		return locationZone{loc}, nil
+	}
+
+	definition, ok := z.definitions[tzid]
+	if !ok {
+		return nil, fmt.Errorf("unknown time zone %q", tzid)
+	}
+	if location := definition.Property("X-LIC-LOCATION"); location != nil {
+		if loc := loadLocation(location.Value); loc != nil {
+			return locationZone{loc}, nil
+		}
+	}
+	return z.parseDefinition(tzid, definition)
+}
+
+// loadLocation finds an IANA zone, also behind prefixes such as
+// /mozilla.org/20050126_1/America/New_York
+func loadLocation(name string) *time.Location {
+	if name == "" || name == "Local" {
+		return nil
+	}
+
+	parts := strings.Split(strings.Trim(name, "/"), "/")
+	for i := range parts {
+		candidate := strings.Join(parts[i:], "/")
+		if candidate == "" || candidate == "Local" {
+			continue
+		}
+		if loc, err := time.LoadLocation(candidate); err == nil {
+			return loc
+		}
+	}
+	return nil
+}
+
+// parseValue reads a DATE or DATE-TIME value. Times ending in Z are UTC,
+// others are in tzid or, without it, floating.
+func (z *zones) parseValue(value, tzid string) (time.Time, zone, bool, error) {
+	value = strings.TrimSpace(value)
+
+	if len(value) == len(dateLayout) {
+		wall, err := time.Parse(dateLayout, value)
+		if err != nil {
+			return time.Time{}, nil, false, fmt.Errorf("invalid date %q", value)
+		}
+		return wall, locationZone{z.floating}, true, nil
+	}
+
+	utc := strings.HasSuffix(value, "Z")
+	wall, err := time.Parse(dateTimeLayout, strings.TrimSuffix(value, "Z"))
+	if err != nil {
+		return time.Time{}, nil, false, fmt.Errorf("invalid date-time %q", value)
+	}
+
+	switch {
+	case utc:
+		return wall, locationZone{time.UTC}, false, nil
+	case tzid != "":
+		zone, err := z.lookup(tzid)
+		if err != nil {
+			return time.Time{}, nil, false, err
+		}
+		return wall, zone, false, nil
+	default:
+		return wall, locationZone{z.floating}, false, nil
+	}
+}
+
+// parseTime reads a DTSTART, DTEND, RECURRENCE-ID or similar property
+func (z *zones) parseTime(prop *Property) (time.Time, zone, bool, error) {
+	wall, zone, allDay, err := z.parseValue(prop.Value, prop.Param("TZID"))
+	if err != nil {
+		return time.Time{}, nil, false, fmt.Errorf("%s: %w", prop.Name, err)
+	}
+	return wall, zone, allDay, nil
+}
+
+// parseTimes reads the instants of an EXDATE or RDATE, which may list
+// several values. Periods of an RDATE count by their start.
+func (z *zones) parseTimes(prop *Property) ([]time.Time, error) {
+	var times []time.Time
+	for _, value := range strings.Split(prop.Value, ",") {
+		value, _, _ = strings.Cut(value, "/")
+		wall, zone, _, err := z.parseValue(value, prop.Param("TZID"))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", prop.Name, err)
+		}
+		times = append(times, zone.fromWall(wall))
+	}
+	return times, nil
+}
+
+// definedZone follows the STANDARD and DAYLIGHT observances of a VTIMEZONE
+type definedZone struct {
+	observances []*observance
+}
+
+type observance struct {
+	start      time.Time
+	offsetFrom time.Duration
+	offsetTo   time.Duration
+	rule       *recurrence
+	rdates     []time.Time
+
+	// onsets are the wall clock times the observance began, up to through
+	onsets  []time.Time
+	through time.Time
+}
+
+func (z *zones) parseDefinition(tzid string, definition *Component) (zone, error) {
+	zone := &definedZone{}
+
+	for _, child := range definition.Components {
+		if child.Name != "STANDARD" && child.Name != "DAYLIGHT" {
+			continue
+		}
+
+		start := child.Property("DTSTART")
+		from := child.Property("TZOFFSETFROM")
+		to := child.Property("TZOFFSETTO")
+		if start == nil || from == nil || to == nil {
+			return nil, fmt.Errorf("time zone %q: %s without DTSTART, TZOFFSETFROM or TZOFFSETTO", tzid, child.Name)
+		}
+
+		obs := &observance{}
+		var err error
+		if obs.start, err = time.Parse(dateTimeLayout, strings.TrimSuffix(start.Value, "Z")); err != nil {
+			return nil, fmt.Errorf("time zone %q: invalid DTSTART %q", tzid, start.Value)
+		}
+		if obs.offsetFrom, err = parseOffset(from.Value); err != nil {
+			return nil, fmt.Errorf("time zone %q: %w", tzid, err)
+		}
+		if obs.offsetTo, err = parseOffset(to.Value); err != nil {
+			return nil, fmt.Errorf("time zone %q: %w", tzid, err)
+		}
+		if rule := child.Property("RRULE"); rule != nil {
+			if obs.rule, err = parseRecurrence(rule.Value, z); err != nil {
+				return nil, fmt.Errorf("time zone %q: %w", tzid, err)
+			}
+		}
+		for _, rdate := range child.PropertyValues("RDATE") {
+			for _, value := range strings.Split(rdate.Value, ",") {
+				wall, err := time.Parse(dateTimeLayout, strings.TrimSuffix(value, "Z"))
+				if err != nil {
+					return nil, fmt.Errorf("time zone %q: invalid RDATE %q", tzid, value)
+				}
+				obs.rdates = append(obs.rdates, wall)
+			}
+		}
+
+		zone.observances = append(zone.observances, obs)
+	}
+
+	if len(zone.observances) == 0 {
+		return nil, fmt.Errorf("time zone %q has no observances", tzid)
+	}
+	return zone, nil
+}
+
+// fromWall applies the offset of the observance that began last before
+// wall. Before the first one, the offset it changed from applies.
+func (z *definedZone) fromWall(wall time.Time) time.Time {
+	var (
+		latest time.Time
+		offset time.Duration
+		found  bool
+	)
+	for _, obs := range z.observances {
+		onset, ok := obs.lastOnset(wall)
+		if ok && (!found || onset.After(latest)) {
+			latest, offset, found = onset, obs.offsetTo, true
+		}
+	}
+
+	if !found {
+		earliest := z.observances[0]
+		for _, obs := range z.observances[1:] {
+			if obs.start.Before(earliest.start) {
+				earliest = obs
+			}
+		}
+		offset = earliest.offsetFrom
+	}
+
+	return wall.Add(-offset)
+}
+
+func (o *observance) lastOnset(wall time.Time) (time.Time, bool) {
+	if wall.Before(o.start) {
+		return time.Time{}, false
+	}
+
+	if wall.After(o.through) {
+		// Work out a year more so the next lookups hit
+		o.through = wall.AddDate(1, 0, 0)
+		o.onsets = o.onsets[:0]
+		if o.rule != nil {
+			o.rule.each(o.start, fixedZone(o.offsetFrom), o.through, func(onset time.Time) bool {
+				if onset.After(o.through) {
+					return false
+				}
+				o.onsets = append(o.onsets, onset)
+				return true
+			})
+		} else {
+			o.onsets = append(o.onsets, o.start)
+		}
+		o.onsets = append(o.onsets, o.rdates...)
+		sort.Slice(o.onsets, func(i, j int) bool { return o.onsets[i].Before(o.onsets[j]) })
+	}
+
+	i := sort.Search(len(o.onsets), func(i int) bool { return o.onsets[i].After(wall) })
+	if i == 0 {
+		return time.Time{}, false
+	}
+	return o.onsets[i-1], true
+}
+
+// parseOffset reads a UTC offset such as +0200 or -053000
+func parseOffset(value string) (time.Duration, error) {
+	if len(value) != 5 && len(value) != 7 || (value[0] != '+' && value[0] != '-') {
+		return 0, fmt.Errorf("invalid UTC offset %q", value)
+	}
+
+	var parts [3]int
+	for i := 0; i < (len(value)-1)/2; i++ {
+		n, err := strconv.Atoi(value[1+2*i : 3+2*i])
+		if err != nil {
+			return 0, fmt.Errorf("invalid UTC offset %q", value)
+		}
+		parts[i] = n
+	}
+
+	offset := time.Duration(parts[0])*time.Hour + time.Duration(parts[1])*time.Minute + time.Duration(parts[2])*time.Second
+	if value[0] == '-' {
+		offset = -offset
+	}
+	return offset, nil
+}
@@ -0,0 +1,94 @@
+// Package lockout slows down and locks out repeated failed sign ins. Failures
+// are counted per key, such as an account or an IP address, in a Store.
+package lockout
+
+import (
+	"context"
+	"time"
+)
+
+type State struct {
+	Failures     int64
+	BlockedUntil time.Time
+}
+
+type Store interface {
+	Get(ctx context.Context, key string) (State, error)
+	// Fail counts a failure of the key and returns the failures so far. The
+	// failures are forgotten window after the last one.
+	Fail(ctx context.Context, key string, window time.Duration) (int64, error)
+	Block(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+}
+
+// Policy tells how a key is slowed down. After FreeAttempts failures every
+// failure doubles the wait before the next attempt, up to MaxDelay. After
+// MaxFailures the key is locked for LockDuration.
+type Policy struct {
+	FreeAttempts int64
+	MaxDelay     time.Duration
+	MaxFailures  int64
+	LockDuration time.Duration
+	Window       time.Duration
+}
+
+// delay returns how long the key waits after its failures, 0 while they are
+// free
+func (p Policy) delay(failures int64) time.Duration {
+	switch {
+	case failures >= p.MaxFailures:
+		return p.LockDuration
+	case failures <= p.FreeAttempts:
+		return 0
+	}
+
+	// Doubling past MaxDelay would overflow the shift, so stop there
+	delay := time.Second
+	for n := failures - p.FreeAttempts - 1; n > 0 && delay < p.MaxDelay; n-- {
+		delay *= 2
+	}
+	return min(delay, p.MaxDelay)
+}
+
+type Guard struct {
+	store Store
+}
+
+func NewGuard(store Store) *Guard {
+	return &Guard{store: store}
+}
+
+// Wait returns how long the key has to wait before its next attempt
+func (g *Guard) Wait(ctx context.Context, key string) (time.Duration, error) {
+	state, err := g.store.Get(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+
+	return max(time.Until(state.BlockedUntil), 0), nil
+}
+
+// Fail records a failed attempt and blocks the key for the delay of the
+// policy. locked is true for the failure that locks the key.
+func (g *Guard) Fail(ctx context.Context, key string, policy Policy) (bool, error) {
+	failures, err := g.store.Fail(ctx, key, policy.Window)
+	if err != nil {
+		return false, err
+	}
+
+	delay := policy.delay(failures)
+	if delay == 0 {
+		return false, nil
+	}
+
+	if err := g.store.Block(ctx, key, time.Now().UTC().Add(delay)); err != nil {
+		return false, err
+	}
+
+	return failures == policy.MaxFailures, nil
+}
+
+// Reset forgets the failures of the key and lifts any lock
+func (g *Guard) Reset(ctx context.Context, key string) error {
+	return g.store.Reset(ctx, key)
+}
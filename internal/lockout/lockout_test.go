@@ -0,0 +1,124 @@
+package lockout
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// ipPolicy is the IP policy of cmd/main.go
+var ipPolicy = Policy{
+	FreeAttempts: 20,
+	MaxDelay:     30 * time.Second,
+	MaxFailures:  100,
+	LockDuration: 15 * time.Minute,
+	Window:       15 * time.Minute,
+}
+
+func TestPolicyDelay(t *testing.T) {
+	tests := []struct {
+		failures int64
+		want     time.Duration
+	}{
+		{failures: 1, want: 0},
+		{failures: 20, want: 0},
+		{failures: 21, want: time.Second},
+		{failures: 22, want: 2 * time.Second},
+		{failures: 25, want: 16 * time.Second},
+		{failures: 26, want: 30 * time.Second},
+		{failures: 50, want: 30 * time.Second},
+		{failures: 55, want: 30 * time.Second},
+		{failures: 75, want: 30 * time.Second},
+		{failures: 84, want: 30 * time.Second},
+		{failures: 99, want: 30 * time.Second},
+		{failures: 100, want: 15 * time.Minute},
+		{failures: 500, want: 15 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := ipPolicy.delay(tt.failures); got != tt.want {
+			t.Errorf("delay(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestPolicyDelayNeverShrinks(t *testing.T) {
+	policy := ipPolicy
+	policy.MaxFailures = 1_000
+
+	previous := time.Duration(0)
+	for failures := int64(1); failures < policy.MaxFailures; failures++ {
+		delay := policy.delay(failures)
+		if delay < previous || delay > policy.MaxDelay {
+			t.Fatalf("delay(%d) = %v after %v", failures, delay, previous)
+		}
+		previous = delay
+	}
+}
+
+// memoryStore keeps the state of the keys in a map
+type memoryStore struct {
+	states map[string]State
+}
+
+func (s *memoryStore) Get(ctx context.Context, key string) (State, error) {
+	return s.states[key], nil
+}
+
+func (s *memoryStore) Fail(ctx context.Context, key string, window time.Duration) (int64, error) {
+	state := s.states[key]
+	state.Failures++
+	s.states[key] = state
+	return state.Failures, nil
+}
+
+func (s *memoryStore) Block(ctx context.Context, key string, until time.Time) error {
+	state := s.states[key]
+	state.BlockedUntil = until
+	s.states[key] = state
+	return nil
+}
+
+func (s *memoryStore) Reset(ctx context.Context, key string) error {
+	delete(s.states, key)
+	return nil
+}
+
+func TestGuard(t *testing.T) {
+	ctx := context.Background()
+	guard := NewGuard(&memoryStore{states: map[string]State{}})
+	const key = "ip:192.0.2.1"
+
+	for i := int64(1); i < ipPolicy.MaxFailures; i++ {
+		locked, err := guard.Fail(ctx, key, ipPolicy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if locked {
+			t.Fatalf("locked after %d failures", i)
+		}
+
+		wait, err := guard.Wait(ctx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i > 60 && wait < 29*time.Second {
+			t.Fatalf("wait after %d failures = %v, want about 30s", i, wait)
+		}
+	}
+
+	locked, err := guard.Fail(ctx, key, ipPolicy)
+	if err != nil || !locked {
+		t.Fatalf("Fail = %v, %v, want the key locked", locked, err)
+	}
+	if wait, _ := guard.Wait(ctx, key); wait < 14*time.Minute {
+		t.Fatalf("wait after the lock = %v", wait)
+	}
+
+	if err := guard.Reset(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if wait, _ := guard.Wait(ctx, key); wait != 0 {
+		t.Fatalf("wait after Reset = %v", wait)
+	}
+}
@@ -0,0 +1,53 @@
+package lockout
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/georgifotev1/bms/internal/store"
+)
+
+// PostgresStore keeps the failures in the sign_in_throttles table for setups
+// without Redis
+type PostgresStore struct {
+	store store.Store
+}
+
+func NewPostgresStore(store store.Store) *PostgresStore {
+	return &PostgresStore{store: store}
+}
+
+func (s *PostgresStore) Get(ctx context.Context, key string) (State, error) {
+	throttle, err := s.store.GetSignInThrottle(ctx, key)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return State{}, nil
+		}
+		return State{}, err
+	}
+
+	return State{
+		Failures:     int64(throttle.Failures),
+		BlockedUntil: throttle.BlockedUntil.Time,
+	}, nil
+}
+
+func (s *PostgresStore) Fail(ctx context.Context, key string, window time.Duration) (int64, error) {
+	failures, err := s.store.FailSignInThrottle(ctx, store.FailSignInThrottleParams{
+		Key:       key,
+		ExpiresAt: time.Now().UTC().Add(window),
+	})
+	return int64(failures), err
+}
+
+func (s *PostgresStore) Block(ctx context.Context, key string, until time.Time) error {
+	return s.store.BlockSignInThrottle(ctx, store.BlockSignInThrottleParams{
+		Key:          key,
+		BlockedUntil: sql.NullTime{Time: until.UTC(), Valid: true},
+	})
+}
+
+func (s *PostgresStore) Reset(ctx context.Context, key string) error {
+	return s.store.DeleteSignInThrottle(ctx, key)
+}
@@ -0,0 +1,83 @@
+package lockout
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type RedisStore struct {
+	rdb *redis.Client
+}
+
+func NewRedisStore(rdb *redis.Client) *RedisStore {
+	return &RedisStore{rdb: rdb}
+}
+
+func redisKey(key string) string {
+	return "lockout-" + key
+}
+
+func (s *RedisStore) Get(ctx context.Context, key string) (State, error) {
+	values, err := s.rdb.HGetAll(ctx, redisKey(key)).Result()
+	if err != nil {
+		return State{}, err
+	}
+
+	var state State
+	if failures, ok := values["failures"]; ok {
+		state.Failures, _ = strconv.ParseInt(failures, 10, 64)
+	}
+	if blockedUntil, ok := values["blocked_until"]; ok {
+		ms, _ := strconv.ParseInt(blockedUntil, 10, 64)
+		state.BlockedUntil = time.UnixMilli(ms)
+	}
+
+	return state, nil
+}
+
+func (s *RedisStore) Fail(ctx context.Context, key string, window time.Duration) (int64, error) {
+	var failures *redis.IntCmd
+	var ttl *redis.DurationCmd
+	_, err := s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		failures = pipe.HIncrBy(ctx, redisKey(key), "failures", 1)
+		ttl = pipe.PTTL(ctx, redisKey(key))
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// A lock that lasts longer than the window keeps its expiry
+	if ttl.Val() < window {
+		if err := s.rdb.PExpire(ctx, redisKey(key), window).Err(); err != nil {
+			return 0, err
+		}
+	}
+
+	return failures.Val(), nil
+}
+
+func (s *RedisStore) Block(ctx context.Context, key string, until time.Time) error {
+	var ttl *redis.DurationCmd
+	_, err := s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, redisKey(key), "blocked_until", until.UnixMilli())
+		ttl = pipe.PTTL(ctx, redisKey(key))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// The failures are kept at least as long as the block
+	if ttl.Val() < time.Until(until) {
+		return s.rdb.PExpireAt(ctx, redisKey(key), until).Err()
+	}
+	return nil
+}
+
+func (s *RedisStore) Reset(ctx context.Context, key string) error {
+	return s.rdb.Del(ctx, redisKey(key)).Err()
+}
@@ -1,17 +1,52 @@
 package mailer
 
-import "embed"
+import (
+	"context"
+	"embed"
+)
 
 const (
 	FromName               = "BMS"
 	maxRetires             = 3
 	UserInvitationTemplate = "user_invitation.tmpl"
 	WelcomeTemplate        = "welcome.tmpl"
+	VerifyEmailTemplate    = "verify_email.tmpl"
+	PasswordResetTemplate  = "password_reset.tmpl"
+	SignInCodeTemplate     = "sign_in_code.tmpl"
+	AccountLockedTemplate  = "account_locked.tmpl"
+)
+
+const (
+	ChannelEmail = "email"
+	ChannelSMS   = "sms"
+
+	PurposeTransactional = "transactional"
+	PurposeReminders     = "reminders"
+	PurposeMarketing     = "marketing"
 )
 
+// templatePurposes tells what every template is sent for. Templates missing
+// here are treated as marketing.
+var templatePurposes = map[string]string{
+	UserInvitationTemplate: PurposeTransactional,
+	WelcomeTemplate:        PurposeTransactional,
+	VerifyEmailTemplate:    PurposeTransactional,
+	PasswordResetTemplate:  PurposeTransactional,
+	SignInCodeTemplate:     PurposeTransactional,
+	AccountLockedTemplate:  PurposeTransactional,
+}
+
 //go:embed "templates"
 var FS embed.FS
 
 type Client interface {
-	Send(templateFile, username, email string, data any) (int, error)
+	Send(ctx context.Context, templateFile, username, email string, data any) (int, error)
+}
+
+// TemplatePurpose returns the purpose a template is sent for
+func TemplatePurpose(templateFile string) string {
+	if purpose, ok := templatePurposes[templateFile]; ok {
+		return purpose
+	}
+	return PurposeMarketing
 }
@@ -0,0 +1,41 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrRecipientOptedOut = errors.New("the recipient does not accept this kind of message")
+
+// Preferences reports whether a recipient accepts messages sent over a
+// channel for a purpose
+type Preferences interface {
+	Allows(ctx context.Context, channel, recipient, purpose string) (bool, error)
+}
+
+type preferencesClient struct {
+	client      Client
+	preferences Preferences
+}
+
+// NewPreferencesClient wraps a client so that a message is only sent when the
+// recipient accepts email for the purpose of its template
+func NewPreferencesClient(client Client, preferences Preferences) Client {
+	return preferencesClient{
+		client:      client,
+		preferences: preferences,
+	}
+}
+
+func (c preferencesClient) Send(ctx context.Context, templateFile, username, email string, data any) (int, error) {
+	allowed, err := c.preferences.Allows(ctx, ChannelEmail, email, TemplatePurpose(templateFile))
+	if err != nil {
+		return -1, err
+	}
+
+	if !allowed {
+		return -1, ErrRecipientOptedOut
+	}
+
+	return c.client.Send(ctx, templateFile, username, email, data)
+}
@@ -0,0 +1,265 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	testClientID = "bms-client"
+	testNonce    = "nonce-1"
+)
+
+// mockIssuer serves a discovery document and a key set with one RSA and one
+// EC key
+type mockIssuer struct {
+	server    *httptest.Server
+	rsaKey    *rsa.PrivateKey
+	ecKey     *ecdsa.PrivateKey
+	jwksFetch atomic.Int32
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &mockIssuer{rsaKey: rsaKey, ecKey: ecKey}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Provider{
+			Issuer:                m.server.URL,
+			AuthorizationEndpoint: m.server.URL + "/authorize",
+			TokenEndpoint:         m.server.URL + "/token",
+			JWKSURI:               m.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		m.jwksFetch.Add(1)
+		json.NewEncoder(w).Encode(map[string]any{"keys": []jwk{
+			{
+				Kty: "RSA",
+				Kid: "rsa-1",
+				Use: "sig",
+				N:   encode(rsaKey.N.Bytes()),
+				E:   encode(big.NewInt(int64(rsaKey.E)).Bytes()),
+			},
+			{
+				Kty: "EC",
+				Kid: "ec-1",
+				Crv: "P-256",
+				X:   encode(ecKey.X.FillBytes(make([]byte, 32))),
+				Y:   encode(ecKey.Y.FillBytes(make([]byte, 32))),
+			},
+		}})
+	})
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+
+	return m
+}
+
+func (m *mockIssuer) claims() map[string]any {
+	now := time.Now()
+	return map[string]any{
+		"iss":            m.server.URL,
+		"sub":            "user-1",
+		"aud":            testClientID,
+		"exp":            now.Add(time.Hour).Unix(),
+		"iat":            now.Unix(),
+		"nonce":          testNonce,
+		"email":          "jane@example.com",
+		"email_verified": true,
+		"name":           "Jane",
+	}
+}
+
+func (m *mockIssuer) sign(t *testing.T, alg, kid string, claims map[string]any) string {
+	t.Helper()
+
+	header, _ := json.Marshal(idTokenHeader{Alg: alg, Kid: kid})
+	payload, _ := json.Marshal(claims)
+	signed := encode(header) + "." + encode(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	switch alg {
+	case "RS256":
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, m.rsaKey, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, m.ecKey, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+
+	return signed + "." + encode(signature)
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func TestVerify(t *testing.T) {
+	issuer := newMockIssuer(t)
+	client := NewClient(5*time.Second, true)
+
+	provider, err := client.Discover(context.Background(), issuer.server.URL)
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		alg     string
+		kid     string
+		modify  func(claims map[string]any)
+		wantErr bool
+	}{
+		{name: "RS256", alg: "RS256", kid: "rsa-1"},
+		{name: "ES256", alg: "ES256", kid: "ec-1"},
+		{name: "audience list", alg: "RS256", kid: "rsa-1", modify: func(c map[string]any) {
+			c["aud"] = []string{"other", testClientID}
+		}},
+		{name: "expired within leeway", alg: "RS256", kid: "rsa-1", modify: func(c map[string]any) {
+			c["exp"] = time.Now().Add(-leeway / 2).Unix()
+		}},
+		{name: "wrong issuer", alg: "RS256", kid: "rsa-1", wantErr: true, modify: func(c map[string]any) {
+			c["iss"] = "https://evil.example.com"
+		}},
+		{name: "wrong audience", alg: "RS256", kid: "rsa-1", wantErr: true, modify: func(c map[string]any) {
+			c["aud"] = "other"
+		}},
+		{name: "expired", alg: "ES256", kid: "ec-1", wantErr: true, modify: func(c map[string]any) {
+			c["exp"] = time.Now().Add(-time.Hour).Unix()
+		}},
+		{name: "issued in the future", alg: "RS256", kid: "rsa-1", wantErr: true, modify: func(c map[string]any) {
+			c["iat"] = time.Now().Add(time.Hour).Unix()
+		}},
+		{name: "wrong nonce", alg: "RS256", kid: "rsa-1", wantErr: true, modify: func(c map[string]any) {
+			c["nonce"] = "replayed"
+		}},
+		{name: "missing subject", alg: "RS256", kid: "rsa-1", wantErr: true, modify: func(c map[string]any) {
+			delete(c, "sub")
+		}},
+		{name: "algorithm does not match the key", alg: "ES256", kid: "rsa-1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := issuer.claims()
+			if tt.modify != nil {
+				tt.modify(claims)
+			}
+			token := issuer.sign(t, tt.alg, tt.kid, claims)
+
+			got, err := client.Verify(context.Background(), provider, testClientID, token, testNonce)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidIDToken) {
+					t.Fatalf("Verify error = %v, want ErrInvalidIDToken", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if got.Subject != "user-1" || got.Email != "jane@example.com" || !got.EmailVerified || got.Name != "Jane" {
+				t.Fatalf("Verify claims = %+v", got)
+			}
+		})
+	}
+}
+
+func TestVerifyTamperedToken(t *testing.T) {
+	issuer := newMockIssuer(t)
+	client := NewClient(5*time.Second, true)
+
+	provider, err := client.Discover(context.Background(), issuer.server.URL)
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+
+	token := issuer.sign(t, "RS256", "rsa-1", issuer.claims())
+	other := issuer.claims()
+	other["sub"] = "admin"
+	forged := issuer.sign(t, "RS256", "rsa-1", other)
+
+	// The claims of one token with the signature of another
+	tampered := token[:len(token)-len(signatureOf(token))] + signatureOf(forged)
+	if _, err := client.Verify(context.Background(), provider, testClientID, tampered, testNonce); !errors.Is(err, ErrInvalidIDToken) {
+		t.Fatalf("Verify error = %v, want ErrInvalidIDToken", err)
+	}
+
+	unsigned := token[:len(token)-len(signatureOf(token))]
+	if _, err := client.Verify(context.Background(), provider, testClientID, unsigned, testNonce); !errors.Is(err, ErrInvalidIDToken) {
+		t.Fatalf("Verify error = %v, want ErrInvalidIDToken", err)
+	}
+}
+
+func signatureOf(token string) string {
+	return token[strings.LastIndex(token, ".")+1:]
+}
+
+func TestVerifyUnknownKey(t *testing.T) {
+	issuer := newMockIssuer(t)
+	client := NewClient(5*time.Second, true)
+
+	provider, err := client.Discover(context.Background(), issuer.server.URL)
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+
+	valid := issuer.sign(t, "RS256", "rsa-1", issuer.claims())
+	if _, err := client.Verify(context.Background(), provider, testClientID, valid, testNonce); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	unknown := issuer.sign(t, "RS256", "rotated", issuer.claims())
+	for i := 0; i < 3; i++ {
+		if _, err := client.Verify(context.Background(), provider, testClientID, unknown, testNonce); !errors.Is(err, ErrInvalidIDToken) {
+			t.Fatalf("Verify error = %v, want ErrInvalidIDToken", err)
+		}
+	}
+
+	// Unknown keys don't fetch the key set again within keysRefetchInterval
+	if got := issuer.jwksFetch.Load(); got != 1 {
+		t.Fatalf("key set fetched %d times, want 1", got)
+	}
+}
+
+func TestNewClientRefusesPrivateIssuers(t *testing.T) {
+	issuer := newMockIssuer(t)
+	client := NewClient(5*time.Second, false)
+
+	if _, err := client.Discover(context.Background(), issuer.server.URL); !errors.Is(err, ErrDiscovery) {
+		t.Fatalf("Discover error = %v, want ErrDiscovery", err)
+	}
+}
@@ -0,0 +1,185 @@
+// Package realtime fans calendar changes out to the clients streaming them.
+// Changes are published by Postgres with NOTIFY, so every API instance sees
+// the changes made through the others.
+package realtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Channel is the NOTIFY channel the calendar_changes trigger publishes on
+const Channel = "calendar_changes"
+
+// subscriberBuffer is how many changes a subscriber may fall behind before it
+// is dropped
+const subscriberBuffer = 64
+
+const (
+	KindCreated   = "created"
+	KindUpdated   = "updated"
+	KindCancelled = "cancelled"
+)
+
+// Change is a row of calendar_changes as sent by NOTIFY
+type Change struct {
+	ID             int64     `json:"id"`
+	BrandID        int32     `json:"brandId"`
+	EventID        int64     `json:"eventId"`
+	Kind           string    `json:"kind"`
+	UserID         int64     `json:"userId"`
+	PreviousUserID *int64    `json:"previousUserId"`
+	StartTime      time.Time `json:"startTime"`
+	EndTime        time.Time `json:"endTime"`
+}
+
+// Concerns tells whether the change shows on the calendar of the staff member
+func (c Change) Concerns(userID int64) bool {
+	return c.UserID == userID || (c.PreviousUserID != nil && *c.PreviousUserID == userID)
+}
+
+// Subscription receives the changes of one brand. C is closed when the
+// subscriber falls behind, the listener loses its connection or the broker
+// closes. Changes may have been missed then, so the client should catch up
+// from the last change it saw.
+type Subscription struct {
+	C <-chan Change
+
+	c       chan Change
+	brandID int32
+	broker  *Broker
+}
+
+func (s *Subscription) Close() {
+	s.broker.remove(s)
+}
+
+type Broker struct {
+	mu     sync.Mutex
+	subs   map[int32]map[*Subscription]struct{}
+	closed bool
+}
+
+func NewBroker() *Broker {
+	return &Broker{subs: make(map[int32]map[*Subscription]struct{})}
+}
+
+func (b *Broker) Subscribe(brandID int32) *Subscription {
+	c := make(chan Change, subscriberBuffer)
+	sub := &Subscription{C: c, c: c, brandID: brandID, broker: b}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		close(c)
+		return sub
+	}
+
+	if b.subs[brandID] == nil {
+		b.subs[brandID] = make(map[*Subscription]struct{})
+	}
+	b.subs[brandID][sub] = struct{}{}
+	return sub
+}
+
+// Publish hands the change to the subscribers of its brand without waiting
+// for them
+func (b *Broker) Publish(change Change) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subs[change.BrandID] {
+		select {
+		case sub.c <- change:
+		default:
+			b.drop(sub)
+		}
+	}
+}
+
+// Reset drops every subscriber so they catch up from the database
+func (b *Broker) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, subs := range b.subs {
+		for sub := range subs {
+			b.drop(sub)
+		}
+	}
+}
+
+// Close drops every subscriber and refuses new ones
+func (b *Broker) Close() {
+	b.Reset()
+
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+}
+
+func (b *Broker) remove(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subs[sub.brandID][sub]; ok {
+		b.drop(sub)
+	}
+}
+
+// drop must be called with the lock held
+func (b *Broker) drop(sub *Subscription) {
+	delete(b.subs[sub.brandID], sub)
+	if len(b.subs[sub.brandID]) == 0 {
+		delete(b.subs, sub.brandID)
+	}
+	close(sub.c)
+}
+
+// Listen publishes the changes sent on Channel until stop is closed. The
+// listener reconnects on its own, subscribers are reset when it does as
+// changes may have been sent in between.
+func (b *Broker) Listen(dsn string, stop <-chan struct{}, onError func(error)) error {
+	listener := pq.NewListener(dsn, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			onError(err)
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(Channel); err != nil {
+		return err
+	}
+
+	ping := time.NewTicker(time.Minute)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case notification := <-listener.Notify:
+			// A nil notification follows a reconnect
+			if notification == nil {
+				b.Reset()
+				continue
+			}
+
+			var change Change
+			if err := json.Unmarshal([]byte(notification.Extra), &change); err != nil {
+				onError(fmt.Errorf("realtime: malformed change: %w", err))
+				continue
+			}
+			b.Publish(change)
+		case <-ping.C:
+			if err := listener.Ping(); err != nil {
+				onError(err)
+			}
+		}
+	}
+}
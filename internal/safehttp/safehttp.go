@@ -0,0 +1,71 @@
+// Package safehttp makes HTTP clients for URLs users enter, which must not
+// reach the services next to the API.
+package safehttp
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+var ErrForbiddenAddress = errors.New("the URL points to a private address")
+
+// reservedNets are not covered by the net.IP checks but are just as likely to
+// reach internal services
+var reservedNets = []*net.IPNet{
+	mustParseCIDR("100.64.0.0/10"), // carrier-grade NAT, routable in some cloud networks
+	mustParseCIDR("192.0.0.0/24"),  // IETF protocol assignments
+	mustParseCIDR("198.18.0.0/15"), // benchmarking
+}
+
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+// forbidden tells if the address must not be reached from a user's URL
+func forbidden(ip net.IP) bool {
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() || ip.IsMulticast() {
+		return true
+	}
+	for _, network := range reservedNets {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewClient returns a client that gives up after timeout. Unless
+// allowPrivate is set, it refuses to connect to loopback, private, link local
+// and other reserved addresses. The check runs on every connection, redirects included,
+// so it holds whatever the names resolve to.
+func NewClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if forbidden(net.ParseIP(host)) {
+				return ErrForbiddenAddress
+			}
+			return nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}
+}
@@ -0,0 +1,39 @@
+package safehttp
+
+import (
+	"net"
+	"testing"
+)
+
+func TestForbidden(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{ip: "127.0.0.1", want: true},
+		{ip: "10.1.2.3", want: true},
+		{ip: "172.16.0.1", want: true},
+		{ip: "192.168.1.1", want: true},
+		{ip: "169.254.169.254", want: true},
+		{ip: "100.64.0.1", want: true},
+		{ip: "100.127.255.254", want: true},
+		{ip: "192.0.0.170", want: true},
+		{ip: "198.18.0.1", want: true},
+		{ip: "198.19.255.255", want: true},
+		{ip: "0.0.0.0", want: true},
+		{ip: "::1", want: true},
+		{ip: "fc00::1", want: true},
+		{ip: "fe80::1", want: true},
+		{ip: "::ffff:100.64.0.1", want: true},
+		{ip: "100.128.0.1", want: false},
+		{ip: "198.20.0.1", want: false},
+		{ip: "93.184.216.34", want: false},
+		{ip: "2606:4700::1111", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := forbidden(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("forbidden(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
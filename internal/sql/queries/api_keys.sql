@@ -0,0 +1,29 @@
+-- name: CreateApiKey :one
+INSERT INTO api_keys (brand_id, created_by, name, prefix, key_hash, scopes, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING *;
+
+-- name: CountActiveBrandApiKeys :one
+SELECT COUNT(*) FROM api_keys
+WHERE brand_id = $1
+AND revoked_at IS NULL
+AND (expires_at IS NULL OR expires_at > NOW());
+
+-- name: GetApiKeyByPrefix :one
+SELECT * FROM api_keys WHERE prefix = $1;
+
+-- name: ListBrandApiKeys :many
+SELECT * FROM api_keys
+WHERE brand_id = $1
+ORDER BY created_at DESC;
+
+-- name: RevokeApiKey :one
+UPDATE api_keys
+SET revoked_at = NOW()
+WHERE id = $1 AND brand_id = $2 AND revoked_at IS NULL
+RETURNING *;
+
+-- name: TouchApiKey :exec
+UPDATE api_keys
+SET last_used_at = NOW(), last_used_ip = $2
+WHERE id = $1;
@@ -0,0 +1,22 @@
+-- name: ListAuditLog :many
+SELECT * FROM audit_log
+WHERE brand_id = sqlc.arg(brand_id)
+AND (sqlc.arg(entity_type)::text = '' OR entity_type = sqlc.arg(entity_type)::text)
+AND (sqlc.arg(entity_id)::text = '' OR entity_id = sqlc.arg(entity_id)::text)
+AND (sqlc.arg(action)::text = '' OR action = sqlc.arg(action)::text)
+AND (sqlc.arg(actor_type)::text = '' OR actor_type = sqlc.arg(actor_type)::text)
+AND (sqlc.arg(actor_id)::bigint = 0 OR actor_id = sqlc.arg(actor_id)::bigint)
+AND (sqlc.narg(created_from)::timestamp IS NULL OR created_at >= sqlc.narg(created_from)::timestamp)
+AND (sqlc.narg(created_to)::timestamp IS NULL OR created_at < sqlc.narg(created_to)::timestamp)
+AND (
+    NOT sqlc.arg(has_cursor)::boolean
+    OR (sqlc.arg(descending)::boolean AND id < sqlc.arg(cursor_id)::bigint)
+    OR (NOT sqlc.arg(descending)::boolean AND id > sqlc.arg(cursor_id)::bigint)
+)
+ORDER BY
+    CASE WHEN sqlc.arg(descending)::boolean THEN id END DESC,
+    id
+LIMIT sqlc.arg(page_limit);
+
+-- name: DeleteAuditLogBefore :execrows
+DELETE FROM audit_log WHERE created_at < $1;
@@ -0,0 +1,41 @@
+-- name: UpsertBrandOidcProvider :one
+INSERT INTO brand_oidc_providers (
+    brand_id, issuer, client_id, client_secret, allowed_domains, default_role, enabled
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7
+) ON CONFLICT (brand_id) DO UPDATE
+SET issuer = EXCLUDED.issuer,
+    client_id = EXCLUDED.client_id,
+    client_secret = EXCLUDED.client_secret,
+    allowed_domains = EXCLUDED.allowed_domains,
+    default_role = EXCLUDED.default_role,
+    enabled = EXCLUDED.enabled,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: GetBrandOidcProvider :one
+SELECT * FROM brand_oidc_providers WHERE brand_id = $1;
+
+-- name: DeleteBrandOidcProvider :execrows
+DELETE FROM brand_oidc_providers WHERE brand_id = $1;
+
+-- name: CreateOidcLoginState :exec
+INSERT INTO oidc_login_states (state, brand_id, nonce, code_verifier, remember, expiry)
+VALUES ($1, $2, $3, $4, $5, $6);
+
+-- name: ConsumeOidcLoginState :one
+DELETE FROM oidc_login_states
+WHERE state = $1 AND expiry > NOW()
+RETURNING *;
+
+-- name: DeleteExpiredOidcLoginStates :execrows
+DELETE FROM oidc_login_states WHERE expiry <= NOW();
+
+-- name: GetUserIdentity :one
+SELECT * FROM user_identities
+WHERE issuer = $1 AND subject = $2;
+
+-- name: CreateUserIdentity :one
+INSERT INTO user_identities (user_id, issuer, subject, email)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
@@ -108,3 +108,10 @@ SELECT id FROM brand WHERE page_url = $1;
 
 -- name: GetBrandById :one
 SELECT * FROM brand WHERE id = $1;
+
+-- name: SetBrandRequireTwoFactor :one
+UPDATE brand
+SET require_two_factor = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
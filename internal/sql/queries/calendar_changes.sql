@@ -0,0 +1,8 @@
+-- name: ListCalendarChangesAfter :many
+SELECT * FROM calendar_changes
+WHERE brand_id = $1 AND id > $2
+ORDER BY id
+LIMIT $3;
+
+-- name: DeleteCalendarChangesBefore :execrows
+DELETE FROM calendar_changes WHERE created_at < $1;
@@ -0,0 +1,48 @@
+-- name: RotateUserCalendarFeed :one
+INSERT INTO calendar_feeds (brand_id, user_id, token_hash)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id) WHERE user_id IS NOT NULL
+DO UPDATE SET token_hash = EXCLUDED.token_hash, last_fetched_at = NULL, created_at = NOW()
+RETURNING *;
+
+-- name: RotateCustomerCalendarFeed :one
+INSERT INTO calendar_feeds (brand_id, customer_id, token_hash)
+VALUES ($1, $2, $3)
+ON CONFLICT (customer_id) WHERE customer_id IS NOT NULL
+DO UPDATE SET token_hash = EXCLUDED.token_hash, last_fetched_at = NULL, created_at = NOW()
+RETURNING *;
+
+-- name: RotateBrandCalendarFeed :one
+INSERT INTO calendar_feeds (brand_id, token_hash)
+VALUES ($1, $2)
+ON CONFLICT (brand_id) WHERE user_id IS NULL AND customer_id IS NULL
+DO UPDATE SET token_hash = EXCLUDED.token_hash, last_fetched_at = NULL, created_at = NOW()
+RETURNING *;
+
+-- name: GetCalendarFeedByTokenHash :one
+SELECT * FROM calendar_feeds WHERE token_hash = $1;
+
+-- name: GetUserCalendarFeed :one
+SELECT * FROM calendar_feeds WHERE user_id = $1;
+
+-- name: GetCustomerCalendarFeed :one
+SELECT * FROM calendar_feeds WHERE customer_id = $1;
+
+-- name: GetBrandCalendarFeed :one
+SELECT * FROM calendar_feeds
+WHERE brand_id = $1 AND user_id IS NULL AND customer_id IS NULL;
+
+-- name: DeleteCalendarFeed :execrows
+DELETE FROM calendar_feeds WHERE id = $1;
+
+-- name: TouchCalendarFeed :exec
+UPDATE calendar_feeds SET last_fetched_at = NOW() WHERE id = $1;
+
+-- name: ListCalendarFeedEvents :many
+SELECT * FROM events
+WHERE brand_id = sqlc.arg(brand_id)
+AND (sqlc.narg(user_id)::bigint IS NULL OR user_id = sqlc.narg(user_id)::bigint)
+AND (sqlc.narg(customer_id)::bigint IS NULL OR customer_id = sqlc.narg(customer_id)::bigint)
+AND start_time >= sqlc.arg(start_from)
+ORDER BY start_time
+LIMIT sqlc.arg(page_limit);
@@ -0,0 +1,39 @@
+-- name: UpsertCustomerConsent :one
+INSERT INTO customer_consents (customer_id, brand_id, channel, purpose, granted, source)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (customer_id, channel, purpose)
+DO UPDATE SET
+    granted = EXCLUDED.granted,
+    source = EXCLUDED.source,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: ListCustomerConsents :many
+SELECT * FROM customer_consents
+WHERE customer_id = $1
+ORDER BY channel, purpose;
+
+-- name: GetCustomerConsentByRecipient :one
+SELECT cc.* FROM customer_consents cc
+JOIN customers c ON c.id = cc.customer_id
+WHERE c.brand_id = sqlc.arg(brand_id)
+AND cc.channel = sqlc.arg(channel)
+AND cc.purpose = sqlc.arg(purpose)
+AND (
+    (cc.channel = 'email' AND lower(c.email) = lower(sqlc.arg(recipient)))
+    OR (cc.channel = 'sms' AND c.phone_e164 = sqlc.arg(recipient))
+)
+LIMIT 1;
+
+-- name: CopyCustomerConsents :exec
+-- Moves the consents of a merged customer, the choice made last wins
+INSERT INTO customer_consents (customer_id, brand_id, channel, purpose, granted, source, created_at, updated_at)
+SELECT sqlc.arg(survivor_id), brand_id, channel, purpose, granted, source, created_at, updated_at
+FROM customer_consents
+WHERE customer_id = sqlc.arg(customer_id)
+ON CONFLICT (customer_id, channel, purpose)
+DO UPDATE SET
+    granted = EXCLUDED.granted,
+    source = EXCLUDED.source,
+    updated_at = EXCLUDED.updated_at
+WHERE customer_consents.updated_at < EXCLUDED.updated_at;
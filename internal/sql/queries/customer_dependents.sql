@@ -0,0 +1,34 @@
+-- name: CreateCustomerDependent :one
+INSERT INTO customer_dependents (customer_id, brand_id, name, birth_date, notes)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetCustomerDependent :one
+SELECT * FROM customer_dependents
+WHERE id = $1 AND customer_id = $2;
+
+-- name: ListCustomerDependents :many
+SELECT * FROM customer_dependents
+WHERE customer_id = $1
+ORDER BY name, id;
+
+-- name: UpdateCustomerDependent :one
+UPDATE customer_dependents
+SET
+    name = $3,
+    birth_date = $4,
+    notes = $5,
+    updated_at = NOW()
+WHERE id = $1 AND customer_id = $2
+RETURNING *;
+
+-- name: DeleteCustomerDependent :execrows
+DELETE FROM customer_dependents
+WHERE id = $1 AND customer_id = $2;
+
+-- name: ReassignCustomerDependents :execrows
+UPDATE customer_dependents
+SET
+    customer_id = sqlc.arg(survivor_id),
+    updated_at = NOW()
+WHERE customer_id = sqlc.arg(customer_id);
@@ -0,0 +1,4 @@
+-- name: CreateCustomerErasure :one
+INSERT INTO customer_erasures (brand_id, customer_id, events_anonymized, erased_by)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
@@ -0,0 +1,46 @@
+-- name: CreateCustomerField :one
+INSERT INTO customer_fields (brand_id, label, field_type, options)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetCustomerField :one
+SELECT * FROM customer_fields WHERE id = $1;
+
+-- name: ListCustomerFields :many
+SELECT * FROM customer_fields
+WHERE brand_id = $1
+ORDER BY label;
+
+-- name: DeleteCustomerField :execrows
+DELETE FROM customer_fields
+WHERE id = $1 AND brand_id = $2;
+
+-- name: UpsertCustomerFieldValue :one
+INSERT INTO customer_field_values (customer_id, field_id, value)
+VALUES ($1, $2, $3)
+ON CONFLICT (customer_id, field_id) DO UPDATE
+SET value = EXCLUDED.value,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: DeleteCustomerFieldValue :exec
+DELETE FROM customer_field_values
+WHERE customer_id = $1 AND field_id = $2;
+
+-- name: ListCustomerFieldValues :many
+SELECT
+    f.id AS field_id,
+    f.label,
+    f.field_type,
+    v.value
+FROM customer_field_values v
+JOIN customer_fields f ON f.id = v.field_id
+WHERE v.customer_id = $1
+ORDER BY f.label;
+
+-- name: CopyCustomerFieldValues :exec
+INSERT INTO customer_field_values (customer_id, field_id, value)
+SELECT sqlc.arg(survivor_id), field_id, value
+FROM customer_field_values
+WHERE customer_id = sqlc.arg(customer_id)
+ON CONFLICT (customer_id, field_id) DO NOTHING;
@@ -0,0 +1,9 @@
+-- name: CreateCustomerMerge :one
+INSERT INTO customer_merges (brand_id, survivor_id, merged_customer_id, merged_customer, events_moved, merged_by)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: ListCustomerMerges :many
+SELECT * FROM customer_merges
+WHERE survivor_id = $1
+ORDER BY created_at DESC;
@@ -0,0 +1,18 @@
+-- name: CreateCustomerNote :one
+INSERT INTO customer_notes (customer_id, brand_id, author_id, author_name, body, pinned)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: ListCustomerNotes :many
+SELECT * FROM customer_notes
+WHERE customer_id = $1
+ORDER BY pinned DESC, created_at DESC;
+
+-- name: DeleteCustomerNote :execrows
+DELETE FROM customer_notes
+WHERE id = $1 AND customer_id = $2;
+
+-- name: ReassignCustomerNotes :execrows
+UPDATE customer_notes
+SET customer_id = sqlc.arg(survivor_id)
+WHERE customer_id = sqlc.arg(customer_id);
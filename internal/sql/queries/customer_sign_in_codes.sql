@@ -0,0 +1,35 @@
+-- name: CreateCustomerSignInCode :one
+INSERT INTO customer_sign_in_codes (customer_id, brand_id, token, code, channel, expiry)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: InvalidateCustomerSignInCodes :exec
+UPDATE customer_sign_in_codes
+SET used_at = NOW()
+WHERE customer_id = $1 AND used_at IS NULL;
+
+-- name: GetActiveCustomerSignInCode :one
+SELECT * FROM customer_sign_in_codes
+WHERE customer_id = $1 AND used_at IS NULL AND expiry > NOW()
+ORDER BY created_at DESC, id DESC
+LIMIT 1
+FOR UPDATE;
+
+-- name: IncrementCustomerSignInCodeAttempts :exec
+UPDATE customer_sign_in_codes
+SET attempts = attempts + 1
+WHERE id = $1;
+
+-- name: UseCustomerSignInCode :exec
+UPDATE customer_sign_in_codes
+SET used_at = NOW()
+WHERE id = $1;
+
+-- name: UseCustomerSignInToken :one
+UPDATE customer_sign_in_codes
+SET used_at = NOW()
+WHERE token = sqlc.arg(token)
+AND used_at IS NULL
+AND expiry > NOW()
+AND attempts < sqlc.arg(max_attempts)::integer
+RETURNING customer_id;
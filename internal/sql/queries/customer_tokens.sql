@@ -0,0 +1,12 @@
+-- name: CreateCustomerToken :exec
+INSERT INTO customer_tokens (token, customer_id, brand_id, scope, expiry)
+VALUES ($1, $2, $3, $4, $5);
+
+-- name: ConsumeCustomerToken :one
+DELETE FROM customer_tokens
+WHERE token = $1 AND scope = $2 AND expiry > NOW()
+RETURNING customer_id;
+
+-- name: DeleteCustomerTokens :exec
+DELETE FROM customer_tokens
+WHERE customer_id = $1 AND scope = $2;
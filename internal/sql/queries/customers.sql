@@ -1,22 +1,154 @@
 -- name: CreateCustomer :one
-INSERT INTO customers (name, email, password, phone_number, brand_id) VALUES ($1, $2, $3, $4, $5)
+INSERT INTO customers (name, email, password, phone_number, brand_id, phone_e164) VALUES ($1, $2, $3, $4, $5, $6)
 RETURNING *;
 
 -- name: CreateGuestCustomer :one
-INSERT INTO customers (name, email, phone_number, brand_id) VALUES ($1, $2, $3, $4)
+INSERT INTO customers (name, email, phone_number, brand_id, phone_e164) VALUES ($1, $2, $3, $4, $5)
 RETURNING *;
 
 -- name: DeleteCustomer :exec
 DELETE FROM customers WHERE id = $1;
 
 -- name: GetCustomerByEmail :one
-SELECT * FROM customers WHERE email = $1;
+SELECT * FROM customers WHERE brand_id = $1 AND email = $2;
 
 -- name: GetCustomerById :one
 SELECT * FROM customers WHERE id = $1;
 
--- name: GetCustomerByNameAndPhone :one
-SELECT * FROM customers WHERE name = $1 AND phone_number = $2;
+-- name: GetCustomerByPhone :one
+-- Numbers entered before they were normalized can match several customers,
+-- accounts come first and then the oldest
+SELECT * FROM customers WHERE brand_id = $1 AND (phone_number = $2 OR phone_e164 = $3)
+ORDER BY password IS NULL, id
+LIMIT 1;
 
 -- name: GetCustomersByBrand :many
 SELECT * FROM customers WHERE brand_id = $1;
+
+-- name: FillCustomerAccount :one
+UPDATE customers
+SET email = COALESCE(email, $2),
+    phone_e164 = COALESCE(phone_e164, $3),
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: ListDuplicateCustomers :many
+-- Name matches use the trigram index through the % operator, whose threshold
+-- is set by SetSimilarityThreshold in the same transaction
+SELECT
+    a.id AS customer_id,
+    a.name AS customer_name,
+    a.email AS customer_email,
+    a.phone_number AS customer_phone_number,
+    b.id AS duplicate_id,
+    b.name AS duplicate_name,
+    b.email AS duplicate_email,
+    b.phone_number AS duplicate_phone_number,
+    COALESCE(a.phone_e164 = b.phone_e164, false)::boolean AS phone_match,
+    COALESCE(lower(a.email) = lower(b.email), false)::boolean AS email_match,
+    similarity(a.name, b.name)::real AS name_similarity
+FROM customers a
+JOIN customers b ON b.brand_id = a.brand_id AND b.id > a.id
+AND (
+    a.phone_e164 = b.phone_e164
+    OR lower(a.email) = lower(b.email)
+    OR a.name % b.name
+)
+WHERE a.brand_id = sqlc.arg(brand_id)
+AND (
+    NOT sqlc.arg(has_cursor)::boolean
+    OR (a.id, b.id) > (sqlc.arg(cursor_customer_id)::bigint, sqlc.arg(cursor_duplicate_id)::bigint)
+)
+ORDER BY a.id, b.id
+LIMIT sqlc.arg(page_limit);
+
+-- name: SetSimilarityThreshold :exec
+SELECT set_config('pg_trgm.similarity_threshold', sqlc.arg(threshold)::real::text, true);
+
+-- name: UpdateCustomerTags :one
+UPDATE customers
+SET tags = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: ListCustomers :many
+WITH visits AS (
+    SELECT
+        customer_id,
+        MAX(start_time) FILTER (WHERE start_time < NOW() AND NOT no_show)::timestamp AS last_visit,
+        COUNT(*) FILTER (WHERE no_show) AS no_show_count
+    FROM events
+    WHERE brand_id = sqlc.arg(brand_id)
+    GROUP BY customer_id
+), listed AS (
+    SELECT
+        c.*,
+        v.last_visit,
+        COALESCE(v.no_show_count, 0)::bigint AS no_show_count,
+        (CASE sqlc.arg(sort)::text
+            WHEN 'createdAt' THEN to_char(c.created_at, 'YYYY-MM-DD"T"HH24:MI:SS')
+            WHEN 'lastVisit' THEN COALESCE(to_char(v.last_visit, 'YYYY-MM-DD"T"HH24:MI:SS'), '')
+            ELSE lower(c.name)
+        END)::text AS sort_key
+    FROM customers c
+    LEFT JOIN visits v ON v.customer_id = c.id
+    WHERE c.brand_id = sqlc.arg(brand_id)
+    AND (
+        sqlc.arg(search)::text = ''
+        OR c.name ILIKE sqlc.arg(search)::text || '%'
+        OR c.email ILIKE sqlc.arg(search)::text || '%'
+        OR c.phone_number LIKE sqlc.arg(search)::text || '%'
+        OR (sqlc.arg(phone)::text <> '' AND c.phone_e164 LIKE sqlc.arg(phone)::text || '%')
+        OR c.name % sqlc.arg(search)::text
+    )
+    AND c.tags @> sqlc.arg(tags)::text[]
+    AND NOT EXISTS (
+        SELECT 1
+        FROM unnest(sqlc.arg(field_ids)::bigint[], sqlc.arg(field_values)::text[]) AS f(field_id, value)
+        WHERE NOT EXISTS (
+            SELECT 1
+            FROM customer_field_values fv
+            WHERE fv.customer_id = c.id
+            AND fv.field_id = f.field_id
+            AND fv.value = f.value
+        )
+    )
+    AND (sqlc.arg(note)::text = '' OR EXISTS (
+        SELECT 1
+        FROM customer_notes n
+        WHERE n.customer_id = c.id
+        AND n.body ILIKE '%' || sqlc.arg(note)::text || '%'
+    ))
+    AND (sqlc.narg(last_visit_before)::timestamp IS NULL OR v.last_visit < sqlc.narg(last_visit_before)::timestamp)
+    AND (sqlc.narg(last_visit_after)::timestamp IS NULL OR v.last_visit >= sqlc.narg(last_visit_after)::timestamp)
+    AND COALESCE(v.no_show_count, 0) >= sqlc.arg(min_no_shows)::bigint
+)
+SELECT * FROM listed
+WHERE NOT sqlc.arg(has_cursor)::boolean
+OR (sqlc.arg(descending)::boolean AND (sort_key, id) < (sqlc.arg(cursor_key)::text, sqlc.arg(cursor_id)::bigint))
+OR (NOT sqlc.arg(descending)::boolean AND (sort_key, id) > (sqlc.arg(cursor_key)::text, sqlc.arg(cursor_id)::bigint))
+ORDER BY
+    CASE WHEN sqlc.arg(descending)::boolean THEN sort_key END DESC,
+    CASE WHEN sqlc.arg(descending)::boolean THEN id END DESC,
+    sort_key,
+    id
+LIMIT sqlc.arg(page_limit);
+
+-- name: ExportCustomers :many
+SELECT * FROM customers
+WHERE brand_id = $1
+ORDER BY id;
+
+-- name: VerifyCustomer :exec
+UPDATE customers SET
+verified = TRUE,
+updated_at = NOW()
+WHERE id = $1;
+
+-- name: UpdateCustomerPassword :exec
+UPDATE customers SET
+password = $2,
+updated_at = NOW()
+WHERE id = $1;
@@ -36,10 +36,12 @@ INSERT INTO events (
   user_name,
   cost,
   buffer_time,
+  dependent_id,
+  dependent_name,
   created_at,
   updated_at
 ) VALUES (
-  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, NOW(), NOW()
+  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, NOW(), NOW()
 ) RETURNING *;
 
 -- name: UpdateEvent :one
@@ -57,8 +59,10 @@ SET
   user_name = $11,
   cost = $12,
   buffer_time = $13,
+  dependent_id = $14,
+  dependent_name = $15,
   updated_at = NOW()
-WHERE id = $1
+WHERE id = $1 AND version = COALESCE(sqlc.narg(version)::INTEGER, version)
 RETURNING *;
 
 -- name: DeleteEvent :exec
@@ -119,7 +123,122 @@ SELECT
                   OR (b.start_time < (sqlc.arg(end_time) + (INTERVAL '1 minute' * si.buffer_time))
                       AND b.end_time > sqlc.arg(start_time))
               )
+        )
+        AND NOT EXISTS (
+            SELECT 1
+            FROM external_busy_times x
+            WHERE x.user_id = sqlc.arg(user_id)
+              AND x.start_time < (sqlc.arg(end_time) + (INTERVAL '1 minute' * COALESCE(si.buffer_time, 0)))
+              AND x.end_time > sqlc.arg(start_time)
         ),
         (sqlc.arg(user_id) IS NULL)
     ) AS is_available
 FROM service_info si;
+
+-- name: ReassignCustomerEvents :execrows
+UPDATE events
+SET
+  customer_id = sqlc.arg(survivor_id),
+  customer_name = sqlc.arg(customer_name),
+  updated_at = NOW()
+WHERE customer_id = sqlc.arg(customer_id);
+
+-- name: GetCustomerStats :one
+SELECT
+    COUNT(*) FILTER (WHERE start_time < NOW() AND NOT no_show) AS visit_count,
+    COALESCE(SUM(cost) FILTER (WHERE start_time < NOW() AND NOT no_show), 0)::text AS total_spend,
+    MAX(start_time) FILTER (WHERE start_time < NOW() AND NOT no_show)::timestamp AS last_visit,
+    MIN(start_time) FILTER (WHERE start_time >= NOW())::timestamp AS next_appointment
+FROM events
+WHERE customer_id = $1;
+
+-- name: ListCustomerVisits :many
+SELECT * FROM events
+WHERE customer_id = $1
+AND start_time < NOW()
+ORDER BY start_time DESC
+LIMIT $2;
+
+-- name: GetNextCustomerEvent :one
+SELECT * FROM events
+WHERE customer_id = $1
+AND start_time >= NOW()
+ORDER BY start_time
+LIMIT 1;
+
+-- name: ListEvents :many
+WITH listed AS (
+    SELECT
+        e.*,
+        (CASE sqlc.arg(sort)::text
+            WHEN 'createdAt' THEN to_char(e.created_at, 'YYYY-MM-DD"T"HH24:MI:SS')
+            ELSE to_char(e.start_time, 'YYYY-MM-DD"T"HH24:MI:SS')
+        END)::text AS sort_key
+    FROM events e
+    WHERE e.brand_id = sqlc.arg(brand_id)
+    AND (sqlc.narg(start_from)::timestamp IS NULL OR e.start_time >= sqlc.narg(start_from)::timestamp)
+    AND (sqlc.narg(start_to)::timestamp IS NULL OR e.start_time < sqlc.narg(start_to)::timestamp)
+    AND (sqlc.arg(user_id)::bigint = 0 OR e.user_id = sqlc.arg(user_id)::bigint)
+    AND (sqlc.arg(customer_id)::bigint = 0 OR e.customer_id = sqlc.arg(customer_id)::bigint)
+)
+SELECT * FROM listed
+WHERE NOT sqlc.arg(has_cursor)::boolean
+OR (sqlc.arg(descending)::boolean AND (sort_key, id) < (sqlc.arg(cursor_key)::text, sqlc.arg(cursor_id)::bigint))
+OR (NOT sqlc.arg(descending)::boolean AND (sort_key, id) > (sqlc.arg(cursor_key)::text, sqlc.arg(cursor_id)::bigint))
+ORDER BY
+    CASE WHEN sqlc.arg(descending)::boolean THEN sort_key END DESC,
+    CASE WHEN sqlc.arg(descending)::boolean THEN id END DESC,
+    sort_key,
+    id
+LIMIT sqlc.arg(page_limit);
+
+-- name: SetEventNoShow :one
+UPDATE events
+SET no_show = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: GetEventByUserAndStart :one
+SELECT * FROM events
+WHERE user_id = $1 AND start_time = $2
+LIMIT 1;
+
+-- name: ExportEvents :many
+SELECT
+    e.*,
+    c.email AS customer_email,
+    c.phone_number AS customer_phone
+FROM events e
+LEFT JOIN customers c ON c.id = e.customer_id
+WHERE e.brand_id = sqlc.arg(brand_id)
+AND (sqlc.narg(start_from)::timestamp IS NULL OR e.start_time >= sqlc.narg(start_from)::timestamp)
+AND (sqlc.narg(start_to)::timestamp IS NULL OR e.start_time < sqlc.narg(start_to)::timestamp)
+ORDER BY e.start_time, e.id;
+
+-- name: ListCustomerEvents :many
+SELECT * FROM events
+WHERE customer_id = $1
+ORDER BY start_time;
+
+-- name: AnonymizeCustomerEvents :execrows
+UPDATE events
+SET
+  customer_id = NULL,
+  customer_name = sqlc.arg(customer_name),
+  comment = NULL,
+  dependent_id = NULL,
+  dependent_name = NULL,
+  updated_at = NOW()
+WHERE customer_id = sqlc.arg(customer_id);
+
+-- name: DeleteCustomerEventHistory :exec
+-- Drops the history of the customer's events before an erasure, the old
+-- versions still hold the customer and their comments
+DELETE FROM event_history
+WHERE event_id IN (SELECT id FROM events WHERE customer_id = $1);
+
+-- name: ListEventHistory :many
+SELECT * FROM event_history
+WHERE event_id = $1
+ORDER BY version;
@@ -0,0 +1,54 @@
+-- name: CreateExternalCalendar :one
+INSERT INTO external_calendars (brand_id, user_id, name, url, content)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: ListExternalCalendars :many
+SELECT id, brand_id, user_id, name, url, last_synced_at, last_error, next_sync_at, created_at, updated_at
+FROM external_calendars
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: GetExternalCalendar :one
+SELECT * FROM external_calendars
+WHERE id = $1 AND user_id = $2;
+
+-- name: DeleteExternalCalendar :execrows
+DELETE FROM external_calendars WHERE id = $1 AND user_id = $2;
+
+-- name: ClaimDueExternalCalendars :many
+UPDATE external_calendars
+SET next_sync_at = sqlc.arg(locked_until)
+WHERE id IN (
+    SELECT id FROM external_calendars
+    WHERE next_sync_at <= NOW()
+    ORDER BY next_sync_at
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: RecordExternalCalendarSync :one
+UPDATE external_calendars
+SET
+    last_synced_at = CASE WHEN sqlc.arg(synced)::boolean THEN NOW() ELSE last_synced_at END,
+    last_error = sqlc.arg(last_error),
+    next_sync_at = sqlc.arg(next_sync_at),
+    updated_at = NOW()
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: DeleteExternalBusyTimes :exec
+DELETE FROM external_busy_times WHERE calendar_id = $1;
+
+-- name: CreateExternalBusyTimes :exec
+INSERT INTO external_busy_times (calendar_id, brand_id, user_id, start_time, end_time)
+SELECT sqlc.arg(calendar_id), sqlc.arg(brand_id), sqlc.arg(user_id), b.start_time, b.end_time
+FROM unnest(sqlc.arg(start_times)::timestamp[], sqlc.arg(end_times)::timestamp[]) AS b(start_time, end_time);
+
+-- name: ListUserBusyTimes :many
+SELECT * FROM external_busy_times
+WHERE user_id = sqlc.arg(user_id)
+AND start_time < sqlc.arg(range_end)
+AND end_time > sqlc.arg(range_start)
+ORDER BY start_time;
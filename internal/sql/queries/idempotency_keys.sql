@@ -0,0 +1,30 @@
+-- name: ClaimIdempotencyKey :one
+INSERT INTO idempotency_keys (scope, idempotency_key, method, path, fingerprint, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (scope, idempotency_key) DO UPDATE
+SET method = EXCLUDED.method,
+    path = EXCLUDED.path,
+    fingerprint = EXCLUDED.fingerprint,
+    status_code = NULL,
+    content_type = '',
+    response_body = NULL,
+    created_at = NOW(),
+    expires_at = EXCLUDED.expires_at
+WHERE idempotency_keys.expires_at < NOW()
+OR (idempotency_keys.status_code IS NULL AND idempotency_keys.created_at < sqlc.arg(abandoned_before))
+RETURNING id;
+
+-- name: GetIdempotencyKey :one
+SELECT * FROM idempotency_keys
+WHERE scope = $1 AND idempotency_key = $2;
+
+-- name: CompleteIdempotencyKey :exec
+UPDATE idempotency_keys
+SET status_code = $2, content_type = $3, response_body = $4
+WHERE id = $1;
+
+-- name: ReleaseIdempotencyKey :exec
+DELETE FROM idempotency_keys WHERE id = $1;
+
+-- name: DeleteExpiredIdempotencyKeys :execrows
+DELETE FROM idempotency_keys WHERE expires_at < NOW();
@@ -0,0 +1,44 @@
+-- name: CreateImportJob :one
+INSERT INTO import_jobs (brand_id, created_by, kind, file_name, dry_run, total_rows)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetImportJob :one
+SELECT * FROM import_jobs
+WHERE id = $1;
+
+-- name: ListImportJobs :many
+SELECT * FROM import_jobs
+WHERE brand_id = $1
+ORDER BY created_at DESC
+LIMIT $2;
+
+-- name: UpdateImportJobProgress :one
+UPDATE import_jobs
+SET status = 'running',
+    processed_rows = $2,
+    created_rows = $3,
+    duplicate_rows = $4,
+    invalid_rows = $5,
+    report = $6,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: FinishImportJob :one
+UPDATE import_jobs
+SET status = $2,
+    error = $3,
+    updated_at = NOW(),
+    finished_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: FailStaleImportJobs :execrows
+UPDATE import_jobs
+SET status = 'failed',
+    error = 'the import was interrupted',
+    updated_at = NOW(),
+    finished_at = NOW()
+WHERE status IN ('pending', 'running')
+AND updated_at < NOW() - INTERVAL '15 minutes';
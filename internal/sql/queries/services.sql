@@ -36,6 +36,40 @@ LEFT JOIN users ON us.user_id = users.id
 WHERE services.brand_id = $1
 ORDER BY services.title, users.name;
 
+-- name: ListServices :many
+WITH listed AS (
+    SELECT
+        s.*,
+        (CASE sqlc.arg(sort)::text
+            WHEN 'createdAt' THEN to_char(s.created_at, 'YYYY-MM-DD"T"HH24:MI:SS')
+            ELSE lower(s.title)
+        END)::text AS sort_key
+    FROM services s
+    WHERE s.brand_id = sqlc.arg(brand_id)
+    AND (
+        sqlc.arg(search)::text = ''
+        OR s.title ILIKE sqlc.arg(search)::text || '%'
+        OR s.title % sqlc.arg(search)::text
+    )
+)
+SELECT * FROM listed
+WHERE NOT sqlc.arg(has_cursor)::boolean
+OR (sqlc.arg(descending)::boolean AND (sort_key, id) < (sqlc.arg(cursor_key)::text, sqlc.arg(cursor_id)::uuid))
+OR (NOT sqlc.arg(descending)::boolean AND (sort_key, id) > (sqlc.arg(cursor_key)::text, sqlc.arg(cursor_id)::uuid))
+ORDER BY
+    CASE WHEN sqlc.arg(descending)::boolean THEN sort_key END DESC,
+    CASE WHEN sqlc.arg(descending)::boolean THEN id END DESC,
+    sort_key,
+    id
+LIMIT sqlc.arg(page_limit);
+
+-- name: ListServiceProviders :many
+SELECT us.service_id, us.user_id
+FROM user_services us
+JOIN users u ON u.id = us.user_id
+WHERE us.service_id = ANY(sqlc.arg(service_ids)::uuid[])
+ORDER BY u.name;
+
 -- name: ListVisibleServices :many
 SELECT * FROM services
 WHERE brand_id = $1 AND is_visible = true
@@ -1,13 +1,15 @@
 -- name: CreateUserSession :one
-INSERT INTO user_sessions (user_id, expires_at)
-VALUES ($1, $2)
+INSERT INTO user_sessions (user_id, expires_at, absolute_expires_at, remember, user_agent, ip_address)
+VALUES ($1, $2, $3, $4, $5, $6)
 RETURNING *;
 
 -- name: GetUserSessionById :one
 SELECT * FROM user_sessions WHERE id = $1;
 
--- name: GetSessionByUserId :one
-SELECT * FROM user_sessions WHERE user_id = $1;
+-- name: ListUserSessions :many
+SELECT * FROM user_sessions
+WHERE user_id = $1
+ORDER BY last_seen_at DESC;
 
 -- name: UpdateUserSession :one
 UPDATE user_sessions
@@ -15,16 +17,41 @@ SET expires_at = $2
 WHERE id = $1
 RETURNING *;
 
+-- name: TouchUserSession :exec
+UPDATE user_sessions
+SET last_seen_at = NOW(),
+expires_at = LEAST(sqlc.arg(expires_at)::timestamp, absolute_expires_at)
+WHERE id = sqlc.arg(id) AND expires_at > NOW();
+
+-- name: RotateUserSession :one
+INSERT INTO user_sessions (user_id, expires_at, absolute_expires_at, remember, user_agent, ip_address, created_at)
+SELECT user_id, expires_at, absolute_expires_at, remember, sqlc.arg(user_agent)::text, sqlc.arg(ip_address)::text, created_at
+FROM user_sessions
+WHERE id = sqlc.arg(id) AND expires_at > NOW()
+RETURNING *;
+
+-- name: RevokeUserSession :execrows
+UPDATE user_sessions
+SET expires_at = NOW()
+WHERE id = $1 AND user_id = $2 AND expires_at > NOW();
+
+-- name: RevokeOtherUserSessions :execrows
+UPDATE user_sessions
+SET expires_at = NOW()
+WHERE user_id = $1 AND id <> $2 AND expires_at > NOW();
+
 -- name: CreateCustomerSession :one
-INSERT INTO customer_sessions (customer_id, expires_at)
-VALUES ($1, $2)
+INSERT INTO customer_sessions (customer_id, expires_at, absolute_expires_at, remember, user_agent, ip_address)
+VALUES ($1, $2, $3, $4, $5, $6)
 RETURNING *;
 
 -- name: GetCustomerSessionById :one
 SELECT * FROM customer_sessions WHERE id = $1;
 
--- name: GetSessionByCustomerId :one
-SELECT * FROM customer_sessions WHERE customer_id = $1;
+-- name: ListCustomerSessions :many
+SELECT * FROM customer_sessions
+WHERE customer_id = $1
+ORDER BY last_seen_at DESC;
 
 -- name: UpdateCustomerSession :one
 UPDATE customer_sessions
@@ -32,18 +59,43 @@ SET expires_at = $2
 WHERE id = $1
 RETURNING *;
 
--- name: UpsertUserSession :one
-INSERT INTO user_sessions (user_id, expires_at)
-VALUES ($1, $2)
-ON CONFLICT (user_id)
-DO UPDATE SET
-    expires_at = EXCLUDED.expires_at
-RETURNING *;
+-- name: TouchCustomerSession :exec
+UPDATE customer_sessions
+SET last_seen_at = NOW(),
+expires_at = LEAST(sqlc.arg(expires_at)::timestamp, absolute_expires_at)
+WHERE id = sqlc.arg(id) AND expires_at > NOW();
 
--- name: UpsertCustomerSession :one
-INSERT INTO customer_sessions (customer_id, expires_at)
-VALUES ($1, $2)
-ON CONFLICT (customer_id)
-DO UPDATE SET
-    expires_at = EXCLUDED.expires_at
+-- name: RotateCustomerSession :one
+INSERT INTO customer_sessions (customer_id, expires_at, absolute_expires_at, remember, user_agent, ip_address, created_at)
+SELECT customer_id, expires_at, absolute_expires_at, remember, sqlc.arg(user_agent)::text, sqlc.arg(ip_address)::text, created_at
+FROM customer_sessions
+WHERE id = sqlc.arg(id) AND expires_at > NOW()
 RETURNING *;
+
+-- name: RevokeCustomerSession :execrows
+UPDATE customer_sessions
+SET expires_at = NOW()
+WHERE id = $1 AND customer_id = $2 AND expires_at > NOW();
+
+-- name: RevokeOtherCustomerSessions :execrows
+UPDATE customer_sessions
+SET expires_at = NOW()
+WHERE customer_id = $1 AND id <> $2 AND expires_at > NOW();
+
+-- name: ExpireUserSessions :exec
+UPDATE user_sessions
+SET expires_at = NOW()
+WHERE user_id = $1;
+
+-- name: ExpireCustomerSessions :exec
+UPDATE customer_sessions
+SET expires_at = NOW()
+WHERE customer_id = $1;
+
+-- name: DeleteExpiredUserSessions :execrows
+DELETE FROM user_sessions
+WHERE expires_at < NOW();
+
+-- name: DeleteExpiredCustomerSessions :execrows
+DELETE FROM customer_sessions
+WHERE expires_at < NOW();
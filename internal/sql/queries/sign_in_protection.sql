@@ -0,0 +1,36 @@
+-- name: GetSignInThrottle :one
+SELECT * FROM sign_in_throttles
+WHERE key = $1 AND expires_at > NOW();
+
+-- name: FailSignInThrottle :one
+INSERT INTO sign_in_throttles (key, failures, expires_at)
+VALUES ($1, 1, $2)
+ON CONFLICT (key) DO UPDATE
+SET failures = CASE
+        WHEN sign_in_throttles.expires_at > NOW() THEN sign_in_throttles.failures + 1
+        ELSE 1
+    END,
+    blocked_until = CASE
+        WHEN sign_in_throttles.expires_at > NOW() THEN sign_in_throttles.blocked_until
+    END,
+    expires_at = GREATEST(sign_in_throttles.expires_at, EXCLUDED.expires_at)
+RETURNING failures;
+
+-- name: BlockSignInThrottle :exec
+UPDATE sign_in_throttles
+SET blocked_until = $2,
+    expires_at = GREATEST(expires_at, $2)
+WHERE key = $1;
+
+-- name: DeleteSignInThrottle :exec
+DELETE FROM sign_in_throttles WHERE key = $1;
+
+-- name: DeleteExpiredSignInThrottles :execrows
+DELETE FROM sign_in_throttles WHERE expires_at <= NOW();
+
+-- name: CreateSignInAttempt :exec
+INSERT INTO sign_in_attempts (
+    principal, account_id, brand_id, email, ip_address, user_agent, success, reason
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8
+);
@@ -0,0 +1,12 @@
+-- name: CreateUserToken :exec
+INSERT INTO user_tokens (token, user_id, scope, expiry)
+VALUES ($1, $2, $3, $4);
+
+-- name: ConsumeUserToken :one
+DELETE FROM user_tokens
+WHERE token = $1 AND scope = $2 AND expiry > NOW()
+RETURNING user_id;
+
+-- name: DeleteUserTokens :exec
+DELETE FROM user_tokens
+WHERE user_id = $1 AND scope = $2;
@@ -0,0 +1,75 @@
+-- name: UpsertUserTotp :one
+INSERT INTO user_totp (user_id, secret)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE
+SET secret = EXCLUDED.secret,
+    last_used_step = 0,
+    created_at = NOW()
+WHERE user_totp.confirmed_at IS NULL
+RETURNING *;
+
+-- name: GetUserTotp :one
+SELECT * FROM user_totp WHERE user_id = $1;
+
+-- name: ConfirmUserTotp :execrows
+UPDATE user_totp
+SET confirmed_at = NOW(),
+    last_used_step = $2
+WHERE user_id = $1 AND confirmed_at IS NULL;
+
+-- name: UseUserTotpStep :execrows
+UPDATE user_totp
+SET last_used_step = $2
+WHERE user_id = $1 AND confirmed_at IS NOT NULL AND last_used_step < $2;
+
+-- name: DeleteUserTotp :exec
+DELETE FROM user_totp WHERE user_id = $1;
+
+-- name: CreateUserRecoveryCode :exec
+INSERT INTO user_recovery_codes (user_id, code_hash)
+VALUES ($1, $2);
+
+-- name: DeleteUserRecoveryCodes :exec
+DELETE FROM user_recovery_codes WHERE user_id = $1;
+
+-- name: UseUserRecoveryCode :execrows
+UPDATE user_recovery_codes
+SET used_at = NOW()
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL;
+
+-- name: CountUserRecoveryCodes :one
+SELECT COUNT(*) FROM user_recovery_codes
+WHERE user_id = $1 AND used_at IS NULL;
+
+-- name: CreateUserSignInChallenge :exec
+INSERT INTO user_sign_in_challenges (token, user_id, remember, expiry)
+VALUES ($1, $2, $3, $4);
+
+-- name: DeleteUserSignInChallenges :exec
+DELETE FROM user_sign_in_challenges WHERE user_id = $1;
+
+-- name: GetUserSignInChallenge :one
+SELECT * FROM user_sign_in_challenges
+WHERE token = $1 AND expiry > NOW() AND attempts < $2;
+
+-- name: IncrementUserSignInChallengeAttempts :exec
+UPDATE user_sign_in_challenges
+SET attempts = attempts + 1
+WHERE token = $1;
+
+-- name: ConsumeUserSignInChallenge :one
+DELETE FROM user_sign_in_challenges
+WHERE token = $1 AND expiry > NOW() AND attempts < $2
+RETURNING user_id;
+
+-- name: ExpireBrandUserSessionsWithoutTwoFactor :execrows
+UPDATE user_sessions s
+SET expires_at = NOW()
+FROM users u
+WHERE s.user_id = u.id
+  AND u.brand_id = $1
+  AND s.expires_at > NOW()
+  AND NOT EXISTS (
+      SELECT 1 FROM user_totp t
+      WHERE t.user_id = u.id AND t.confirmed_at IS NOT NULL
+  );
@@ -23,3 +23,37 @@ SELECT * FROM users WHERE brand_id = $1;
 -- name: ValidateUsersCount :one
 SELECT COUNT(*) FROM users
 WHERE id = ANY(@ids::bigint[]) AND brand_id = @brand_id;
+
+-- name: ListUsers :many
+WITH listed AS (
+    SELECT
+        u.*,
+        (CASE sqlc.arg(sort)::text
+            WHEN 'createdAt' THEN to_char(u.created_at, 'YYYY-MM-DD"T"HH24:MI:SS')
+            ELSE lower(u.name)
+        END)::text AS sort_key
+    FROM users u
+    WHERE u.brand_id = sqlc.arg(brand_id)
+    AND (
+        sqlc.arg(search)::text = ''
+        OR u.name ILIKE sqlc.arg(search)::text || '%'
+        OR u.email ILIKE sqlc.arg(search)::text || '%'
+    )
+    AND (sqlc.arg(role)::text = '' OR u.role = sqlc.arg(role)::text)
+)
+SELECT * FROM listed
+WHERE NOT sqlc.arg(has_cursor)::boolean
+OR (sqlc.arg(descending)::boolean AND (sort_key, id) < (sqlc.arg(cursor_key)::text, sqlc.arg(cursor_id)::bigint))
+OR (NOT sqlc.arg(descending)::boolean AND (sort_key, id) > (sqlc.arg(cursor_key)::text, sqlc.arg(cursor_id)::bigint))
+ORDER BY
+    CASE WHEN sqlc.arg(descending)::boolean THEN sort_key END DESC,
+    CASE WHEN sqlc.arg(descending)::boolean THEN id END DESC,
+    sort_key,
+    id
+LIMIT sqlc.arg(page_limit);
+
+-- name: UpdateUserPassword :exec
+UPDATE users SET
+password = $2,
+updated_at = NOW()
+WHERE id = $1;
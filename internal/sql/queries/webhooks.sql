@@ -0,0 +1,96 @@
+-- name: CreateWebhookSubscription :one
+INSERT INTO webhook_subscriptions (brand_id, url, event_types, secret, created_by)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: ListWebhookSubscriptions :many
+SELECT * FROM webhook_subscriptions
+WHERE brand_id = $1
+ORDER BY created_at DESC;
+
+-- name: GetWebhookSubscription :one
+SELECT * FROM webhook_subscriptions
+WHERE id = $1 AND brand_id = $2;
+
+-- name: UpdateWebhookSubscription :one
+UPDATE webhook_subscriptions
+SET url = $3, event_types = $4, active = $5, updated_at = NOW()
+WHERE id = $1 AND brand_id = $2
+RETURNING *;
+
+-- name: DeleteWebhookSubscription :execrows
+DELETE FROM webhook_subscriptions WHERE id = $1 AND brand_id = $2;
+
+-- name: ListWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE subscription_id = sqlc.arg(subscription_id)
+AND (
+    NOT sqlc.arg(has_cursor)::boolean
+    OR (sqlc.arg(descending)::boolean AND id < sqlc.arg(cursor_id)::bigint)
+    OR (NOT sqlc.arg(descending)::boolean AND id > sqlc.arg(cursor_id)::bigint)
+)
+ORDER BY
+    CASE WHEN sqlc.arg(descending)::boolean THEN id END DESC,
+    id
+LIMIT sqlc.arg(page_limit);
+
+-- name: RedeliverWebhookDelivery :one
+INSERT INTO webhook_deliveries (subscription_id, brand_id, event_type, payload, redelivery_of)
+SELECT d.subscription_id, d.brand_id, d.event_type, d.payload, d.id
+FROM webhook_deliveries d
+WHERE d.id = $1 AND d.subscription_id = $2
+RETURNING *;
+
+-- name: ClaimWebhookDeliveries :many
+-- Deliveries of paused subscriptions wait until the subscription is active again
+WITH due AS (
+    SELECT d.id FROM webhook_deliveries d
+    JOIN webhook_subscriptions s ON s.id = d.subscription_id
+    WHERE d.status = 'pending' AND d.next_attempt_at <= NOW()
+    AND s.active
+    ORDER BY d.next_attempt_at
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE OF d SKIP LOCKED
+), claimed AS (
+    UPDATE webhook_deliveries d
+    SET next_attempt_at = sqlc.arg(locked_until)
+    FROM due
+    WHERE d.id = due.id
+    RETURNING d.*
+)
+SELECT c.id, c.subscription_id, c.event_type, c.payload, c.attempts, c.created_at, s.url, s.secret
+FROM claimed c
+JOIN webhook_subscriptions s ON s.id = c.subscription_id
+WHERE s.active;
+
+-- name: RecordWebhookAttempt :exec
+UPDATE webhook_deliveries
+SET status = $2,
+    attempts = attempts + 1,
+    next_attempt_at = $3,
+    last_attempt_at = NOW(),
+    last_status_code = $4,
+    last_error = $5,
+    delivered_at = CASE WHEN $2 = 'succeeded' THEN NOW() END
+WHERE id = $1;
+
+-- name: DeleteWebhookDeliveriesBefore :execrows
+DELETE FROM webhook_deliveries
+WHERE status <> 'pending' AND created_at < $1;
+
+-- name: RedactCustomerWebhookDeliveries :execrows
+-- Strips an erased customer from the deliveries, sent ones can be sent again.
+-- Integrations still learn which customer was deleted.
+UPDATE webhook_deliveries
+SET payload = CASE
+    WHEN event_type LIKE 'customer.%' THEN jsonb_build_object('id', payload -> 'id', 'brand_id', payload -> 'brand_id')
+    ELSE payload || jsonb_build_object(
+        'customer_id', NULL,
+        'customer_name', sqlc.arg(customer_name)::TEXT,
+        'comment', NULL,
+        'dependent_id', NULL,
+        'dependent_name', NULL
+    )
+END
+WHERE (event_type LIKE 'customer.%' AND payload ->> 'id' = sqlc.arg(customer_id)::BIGINT::TEXT)
+OR (event_type LIKE 'event.%' AND payload ->> 'customer_id' = sqlc.arg(customer_id)::BIGINT::TEXT);
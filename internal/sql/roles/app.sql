@@ -0,0 +1,36 @@
+-- The API connects as its own role. Superusers and BYPASSRLS roles skip the
+-- row level security policies, so this one is neither. Migrations keep
+-- running as the owner of the database, which also runs this script after
+-- migrating:
+--
+--   psql -v app_user=bms_app -v app_password=secret -f internal/sql/roles/app.sql
+SELECT
+    format('CREATE ROLE %I LOGIN PASSWORD %L NOSUPERUSER NOBYPASSRLS NOCREATEDB NOCREATEROLE', :'app_user', :'app_password')
+WHERE
+    NOT EXISTS (
+        SELECT
+            1
+        FROM
+            pg_roles
+        WHERE
+            rolname = :'app_user'
+    ) \gexec
+
+SELECT format('GRANT CONNECT ON DATABASE %I TO %I', current_database(), :'app_user') \gexec
+
+GRANT USAGE ON SCHEMA public TO :"app_user";
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA public TO :"app_user";
+
+GRANT USAGE, SELECT ON ALL SEQUENCES IN SCHEMA public TO :"app_user";
+
+-- Tables of later migrations, which the owner creates
+ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT SELECT, INSERT, UPDATE, DELETE ON TABLES TO :"app_user";
+
+ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT USAGE, SELECT ON SEQUENCES TO :"app_user";
+
+-- The migration history is the owner's business
+SELECT
+    format('REVOKE ALL ON goose_db_version FROM %I', :'app_user')
+WHERE
+    to_regclass('goose_db_version') IS NOT NULL \gexec
@@ -0,0 +1,97 @@
+-- +goose Up
+-- Tenant isolation is keyed on the app.brand_id setting which the store applies
+-- on every connection from the request context. Privileged callers (cross-tenant
+-- admin tasks, data migrations) set app.bypass_rls = 'on' instead. Superusers
+-- and BYPASSRLS roles skip the policies altogether, so the API connects as the
+-- role of internal/sql/roles/app.sql.
+-- +goose StatementBegin
+CREATE FUNCTION tenant_allows (row_brand_id INTEGER) RETURNS BOOLEAN AS $$
+    SELECT current_setting('app.bypass_rls', true) = 'on'
+        OR row_brand_id = NULLIF(current_setting('app.brand_id', true), '')::INTEGER
+$$ LANGUAGE SQL STABLE;
+-- +goose StatementEnd
+
+ALTER TABLE events ENABLE ROW LEVEL SECURITY;
+
+ALTER TABLE events FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY events_tenant_isolation ON events USING (tenant_allows (brand_id));
+
+ALTER TABLE customers ENABLE ROW LEVEL SECURITY;
+
+ALTER TABLE customers FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY customers_tenant_isolation ON customers USING (tenant_allows (brand_id));
+
+ALTER TABLE services ENABLE ROW LEVEL SECURITY;
+
+ALTER TABLE services FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY services_tenant_isolation ON services USING (tenant_allows (brand_id));
+
+ALTER TABLE user_services ENABLE ROW LEVEL SECURITY;
+
+ALTER TABLE user_services FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY user_services_tenant_isolation ON user_services USING (
+    EXISTS (
+        SELECT
+            1
+        FROM
+            services s
+        WHERE
+            s.id = user_services.service_id
+            AND tenant_allows (s.brand_id)
+    )
+);
+
+ALTER TABLE brand_working_hours ENABLE ROW LEVEL SECURITY;
+
+ALTER TABLE brand_working_hours FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY brand_working_hours_tenant_isolation ON brand_working_hours USING (tenant_allows (brand_id));
+
+ALTER TABLE brand_social_link ENABLE ROW LEVEL SECURITY;
+
+ALTER TABLE brand_social_link FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY brand_social_link_tenant_isolation ON brand_social_link USING (tenant_allows (brand_id));
+
+-- +goose Down
+DROP POLICY brand_social_link_tenant_isolation ON brand_social_link;
+
+ALTER TABLE brand_social_link NO FORCE ROW LEVEL SECURITY;
+
+ALTER TABLE brand_social_link DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY brand_working_hours_tenant_isolation ON brand_working_hours;
+
+ALTER TABLE brand_working_hours NO FORCE ROW LEVEL SECURITY;
+
+ALTER TABLE brand_working_hours DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY user_services_tenant_isolation ON user_services;
+
+ALTER TABLE user_services NO FORCE ROW LEVEL SECURITY;
+
+ALTER TABLE user_services DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY services_tenant_isolation ON services;
+
+ALTER TABLE services NO FORCE ROW LEVEL SECURITY;
+
+ALTER TABLE services DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY customers_tenant_isolation ON customers;
+
+ALTER TABLE customers NO FORCE ROW LEVEL SECURITY;
+
+ALTER TABLE customers DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY events_tenant_isolation ON events;
+
+ALTER TABLE events NO FORCE ROW LEVEL SECURITY;
+
+ALTER TABLE events DISABLE ROW LEVEL SECURITY;
+
+DROP FUNCTION tenant_allows;
@@ -0,0 +1,102 @@
+-- +goose Up
+-- Data migrations touch every tenant, so they run with row level security bypassed
+SELECT set_config('app.bypass_rls', 'on', true);
+
+ALTER TABLE customers DROP CONSTRAINT customers_email_key;
+
+-- Customers linked to events of another brand get their own copy in that brand
+CREATE TEMP TABLE customer_splits ON COMMIT DROP AS
+SELECT
+    p.old_id,
+    p.brand_id,
+    nextval('customers_id_seq') AS new_id
+FROM
+    (
+        SELECT DISTINCT
+            e.customer_id AS old_id,
+            e.brand_id
+        FROM
+            events e
+            JOIN customers c ON c.id = e.customer_id
+        WHERE
+            c.brand_id <> e.brand_id
+    ) p;
+
+INSERT INTO
+    customers (id, name, email, phone_number, brand_id, created_at, updated_at)
+SELECT
+    s.new_id,
+    c.name,
+    c.email,
+    c.phone_number,
+    s.brand_id,
+    c.created_at,
+    NOW ()
+FROM
+    customer_splits s
+    JOIN customers c ON c.id = s.old_id;
+
+UPDATE events e
+SET
+    customer_id = s.new_id
+FROM
+    customer_splits s
+WHERE
+    e.customer_id = s.old_id
+    AND e.brand_id = s.brand_id;
+
+-- Collapse guests sharing a phone number within a brand into the account, or
+-- the oldest guest when there is none. Accounts only collapse when their email
+-- matches too, so nobody loses their credentials or sessions.
+CREATE TEMP TABLE customer_duplicates ON COMMIT DROP AS
+SELECT
+    duplicate_id,
+    survivor_id
+FROM
+    (
+        SELECT
+            id AS duplicate_id,
+            password IS NULL AS guest,
+            email,
+            FIRST_VALUE (id) OVER w AS survivor_id,
+            FIRST_VALUE (email) OVER w AS survivor_email
+        FROM
+            customers
+        WINDOW
+            w AS (
+                PARTITION BY
+                    brand_id,
+                    phone_number
+                ORDER BY
+                    password IS NULL,
+                    id
+            )
+    ) c
+WHERE
+    duplicate_id <> survivor_id
+    AND (
+        guest
+        OR email = survivor_email
+    );
+
+UPDATE events e
+SET
+    customer_id = d.survivor_id
+FROM
+    customer_duplicates d
+WHERE
+    e.customer_id = d.duplicate_id;
+
+DELETE FROM customers c USING customer_duplicates d
+WHERE
+    c.id = d.duplicate_id;
+
+-- Phone numbers become unique per brand in 012, once they are normalized.
+-- Accounts of different people sharing one are sorted out there.
+ALTER TABLE customers ADD CONSTRAINT customers_brand_id_email_key UNIQUE (brand_id, email);
+
+-- +goose Down
+-- Split and merged records are not restored
+ALTER TABLE customers DROP CONSTRAINT customers_brand_id_email_key;
+
+ALTER TABLE customers ADD CONSTRAINT customers_email_key UNIQUE (email);
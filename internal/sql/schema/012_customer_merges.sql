@@ -0,0 +1,139 @@
+-- +goose Up
+SELECT set_config('app.bypass_rls', 'on', true);
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE customers
+ADD COLUMN phone_e164 VARCHAR(20);
+
+UPDATE customers c
+SET
+    phone_e164 = CASE
+        WHEN p.digits = '' THEN NULL
+        WHEN c.phone_number LIKE '+%' THEN '+' || p.digits
+        WHEN p.digits LIKE '00%' THEN '+' || substr(p.digits, 3)
+        WHEN p.digits LIKE '0%' THEN '+359' || substr(p.digits, 2)
+        ELSE '+' || p.digits
+    END
+FROM
+    (
+        SELECT
+            id,
+            regexp_replace(phone_number, '\D', '', 'g') AS digits
+        FROM
+            customers
+    ) p
+WHERE
+    p.id = c.id;
+
+-- Numbers written differently can only be told apart now. Guests are
+-- collapsed into the account or the oldest guest like in 011.
+CREATE TEMP TABLE customer_duplicates ON COMMIT DROP AS
+SELECT
+    duplicate_id,
+    survivor_id
+FROM
+    (
+        SELECT
+            id AS duplicate_id,
+            password IS NULL AS guest,
+            email,
+            FIRST_VALUE (id) OVER w AS survivor_id,
+            FIRST_VALUE (email) OVER w AS survivor_email
+        FROM
+            customers
+        WHERE
+            phone_e164 IS NOT NULL
+        WINDOW
+            w AS (
+                PARTITION BY
+                    brand_id,
+                    phone_e164
+                ORDER BY
+                    password IS NULL,
+                    id
+            )
+    ) c
+WHERE
+    duplicate_id <> survivor_id
+    AND (
+        guest
+        OR email = survivor_email
+    );
+
+UPDATE events e
+SET
+    customer_id = d.survivor_id
+FROM
+    customer_duplicates d
+WHERE
+    e.customer_id = d.duplicate_id;
+
+DELETE FROM customers c USING customer_duplicates d
+WHERE
+    c.id = d.duplicate_id;
+
+-- Accounts of different people keep their numbers as entered, only the first
+-- one is matched by phone. Staff can merge the others by hand.
+UPDATE customers c
+SET
+    phone_e164 = NULL
+FROM
+    (
+        SELECT
+            id,
+            ROW_NUMBER() OVER (
+                PARTITION BY
+                    brand_id,
+                    phone_e164
+                ORDER BY
+                    password IS NULL,
+                    id
+            ) AS position
+        FROM
+            customers
+        WHERE
+            phone_e164 IS NOT NULL
+    ) p
+WHERE
+    p.id = c.id
+    AND p.position > 1;
+
+CREATE UNIQUE INDEX idx_customers_brand_phone_e164 ON customers (brand_id, phone_e164);
+
+CREATE INDEX idx_customers_brand_lower_email ON customers (brand_id, lower(email));
+
+CREATE INDEX idx_customers_name_trgm ON customers USING GIN (name gin_trgm_ops);
+
+CREATE TABLE customer_merges (
+    id BIGSERIAL PRIMARY KEY,
+    brand_id INTEGER NOT NULL REFERENCES brand (id) ON DELETE CASCADE,
+    -- No references, the trail outlives a survivor that is merged or erased
+    -- later
+    survivor_id BIGINT NOT NULL,
+    merged_customer_id BIGINT NOT NULL,
+    merged_customer JSONB NOT NULL,
+    events_moved INTEGER NOT NULL DEFAULT 0,
+    merged_by BIGINT REFERENCES users (id) ON DELETE SET NULL,
+    created_at TIMESTAMP(0) NOT NULL DEFAULT NOW ()
+);
+
+CREATE INDEX idx_customer_merges_survivor_id ON customer_merges (survivor_id);
+
+ALTER TABLE customer_merges ENABLE ROW LEVEL SECURITY;
+
+ALTER TABLE customer_merges FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY customer_merges_tenant_isolation ON customer_merges USING (tenant_allows (brand_id));
+
+-- +goose Down
+DROP TABLE customer_merges;
+
+DROP INDEX idx_customers_name_trgm;
+
+DROP INDEX idx_customers_brand_lower_email;
+
+DROP INDEX idx_customers_brand_phone_e164;
+
+ALTER TABLE customers
+DROP COLUMN phone_e164;
@@ -0,0 +1,83 @@
+-- +goose Up
+ALTER TABLE customers
+ADD COLUMN tags TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX idx_customers_tags ON customers USING GIN (tags);
+
+CREATE TABLE customer_notes (
+    id BIGSERIAL PRIMARY KEY,
+    customer_id BIGINT NOT NULL REFERENCES customers (id) ON DELETE CASCADE,
+    brand_id INTEGER NOT NULL REFERENCES brand (id) ON DELETE CASCADE,
+    author_id BIGINT REFERENCES users (id) ON DELETE SET NULL,
+    author_name VARCHAR(50) NOT NULL,
+    body TEXT NOT NULL,
+    pinned BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP(0) NOT NULL DEFAULT NOW (),
+    updated_at TIMESTAMP(0) NOT NULL DEFAULT NOW ()
+);
+
+CREATE INDEX idx_customer_notes_customer_id ON customer_notes (customer_id);
+
+CREATE TABLE customer_fields (
+    id BIGSERIAL PRIMARY KEY,
+    brand_id INTEGER NOT NULL REFERENCES brand (id) ON DELETE CASCADE,
+    label VARCHAR(100) NOT NULL,
+    field_type VARCHAR(20) NOT NULL,
+    options TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP(0) NOT NULL DEFAULT NOW (),
+    updated_at TIMESTAMP(0) NOT NULL DEFAULT NOW (),
+    UNIQUE (brand_id, label)
+);
+
+ALTER TABLE customer_fields ADD CONSTRAINT valid_field_type CHECK (field_type IN ('text', 'number', 'date', 'select'));
+
+CREATE TABLE customer_field_values (
+    customer_id BIGINT NOT NULL REFERENCES customers (id) ON DELETE CASCADE,
+    field_id BIGINT NOT NULL REFERENCES customer_fields (id) ON DELETE CASCADE,
+    value TEXT NOT NULL,
+    created_at TIMESTAMP(0) NOT NULL DEFAULT NOW (),
+    updated_at TIMESTAMP(0) NOT NULL DEFAULT NOW (),
+    PRIMARY KEY (customer_id, field_id)
+);
+
+CREATE INDEX idx_customer_field_values_field_id ON customer_field_values (field_id, value);
+
+ALTER TABLE customer_notes ENABLE ROW LEVEL SECURITY;
+
+ALTER TABLE customer_notes FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY customer_notes_tenant_isolation ON customer_notes USING (tenant_allows (brand_id));
+
+ALTER TABLE customer_fields ENABLE ROW LEVEL SECURITY;
+
+ALTER TABLE customer_fields FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY customer_fields_tenant_isolation ON customer_fields USING (tenant_allows (brand_id));
+
+ALTER TABLE customer_field_values ENABLE ROW LEVEL SECURITY;
+
+ALTER TABLE customer_field_values FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY customer_field_values_tenant_isolation ON customer_field_values USING (
+    EXISTS (
+        SELECT
+            1
+        FROM
+            customer_fields f
+        WHERE
+            f.id = customer_field_values.field_id
+            AND tenant_allows (f.brand_id)
+    )
+);
+
+-- +goose Down
+DROP TABLE customer_field_values;
+
+DROP TABLE customer_fields;
+
+DROP TABLE customer_notes;
+
+DROP INDEX idx_customers_tags;
+
+ALTER TABLE customers
+DROP COLUMN tags;
@@ -0,0 +1,31 @@
+-- +goose Up
+ALTER TABLE events
+ADD COLUMN no_show BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX idx_events_brand_customer_start ON events (brand_id, customer_id, start_time);
+
+CREATE INDEX idx_events_brand_start_id ON events (brand_id, start_time, id);
+
+CREATE INDEX idx_customers_brand_lower_name ON customers (brand_id, lower(name), id);
+
+CREATE INDEX idx_customers_phone_e164_pattern ON customers (phone_e164 text_pattern_ops);
+
+CREATE INDEX idx_users_brand_lower_name ON users (brand_id, lower(name), id);
+
+CREATE INDEX idx_services_brand_lower_title ON services (brand_id, lower(title), id);
+
+-- +goose Down
+DROP INDEX idx_services_brand_lower_title;
+
+DROP INDEX idx_users_brand_lower_name;
+
+DROP INDEX idx_customers_phone_e164_pattern;
+
+DROP INDEX idx_customers_brand_lower_name;
+
+DROP INDEX idx_events_brand_start_id;
+
+DROP INDEX idx_events_brand_customer_start;
+
+ALTER TABLE events
+DROP COLUMN no_show;
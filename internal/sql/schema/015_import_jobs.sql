@@ -0,0 +1,39 @@
+-- +goose Up
+CREATE TABLE import_jobs (
+    id BIGSERIAL PRIMARY KEY,
+    brand_id INTEGER NOT NULL REFERENCES brand (id) ON DELETE CASCADE,
+    created_by BIGINT REFERENCES users (id) ON DELETE SET NULL,
+    kind VARCHAR(20) NOT NULL,
+    file_name VARCHAR(255) NOT NULL,
+    dry_run BOOLEAN NOT NULL DEFAULT FALSE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    total_rows INTEGER NOT NULL DEFAULT 0,
+    processed_rows INTEGER NOT NULL DEFAULT 0,
+    created_rows INTEGER NOT NULL DEFAULT 0,
+    duplicate_rows INTEGER NOT NULL DEFAULT 0,
+    invalid_rows INTEGER NOT NULL DEFAULT 0,
+    report JSONB NOT NULL DEFAULT '[]',
+    error TEXT,
+    created_at TIMESTAMP(0) NOT NULL DEFAULT NOW (),
+    updated_at TIMESTAMP(0) NOT NULL DEFAULT NOW (),
+    finished_at TIMESTAMP(0)
+);
+
+ALTER TABLE import_jobs ADD CONSTRAINT valid_import_kind CHECK (kind IN ('customers', 'events'));
+
+ALTER TABLE import_jobs ADD CONSTRAINT valid_import_status CHECK (status IN ('pending', 'running', 'completed', 'failed'));
+
+CREATE INDEX idx_import_jobs_brand_id ON import_jobs (brand_id, created_at DESC);
+
+CREATE INDEX idx_events_user_start ON events (user_id, start_time);
+
+ALTER TABLE import_jobs ENABLE ROW LEVEL SECURITY;
+
+ALTER TABLE import_jobs FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY import_jobs_tenant_isolation ON import_jobs USING (tenant_allows (brand_id));
+
+-- +goose Down
+DROP INDEX idx_events_user_start;
+
+DROP TABLE import_jobs;
@@ -0,0 +1,54 @@
+-- +goose Up
+-- Events outlive an erased customer so that revenue figures stay intact
+ALTER TABLE events DROP CONSTRAINT events_customer_id_fkey;
+
+ALTER TABLE events ALTER COLUMN customer_id DROP NOT NULL;
+
+ALTER TABLE events ADD CONSTRAINT events_customer_id_fkey FOREIGN KEY (customer_id) REFERENCES customers (id) ON DELETE SET NULL;
+
+CREATE INDEX idx_events_customer_id ON events (customer_id);
+
+CREATE TABLE customer_erasures (
+    id BIGSERIAL PRIMARY KEY,
+    brand_id INTEGER NOT NULL REFERENCES brand (id) ON DELETE CASCADE,
+    customer_id BIGINT NOT NULL,
+    events_anonymized INTEGER NOT NULL DEFAULT 0,
+    erased_by BIGINT REFERENCES users (id) ON DELETE SET NULL,
+    created_at TIMESTAMP(0) NOT NULL DEFAULT NOW ()
+);
+
+CREATE INDEX idx_customer_erasures_brand_id ON customer_erasures (brand_id, created_at DESC);
+
+ALTER TABLE customer_erasures ENABLE ROW LEVEL SECURITY;
+
+ALTER TABLE customer_erasures FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY customer_erasures_tenant_isolation ON customer_erasures USING (tenant_allows (brand_id));
+
+-- +goose Down
+SELECT set_config('app.bypass_rls', 'on', true);
+
+-- Anonymized events have no customer to point back to, and they are part of
+-- the revenue history, so they have to be dealt with by hand first
+-- +goose StatementBegin
+DO $$
+DECLARE
+    anonymized BIGINT;
+BEGIN
+    SELECT COUNT(*) INTO anonymized FROM events WHERE customer_id IS NULL;
+
+    IF anonymized > 0 THEN
+        RAISE EXCEPTION '% events of erased customers have no customer', anonymized;
+    END IF;
+END $$;
+-- +goose StatementEnd
+
+DROP TABLE customer_erasures;
+
+DROP INDEX idx_events_customer_id;
+
+ALTER TABLE events DROP CONSTRAINT events_customer_id_fkey;
+
+ALTER TABLE events ALTER COLUMN customer_id SET NOT NULL;
+
+ALTER TABLE events ADD CONSTRAINT events_customer_id_fkey FOREIGN KEY (customer_id) REFERENCES customers (id);
@@ -0,0 +1,29 @@
+-- +goose Up
+CREATE TABLE customer_consents (
+    customer_id BIGINT NOT NULL REFERENCES customers (id) ON DELETE CASCADE,
+    brand_id INTEGER NOT NULL REFERENCES brand (id) ON DELETE CASCADE,
+    channel VARCHAR(10) NOT NULL,
+    purpose VARCHAR(20) NOT NULL,
+    granted BOOLEAN NOT NULL,
+    source VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP(0) NOT NULL DEFAULT NOW (),
+    updated_at TIMESTAMP(0) NOT NULL DEFAULT NOW (),
+    PRIMARY KEY (customer_id, channel, purpose)
+);
+
+ALTER TABLE customer_consents ADD CONSTRAINT valid_consent_channel CHECK (channel IN ('email', 'sms'));
+
+ALTER TABLE customer_consents ADD CONSTRAINT valid_consent_purpose CHECK (purpose IN ('transactional', 'reminders', 'marketing'));
+
+ALTER TABLE customer_consents ADD CONSTRAINT valid_consent_source CHECK (source IN ('signup', 'customer', 'staff', 'unsubscribe'));
+
+CREATE INDEX idx_customer_consents_brand_id ON customer_consents (brand_id);
+
+ALTER TABLE customer_consents ENABLE ROW LEVEL SECURITY;
+
+ALTER TABLE customer_consents FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY customer_consents_tenant_isolation ON customer_consents USING (tenant_allows (brand_id));
+
+-- +goose Down
+DROP TABLE customer_consents;
@@ -0,0 +1,36 @@
+-- +goose Up
+CREATE TABLE customer_dependents (
+    id BIGSERIAL PRIMARY KEY,
+    customer_id BIGINT NOT NULL REFERENCES customers (id) ON DELETE CASCADE,
+    brand_id INTEGER NOT NULL REFERENCES brand (id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    birth_date DATE,
+    notes TEXT,
+    created_at TIMESTAMP(0) NOT NULL DEFAULT NOW (),
+    updated_at TIMESTAMP(0) NOT NULL DEFAULT NOW ()
+);
+
+CREATE INDEX idx_customer_dependents_customer_id ON customer_dependents (customer_id);
+
+ALTER TABLE customer_dependents ENABLE ROW LEVEL SECURITY;
+
+ALTER TABLE customer_dependents FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY customer_dependents_tenant_isolation ON customer_dependents USING (tenant_allows (brand_id));
+
+-- The customer stays the account holder who gets the notifications, the
+-- dependent is the person the event is booked for
+ALTER TABLE events
+ADD COLUMN dependent_id BIGINT REFERENCES customer_dependents (id) ON DELETE SET NULL,
+ADD COLUMN dependent_name VARCHAR(100);
+
+CREATE INDEX idx_events_dependent_id ON events (dependent_id);
+
+-- +goose Down
+DROP INDEX idx_events_dependent_id;
+
+ALTER TABLE events
+DROP COLUMN dependent_name,
+DROP COLUMN dependent_id;
+
+DROP TABLE customer_dependents;
@@ -0,0 +1,38 @@
+-- +goose Up
+ALTER TABLE customers
+ADD COLUMN verified BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Tokens are stored hashed like user_invitations. A token is deleted when it
+-- is used so it can only be used once.
+CREATE TABLE customer_tokens (
+    token TEXT PRIMARY KEY,
+    customer_id BIGINT NOT NULL REFERENCES customers (id) ON DELETE CASCADE,
+    brand_id INTEGER NOT NULL REFERENCES brand (id) ON DELETE CASCADE,
+    scope VARCHAR(20) NOT NULL CHECK (scope IN ('verification', 'password_reset')),
+    expiry TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_customer_tokens_customer_id ON customer_tokens (customer_id);
+
+ALTER TABLE customer_tokens ENABLE ROW LEVEL SECURITY;
+
+ALTER TABLE customer_tokens FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY customer_tokens_tenant_isolation ON customer_tokens USING (tenant_allows (brand_id));
+
+CREATE TABLE user_tokens (
+    token TEXT PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    scope VARCHAR(20) NOT NULL CHECK (scope IN ('password_reset')),
+    expiry TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_user_tokens_user_id ON user_tokens (user_id);
+
+-- +goose Down
+DROP TABLE user_tokens;
+
+DROP TABLE customer_tokens;
+
+ALTER TABLE customers
+DROP COLUMN verified;
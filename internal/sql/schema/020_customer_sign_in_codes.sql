@@ -0,0 +1,27 @@
+-- +goose Up
+-- A passwordless sign in request carries a magic link token and a 6 digit code.
+-- Both are stored hashed and the request is used once, whichever of them
+-- arrives first.
+CREATE TABLE customer_sign_in_codes (
+    id BIGSERIAL PRIMARY KEY,
+    customer_id BIGINT NOT NULL REFERENCES customers (id) ON DELETE CASCADE,
+    brand_id INTEGER NOT NULL REFERENCES brand (id) ON DELETE CASCADE,
+    token TEXT NOT NULL UNIQUE,
+    code TEXT NOT NULL,
+    channel VARCHAR(10) NOT NULL CHECK (channel IN ('email', 'sms')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expiry TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP(0) NOT NULL DEFAULT NOW ()
+);
+
+CREATE INDEX idx_customer_sign_in_codes_customer_id ON customer_sign_in_codes (customer_id);
+
+ALTER TABLE customer_sign_in_codes ENABLE ROW LEVEL SECURITY;
+
+ALTER TABLE customer_sign_in_codes FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY customer_sign_in_codes_tenant_isolation ON customer_sign_in_codes USING (tenant_allows (brand_id));
+
+-- +goose Down
+DROP TABLE customer_sign_in_codes;
@@ -0,0 +1,49 @@
+-- +goose Up
+-- A user or customer may be signed in on several devices at once
+ALTER TABLE user_sessions
+DROP CONSTRAINT user_sessions_user_id_key,
+ADD COLUMN user_agent TEXT NOT NULL DEFAULT '',
+ADD COLUMN ip_address TEXT NOT NULL DEFAULT '',
+ADD COLUMN created_at TIMESTAMP(0) NOT NULL DEFAULT NOW (),
+ADD COLUMN last_seen_at TIMESTAMP NOT NULL DEFAULT NOW ();
+
+CREATE INDEX idx_user_sessions_user_id ON user_sessions (user_id);
+
+ALTER TABLE customer_sessions
+DROP CONSTRAINT customer_sessions_customer_id_key,
+ADD COLUMN user_agent TEXT NOT NULL DEFAULT '',
+ADD COLUMN ip_address TEXT NOT NULL DEFAULT '',
+ADD COLUMN created_at TIMESTAMP(0) NOT NULL DEFAULT NOW (),
+ADD COLUMN last_seen_at TIMESTAMP NOT NULL DEFAULT NOW ();
+
+CREATE INDEX idx_customer_sessions_customer_id ON customer_sessions (customer_id);
+
+-- +goose Down
+DROP INDEX idx_customer_sessions_customer_id;
+
+-- Keep the most recently used session of every customer
+DELETE FROM customer_sessions cs
+USING customer_sessions newer
+WHERE newer.customer_id = cs.customer_id
+AND (newer.last_seen_at, newer.id) > (cs.last_seen_at, cs.id);
+
+ALTER TABLE customer_sessions
+DROP COLUMN last_seen_at,
+DROP COLUMN created_at,
+DROP COLUMN ip_address,
+DROP COLUMN user_agent,
+ADD CONSTRAINT customer_sessions_customer_id_key UNIQUE (customer_id);
+
+DROP INDEX idx_user_sessions_user_id;
+
+DELETE FROM user_sessions us
+USING user_sessions newer
+WHERE newer.user_id = us.user_id
+AND (newer.last_seen_at, newer.id) > (us.last_seen_at, us.id);
+
+ALTER TABLE user_sessions
+DROP COLUMN last_seen_at,
+DROP COLUMN created_at,
+DROP COLUMN ip_address,
+DROP COLUMN user_agent,
+ADD CONSTRAINT user_sessions_user_id_key UNIQUE (user_id);
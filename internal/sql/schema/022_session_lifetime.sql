@@ -0,0 +1,36 @@
+-- +goose Up
+-- expires_at slides forward on activity but never past absolute_expires_at
+ALTER TABLE user_sessions
+ADD COLUMN absolute_expires_at TIMESTAMP,
+ADD COLUMN remember BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE user_sessions SET absolute_expires_at = expires_at;
+
+ALTER TABLE user_sessions
+ALTER COLUMN absolute_expires_at SET NOT NULL;
+
+ALTER TABLE customer_sessions
+ADD COLUMN absolute_expires_at TIMESTAMP,
+ADD COLUMN remember BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE customer_sessions SET absolute_expires_at = expires_at;
+
+ALTER TABLE customer_sessions
+ALTER COLUMN absolute_expires_at SET NOT NULL;
+
+CREATE INDEX idx_user_sessions_expires_at ON user_sessions (expires_at);
+
+CREATE INDEX idx_customer_sessions_expires_at ON customer_sessions (expires_at);
+
+-- +goose Down
+DROP INDEX idx_customer_sessions_expires_at;
+
+DROP INDEX idx_user_sessions_expires_at;
+
+ALTER TABLE customer_sessions
+DROP COLUMN remember,
+DROP COLUMN absolute_expires_at;
+
+ALTER TABLE user_sessions
+DROP COLUMN remember,
+DROP COLUMN absolute_expires_at;
@@ -0,0 +1,44 @@
+-- +goose Up
+ALTER TABLE brand
+ADD COLUMN require_two_factor BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- The secret is kept unconfirmed until the user proves the authenticator app
+-- works. last_used_step stops a code from being used twice.
+CREATE TABLE user_totp (
+    user_id BIGINT PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    secret TEXT NOT NULL,
+    confirmed_at TIMESTAMP,
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE user_recovery_codes (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    code_hash TEXT NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, code_hash)
+);
+
+-- A challenge is issued when the password is correct and the second step is
+-- still missing. The token is stored hashed and deleted when used.
+CREATE TABLE user_sign_in_challenges (
+    token TEXT PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    remember BOOLEAN NOT NULL DEFAULT FALSE,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expiry TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_user_sign_in_challenges_user_id ON user_sign_in_challenges (user_id);
+
+-- +goose Down
+DROP TABLE user_sign_in_challenges;
+
+DROP TABLE user_recovery_codes;
+
+DROP TABLE user_totp;
+
+ALTER TABLE brand
+DROP COLUMN require_two_factor;
@@ -0,0 +1,35 @@
+-- +goose Up
+-- Failed sign in counters used when Redis is not enabled. A row is forgotten
+-- once expires_at passes.
+CREATE TABLE sign_in_throttles (
+    key TEXT PRIMARY KEY,
+    failures INTEGER NOT NULL DEFAULT 0,
+    blocked_until TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_sign_in_throttles_expires_at ON sign_in_throttles (expires_at);
+
+-- Every sign in attempt of users and customers. Rows keep no foreign keys so
+-- the trail outlives the accounts.
+CREATE TABLE sign_in_attempts (
+    id BIGSERIAL PRIMARY KEY,
+    principal VARCHAR(20) NOT NULL CHECK (principal IN ('user', 'customer')),
+    account_id BIGINT,
+    brand_id INTEGER,
+    email VARCHAR(255) NOT NULL,
+    ip_address TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    success BOOLEAN NOT NULL,
+    reason VARCHAR(30) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_sign_in_attempts_account ON sign_in_attempts (principal, account_id, created_at DESC);
+
+CREATE INDEX idx_sign_in_attempts_ip_address ON sign_in_attempts (ip_address, created_at DESC);
+
+-- +goose Down
+DROP TABLE sign_in_attempts;
+
+DROP TABLE sign_in_throttles;
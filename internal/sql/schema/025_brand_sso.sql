@@ -0,0 +1,44 @@
+-- +goose Up
+-- One OpenID Connect provider per brand for staff sign in
+CREATE TABLE brand_oidc_providers (
+    brand_id INTEGER PRIMARY KEY REFERENCES brand (id) ON DELETE CASCADE,
+    issuer TEXT NOT NULL,
+    client_id TEXT NOT NULL,
+    client_secret TEXT NOT NULL DEFAULT '',
+    allowed_domains TEXT[] NOT NULL DEFAULT '{}',
+    default_role VARCHAR(255) NOT NULL DEFAULT 'user' CHECK (default_role IN ('admin', 'user')),
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- A login started at the provider. The state is stored hashed and deleted
+-- when the provider redirects back.
+CREATE TABLE oidc_login_states (
+    state TEXT PRIMARY KEY,
+    brand_id INTEGER NOT NULL REFERENCES brand (id) ON DELETE CASCADE,
+    nonce TEXT NOT NULL,
+    code_verifier TEXT NOT NULL,
+    remember BOOLEAN NOT NULL DEFAULT FALSE,
+    expiry TIMESTAMP NOT NULL
+);
+
+-- Links users to the accounts at the provider they sign in with
+CREATE TABLE user_identities (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    issuer TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (issuer, subject)
+);
+
+CREATE INDEX idx_user_identities_user_id ON user_identities (user_id);
+
+-- +goose Down
+DROP TABLE user_identities;
+
+DROP TABLE oidc_login_states;
+
+DROP TABLE brand_oidc_providers;
@@ -0,0 +1,23 @@
+-- +goose Up
+-- Keys for server to server integrations. Only the hash of a key is kept,
+-- the prefix finds the row without it.
+CREATE TABLE api_keys (
+    id BIGSERIAL PRIMARY KEY,
+    brand_id INTEGER NOT NULL REFERENCES brand (id) ON DELETE CASCADE,
+    -- The key acts as the user who created it
+    created_by BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(16) NOT NULL UNIQUE,
+    key_hash TEXT NOT NULL,
+    scopes TEXT[] NOT NULL,
+    expires_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    last_used_ip VARCHAR(45),
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_api_keys_brand_id ON api_keys (brand_id);
+
+-- +goose Down
+DROP TABLE api_keys;
@@ -0,0 +1,198 @@
+-- +goose Up
+-- Append only record of every change to the audited tables. Rows are written
+-- by triggers, so changes made in transactions, imports and background jobs
+-- are covered too. The actor comes from the app.actor setting the store
+-- applies from the request context, like app.brand_id.
+CREATE TABLE audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    -- No foreign key, the log outlives what it describes
+    brand_id INTEGER,
+    actor_type VARCHAR(20) NOT NULL CHECK (actor_type IN ('user', 'customer', 'api_key', 'anonymous', 'system')),
+    actor_id BIGINT,
+    actor_name VARCHAR(255) NOT NULL DEFAULT '',
+    api_key_id BIGINT,
+    action VARCHAR(100) NOT NULL,
+    entity_type VARCHAR(50) NOT NULL,
+    entity_id TEXT NOT NULL,
+    -- {"column": {"before": ..., "after": ...}} for every changed column
+    changes JSONB NOT NULL,
+    request_id TEXT NOT NULL DEFAULT '',
+    ip_address VARCHAR(45) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_audit_log_brand_id ON audit_log (brand_id, id);
+
+CREATE INDEX idx_audit_log_entity ON audit_log (entity_type, entity_id);
+
+CREATE INDEX idx_audit_log_created_at ON audit_log (created_at);
+
+-- +goose StatementBegin
+CREATE FUNCTION audit_log_append_only () RETURNS TRIGGER AS $$
+BEGIN
+    -- Only the retention purge, which runs privileged, may delete entries
+    IF TG_OP = 'DELETE' AND current_setting('app.bypass_rls', true) = 'on' THEN
+        RETURN OLD;
+    END IF;
+    RAISE EXCEPTION 'audit_log is append only';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON audit_log
+FOR EACH ROW EXECUTE FUNCTION audit_log_append_only ();
+
+-- audit_diff returns the changed columns with their values before and after.
+-- Secrets and the masked columns are replaced with a marker, so the log shows
+-- that personal data changed without keeping it after an erasure. Bookkeeping
+-- columns are left out and an update that only touches those is not logged.
+-- +goose StatementBegin
+CREATE FUNCTION audit_diff (old_row JSONB, new_row JSONB, masked TEXT[]) RETURNS JSONB AS $$
+    SELECT COALESCE(jsonb_object_agg(
+        key,
+        CASE WHEN key = ANY (masked) OR key IN ('password', 'key_hash', 'client_secret', 'secret', 'token', 'code_hash')
+            THEN jsonb_build_object('before', '"[redacted]"'::JSONB, 'after', '"[redacted]"'::JSONB)
+            ELSE jsonb_build_object('before', old_row -> key, 'after', new_row -> key)
+        END
+    ), '{}'::JSONB)
+    FROM jsonb_object_keys(COALESCE(old_row, '{}'::JSONB) || COALESCE(new_row, '{}'::JSONB)) AS key
+    WHERE old_row -> key IS DISTINCT FROM new_row -> key
+    AND key NOT IN ('updated_at', 'last_used_at', 'last_used_ip')
+$$ LANGUAGE SQL IMMUTABLE;
+-- +goose StatementEnd
+
+-- audit_row_change logs a row change. The first trigger argument is the
+-- column that identifies the entity, the others are columns to mask.
+-- +goose StatementBegin
+CREATE FUNCTION audit_row_change () RETURNS TRIGGER AS $$
+DECLARE
+    old_row JSONB;
+    new_row JSONB;
+    row_data JSONB;
+    changes JSONB;
+    actor JSONB;
+BEGIN
+    IF TG_OP <> 'INSERT' THEN
+        old_row := to_jsonb(OLD);
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        new_row := to_jsonb(NEW);
+    END IF;
+
+    changes := audit_diff(old_row, new_row, TG_ARGV[1:]);
+    IF changes = '{}'::JSONB THEN
+        RETURN NULL;
+    END IF;
+
+    row_data := COALESCE(new_row, old_row);
+    actor := NULLIF(current_setting('app.actor', true), '')::JSONB;
+
+    INSERT INTO audit_log (
+        brand_id, actor_type, actor_id, actor_name, api_key_id,
+        action, entity_type, entity_id, changes, request_id, ip_address
+    ) VALUES (
+        COALESCE(
+            (row_data ->> 'brand_id')::INTEGER,
+            CASE WHEN TG_TABLE_NAME = 'brand' THEN (row_data ->> 'id')::INTEGER END,
+            NULLIF(current_setting('app.brand_id', true), '')::INTEGER
+        ),
+        COALESCE(actor ->> 'type', 'system'),
+        (actor ->> 'id')::BIGINT,
+        COALESCE(actor ->> 'name', ''),
+        (actor ->> 'apiKeyId')::BIGINT,
+        TG_TABLE_NAME || '.' || lower(TG_OP),
+        TG_TABLE_NAME,
+        COALESCE(row_data ->> TG_ARGV[0], ''),
+        changes,
+        COALESCE(actor ->> 'requestId', ''),
+        COALESCE(actor ->> 'ip', '')
+    );
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER audit_events AFTER INSERT OR UPDATE OR DELETE ON events
+FOR EACH ROW EXECUTE FUNCTION audit_row_change ('id', 'customer_name', 'dependent_name', 'comment');
+
+CREATE TRIGGER audit_services AFTER INSERT OR UPDATE OR DELETE ON services
+FOR EACH ROW EXECUTE FUNCTION audit_row_change ('id');
+
+CREATE TRIGGER audit_user_services AFTER INSERT OR UPDATE OR DELETE ON user_services
+FOR EACH ROW EXECUTE FUNCTION audit_row_change ('service_id');
+
+CREATE TRIGGER audit_brand AFTER INSERT OR UPDATE OR DELETE ON brand
+FOR EACH ROW EXECUTE FUNCTION audit_row_change ('id');
+
+CREATE TRIGGER audit_brand_working_hours AFTER INSERT OR UPDATE OR DELETE ON brand_working_hours
+FOR EACH ROW EXECUTE FUNCTION audit_row_change ('id');
+
+CREATE TRIGGER audit_brand_social_link AFTER INSERT OR UPDATE OR DELETE ON brand_social_link
+FOR EACH ROW EXECUTE FUNCTION audit_row_change ('id');
+
+CREATE TRIGGER audit_brand_oidc_providers AFTER INSERT OR UPDATE OR DELETE ON brand_oidc_providers
+FOR EACH ROW EXECUTE FUNCTION audit_row_change ('brand_id');
+
+CREATE TRIGGER audit_users AFTER INSERT OR UPDATE OR DELETE ON users
+FOR EACH ROW EXECUTE FUNCTION audit_row_change ('id');
+
+CREATE TRIGGER audit_api_keys AFTER INSERT OR UPDATE OR DELETE ON api_keys
+FOR EACH ROW EXECUTE FUNCTION audit_row_change ('id');
+
+CREATE TRIGGER audit_customers AFTER INSERT OR UPDATE OR DELETE ON customers
+FOR EACH ROW EXECUTE FUNCTION audit_row_change ('id', 'name', 'email', 'phone_number', 'phone_e164');
+
+CREATE TRIGGER audit_customer_notes AFTER INSERT OR UPDATE OR DELETE ON customer_notes
+FOR EACH ROW EXECUTE FUNCTION audit_row_change ('id', 'body');
+
+CREATE TRIGGER audit_customer_fields AFTER INSERT OR UPDATE OR DELETE ON customer_fields
+FOR EACH ROW EXECUTE FUNCTION audit_row_change ('id');
+
+CREATE TRIGGER audit_customer_field_values AFTER INSERT OR UPDATE OR DELETE ON customer_field_values
+FOR EACH ROW EXECUTE FUNCTION audit_row_change ('customer_id', 'value');
+
+CREATE TRIGGER audit_customer_consents AFTER INSERT OR UPDATE OR DELETE ON customer_consents
+FOR EACH ROW EXECUTE FUNCTION audit_row_change ('customer_id');
+
+CREATE TRIGGER audit_customer_dependents AFTER INSERT OR UPDATE OR DELETE ON customer_dependents
+FOR EACH ROW EXECUTE FUNCTION audit_row_change ('id', 'name', 'birth_date', 'notes');
+
+-- +goose Down
+DROP TRIGGER audit_customer_dependents ON customer_dependents;
+
+DROP TRIGGER audit_customer_consents ON customer_consents;
+
+DROP TRIGGER audit_customer_field_values ON customer_field_values;
+
+DROP TRIGGER audit_customer_fields ON customer_fields;
+
+DROP TRIGGER audit_customer_notes ON customer_notes;
+
+DROP TRIGGER audit_customers ON customers;
+
+DROP TRIGGER audit_api_keys ON api_keys;
+
+DROP TRIGGER audit_users ON users;
+
+DROP TRIGGER audit_brand_oidc_providers ON brand_oidc_providers;
+
+DROP TRIGGER audit_brand_social_link ON brand_social_link;
+
+DROP TRIGGER audit_brand_working_hours ON brand_working_hours;
+
+DROP TRIGGER audit_brand ON brand;
+
+DROP TRIGGER audit_user_services ON user_services;
+
+DROP TRIGGER audit_services ON services;
+
+DROP TRIGGER audit_events ON events;
+
+DROP FUNCTION audit_row_change;
+
+DROP FUNCTION audit_diff;
+
+DROP TABLE audit_log;
+
+DROP FUNCTION audit_log_append_only;
@@ -0,0 +1,111 @@
+-- +goose Up
+-- Events carry a version that goes up with every change, so an update made
+-- from a stale copy of the event can be rejected.
+ALTER TABLE events ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+-- Previous and new values of the fields the calendar shows, per version.
+-- Customer and dependent names are left out and comments are redacted so an
+-- erasure doesn't leave them behind. The erasure drops the older versions of
+-- the customer's events.
+CREATE TABLE event_history (
+    id BIGSERIAL PRIMARY KEY,
+    event_id BIGINT NOT NULL REFERENCES events (id) ON DELETE CASCADE,
+    brand_id INTEGER NOT NULL REFERENCES brand (id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    action VARCHAR(20) NOT NULL CHECK (action IN ('created', 'updated')),
+    actor_type VARCHAR(20) NOT NULL,
+    actor_id BIGINT,
+    actor_name VARCHAR(255) NOT NULL DEFAULT '',
+    changes JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (event_id, version)
+);
+
+ALTER TABLE event_history ENABLE ROW LEVEL SECURITY;
+
+ALTER TABLE event_history FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY event_history_tenant_isolation ON event_history USING (tenant_allows (brand_id));
+
+-- +goose StatementBegin
+CREATE FUNCTION event_tracked_fields (e events) RETURNS JSONB AS $$
+    SELECT jsonb_build_object(
+        'start_time', e.start_time,
+        'end_time', e.end_time,
+        'user_id', e.user_id,
+        'user_name', e.user_name,
+        'service_id', e.service_id,
+        'service_name', e.service_name,
+        'customer_id', e.customer_id,
+        'dependent_id', e.dependent_id,
+        'comment', e.comment,
+        'cost', e.cost,
+        'buffer_time', e.buffer_time,
+        'no_show', e.no_show
+    )
+$$ LANGUAGE SQL IMMUTABLE;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE FUNCTION event_bump_version () RETURNS TRIGGER AS $$
+BEGIN
+    IF event_tracked_fields(NEW) IS DISTINCT FROM event_tracked_fields(OLD) THEN
+        NEW.version := OLD.version + 1;
+    ELSE
+        NEW.version := OLD.version;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE FUNCTION event_record_history () RETURNS TRIGGER AS $$
+DECLARE
+    actor JSONB := NULLIF(current_setting('app.actor', true), '')::JSONB;
+    old_fields JSONB;
+BEGIN
+    IF TG_OP = 'UPDATE' THEN
+        IF NEW.version = OLD.version THEN
+            RETURN NULL;
+        END IF;
+        old_fields := event_tracked_fields(OLD);
+    END IF;
+
+    INSERT INTO event_history (event_id, brand_id, version, action, actor_type, actor_id, actor_name, changes)
+    VALUES (
+        NEW.id,
+        NEW.brand_id,
+        NEW.version,
+        CASE TG_OP WHEN 'INSERT' THEN 'created' ELSE 'updated' END,
+        COALESCE(actor ->> 'type', 'system'),
+        (actor ->> 'id')::BIGINT,
+        COALESCE(actor ->> 'name', ''),
+        audit_diff(old_fields, event_tracked_fields(NEW), '{comment}')
+    );
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER event_bump_version BEFORE UPDATE ON events
+FOR EACH ROW EXECUTE FUNCTION event_bump_version ();
+
+CREATE TRIGGER event_record_history AFTER INSERT OR UPDATE ON events
+FOR EACH ROW EXECUTE FUNCTION event_record_history ();
+
+-- +goose Down
+DROP TRIGGER event_record_history ON events;
+
+DROP TRIGGER event_bump_version ON events;
+
+DROP FUNCTION event_record_history;
+
+DROP FUNCTION event_bump_version;
+
+DROP FUNCTION event_tracked_fields;
+
+DROP TABLE event_history;
+
+ALTER TABLE events DROP COLUMN version;
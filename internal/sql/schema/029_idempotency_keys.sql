@@ -0,0 +1,25 @@
+-- +goose Up
+-- Responses of requests sent with an Idempotency-Key header, replayed when a
+-- client retries the request. A key without a status code is claimed by a
+-- request that is still running.
+CREATE TABLE idempotency_keys (
+    id BIGSERIAL PRIMARY KEY,
+    -- Who sent the request, so clients can't read each other's responses
+    scope VARCHAR(100) NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL,
+    method VARCHAR(10) NOT NULL,
+    path TEXT NOT NULL,
+    -- SHA-256 of the method, path and body of the request
+    fingerprint VARCHAR(64) NOT NULL,
+    status_code INTEGER,
+    content_type VARCHAR(255) NOT NULL DEFAULT '',
+    response_body BYTEA,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP NOT NULL,
+    UNIQUE (scope, idempotency_key)
+);
+
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
+
+-- +goose Down
+DROP TABLE idempotency_keys;
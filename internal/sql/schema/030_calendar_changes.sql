@@ -0,0 +1,92 @@
+-- +goose Up
+-- Changes to the calendar, streamed to the front desk. Every change is sent to
+-- the listening API instances with NOTIFY and kept for a while so a client
+-- that reconnects can catch up from the last change it saw.
+CREATE TABLE calendar_changes (
+    id BIGSERIAL PRIMARY KEY,
+    brand_id INTEGER NOT NULL REFERENCES brand (id) ON DELETE CASCADE,
+    -- No reference, the change outlives a cancelled event
+    event_id BIGINT NOT NULL,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('created', 'updated', 'cancelled')),
+    user_id BIGINT NOT NULL,
+    -- Staff the event was moved away from
+    previous_user_id BIGINT,
+    start_time TIMESTAMP NOT NULL,
+    end_time TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_calendar_changes_brand_id ON calendar_changes (brand_id, id);
+
+CREATE INDEX idx_calendar_changes_created_at ON calendar_changes (created_at);
+
+ALTER TABLE calendar_changes ENABLE ROW LEVEL SECURITY;
+
+ALTER TABLE calendar_changes FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY calendar_changes_tenant_isolation ON calendar_changes USING (tenant_allows (brand_id));
+
+-- The version only moves when a field the calendar shows changed, other
+-- updates are not streamed.
+-- +goose StatementBegin
+CREATE FUNCTION calendar_record_change () RETURNS TRIGGER AS $$
+DECLARE
+    change calendar_changes;
+    lock_brand_id INTEGER := COALESCE(NEW.brand_id, OLD.brand_id);
+BEGIN
+    IF TG_OP = 'UPDATE' AND NEW.version = OLD.version THEN
+        RETURN NULL;
+    END IF;
+
+    -- Streams skip the IDs they already sent, so the changes of a brand must
+    -- commit in ID order. Taking the ID under a lock held until the commit
+    -- keeps a lower ID from committing after a higher one.
+    PERFORM pg_advisory_xact_lock(hashtext('calendar_changes'), lock_brand_id);
+
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO calendar_changes (brand_id, event_id, kind, user_id, start_time, end_time)
+        VALUES (OLD.brand_id, OLD.id, 'cancelled', OLD.user_id, OLD.start_time, OLD.end_time)
+        RETURNING * INTO change;
+    ELSIF TG_OP = 'INSERT' THEN
+        INSERT INTO calendar_changes (brand_id, event_id, kind, user_id, start_time, end_time)
+        VALUES (NEW.brand_id, NEW.id, 'created', NEW.user_id, NEW.start_time, NEW.end_time)
+        RETURNING * INTO change;
+    ELSE
+        INSERT INTO calendar_changes (brand_id, event_id, kind, user_id, previous_user_id, start_time, end_time)
+        VALUES (
+            NEW.brand_id,
+            NEW.id,
+            'updated',
+            NEW.user_id,
+            NULLIF(OLD.user_id, NEW.user_id),
+            NEW.start_time,
+            NEW.end_time
+        )
+        RETURNING * INTO change;
+    END IF;
+
+    PERFORM pg_notify('calendar_changes', json_build_object(
+        'id', change.id,
+        'brandId', change.brand_id,
+        'eventId', change.event_id,
+        'kind', change.kind,
+        'userId', change.user_id,
+        'previousUserId', change.previous_user_id,
+        'startTime', change.start_time AT TIME ZONE 'UTC',
+        'endTime', change.end_time AT TIME ZONE 'UTC'
+    )::TEXT);
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER calendar_record_change AFTER INSERT OR UPDATE OR DELETE ON events
+FOR EACH ROW EXECUTE FUNCTION calendar_record_change ();
+
+-- +goose Down
+DROP TRIGGER calendar_record_change ON events;
+
+DROP FUNCTION calendar_record_change;
+
+DROP TABLE calendar_changes;
@@ -0,0 +1,115 @@
+-- +goose Up
+CREATE TABLE webhook_subscriptions (
+    id BIGSERIAL PRIMARY KEY,
+    brand_id INTEGER NOT NULL REFERENCES brand (id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    event_types TEXT[] NOT NULL,
+    -- Signs the deliveries, so it is kept in plain text
+    secret TEXT NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by BIGINT REFERENCES users (id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_webhook_subscriptions_brand_id ON webhook_subscriptions (brand_id);
+
+-- The outbox. Deliveries are written by triggers in the transaction of the
+-- change, the dispatcher sends the pending ones.
+CREATE TABLE webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    subscription_id BIGINT NOT NULL REFERENCES webhook_subscriptions (id) ON DELETE CASCADE,
+    brand_id INTEGER NOT NULL REFERENCES brand (id) ON DELETE CASCADE,
+    event_type VARCHAR(50) NOT NULL,
+    -- The changed row, or the removed one for deletes
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'succeeded', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_attempt_at TIMESTAMP,
+    last_status_code INTEGER,
+    last_error TEXT NOT NULL DEFAULT '',
+    delivered_at TIMESTAMP,
+    -- Delivery this one was sent again for
+    redelivery_of BIGINT REFERENCES webhook_deliveries (id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_webhook_deliveries_subscription_id ON webhook_deliveries (subscription_id, id);
+
+CREATE INDEX idx_webhook_deliveries_pending ON webhook_deliveries (next_attempt_at)
+WHERE status = 'pending';
+
+ALTER TABLE webhook_subscriptions ENABLE ROW LEVEL SECURITY;
+
+ALTER TABLE webhook_subscriptions FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY webhook_subscriptions_tenant_isolation ON webhook_subscriptions USING (tenant_allows (brand_id));
+
+ALTER TABLE webhook_deliveries ENABLE ROW LEVEL SECURITY;
+
+ALTER TABLE webhook_deliveries FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY webhook_deliveries_tenant_isolation ON webhook_deliveries USING (tenant_allows (brand_id));
+
+-- webhook_enqueue queues a delivery of a row change for every subscription of
+-- the brand to its type. The first trigger argument is the entity name used
+-- in the type, the second the type of a delete, the others are columns left
+-- out of the payload.
+-- +goose StatementBegin
+CREATE FUNCTION webhook_enqueue () RETURNS TRIGGER AS $$
+DECLARE
+    row_data JSONB;
+    change_type TEXT;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        row_data := to_jsonb(OLD);
+        change_type := TG_ARGV[0] || '.' || TG_ARGV[1];
+    ELSE
+        row_data := to_jsonb(NEW);
+        IF TG_OP = 'UPDATE' AND row_data - 'updated_at' = to_jsonb(OLD) - 'updated_at' THEN
+            RETURN NULL;
+        END IF;
+        change_type := TG_ARGV[0] || CASE TG_OP WHEN 'INSERT' THEN '.created' ELSE '.updated' END;
+    END IF;
+
+    row_data := row_data - TG_ARGV[2:];
+
+    INSERT INTO webhook_deliveries (subscription_id, brand_id, event_type, payload)
+    SELECT s.id, s.brand_id, change_type, row_data
+    FROM webhook_subscriptions s
+    WHERE s.brand_id = (row_data ->> 'brand_id')::INTEGER
+    AND s.active
+    AND change_type = ANY (s.event_types);
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER webhook_events AFTER INSERT OR UPDATE OR DELETE ON events
+FOR EACH ROW EXECUTE FUNCTION webhook_enqueue ('event', 'cancelled');
+
+CREATE TRIGGER webhook_customers AFTER INSERT OR UPDATE OR DELETE ON customers
+FOR EACH ROW EXECUTE FUNCTION webhook_enqueue ('customer', 'deleted', 'password');
+
+CREATE TRIGGER webhook_services AFTER INSERT OR UPDATE OR DELETE ON services
+FOR EACH ROW EXECUTE FUNCTION webhook_enqueue ('service', 'deleted');
+
+CREATE TRIGGER audit_webhook_subscriptions AFTER INSERT OR UPDATE OR DELETE ON webhook_subscriptions
+FOR EACH ROW EXECUTE FUNCTION audit_row_change ('id');
+
+-- +goose Down
+DROP TRIGGER audit_webhook_subscriptions ON webhook_subscriptions;
+
+DROP TRIGGER webhook_services ON services;
+
+DROP TRIGGER webhook_customers ON customers;
+
+DROP TRIGGER webhook_events ON events;
+
+DROP FUNCTION webhook_enqueue;
+
+DROP TABLE webhook_deliveries;
+
+DROP TABLE webhook_subscriptions;
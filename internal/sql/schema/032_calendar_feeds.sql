@@ -0,0 +1,25 @@
+-- +goose Up
+-- Secret iCalendar feed URLs. A feed is for a staff member, a customer or,
+-- without either, the whole brand. Only the hash of the token is kept.
+CREATE TABLE calendar_feeds (
+    id BIGSERIAL PRIMARY KEY,
+    brand_id INTEGER NOT NULL REFERENCES brand (id) ON DELETE CASCADE,
+    user_id BIGINT REFERENCES users (id) ON DELETE CASCADE,
+    customer_id BIGINT REFERENCES customers (id) ON DELETE CASCADE,
+    token_hash TEXT NOT NULL UNIQUE,
+    last_fetched_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CHECK (user_id IS NULL OR customer_id IS NULL)
+);
+
+CREATE UNIQUE INDEX idx_calendar_feeds_user_id ON calendar_feeds (user_id)
+WHERE user_id IS NOT NULL;
+
+CREATE UNIQUE INDEX idx_calendar_feeds_customer_id ON calendar_feeds (customer_id)
+WHERE customer_id IS NOT NULL;
+
+CREATE UNIQUE INDEX idx_calendar_feeds_brand_id ON calendar_feeds (brand_id)
+WHERE user_id IS NULL AND customer_id IS NULL;
+
+-- +goose Down
+DROP TABLE calendar_feeds;
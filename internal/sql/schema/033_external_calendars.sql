@@ -0,0 +1,55 @@
+-- +goose Up
+-- ICS calendars staff keep elsewhere, subscribed to by URL or uploaded. The
+-- file of an uploaded calendar is kept to expand its recurring events as
+-- time goes by, a subscribed one is fetched on every sync.
+CREATE TABLE external_calendars (
+    id BIGSERIAL PRIMARY KEY,
+    brand_id INTEGER NOT NULL REFERENCES brand (id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    url TEXT,
+    content TEXT,
+    -- Last successful sync and the error of the last attempt, if any
+    last_synced_at TIMESTAMP,
+    last_error TEXT NOT NULL DEFAULT '',
+    next_sync_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CHECK ((url IS NULL) <> (content IS NULL))
+);
+
+CREATE INDEX idx_external_calendars_user_id ON external_calendars (user_id);
+
+CREATE INDEX idx_external_calendars_next_sync_at ON external_calendars (next_sync_at);
+
+-- Only when the calendars are busy, never what their events are about
+CREATE TABLE external_busy_times (
+    id BIGSERIAL PRIMARY KEY,
+    calendar_id BIGINT NOT NULL REFERENCES external_calendars (id) ON DELETE CASCADE,
+    brand_id INTEGER NOT NULL REFERENCES brand (id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    start_time TIMESTAMP NOT NULL,
+    end_time TIMESTAMP NOT NULL,
+    CHECK (end_time > start_time)
+);
+
+CREATE INDEX idx_external_busy_times_user_id ON external_busy_times (user_id, start_time);
+
+CREATE INDEX idx_external_busy_times_calendar_id ON external_busy_times (calendar_id);
+
+ALTER TABLE external_calendars ENABLE ROW LEVEL SECURITY;
+
+ALTER TABLE external_calendars FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY external_calendars_tenant_isolation ON external_calendars USING (tenant_allows (brand_id));
+
+ALTER TABLE external_busy_times ENABLE ROW LEVEL SECURITY;
+
+ALTER TABLE external_busy_times FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY external_busy_times_tenant_isolation ON external_busy_times USING (tenant_allows (brand_id));
+
+-- +goose Down
+DROP TABLE external_busy_times;
+
+DROP TABLE external_calendars;
@@ -0,0 +1,55 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+)
+
+type actorKey string
+
+const auditActorKey actorKey = "audit-actor"
+
+// Actor types recorded in the audit log. Changes made without an actor in the
+// context, such as background jobs, are logged as the system.
+const (
+	ActorUser      = "user"
+	ActorCustomer  = "customer"
+	ActorAPIKey    = "api_key"
+	ActorAnonymous = "anonymous"
+	ActorSystem    = "system"
+)
+
+// Actor is who makes the changes of a request. The store hands it to the
+// audit triggers in the app.actor setting.
+type Actor struct {
+	Type      string `json:"type"`
+	ID        int64  `json:"id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	APIKeyID  int64  `json:"apiKeyId,omitempty"`
+	RequestID string `json:"requestId,omitempty"`
+	IP        string `json:"ip,omitempty"`
+}
+
+// WithActor attributes the changes made with the returned context to the
+// actor in the audit log
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, auditActorKey, actor)
+}
+
+func ActorFromContext(ctx context.Context) (Actor, bool) {
+	actor, ok := ctx.Value(auditActorKey).(Actor)
+	return actor, ok
+}
+
+func actorFromContext(ctx context.Context) string {
+	actor, ok := ActorFromContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	data, err := json.Marshal(actor)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
@@ -60,7 +60,7 @@ func (q *Queries) AssociateUserWithBrand(ctx context.Context, arg AssociateUserW
 
 const createBrand = `-- name: CreateBrand :one
 INSERT INTO brand (name, page_url)
-VALUES ($1, $2) RETURNING id, name, page_url, description, email, phone, country, state, zip_code, city, address, logo_url, banner_url, currency, created_at, updated_at
+VALUES ($1, $2) RETURNING id, name, page_url, description, email, phone, country, state, zip_code, city, address, logo_url, banner_url, currency, created_at, updated_at, require_two_factor
 `
 
 type CreateBrandParams struct {
@@ -88,6 +88,7 @@ func (q *Queries) CreateBrand(ctx context.Context, arg CreateBrandParams) (*Bran
 		&i.Currency,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.RequireTwoFactor,
 	)
 	return &i, err
 }
@@ -102,7 +103,7 @@ func (q *Queries) DeleteBrandSocialLinks(ctx context.Context, brandID int32) err
 }
 
 const getBrand = `-- name: GetBrand :one
-SELECT id, name, page_url, description, email, phone, country, state, zip_code, city, address, logo_url, banner_url, currency, created_at, updated_at FROM brand WHERE id = $1
+SELECT id, name, page_url, description, email, phone, country, state, zip_code, city, address, logo_url, banner_url, currency, created_at, updated_at, require_two_factor FROM brand WHERE id = $1
 `
 
 func (q *Queries) GetBrand(ctx context.Context, id int32) (*Brand, error) {
@@ -125,12 +126,13 @@ func (q *Queries) GetBrand(ctx context.Context, id int32) (*Brand, error) {
 		&i.Currency,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.RequireTwoFactor,
 	)
 	return &i, err
 }
 
 const getBrandById = `-- name: GetBrandById :one
-SELECT id, name, page_url, description, email, phone, country, state, zip_code, city, address, logo_url, banner_url, currency, created_at, updated_at FROM brand WHERE id = $1
+SELECT id, name, page_url, description, email, phone, country, state, zip_code, city, address, logo_url, banner_url, currency, created_at, updated_at, require_two_factor FROM brand WHERE id = $1
 `
 
 func (q *Queries) GetBrandById(ctx context.Context, id int32) (*Brand, error) {
@@ -153,6 +155,7 @@ func (q *Queries) GetBrandById(ctx context.Context, id int32) (*Brand, error) {
 		&i.Currency,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.RequireTwoFactor,
 	)
 	return &i, err
 }
@@ -279,6 +282,44 @@ func (q *Queries) GetBrandWorkingHours(ctx context.Context, brandID int32) ([]*B
 	return items, nil
 }
 
+const setBrandRequireTwoFactor = `-- name: SetBrandRequireTwoFactor :one
+UPDATE brand
+SET require_two_factor = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING id, name, page_url, description, email, phone, country, state, zip_code, city, address, logo_url, banner_url, currency, created_at, updated_at, require_two_factor
+`
+
+type SetBrandRequireTwoFactorParams struct {
+	ID               int32 `json:"id"`
+	RequireTwoFactor bool  `json:"requireTwoFactor"`
+}
+
+func (q *Queries) SetBrandRequireTwoFactor(ctx context.Context, arg SetBrandRequireTwoFactorParams) (*Brand, error) {
+	row := q.db.QueryRowContext(ctx, setBrandRequireTwoFactor, arg.ID, arg.RequireTwoFactor)
+	var i Brand
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.PageUrl,
+		&i.Description,
+		&i.Email,
+		&i.Phone,
+		&i.Country,
+		&i.State,
+		&i.ZipCode,
+		&i.City,
+		&i.Address,
+		&i.LogoUrl,
+		&i.BannerUrl,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.RequireTwoFactor,
+	)
+	return &i, err
+}
+
 const updateBrand = `-- name: UpdateBrand :one
 UPDATE brand
 SET name = $1,
@@ -296,7 +337,7 @@ SET name = $1,
     currency = $13,
     updated_at = NOW()
 WHERE id = $14
-RETURNING id, name, page_url, description, email, phone, country, state, zip_code, city, address, logo_url, banner_url, currency, created_at, updated_at
+RETURNING id, name, page_url, description, email, phone, country, state, zip_code, city, address, logo_url, banner_url, currency, created_at, updated_at, require_two_factor
 `
 
 type UpdateBrandParams struct {
@@ -351,6 +392,7 @@ func (q *Queries) UpdateBrand(ctx context.Context, arg UpdateBrandParams) (*Bran
 		&i.Currency,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.RequireTwoFactor,
 	)
 	return &i, err
 }
@@ -373,7 +415,7 @@ SET
     currency = COALESCE($13, currency),
     updated_at = NOW()
 WHERE id = $14
-RETURNING id, name, page_url, description, email, phone, country, state, zip_code, city, address, logo_url, banner_url, currency, created_at, updated_at
+RETURNING id, name, page_url, description, email, phone, country, state, zip_code, city, address, logo_url, banner_url, currency, created_at, updated_at, require_two_factor
 `
 
 type UpdateBrandPartialParams struct {
@@ -428,6 +470,7 @@ func (q *Queries) UpdateBrandPartial(ctx context.Context, arg UpdateBrandPartial
 		&i.Currency,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.RequireTwoFactor,
 	)
 	return &i, err
 }
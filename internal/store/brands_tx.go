@@ -8,24 +8,26 @@ import (
 )
 
 type BrandResponse struct {
-	ID           int32         `json:"id"`
-	Name         string        `json:"name"`
-	PageUrl      string        `json:"pageUrl"`
-	Description  string        `json:"description"`
-	Email        string        `json:"email"`
-	Phone        string        `json:"phone"`
-	Country      string        `json:"country"`
-	State        string        `json:"state"`
-	ZipCode      string        `json:"zipCode"`
-	City         string        `json:"city"`
-	Address      string        `json:"address"`
-	LogoUrl      string        `json:"logoUrl"`
-	BannerUrl    string        `json:"bannerUrl"`
-	Currency     string        `json:"currency"`
-	CreatedAt    time.Time     `json:"createdAt"`
-	UpdatedAt    time.Time     `json:"updatedAt"`
-	SocialLinks  []SocialLink  `json:"socialLinks"`
-	WorkingHours []WorkingHour `json:"workingHours"`
+	ID          int32  `json:"id"`
+	Name        string `json:"name"`
+	PageUrl     string `json:"pageUrl"`
+	Description string `json:"description"`
+	Email       string `json:"email"`
+	Phone       string `json:"phone"`
+	Country     string `json:"country"`
+	State       string `json:"state"`
+	ZipCode     string `json:"zipCode"`
+	City        string `json:"city"`
+	Address     string `json:"address"`
+	LogoUrl     string `json:"logoUrl"`
+	BannerUrl   string `json:"bannerUrl"`
+	Currency    string `json:"currency"`
+	// RequireTwoFactor makes every user of the brand sign in with 2FA
+	RequireTwoFactor bool          `json:"requireTwoFactor"`
+	CreatedAt        time.Time     `json:"createdAt"`
+	UpdatedAt        time.Time     `json:"updatedAt"`
+	SocialLinks      []SocialLink  `json:"socialLinks"`
+	WorkingHours     []WorkingHour `json:"workingHours"`
 }
 
 type SocialLink struct {
@@ -69,6 +71,9 @@ func (s *SQLStore) CreateBrandTx(ctx context.Context, arg CreateBrandTxParams) (
 			return err
 		}
 
+		// The new brand's rows are written under its own tenant scope
+		ctx := WithBrandID(ctx, brand.ID)
+
 		err = q.AssociateUserWithBrand(ctx, AssociateUserWithBrandParams{
 			BrandID: sql.NullInt32{
 				Valid: true,
@@ -8,11 +8,14 @@ package store
 import (
 	"context"
 	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
 )
 
 const createCustomer = `-- name: CreateCustomer :one
-INSERT INTO customers (name, email, password, phone_number, brand_id) VALUES ($1, $2, $3, $4, $5)
-RETURNING id, name, email, password, phone_number, brand_id, created_at, updated_at
+INSERT INTO customers (name, email, password, phone_number, brand_id, phone_e164) VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, name, email, password, phone_number, brand_id, created_at, updated_at, phone_e164, tags, verified
 `
 
 type CreateCustomerParams struct {
@@ -21,6 +24,7 @@ type CreateCustomerParams struct {
 	Password    []byte         `json:"password"`
 	PhoneNumber string         `json:"phoneNumber"`
 	BrandID     int32          `json:"brandId"`
+	PhoneE164   sql.NullString `json:"phoneE164"`
 }
 
 func (q *Queries) CreateCustomer(ctx context.Context, arg CreateCustomerParams) (*Customer, error) {
@@ -30,6 +34,7 @@ func (q *Queries) CreateCustomer(ctx context.Context, arg CreateCustomerParams)
 		arg.Password,
 		arg.PhoneNumber,
 		arg.BrandID,
+		arg.PhoneE164,
 	)
 	var i Customer
 	err := row.Scan(
@@ -41,13 +46,16 @@ func (q *Queries) CreateCustomer(ctx context.Context, arg CreateCustomerParams)
 		&i.BrandID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.PhoneE164,
+		pq.Array(&i.Tags),
+		&i.Verified,
 	)
 	return &i, err
 }
 
 const createGuestCustomer = `-- name: CreateGuestCustomer :one
-INSERT INTO customers (name, email, phone_number, brand_id) VALUES ($1, $2, $3, $4)
-RETURNING id, name, email, password, phone_number, brand_id, created_at, updated_at
+INSERT INTO customers (name, email, phone_number, brand_id, phone_e164) VALUES ($1, $2, $3, $4, $5)
+RETURNING id, name, email, password, phone_number, brand_id, created_at, updated_at, phone_e164, tags, verified
 `
 
 type CreateGuestCustomerParams struct {
@@ -55,6 +63,7 @@ type CreateGuestCustomerParams struct {
 	Email       sql.NullString `json:"email"`
 	PhoneNumber string         `json:"phoneNumber"`
 	BrandID     int32          `json:"brandId"`
+	PhoneE164   sql.NullString `json:"phoneE164"`
 }
 
 func (q *Queries) CreateGuestCustomer(ctx context.Context, arg CreateGuestCustomerParams) (*Customer, error) {
@@ -63,6 +72,7 @@ func (q *Queries) CreateGuestCustomer(ctx context.Context, arg CreateGuestCustom
 		arg.Email,
 		arg.PhoneNumber,
 		arg.BrandID,
+		arg.PhoneE164,
 	)
 	var i Customer
 	err := row.Scan(
@@ -74,6 +84,9 @@ func (q *Queries) CreateGuestCustomer(ctx context.Context, arg CreateGuestCustom
 		&i.BrandID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.PhoneE164,
+		pq.Array(&i.Tags),
+		&i.Verified,
 	)
 	return &i, err
 }
@@ -87,12 +100,96 @@ func (q *Queries) DeleteCustomer(ctx context.Context, id int64) error {
 	return err
 }
 
+const exportCustomers = `-- name: ExportCustomers :many
+SELECT id, name, email, password, phone_number, brand_id, created_at, updated_at, phone_e164, tags, verified FROM customers
+WHERE brand_id = $1
+ORDER BY id
+`
+
+func (q *Queries) ExportCustomers(ctx context.Context, brandID int32) ([]*Customer, error) {
+	rows, err := q.db.QueryContext(ctx, exportCustomers, brandID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*Customer
+	for rows.Next() {
+		var i Customer
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Email,
+			&i.Password,
+			&i.PhoneNumber,
+			&i.BrandID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.PhoneE164,
+			pq.Array(&i.Tags),
+			&i.Verified,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const fillCustomerAccount = `-- name: FillCustomerAccount :one
+UPDATE customers
+SET email = COALESCE(email, $2),
+    phone_e164 = COALESCE(phone_e164, $3),
+    updated_at = NOW()
+WHERE id = $1
+RETURNING id, name, email, password, phone_number, brand_id, created_at, updated_at, phone_e164, tags, verified
+`
+
+type FillCustomerAccountParams struct {
+	ID        int64          `json:"id"`
+	Email     sql.NullString `json:"email"`
+	PhoneE164 sql.NullString `json:"phoneE164"`
+}
+
+func (q *Queries) FillCustomerAccount(ctx context.Context, arg FillCustomerAccountParams) (*Customer, error) {
+	row := q.db.QueryRowContext(ctx, fillCustomerAccount,
+		arg.ID,
+		arg.Email,
+		arg.PhoneE164,
+	)
+	var i Customer
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Email,
+		&i.Password,
+		&i.PhoneNumber,
+		&i.BrandID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.PhoneE164,
+		pq.Array(&i.Tags),
+		&i.Verified,
+	)
+	return &i, err
+}
+
 const getCustomerByEmail = `-- name: GetCustomerByEmail :one
-SELECT id, name, email, password, phone_number, brand_id, created_at, updated_at FROM customers WHERE email = $1
+SELECT id, name, email, password, phone_number, brand_id, created_at, updated_at, phone_e164, tags, verified FROM customers WHERE brand_id = $1 AND email = $2
 `
 
-func (q *Queries) GetCustomerByEmail(ctx context.Context, email sql.NullString) (*Customer, error) {
-	row := q.db.QueryRowContext(ctx, getCustomerByEmail, email)
+type GetCustomerByEmailParams struct {
+	BrandID int32          `json:"brandId"`
+	Email   sql.NullString `json:"email"`
+}
+
+func (q *Queries) GetCustomerByEmail(ctx context.Context, arg GetCustomerByEmailParams) (*Customer, error) {
+	row := q.db.QueryRowContext(ctx, getCustomerByEmail, arg.BrandID, arg.Email)
 	var i Customer
 	err := row.Scan(
 		&i.ID,
@@ -103,12 +200,15 @@ func (q *Queries) GetCustomerByEmail(ctx context.Context, email sql.NullString)
 		&i.BrandID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.PhoneE164,
+		pq.Array(&i.Tags),
+		&i.Verified,
 	)
 	return &i, err
 }
 
 const getCustomerById = `-- name: GetCustomerById :one
-SELECT id, name, email, password, phone_number, brand_id, created_at, updated_at FROM customers WHERE id = $1
+SELECT id, name, email, password, phone_number, brand_id, created_at, updated_at, phone_e164, tags, verified FROM customers WHERE id = $1
 `
 
 func (q *Queries) GetCustomerById(ctx context.Context, id int64) (*Customer, error) {
@@ -123,21 +223,29 @@ func (q *Queries) GetCustomerById(ctx context.Context, id int64) (*Customer, err
 		&i.BrandID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.PhoneE164,
+		pq.Array(&i.Tags),
+		&i.Verified,
 	)
 	return &i, err
 }
 
-const getCustomerByNameAndPhone = `-- name: GetCustomerByNameAndPhone :one
-SELECT id, name, email, password, phone_number, brand_id, created_at, updated_at FROM customers WHERE name = $1 AND phone_number = $2
+const getCustomerByPhone = `-- name: GetCustomerByPhone :one
+SELECT id, name, email, password, phone_number, brand_id, created_at, updated_at, phone_e164, tags, verified FROM customers WHERE brand_id = $1 AND (phone_number = $2 OR phone_e164 = $3)
+ORDER BY password IS NULL, id
+LIMIT 1
 `
 
-type GetCustomerByNameAndPhoneParams struct {
-	Name        string `json:"name"`
-	PhoneNumber string `json:"phoneNumber"`
+type GetCustomerByPhoneParams struct {
+	BrandID     int32          `json:"brandId"`
+	PhoneNumber string         `json:"phoneNumber"`
+	PhoneE164   sql.NullString `json:"phoneE164"`
 }
 
-func (q *Queries) GetCustomerByNameAndPhone(ctx context.Context, arg GetCustomerByNameAndPhoneParams) (*Customer, error) {
-	row := q.db.QueryRowContext(ctx, getCustomerByNameAndPhone, arg.Name, arg.PhoneNumber)
+// Numbers entered before they were normalized can match several customers,
+// accounts come first and then the oldest
+func (q *Queries) GetCustomerByPhone(ctx context.Context, arg GetCustomerByPhoneParams) (*Customer, error) {
+	row := q.db.QueryRowContext(ctx, getCustomerByPhone, arg.BrandID, arg.PhoneNumber, arg.PhoneE164)
 	var i Customer
 	err := row.Scan(
 		&i.ID,
@@ -148,12 +256,15 @@ func (q *Queries) GetCustomerByNameAndPhone(ctx context.Context, arg GetCustomer
 		&i.BrandID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.PhoneE164,
+		pq.Array(&i.Tags),
+		&i.Verified,
 	)
 	return &i, err
 }
 
 const getCustomersByBrand = `-- name: GetCustomersByBrand :many
-SELECT id, name, email, password, phone_number, brand_id, created_at, updated_at FROM customers WHERE brand_id = $1
+SELECT id, name, email, password, phone_number, brand_id, created_at, updated_at, phone_e164, tags, verified FROM customers WHERE brand_id = $1
 `
 
 func (q *Queries) GetCustomersByBrand(ctx context.Context, brandID int32) ([]*Customer, error) {
@@ -174,6 +285,258 @@ func (q *Queries) GetCustomersByBrand(ctx context.Context, brandID int32) ([]*Cu
 			&i.BrandID,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.PhoneE164,
+			pq.Array(&i.Tags),
+			&i.Verified,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listCustomers = `-- name: ListCustomers :many
+WITH visits AS (
+    SELECT
+        customer_id,
+        MAX(start_time) FILTER (WHERE start_time < NOW() AND NOT no_show)::timestamp AS last_visit,
+        COUNT(*) FILTER (WHERE no_show) AS no_show_count
+    FROM events
+    WHERE brand_id = $1
+    GROUP BY customer_id
+), listed AS (
+    SELECT
+        c.id, c.name, c.email, c.password, c.phone_number, c.brand_id, c.created_at, c.updated_at, c.phone_e164, c.tags, c.verified,
+        v.last_visit,
+        COALESCE(v.no_show_count, 0)::bigint AS no_show_count,
+        (CASE $2::text
+            WHEN 'createdAt' THEN to_char(c.created_at, 'YYYY-MM-DD"T"HH24:MI:SS')
+            WHEN 'lastVisit' THEN COALESCE(to_char(v.last_visit, 'YYYY-MM-DD"T"HH24:MI:SS'), '')
+            ELSE lower(c.name)
+        END)::text AS sort_key
+    FROM customers c
+    LEFT JOIN visits v ON v.customer_id = c.id
+    WHERE c.brand_id = $1
+    AND (
+        $3::text = ''
+        OR c.name ILIKE $3::text || '%'
+        OR c.email ILIKE $3::text || '%'
+        OR c.phone_number LIKE $3::text || '%'
+        OR ($4::text <> '' AND c.phone_e164 LIKE $4::text || '%')
+        OR c.name % $3::text
+    )
+    AND c.tags @> $5::text[]
+    AND NOT EXISTS (
+        SELECT 1
+        FROM unnest($6::bigint[], $7::text[]) AS f(field_id, value)
+        WHERE NOT EXISTS (
+            SELECT 1
+            FROM customer_field_values fv
+            WHERE fv.customer_id = c.id
+            AND fv.field_id = f.field_id
+            AND fv.value = f.value
+        )
+    )
+    AND ($8::text = '' OR EXISTS (
+        SELECT 1
+        FROM customer_notes n
+        WHERE n.customer_id = c.id
+        AND n.body ILIKE '%' || $8::text || '%'
+    ))
+    AND ($9::timestamp IS NULL OR v.last_visit < $9::timestamp)
+    AND ($10::timestamp IS NULL OR v.last_visit >= $10::timestamp)
+    AND COALESCE(v.no_show_count, 0) >= $11::bigint
+)
+SELECT id, name, email, password, phone_number, brand_id, created_at, updated_at, phone_e164, tags, verified, last_visit, no_show_count, sort_key FROM listed
+WHERE NOT $12::boolean
+OR ($13::boolean AND (sort_key, id) < ($14::text, $15::bigint))
+OR (NOT $13::boolean AND (sort_key, id) > ($14::text, $15::bigint))
+ORDER BY
+    CASE WHEN $13::boolean THEN sort_key END DESC,
+    CASE WHEN $13::boolean THEN id END DESC,
+    sort_key,
+    id
+LIMIT $16
+`
+
+type ListCustomersParams struct {
+	BrandID         int32        `json:"brandId"`
+	Sort            string       `json:"sort"`
+	Search          string       `json:"search"`
+	Phone           string       `json:"phone"`
+	Tags            []string     `json:"tags"`
+	FieldIds        []int64      `json:"fieldIds"`
+	FieldValues     []string     `json:"fieldValues"`
+	Note            string       `json:"note"`
+	LastVisitBefore sql.NullTime `json:"lastVisitBefore"`
+	LastVisitAfter  sql.NullTime `json:"lastVisitAfter"`
+	MinNoShows      int64        `json:"minNoShows"`
+	HasCursor       bool         `json:"hasCursor"`
+	Descending      bool         `json:"descending"`
+	CursorKey       string       `json:"cursorKey"`
+	CursorID        int64        `json:"cursorId"`
+	PageLimit       int32        `json:"pageLimit"`
+}
+
+type ListCustomersRow struct {
+	ID          int64          `json:"id"`
+	Name        string         `json:"name"`
+	Email       sql.NullString `json:"email"`
+	Password    []byte         `json:"password"`
+	PhoneNumber string         `json:"phoneNumber"`
+	BrandID     int32          `json:"brandId"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	UpdatedAt   time.Time      `json:"updatedAt"`
+	PhoneE164   sql.NullString `json:"phoneE164"`
+	Tags        []string       `json:"tags"`
+	Verified    bool           `json:"verified"`
+	LastVisit   sql.NullTime   `json:"lastVisit"`
+	NoShowCount int64          `json:"noShowCount"`
+	SortKey     string         `json:"sortKey"`
+}
+
+func (q *Queries) ListCustomers(ctx context.Context, arg ListCustomersParams) ([]*ListCustomersRow, error) {
+	rows, err := q.db.QueryContext(ctx, listCustomers,
+		arg.BrandID,
+		arg.Sort,
+		arg.Search,
+		arg.Phone,
+		pq.Array(arg.Tags),
+		pq.Array(arg.FieldIds),
+		pq.Array(arg.FieldValues),
+		arg.Note,
+		arg.LastVisitBefore,
+		arg.LastVisitAfter,
+		arg.MinNoShows,
+		arg.HasCursor,
+		arg.Descending,
+		arg.CursorKey,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*ListCustomersRow
+	for rows.Next() {
+		var i ListCustomersRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Email,
+			&i.Password,
+			&i.PhoneNumber,
+			&i.BrandID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.PhoneE164,
+			pq.Array(&i.Tags),
+			&i.Verified,
+			&i.LastVisit,
+			&i.NoShowCount,
+			&i.SortKey,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDuplicateCustomers = `-- name: ListDuplicateCustomers :many
+SELECT
+    a.id AS customer_id,
+    a.name AS customer_name,
+    a.email AS customer_email,
+    a.phone_number AS customer_phone_number,
+    b.id AS duplicate_id,
+    b.name AS duplicate_name,
+    b.email AS duplicate_email,
+    b.phone_number AS duplicate_phone_number,
+    COALESCE(a.phone_e164 = b.phone_e164, false)::boolean AS phone_match,
+    COALESCE(lower(a.email) = lower(b.email), false)::boolean AS email_match,
+    similarity(a.name, b.name)::real AS name_similarity
+FROM customers a
+JOIN customers b ON b.brand_id = a.brand_id AND b.id > a.id
+AND (
+    a.phone_e164 = b.phone_e164
+    OR lower(a.email) = lower(b.email)
+    OR a.name % b.name
+)
+WHERE a.brand_id = $1
+AND (
+    NOT $2::boolean
+    OR (a.id, b.id) > ($3::bigint, $4::bigint)
+)
+ORDER BY a.id, b.id
+LIMIT $5
+`
+
+type ListDuplicateCustomersParams struct {
+	BrandID           int32 `json:"brandId"`
+	HasCursor         bool  `json:"hasCursor"`
+	CursorCustomerID  int64 `json:"cursorCustomerId"`
+	CursorDuplicateID int64 `json:"cursorDuplicateId"`
+	PageLimit         int32 `json:"pageLimit"`
+}
+
+type ListDuplicateCustomersRow struct {
+	CustomerID           int64          `json:"customerId"`
+	CustomerName         string         `json:"customerName"`
+	CustomerEmail        sql.NullString `json:"customerEmail"`
+	CustomerPhoneNumber  string         `json:"customerPhoneNumber"`
+	DuplicateID          int64          `json:"duplicateId"`
+	DuplicateName        string         `json:"duplicateName"`
+	DuplicateEmail       sql.NullString `json:"duplicateEmail"`
+	DuplicatePhoneNumber string         `json:"duplicatePhoneNumber"`
+	PhoneMatch           bool           `json:"phoneMatch"`
+	EmailMatch           bool           `json:"emailMatch"`
+	NameSimilarity       float32        `json:"nameSimilarity"`
+}
+
+// Name matches use the trigram index through the % operator, whose threshold
+// is set by SetSimilarityThreshold in the same transaction
+func (q *Queries) ListDuplicateCustomers(ctx context.Context, arg ListDuplicateCustomersParams) ([]*ListDuplicateCustomersRow, error) {
+	rows, err := q.db.QueryContext(ctx, listDuplicateCustomers,
+		arg.BrandID,
+		arg.HasCursor,
+		arg.CursorCustomerID,
+		arg.CursorDuplicateID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*ListDuplicateCustomersRow
+	for rows.Next() {
+		var i ListDuplicateCustomersRow
+		if err := rows.Scan(
+			&i.CustomerID,
+			&i.CustomerName,
+			&i.CustomerEmail,
+			&i.CustomerPhoneNumber,
+			&i.DuplicateID,
+			&i.DuplicateName,
+			&i.DuplicateEmail,
+			&i.DuplicatePhoneNumber,
+			&i.PhoneMatch,
+			&i.EmailMatch,
+			&i.NameSimilarity,
 		); err != nil {
 			return nil, err
 		}
@@ -187,3 +550,74 @@ func (q *Queries) GetCustomersByBrand(ctx context.Context, brandID int32) ([]*Cu
 	}
 	return items, nil
 }
+
+const setSimilarityThreshold = `-- name: SetSimilarityThreshold :exec
+SELECT set_config('pg_trgm.similarity_threshold', $1::real::text, true)
+`
+
+func (q *Queries) SetSimilarityThreshold(ctx context.Context, threshold float32) error {
+	_, err := q.db.ExecContext(ctx, setSimilarityThreshold, threshold)
+	return err
+}
+
+const updateCustomerPassword = `-- name: UpdateCustomerPassword :exec
+UPDATE customers SET
+password = $2,
+updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdateCustomerPasswordParams struct {
+	ID       int64  `json:"id"`
+	Password []byte `json:"password"`
+}
+
+func (q *Queries) UpdateCustomerPassword(ctx context.Context, arg UpdateCustomerPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateCustomerPassword, arg.ID, arg.Password)
+	return err
+}
+
+const updateCustomerTags = `-- name: UpdateCustomerTags :one
+UPDATE customers
+SET tags = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING id, name, email, password, phone_number, brand_id, created_at, updated_at, phone_e164, tags, verified
+`
+
+type UpdateCustomerTagsParams struct {
+	ID       int64    `json:"id"`
+	Tags     []string `json:"tags"`
+	Verified bool     `json:"verified"`
+}
+
+func (q *Queries) UpdateCustomerTags(ctx context.Context, arg UpdateCustomerTagsParams) (*Customer, error) {
+	row := q.db.QueryRowContext(ctx, updateCustomerTags, arg.ID, pq.Array(arg.Tags))
+	var i Customer
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Email,
+		&i.Password,
+		&i.PhoneNumber,
+		&i.BrandID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.PhoneE164,
+		pq.Array(&i.Tags),
+		&i.Verified,
+	)
+	return &i, err
+}
+
+const verifyCustomer = `-- name: VerifyCustomer :exec
+UPDATE customers SET
+verified = TRUE,
+updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) VerifyCustomer(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, verifyCustomer, id)
+	return err
+}
@@ -2,12 +2,17 @@ package store
 
 import (
 	"context"
+	"crypto/subtle"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
 )
 
 type CreateGuestTxParams struct {
 	Name        string `json:"name"`
 	PhoneNumber string `json:"phoneNumber"`
+	PhoneE164   string `json:"phoneE164"`
 	Email       string `json:"email"`
 	BrandId     int32  `json:"brandId"`
 }
@@ -16,11 +21,24 @@ func (s *SQLStore) CreateGuestTx(ctx context.Context, arg CreateGuestTxParams) (
 	var result Customer
 	var exists bool
 	err := s.execTx(ctx, func(q Querier) error {
-
-		customer, err := q.GetCustomerByNameAndPhone(ctx, GetCustomerByNameAndPhoneParams{
-			Name:        arg.Name,
+		// Phone numbers identify a customer within a brand, emails are the fallback
+		customer, err := q.GetCustomerByPhone(ctx, GetCustomerByPhoneParams{
+			BrandID:     arg.BrandId,
 			PhoneNumber: arg.PhoneNumber,
+			PhoneE164: sql.NullString{
+				String: arg.PhoneE164,
+				Valid:  arg.PhoneE164 != "",
+			},
 		})
+		if err == sql.ErrNoRows && arg.Email != "" {
+			customer, err = q.GetCustomerByEmail(ctx, GetCustomerByEmailParams{
+				BrandID: arg.BrandId,
+				Email: sql.NullString{
+					String: arg.Email,
+					Valid:  true,
+				},
+			})
+		}
 		if err != nil && err != sql.ErrNoRows {
 			return err
 		}
@@ -39,6 +57,10 @@ func (s *SQLStore) CreateGuestTx(ctx context.Context, arg CreateGuestTxParams) (
 				Valid:  arg.Email != "",
 			},
 			BrandID: arg.BrandId,
+			PhoneE164: sql.NullString{
+				String: arg.PhoneE164,
+				Valid:  arg.PhoneE164 != "",
+			},
 		})
 		if err != nil {
 			return err
@@ -51,3 +73,548 @@ func (s *SQLStore) CreateGuestTx(ctx context.Context, arg CreateGuestTxParams) (
 
 	return &result, exists, err
 }
+
+type ListDuplicateCustomersTxParams struct {
+	ListDuplicateCustomersParams
+	// NameThreshold is the similarity from which names count as a match
+	NameThreshold float32
+}
+
+// ListDuplicateCustomersTx lists a page of possible duplicates with names as
+// similar as NameThreshold. The threshold only applies to this transaction.
+func (s *SQLStore) ListDuplicateCustomersTx(ctx context.Context, arg ListDuplicateCustomersTxParams) ([]*ListDuplicateCustomersRow, error) {
+	var rows []*ListDuplicateCustomersRow
+	err := s.execTx(ctx, func(q Querier) error {
+		if err := q.SetSimilarityThreshold(ctx, arg.NameThreshold); err != nil {
+			return err
+		}
+
+		var err error
+		rows, err = q.ListDuplicateCustomers(ctx, arg.ListDuplicateCustomersParams)
+		return err
+	})
+	return rows, err
+}
+
+type MergeCustomersTxParams struct {
+	// BrandID is the brand of the caller, the survivor has to belong to it
+	BrandID      int32
+	SurvivorID   int64
+	DuplicateIDs []int64
+	MergedBy     int64
+}
+
+type MergeCustomersTxResult struct {
+	Customer    *Customer
+	EventsMoved int64
+}
+
+var (
+	ErrMergeSelf             = errors.New("a customer cannot be merged into itself")
+	ErrMergeAcrossBrands     = errors.New("customers belong to different brands")
+	ErrMergeCustomerNotFound = errors.New("one or more customers were not found")
+)
+
+func (s *SQLStore) MergeCustomersTx(ctx context.Context, arg MergeCustomersTxParams) (*MergeCustomersTxResult, error) {
+	var result MergeCustomersTxResult
+
+	err := s.execTx(ctx, func(q Querier) error {
+		survivor, err := q.GetCustomerById(ctx, arg.SurvivorID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return ErrMergeCustomerNotFound
+			}
+			return err
+		}
+
+		if survivor.BrandID != arg.BrandID {
+			return ErrMergeCustomerNotFound
+		}
+
+		for _, duplicateID := range arg.DuplicateIDs {
+			if duplicateID == survivor.ID {
+				return ErrMergeSelf
+			}
+
+			duplicate, err := q.GetCustomerById(ctx, duplicateID)
+			if err != nil {
+				if err == sql.ErrNoRows {
+					return ErrMergeCustomerNotFound
+				}
+				return err
+			}
+
+			if duplicate.BrandID != survivor.BrandID {
+				return ErrMergeAcrossBrands
+			}
+
+			moved, err := q.ReassignCustomerEvents(ctx, ReassignCustomerEventsParams{
+				SurvivorID:   sql.NullInt64{Int64: survivor.ID, Valid: true},
+				CustomerName: survivor.Name,
+				CustomerID:   sql.NullInt64{Int64: duplicate.ID, Valid: true},
+			})
+			if err != nil {
+				return err
+			}
+
+			// Snapshot the merged record without its credentials for the audit trail
+			duplicate.Password = nil
+			snapshot, err := json.Marshal(duplicate)
+			if err != nil {
+				return err
+			}
+
+			_, err = q.CreateCustomerMerge(ctx, CreateCustomerMergeParams{
+				BrandID:          survivor.BrandID,
+				SurvivorID:       survivor.ID,
+				MergedCustomerID: duplicate.ID,
+				MergedCustomer:   snapshot,
+				EventsMoved:      int32(moved),
+				MergedBy: sql.NullInt64{
+					Int64: arg.MergedBy,
+					Valid: arg.MergedBy != 0,
+				},
+			})
+			if err != nil {
+				return err
+			}
+
+			if _, err := q.ReassignCustomerNotes(ctx, ReassignCustomerNotesParams{
+				SurvivorID: survivor.ID,
+				CustomerID: duplicate.ID,
+			}); err != nil {
+				return err
+			}
+
+			if _, err := q.ReassignCustomerDependents(ctx, ReassignCustomerDependentsParams{
+				SurvivorID: survivor.ID,
+				CustomerID: duplicate.ID,
+			}); err != nil {
+				return err
+			}
+
+			// Values already set on the survivor take precedence
+			if err := q.CopyCustomerFieldValues(ctx, CopyCustomerFieldValuesParams{
+				SurvivorID: survivor.ID,
+				CustomerID: duplicate.ID,
+			}); err != nil {
+				return err
+			}
+
+			if err := q.CopyCustomerConsents(ctx, CopyCustomerConsentsParams{
+				SurvivorID: survivor.ID,
+				CustomerID: duplicate.ID,
+			}); err != nil {
+				return err
+			}
+
+			if err := q.DeleteCustomer(ctx, duplicate.ID); err != nil {
+				return err
+			}
+
+			// The duplicate is gone, so its contact details can be taken over.
+			// Its password never is, so nobody signs in to the survivor with it.
+			survivor, err = q.FillCustomerAccount(ctx, FillCustomerAccountParams{
+				ID:        survivor.ID,
+				Email:     duplicate.Email,
+				PhoneE164: duplicate.PhoneE164,
+			})
+			if err != nil {
+				return err
+			}
+
+			survivor, err = q.UpdateCustomerTags(ctx, UpdateCustomerTagsParams{
+				ID:   survivor.ID,
+				Tags: mergeTags(survivor.Tags, duplicate.Tags),
+			})
+			if err != nil {
+				return err
+			}
+
+			result.EventsMoved += moved
+		}
+
+		result.Customer = survivor
+		return nil
+	})
+
+	return &result, err
+}
+
+func mergeTags(tags []string, other []string) []string {
+	seen := make(map[string]bool, len(tags))
+	result := make([]string, 0, len(tags)+len(other))
+	for _, list := range [][]string{tags, other} {
+		for _, tag := range list {
+			if seen[tag] {
+				continue
+			}
+			seen[tag] = true
+			result = append(result, tag)
+		}
+	}
+	return result
+}
+
+type SetCustomerFieldValuesTxParams struct {
+	CustomerID int64
+	// Values maps field IDs to their new value, an empty value clears the field
+	Values map[int64]string
+}
+
+func (s *SQLStore) SetCustomerFieldValuesTx(ctx context.Context, arg SetCustomerFieldValuesTxParams) ([]*ListCustomerFieldValuesRow, error) {
+	var result []*ListCustomerFieldValuesRow
+
+	err := s.execTx(ctx, func(q Querier) error {
+		for fieldID, value := range arg.Values {
+			if value == "" {
+				if err := q.DeleteCustomerFieldValue(ctx, DeleteCustomerFieldValueParams{
+					CustomerID: arg.CustomerID,
+					FieldID:    fieldID,
+				}); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if _, err := q.UpsertCustomerFieldValue(ctx, UpsertCustomerFieldValueParams{
+				CustomerID: arg.CustomerID,
+				FieldID:    fieldID,
+				Value:      value,
+			}); err != nil {
+				return err
+			}
+		}
+
+		values, err := q.ListCustomerFieldValues(ctx, arg.CustomerID)
+		if err != nil {
+			return err
+		}
+
+		result = values
+		return nil
+	})
+
+	return result, err
+}
+
+// ErasedCustomerName replaces the name of an erased customer on their events
+const ErasedCustomerName = "Deleted customer"
+
+type EraseCustomerTxParams struct {
+	CustomerID int64
+	// ErasedBy is the staff member who requested the erasure, it is empty when
+	// customers erase their own account
+	ErasedBy sql.NullInt64
+}
+
+// EraseCustomerTx deletes a customer together with their sessions, notes,
+// dependents and custom field values. Their events are kept for the revenue
+// figures of the brand, but lose the link to the customer and dependent, their
+// names and the comment. The history of the events goes, and webhook
+// deliveries keep only the ID of the customer.
+func (s *SQLStore) EraseCustomerTx(ctx context.Context, arg EraseCustomerTxParams) (*CustomerErasure, error) {
+	var result *CustomerErasure
+
+	err := s.execTx(ctx, func(q Querier) error {
+		customer, err := q.GetCustomerById(ctx, arg.CustomerID)
+		if err != nil {
+			return err
+		}
+
+		if err := q.DeleteCustomerEventHistory(ctx, sql.NullInt64{Int64: customer.ID, Valid: true}); err != nil {
+			return err
+		}
+
+		anonymized, err := q.AnonymizeCustomerEvents(ctx, AnonymizeCustomerEventsParams{
+			CustomerName: ErasedCustomerName,
+			CustomerID:   sql.NullInt64{Int64: customer.ID, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := q.DeleteCustomer(ctx, customer.ID); err != nil {
+			return err
+		}
+
+		// After the delete, which queues a delivery of the removed customer
+		if _, err := q.RedactCustomerWebhookDeliveries(ctx, RedactCustomerWebhookDeliveriesParams{
+			CustomerName: ErasedCustomerName,
+			CustomerID:   customer.ID,
+		}); err != nil {
+			return err
+		}
+
+		result, err = q.CreateCustomerErasure(ctx, CreateCustomerErasureParams{
+			BrandID:          customer.BrandID,
+			CustomerID:       customer.ID,
+			EventsAnonymized: int32(anonymized),
+			ErasedBy:         arg.ErasedBy,
+		})
+		return err
+	})
+
+	return result, err
+}
+
+type CustomerConsentChange struct {
+	Channel string
+	Purpose string
+	Granted bool
+}
+
+type UpdateCustomerConsentsTxParams struct {
+	CustomerID int64
+	BrandID    int32
+	Source     string
+	Changes    []CustomerConsentChange
+}
+
+// UpdateCustomerConsentsTx records the consent changes of a customer and
+// returns all consents recorded for them
+func (s *SQLStore) UpdateCustomerConsentsTx(ctx context.Context, arg UpdateCustomerConsentsTxParams) ([]*CustomerConsent, error) {
+	var result []*CustomerConsent
+
+	err := s.execTx(ctx, func(q Querier) error {
+		for _, change := range arg.Changes {
+			if _, err := q.UpsertCustomerConsent(ctx, UpsertCustomerConsentParams{
+				CustomerID: arg.CustomerID,
+				BrandID:    arg.BrandID,
+				Channel:    change.Channel,
+				Purpose:    change.Purpose,
+				Granted:    change.Granted,
+				Source:     arg.Source,
+			}); err != nil {
+				return err
+			}
+		}
+
+		consents, err := q.ListCustomerConsents(ctx, arg.CustomerID)
+		if err != nil {
+			return err
+		}
+
+		result = consents
+		return nil
+	})
+
+	return result, err
+}
+
+var ErrInvalidVerificationToken = errors.New("invalid or expired verification link")
+
+func (s *SQLStore) VerifyCustomerTx(ctx context.Context, token string) (int64, error) {
+	var customerID int64
+	err := s.execTx(ctx, func(q Querier) error {
+		id, err := q.ConsumeCustomerToken(ctx, ConsumeCustomerTokenParams{
+			Token: token,
+			Scope: TokenScopeVerification,
+		})
+		if err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrInvalidVerificationToken
+			default:
+				return err
+			}
+		}
+
+		if err := q.VerifyCustomer(ctx, id); err != nil {
+			return err
+		}
+
+		if err := q.DeleteCustomerTokens(ctx, DeleteCustomerTokensParams{
+			CustomerID: id,
+			Scope:      TokenScopeVerification,
+		}); err != nil {
+			return err
+		}
+
+		customerID = id
+		return nil
+	})
+
+	return customerID, err
+}
+
+type ResetCustomerPasswordTxParams struct {
+	Token    string
+	Password []byte
+}
+
+func (s *SQLStore) ResetCustomerPasswordTx(ctx context.Context, arg ResetCustomerPasswordTxParams) (int64, error) {
+	var customerID int64
+	err := s.execTx(ctx, func(q Querier) error {
+		id, err := q.ConsumeCustomerToken(ctx, ConsumeCustomerTokenParams{
+			Token: arg.Token,
+			Scope: TokenScopePasswordReset,
+		})
+		if err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrInvalidResetToken
+			default:
+				return err
+			}
+		}
+
+		if err := q.UpdateCustomerPassword(ctx, UpdateCustomerPasswordParams{
+			ID:       id,
+			Password: arg.Password,
+		}); err != nil {
+			return err
+		}
+
+		// The reset link was delivered to the email so it proves the customer owns it
+		if err := q.VerifyCustomer(ctx, id); err != nil {
+			return err
+		}
+
+		if err := q.DeleteCustomerTokens(ctx, DeleteCustomerTokensParams{
+			CustomerID: id,
+			Scope:      TokenScopePasswordReset,
+		}); err != nil {
+			return err
+		}
+
+		if err := q.ExpireCustomerSessions(ctx, id); err != nil {
+			return err
+		}
+
+		customerID = id
+		return nil
+	})
+
+	return customerID, err
+}
+
+type CreateCustomerSignInCodeTxParams struct {
+	CustomerID int64
+	BrandID    int32
+	Token      string
+	Code       string
+	Channel    string
+	Expiry     time.Time
+}
+
+// CreateCustomerSignInCodeTx stores a new passwordless sign in request. Older
+// requests of the customer stop working so only the latest email counts.
+func (s *SQLStore) CreateCustomerSignInCodeTx(ctx context.Context, arg CreateCustomerSignInCodeTxParams) (*CustomerSignInCode, error) {
+	var result *CustomerSignInCode
+	err := s.execTx(ctx, func(q Querier) error {
+		if err := q.InvalidateCustomerSignInCodes(ctx, arg.CustomerID); err != nil {
+			return err
+		}
+
+		code, err := q.CreateCustomerSignInCode(ctx, CreateCustomerSignInCodeParams(arg))
+		if err != nil {
+			return err
+		}
+
+		result = code
+		return nil
+	})
+
+	return result, err
+}
+
+var ErrInvalidSignInCode = errors.New("invalid or expired sign in code")
+
+type SignInCustomerWithCodeTxParams struct {
+	CustomerID int64
+	// Code is the hashed code entered by the customer
+	Code        string
+	MaxAttempts int32
+	// Session is opened on success, the customer is filled in by the transaction
+	Session CreateCustomerSessionParams
+}
+
+func (s *SQLStore) SignInCustomerWithCodeTx(ctx context.Context, arg SignInCustomerWithCodeTxParams) (*CustomerSession, error) {
+	var result *CustomerSession
+	wrongCode := false
+	err := s.execTx(ctx, func(q Querier) error {
+		request, err := q.GetActiveCustomerSignInCode(ctx, arg.CustomerID)
+		if err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrInvalidSignInCode
+			default:
+				return err
+			}
+		}
+
+		if request.Attempts >= arg.MaxAttempts {
+			return ErrInvalidSignInCode
+		}
+
+		if subtle.ConstantTimeCompare([]byte(request.Code), []byte(arg.Code)) != 1 {
+			// The failed attempt has to be committed so it is counted
+			wrongCode = true
+			return q.IncrementCustomerSignInCodeAttempts(ctx, request.ID)
+		}
+
+		if err := q.UseCustomerSignInCode(ctx, request.ID); err != nil {
+			return err
+		}
+
+		session, err := signInCustomer(ctx, q, arg.CustomerID, arg.Session)
+		if err != nil {
+			return err
+		}
+
+		result = session
+		return nil
+	})
+	if err == nil && wrongCode {
+		return nil, ErrInvalidSignInCode
+	}
+
+	return result, err
+}
+
+type SignInCustomerWithLinkTxParams struct {
+	// Token is the hashed magic link token
+	Token       string
+	MaxAttempts int32
+	Session     CreateCustomerSessionParams
+}
+
+func (s *SQLStore) SignInCustomerWithLinkTx(ctx context.Context, arg SignInCustomerWithLinkTxParams) (*CustomerSession, error) {
+	var result *CustomerSession
+	err := s.execTx(ctx, func(q Querier) error {
+		customerID, err := q.UseCustomerSignInToken(ctx, UseCustomerSignInTokenParams{
+			Token:       arg.Token,
+			MaxAttempts: arg.MaxAttempts,
+		})
+		if err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrInvalidSignInCode
+			default:
+				return err
+			}
+		}
+
+		session, err := signInCustomer(ctx, q, customerID, arg.Session)
+		if err != nil {
+			return err
+		}
+
+		result = session
+		return nil
+	})
+
+	return result, err
+}
+
+// signInCustomer opens a session for a customer who proved they own their
+// email. Guests become verified customers this way.
+func signInCustomer(ctx context.Context, q Querier, customerID int64, session CreateCustomerSessionParams) (*CustomerSession, error) {
+	if err := q.VerifyCustomer(ctx, customerID); err != nil {
+		return nil, err
+	}
+
+	session.CustomerID = customerID
+	return q.CreateCustomerSession(ctx, session)
+}
@@ -13,6 +13,31 @@ import (
 	"github.com/google/uuid"
 )
 
+const anonymizeCustomerEvents = `-- name: AnonymizeCustomerEvents :execrows
+UPDATE events
+SET
+  customer_id = NULL,
+  customer_name = $1,
+  comment = NULL,
+  dependent_id = NULL,
+  dependent_name = NULL,
+  updated_at = NOW()
+WHERE customer_id = $2
+`
+
+type AnonymizeCustomerEventsParams struct {
+	CustomerName string        `json:"customerName"`
+	CustomerID   sql.NullInt64 `json:"customerId"`
+}
+
+func (q *Queries) AnonymizeCustomerEvents(ctx context.Context, arg AnonymizeCustomerEventsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, anonymizeCustomerEvents, arg.CustomerName, arg.CustomerID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const checkSpecificTimeslotAvailability = `-- name: CheckSpecificTimeslotAvailability :one
 WITH service_info AS (
     SELECT s.duration, s.buffer_time
@@ -37,6 +62,13 @@ SELECT
                   OR (b.start_time < ($2 + (INTERVAL '1 minute' * si.buffer_time))
                       AND b.end_time > $3)
               )
+        )
+        AND NOT EXISTS (
+            SELECT 1
+            FROM external_busy_times x
+            WHERE x.user_id = $1
+              AND x.start_time < ($2 + (INTERVAL '1 minute' * COALESCE(si.buffer_time, 0)))
+              AND x.end_time > $3
         ),
         ($1 IS NULL)
     ) AS is_available
@@ -76,26 +108,30 @@ INSERT INTO events (
   user_name,
   cost,
   buffer_time,
+  dependent_id,
+  dependent_name,
   created_at,
   updated_at
 ) VALUES (
-  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, NOW(), NOW()
-) RETURNING id, customer_id, service_id, user_id, brand_id, start_time, end_time, customer_name, service_name, user_name, comment, buffer_time, cost, created_at, updated_at
+  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, NOW(), NOW()
+) RETURNING id, customer_id, service_id, user_id, brand_id, start_time, end_time, customer_name, service_name, user_name, comment, buffer_time, cost, created_at, updated_at, no_show, dependent_id, dependent_name, version
 `
 
 type CreateEventParams struct {
-	CustomerID   int64          `json:"customerId"`
-	ServiceID    uuid.UUID      `json:"serviceId"`
-	UserID       int64          `json:"userId"`
-	BrandID      int32          `json:"brandId"`
-	StartTime    time.Time      `json:"startTime"`
-	EndTime      time.Time      `json:"endTime"`
-	Comment      sql.NullString `json:"comment"`
-	CustomerName string         `json:"customerName"`
-	ServiceName  string         `json:"serviceName"`
-	UserName     string         `json:"userName"`
-	Cost         sql.NullString `json:"cost"`
-	BufferTime   sql.NullInt32  `json:"bufferTime"`
+	CustomerID    sql.NullInt64  `json:"customerId"`
+	ServiceID     uuid.UUID      `json:"serviceId"`
+	UserID        int64          `json:"userId"`
+	BrandID       int32          `json:"brandId"`
+	StartTime     time.Time      `json:"startTime"`
+	EndTime       time.Time      `json:"endTime"`
+	Comment       sql.NullString `json:"comment"`
+	CustomerName  string         `json:"customerName"`
+	ServiceName   string         `json:"serviceName"`
+	UserName      string         `json:"userName"`
+	Cost          sql.NullString `json:"cost"`
+	BufferTime    sql.NullInt32  `json:"bufferTime"`
+	DependentID   sql.NullInt64  `json:"dependentId"`
+	DependentName sql.NullString `json:"dependentName"`
 }
 
 func (q *Queries) CreateEvent(ctx context.Context, arg CreateEventParams) (*Event, error) {
@@ -112,6 +148,8 @@ func (q *Queries) CreateEvent(ctx context.Context, arg CreateEventParams) (*Even
 		arg.UserName,
 		arg.Cost,
 		arg.BufferTime,
+		arg.DependentID,
+		arg.DependentName,
 	)
 	var i Event
 	err := row.Scan(
@@ -130,10 +168,26 @@ func (q *Queries) CreateEvent(ctx context.Context, arg CreateEventParams) (*Even
 		&i.Cost,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.NoShow,
+		&i.DependentID,
+		&i.DependentName,
+		&i.Version,
 	)
 	return &i, err
 }
 
+const deleteCustomerEventHistory = `-- name: DeleteCustomerEventHistory :exec
+DELETE FROM event_history
+WHERE event_id IN (SELECT id FROM events WHERE customer_id = $1)
+`
+
+// Drops the history of the customer's events before an erasure, the old
+// versions still hold the customer and their comments
+func (q *Queries) DeleteCustomerEventHistory(ctx context.Context, customerID sql.NullInt64) error {
+	_, err := q.db.ExecContext(ctx, deleteCustomerEventHistory, customerID)
+	return err
+}
+
 const deleteEvent = `-- name: DeleteEvent :exec
 DELETE FROM events
 WHERE id = $1
@@ -144,8 +198,125 @@ func (q *Queries) DeleteEvent(ctx context.Context, id int64) error {
 	return err
 }
 
+const exportEvents = `-- name: ExportEvents :many
+SELECT
+    e.id, e.customer_id, e.service_id, e.user_id, e.brand_id, e.start_time, e.end_time, e.customer_name, e.service_name, e.user_name, e.comment, e.buffer_time, e.cost, e.created_at, e.updated_at, e.no_show, e.dependent_id, e.dependent_name, e.version,
+    c.email AS customer_email,
+    c.phone_number AS customer_phone
+FROM events e
+LEFT JOIN customers c ON c.id = e.customer_id
+WHERE e.brand_id = $1
+AND ($2::timestamp IS NULL OR e.start_time >= $2::timestamp)
+AND ($3::timestamp IS NULL OR e.start_time < $3::timestamp)
+ORDER BY e.start_time, e.id
+`
+
+type ExportEventsParams struct {
+	BrandID   int32        `json:"brandId"`
+	StartFrom sql.NullTime `json:"startFrom"`
+	StartTo   sql.NullTime `json:"startTo"`
+}
+
+type ExportEventsRow struct {
+	ID            int64          `json:"id"`
+	CustomerID    sql.NullInt64  `json:"customerId"`
+	ServiceID     uuid.UUID      `json:"serviceId"`
+	UserID        int64          `json:"userId"`
+	BrandID       int32          `json:"brandId"`
+	StartTime     time.Time      `json:"startTime"`
+	EndTime       time.Time      `json:"endTime"`
+	CustomerName  string         `json:"customerName"`
+	ServiceName   string         `json:"serviceName"`
+	UserName      string         `json:"userName"`
+	Comment       sql.NullString `json:"comment"`
+	BufferTime    sql.NullInt32  `json:"bufferTime"`
+	Cost          sql.NullString `json:"cost"`
+	CreatedAt     time.Time      `json:"createdAt"`
+	UpdatedAt     time.Time      `json:"updatedAt"`
+	NoShow        bool           `json:"noShow"`
+	DependentID   sql.NullInt64  `json:"dependentId"`
+	DependentName sql.NullString `json:"dependentName"`
+	Version       int32          `json:"version"`
+	CustomerEmail sql.NullString `json:"customerEmail"`
+	CustomerPhone sql.NullString `json:"customerPhone"`
+}
+
+func (q *Queries) ExportEvents(ctx context.Context, arg ExportEventsParams) ([]*ExportEventsRow, error) {
+	rows, err := q.db.QueryContext(ctx, exportEvents, arg.BrandID, arg.StartFrom, arg.StartTo)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*ExportEventsRow
+	for rows.Next() {
+		var i ExportEventsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CustomerID,
+			&i.ServiceID,
+			&i.UserID,
+			&i.BrandID,
+			&i.StartTime,
+			&i.EndTime,
+			&i.CustomerName,
+			&i.ServiceName,
+			&i.UserName,
+			&i.Comment,
+			&i.BufferTime,
+			&i.Cost,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.NoShow,
+			&i.DependentID,
+			&i.DependentName,
+			&i.Version,
+			&i.CustomerEmail,
+			&i.CustomerPhone,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getCustomerStats = `-- name: GetCustomerStats :one
+SELECT
+    COUNT(*) FILTER (WHERE start_time < NOW() AND NOT no_show) AS visit_count,
+    COALESCE(SUM(cost) FILTER (WHERE start_time < NOW() AND NOT no_show), 0)::text AS total_spend,
+    MAX(start_time) FILTER (WHERE start_time < NOW() AND NOT no_show)::timestamp AS last_visit,
+    MIN(start_time) FILTER (WHERE start_time >= NOW())::timestamp AS next_appointment
+FROM events
+WHERE customer_id = $1
+`
+
+type GetCustomerStatsRow struct {
+	VisitCount      int64        `json:"visitCount"`
+	TotalSpend      string       `json:"totalSpend"`
+	LastVisit       sql.NullTime `json:"lastVisit"`
+	NextAppointment sql.NullTime `json:"nextAppointment"`
+}
+
+func (q *Queries) GetCustomerStats(ctx context.Context, customerID sql.NullInt64) (*GetCustomerStatsRow, error) {
+	row := q.db.QueryRowContext(ctx, getCustomerStats, customerID)
+	var i GetCustomerStatsRow
+	err := row.Scan(
+		&i.VisitCount,
+		&i.TotalSpend,
+		&i.LastVisit,
+		&i.NextAppointment,
+	)
+	return &i, err
+}
+
 const getEventByID = `-- name: GetEventByID :one
-SELECT id, customer_id, service_id, user_id, brand_id, start_time, end_time, customer_name, service_name, user_name, comment, buffer_time, cost, created_at, updated_at FROM events b WHERE id = $1
+SELECT id, customer_id, service_id, user_id, brand_id, start_time, end_time, customer_name, service_name, user_name, comment, buffer_time, cost, created_at, updated_at, no_show, dependent_id, dependent_name, version FROM events b WHERE id = $1
 `
 
 func (q *Queries) GetEventByID(ctx context.Context, id int64) (*Event, error) {
@@ -167,12 +338,54 @@ func (q *Queries) GetEventByID(ctx context.Context, id int64) (*Event, error) {
 		&i.Cost,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.NoShow,
+		&i.DependentID,
+		&i.DependentName,
+		&i.Version,
+	)
+	return &i, err
+}
+
+const getEventByUserAndStart = `-- name: GetEventByUserAndStart :one
+SELECT id, customer_id, service_id, user_id, brand_id, start_time, end_time, customer_name, service_name, user_name, comment, buffer_time, cost, created_at, updated_at, no_show, dependent_id, dependent_name, version FROM events
+WHERE user_id = $1 AND start_time = $2
+LIMIT 1
+`
+
+type GetEventByUserAndStartParams struct {
+	UserID    int64     `json:"userId"`
+	StartTime time.Time `json:"startTime"`
+}
+
+func (q *Queries) GetEventByUserAndStart(ctx context.Context, arg GetEventByUserAndStartParams) (*Event, error) {
+	row := q.db.QueryRowContext(ctx, getEventByUserAndStart, arg.UserID, arg.StartTime)
+	var i Event
+	err := row.Scan(
+		&i.ID,
+		&i.CustomerID,
+		&i.ServiceID,
+		&i.UserID,
+		&i.BrandID,
+		&i.StartTime,
+		&i.EndTime,
+		&i.CustomerName,
+		&i.ServiceName,
+		&i.UserName,
+		&i.Comment,
+		&i.BufferTime,
+		&i.Cost,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.NoShow,
+		&i.DependentID,
+		&i.DependentName,
+		&i.Version,
 	)
 	return &i, err
 }
 
 const getEventsByDay = `-- name: GetEventsByDay :many
-SELECT id, customer_id, service_id, user_id, brand_id, start_time, end_time, customer_name, service_name, user_name, comment, buffer_time, cost, created_at, updated_at
+SELECT id, customer_id, service_id, user_id, brand_id, start_time, end_time, customer_name, service_name, user_name, comment, buffer_time, cost, created_at, updated_at, no_show, dependent_id, dependent_name, version
 FROM events
 WHERE DATE(start_time) = $1
 AND brand_id = $2
@@ -209,6 +422,10 @@ func (q *Queries) GetEventsByDay(ctx context.Context, arg GetEventsByDayParams)
 			&i.Cost,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.NoShow,
+			&i.DependentID,
+			&i.DependentName,
+			&i.Version,
 		); err != nil {
 			return nil, err
 		}
@@ -224,7 +441,7 @@ func (q *Queries) GetEventsByDay(ctx context.Context, arg GetEventsByDayParams)
 }
 
 const getEventsByWeek = `-- name: GetEventsByWeek :many
-SELECT id, customer_id, service_id, user_id, brand_id, start_time, end_time, customer_name, service_name, user_name, comment, buffer_time, cost, created_at, updated_at
+SELECT id, customer_id, service_id, user_id, brand_id, start_time, end_time, customer_name, service_name, user_name, comment, buffer_time, cost, created_at, updated_at, no_show, dependent_id, dependent_name, version
 FROM events
 WHERE DATE(start_time) BETWEEN $1 AND $2
 AND brand_id = $3
@@ -262,6 +479,10 @@ func (q *Queries) GetEventsByWeek(ctx context.Context, arg GetEventsByWeekParams
 			&i.Cost,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.NoShow,
+			&i.DependentID,
+			&i.DependentName,
+			&i.Version,
 		); err != nil {
 			return nil, err
 		}
@@ -276,8 +497,43 @@ func (q *Queries) GetEventsByWeek(ctx context.Context, arg GetEventsByWeekParams
 	return items, nil
 }
 
+const getNextCustomerEvent = `-- name: GetNextCustomerEvent :one
+SELECT id, customer_id, service_id, user_id, brand_id, start_time, end_time, customer_name, service_name, user_name, comment, buffer_time, cost, created_at, updated_at, no_show, dependent_id, dependent_name, version FROM events
+WHERE customer_id = $1
+AND start_time >= NOW()
+ORDER BY start_time
+LIMIT 1
+`
+
+func (q *Queries) GetNextCustomerEvent(ctx context.Context, customerID sql.NullInt64) (*Event, error) {
+	row := q.db.QueryRowContext(ctx, getNextCustomerEvent, customerID)
+	var i Event
+	err := row.Scan(
+		&i.ID,
+		&i.CustomerID,
+		&i.ServiceID,
+		&i.UserID,
+		&i.BrandID,
+		&i.StartTime,
+		&i.EndTime,
+		&i.CustomerName,
+		&i.ServiceName,
+		&i.UserName,
+		&i.Comment,
+		&i.BufferTime,
+		&i.Cost,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.NoShow,
+		&i.DependentID,
+		&i.DependentName,
+		&i.Version,
+	)
+	return &i, err
+}
+
 const getUserEventsByDay = `-- name: GetUserEventsByDay :many
-SELECT id, customer_id, service_id, user_id, brand_id, start_time, end_time, customer_name, service_name, user_name, comment, buffer_time, cost, created_at, updated_at
+SELECT id, customer_id, service_id, user_id, brand_id, start_time, end_time, customer_name, service_name, user_name, comment, buffer_time, cost, created_at, updated_at, no_show, dependent_id, dependent_name, version
 FROM events
 WHERE DATE(start_time) = $1
 AND brand_id = $2
@@ -316,6 +572,10 @@ func (q *Queries) GetUserEventsByDay(ctx context.Context, arg GetUserEventsByDay
 			&i.Cost,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.NoShow,
+			&i.DependentID,
+			&i.DependentName,
+			&i.Version,
 		); err != nil {
 			return nil, err
 		}
@@ -331,7 +591,7 @@ func (q *Queries) GetUserEventsByDay(ctx context.Context, arg GetUserEventsByDay
 }
 
 const getUserEventsByWeek = `-- name: GetUserEventsByWeek :many
-SELECT id, customer_id, service_id, user_id, brand_id, start_time, end_time, customer_name, service_name, user_name, comment, buffer_time, cost, created_at, updated_at
+SELECT id, customer_id, service_id, user_id, brand_id, start_time, end_time, customer_name, service_name, user_name, comment, buffer_time, cost, created_at, updated_at, no_show, dependent_id, dependent_name, version
 FROM events
 WHERE DATE(start_time) BETWEEN $1 AND $2
 AND brand_id = $3
@@ -376,6 +636,275 @@ func (q *Queries) GetUserEventsByWeek(ctx context.Context, arg GetUserEventsByWe
 			&i.Cost,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.NoShow,
+			&i.DependentID,
+			&i.DependentName,
+			&i.Version,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listCustomerEvents = `-- name: ListCustomerEvents :many
+SELECT id, customer_id, service_id, user_id, brand_id, start_time, end_time, customer_name, service_name, user_name, comment, buffer_time, cost, created_at, updated_at, no_show, dependent_id, dependent_name, version FROM events
+WHERE customer_id = $1
+ORDER BY start_time
+`
+
+func (q *Queries) ListCustomerEvents(ctx context.Context, customerID sql.NullInt64) ([]*Event, error) {
+	rows, err := q.db.QueryContext(ctx, listCustomerEvents, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*Event
+	for rows.Next() {
+		var i Event
+		if err := rows.Scan(
+			&i.ID,
+			&i.CustomerID,
+			&i.ServiceID,
+			&i.UserID,
+			&i.BrandID,
+			&i.StartTime,
+			&i.EndTime,
+			&i.CustomerName,
+			&i.ServiceName,
+			&i.UserName,
+			&i.Comment,
+			&i.BufferTime,
+			&i.Cost,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.NoShow,
+			&i.DependentID,
+			&i.DependentName,
+			&i.Version,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listCustomerVisits = `-- name: ListCustomerVisits :many
+SELECT id, customer_id, service_id, user_id, brand_id, start_time, end_time, customer_name, service_name, user_name, comment, buffer_time, cost, created_at, updated_at, no_show, dependent_id, dependent_name, version FROM events
+WHERE customer_id = $1
+AND start_time < NOW()
+ORDER BY start_time DESC
+LIMIT $2
+`
+
+type ListCustomerVisitsParams struct {
+	CustomerID sql.NullInt64 `json:"customerId"`
+	Limit      int32         `json:"limit"`
+}
+
+func (q *Queries) ListCustomerVisits(ctx context.Context, arg ListCustomerVisitsParams) ([]*Event, error) {
+	rows, err := q.db.QueryContext(ctx, listCustomerVisits, arg.CustomerID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*Event
+	for rows.Next() {
+		var i Event
+		if err := rows.Scan(
+			&i.ID,
+			&i.CustomerID,
+			&i.ServiceID,
+			&i.UserID,
+			&i.BrandID,
+			&i.StartTime,
+			&i.EndTime,
+			&i.CustomerName,
+			&i.ServiceName,
+			&i.UserName,
+			&i.Comment,
+			&i.BufferTime,
+			&i.Cost,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.NoShow,
+			&i.DependentID,
+			&i.DependentName,
+			&i.Version,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listEventHistory = `-- name: ListEventHistory :many
+SELECT id, event_id, brand_id, version, action, actor_type, actor_id, actor_name, changes, created_at FROM event_history
+WHERE event_id = $1
+ORDER BY version
+`
+
+func (q *Queries) ListEventHistory(ctx context.Context, eventID int64) ([]*EventHistory, error) {
+	rows, err := q.db.QueryContext(ctx, listEventHistory, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*EventHistory
+	for rows.Next() {
+		var i EventHistory
+		if err := rows.Scan(
+			&i.ID,
+			&i.EventID,
+			&i.BrandID,
+			&i.Version,
+			&i.Action,
+			&i.ActorType,
+			&i.ActorID,
+			&i.ActorName,
+			&i.Changes,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listEvents = `-- name: ListEvents :many
+WITH listed AS (
+    SELECT
+        e.id, e.customer_id, e.service_id, e.user_id, e.brand_id, e.start_time, e.end_time, e.customer_name, e.service_name, e.user_name, e.comment, e.buffer_time, e.cost, e.created_at, e.updated_at, e.no_show, e.dependent_id, e.dependent_name, e.version,
+        (CASE $1::text
+            WHEN 'createdAt' THEN to_char(e.created_at, 'YYYY-MM-DD"T"HH24:MI:SS')
+            ELSE to_char(e.start_time, 'YYYY-MM-DD"T"HH24:MI:SS')
+        END)::text AS sort_key
+    FROM events e
+    WHERE e.brand_id = $2
+    AND ($3::timestamp IS NULL OR e.start_time >= $3::timestamp)
+    AND ($4::timestamp IS NULL OR e.start_time < $4::timestamp)
+    AND ($5::bigint = 0 OR e.user_id = $5::bigint)
+    AND ($6::bigint = 0 OR e.customer_id = $6::bigint)
+)
+SELECT id, customer_id, service_id, user_id, brand_id, start_time, end_time, customer_name, service_name, user_name, comment, buffer_time, cost, created_at, updated_at, no_show, dependent_id, dependent_name, version, sort_key FROM listed
+WHERE NOT $7::boolean
+OR ($8::boolean AND (sort_key, id) < ($9::text, $10::bigint))
+OR (NOT $8::boolean AND (sort_key, id) > ($9::text, $10::bigint))
+ORDER BY
+    CASE WHEN $8::boolean THEN sort_key END DESC,
+    CASE WHEN $8::boolean THEN id END DESC,
+    sort_key,
+    id
+LIMIT $11
+`
+
+type ListEventsParams struct {
+	Sort       string       `json:"sort"`
+	BrandID    int32        `json:"brandId"`
+	StartFrom  sql.NullTime `json:"startFrom"`
+	StartTo    sql.NullTime `json:"startTo"`
+	UserID     int64        `json:"userId"`
+	CustomerID int64        `json:"customerId"`
+	HasCursor  bool         `json:"hasCursor"`
+	Descending bool         `json:"descending"`
+	CursorKey  string       `json:"cursorKey"`
+	CursorID   int64        `json:"cursorId"`
+	PageLimit  int32        `json:"pageLimit"`
+}
+
+type ListEventsRow struct {
+	ID            int64          `json:"id"`
+	CustomerID    sql.NullInt64  `json:"customerId"`
+	ServiceID     uuid.UUID      `json:"serviceId"`
+	UserID        int64          `json:"userId"`
+	BrandID       int32          `json:"brandId"`
+	StartTime     time.Time      `json:"startTime"`
+	EndTime       time.Time      `json:"endTime"`
+	CustomerName  string         `json:"customerName"`
+	ServiceName   string         `json:"serviceName"`
+	UserName      string         `json:"userName"`
+	Comment       sql.NullString `json:"comment"`
+	BufferTime    sql.NullInt32  `json:"bufferTime"`
+	Cost          sql.NullString `json:"cost"`
+	CreatedAt     time.Time      `json:"createdAt"`
+	UpdatedAt     time.Time      `json:"updatedAt"`
+	NoShow        bool           `json:"noShow"`
+	DependentID   sql.NullInt64  `json:"dependentId"`
+	DependentName sql.NullString `json:"dependentName"`
+	Version       int32          `json:"version"`
+	SortKey       string         `json:"sortKey"`
+}
+
+func (q *Queries) ListEvents(ctx context.Context, arg ListEventsParams) ([]*ListEventsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listEvents,
+		arg.Sort,
+		arg.BrandID,
+		arg.StartFrom,
+		arg.StartTo,
+		arg.UserID,
+		arg.CustomerID,
+		arg.HasCursor,
+		arg.Descending,
+		arg.CursorKey,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*ListEventsRow
+	for rows.Next() {
+		var i ListEventsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CustomerID,
+			&i.ServiceID,
+			&i.UserID,
+			&i.BrandID,
+			&i.StartTime,
+			&i.EndTime,
+			&i.CustomerName,
+			&i.ServiceName,
+			&i.UserName,
+			&i.Comment,
+			&i.BufferTime,
+			&i.Cost,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.NoShow,
+			&i.DependentID,
+			&i.DependentName,
+			&i.Version,
+			&i.SortKey,
 		); err != nil {
 			return nil, err
 		}
@@ -391,7 +920,7 @@ func (q *Queries) GetUserEventsByWeek(ctx context.Context, arg GetUserEventsByWe
 }
 
 const listEventsByBrand = `-- name: ListEventsByBrand :many
-SELECT id, customer_id, service_id, user_id, brand_id, start_time, end_time, customer_name, service_name, user_name, comment, buffer_time, cost, created_at, updated_at FROM events
+SELECT id, customer_id, service_id, user_id, brand_id, start_time, end_time, customer_name, service_name, user_name, comment, buffer_time, cost, created_at, updated_at, no_show, dependent_id, dependent_name, version FROM events
 WHERE brand_id = $1
 ORDER BY start_time
 LIMIT $2
@@ -429,6 +958,10 @@ func (q *Queries) ListEventsByBrand(ctx context.Context, arg ListEventsByBrandPa
 			&i.Cost,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.NoShow,
+			&i.DependentID,
+			&i.DependentName,
+			&i.Version,
 		); err != nil {
 			return nil, err
 		}
@@ -444,7 +977,7 @@ func (q *Queries) ListEventsByBrand(ctx context.Context, arg ListEventsByBrandPa
 }
 
 const listEventsByCustomer = `-- name: ListEventsByCustomer :many
-SELECT id, customer_id, service_id, user_id, brand_id, start_time, end_time, customer_name, service_name, user_name, comment, buffer_time, cost, created_at, updated_at FROM events
+SELECT id, customer_id, service_id, user_id, brand_id, start_time, end_time, customer_name, service_name, user_name, comment, buffer_time, cost, created_at, updated_at, no_show, dependent_id, dependent_name, version FROM events
 WHERE customer_id = $1
 ORDER BY start_time
 LIMIT $2
@@ -452,9 +985,9 @@ OFFSET $3
 `
 
 type ListEventsByCustomerParams struct {
-	CustomerID int64 `json:"customerId"`
-	Limit      int32 `json:"limit"`
-	Offset     int32 `json:"offset"`
+	CustomerID sql.NullInt64 `json:"customerId"`
+	Limit      int32         `json:"limit"`
+	Offset     int32         `json:"offset"`
 }
 
 func (q *Queries) ListEventsByCustomer(ctx context.Context, arg ListEventsByCustomerParams) ([]*Event, error) {
@@ -482,6 +1015,10 @@ func (q *Queries) ListEventsByCustomer(ctx context.Context, arg ListEventsByCust
 			&i.Cost,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.NoShow,
+			&i.DependentID,
+			&i.DependentName,
+			&i.Version,
 		); err != nil {
 			return nil, err
 		}
@@ -497,7 +1034,7 @@ func (q *Queries) ListEventsByCustomer(ctx context.Context, arg ListEventsByCust
 }
 
 const listEventsByUser = `-- name: ListEventsByUser :many
-SELECT id, customer_id, service_id, user_id, brand_id, start_time, end_time, customer_name, service_name, user_name, comment, buffer_time, cost, created_at, updated_at FROM events
+SELECT id, customer_id, service_id, user_id, brand_id, start_time, end_time, customer_name, service_name, user_name, comment, buffer_time, cost, created_at, updated_at, no_show, dependent_id, dependent_name, version FROM events
 WHERE user_id = $1
 ORDER BY start_time
 LIMIT $2
@@ -535,6 +1072,10 @@ func (q *Queries) ListEventsByUser(ctx context.Context, arg ListEventsByUserPara
 			&i.Cost,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.NoShow,
+			&i.DependentID,
+			&i.DependentName,
+			&i.Version,
 		); err != nil {
 			return nil, err
 		}
@@ -549,6 +1090,69 @@ func (q *Queries) ListEventsByUser(ctx context.Context, arg ListEventsByUserPara
 	return items, nil
 }
 
+const reassignCustomerEvents = `-- name: ReassignCustomerEvents :execrows
+UPDATE events
+SET
+  customer_id = $1,
+  customer_name = $2,
+  updated_at = NOW()
+WHERE customer_id = $3
+`
+
+type ReassignCustomerEventsParams struct {
+	SurvivorID   sql.NullInt64 `json:"survivorId"`
+	CustomerName string        `json:"customerName"`
+	CustomerID   sql.NullInt64 `json:"customerId"`
+}
+
+func (q *Queries) ReassignCustomerEvents(ctx context.Context, arg ReassignCustomerEventsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, reassignCustomerEvents, arg.SurvivorID, arg.CustomerName, arg.CustomerID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setEventNoShow = `-- name: SetEventNoShow :one
+UPDATE events
+SET no_show = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING id, customer_id, service_id, user_id, brand_id, start_time, end_time, customer_name, service_name, user_name, comment, buffer_time, cost, created_at, updated_at, no_show, dependent_id, dependent_name, version
+`
+
+type SetEventNoShowParams struct {
+	ID     int64 `json:"id"`
+	NoShow bool  `json:"noShow"`
+}
+
+func (q *Queries) SetEventNoShow(ctx context.Context, arg SetEventNoShowParams) (*Event, error) {
+	row := q.db.QueryRowContext(ctx, setEventNoShow, arg.ID, arg.NoShow)
+	var i Event
+	err := row.Scan(
+		&i.ID,
+		&i.CustomerID,
+		&i.ServiceID,
+		&i.UserID,
+		&i.BrandID,
+		&i.StartTime,
+		&i.EndTime,
+		&i.CustomerName,
+		&i.ServiceName,
+		&i.UserName,
+		&i.Comment,
+		&i.BufferTime,
+		&i.Cost,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.NoShow,
+		&i.DependentID,
+		&i.DependentName,
+		&i.Version,
+	)
+	return &i, err
+}
+
 const updateEvent = `-- name: UpdateEvent :one
 UPDATE events
 SET
@@ -564,25 +1168,30 @@ SET
   user_name = $11,
   cost = $12,
   buffer_time = $13,
+  dependent_id = $14,
+  dependent_name = $15,
   updated_at = NOW()
-WHERE id = $1
-RETURNING id, customer_id, service_id, user_id, brand_id, start_time, end_time, customer_name, service_name, user_name, comment, buffer_time, cost, created_at, updated_at
+WHERE id = $1 AND version = COALESCE($16::INTEGER, version)
+RETURNING id, customer_id, service_id, user_id, brand_id, start_time, end_time, customer_name, service_name, user_name, comment, buffer_time, cost, created_at, updated_at, no_show, dependent_id, dependent_name, version
 `
 
 type UpdateEventParams struct {
-	ID           int64          `json:"id"`
-	CustomerID   int64          `json:"customerId"`
-	ServiceID    uuid.UUID      `json:"serviceId"`
-	UserID       int64          `json:"userId"`
-	BrandID      int32          `json:"brandId"`
-	StartTime    time.Time      `json:"startTime"`
-	EndTime      time.Time      `json:"endTime"`
-	Comment      sql.NullString `json:"comment"`
-	CustomerName string         `json:"customerName"`
-	ServiceName  string         `json:"serviceName"`
-	UserName     string         `json:"userName"`
-	Cost         sql.NullString `json:"cost"`
-	BufferTime   sql.NullInt32  `json:"bufferTime"`
+	ID            int64          `json:"id"`
+	CustomerID    sql.NullInt64  `json:"customerId"`
+	ServiceID     uuid.UUID      `json:"serviceId"`
+	UserID        int64          `json:"userId"`
+	BrandID       int32          `json:"brandId"`
+	StartTime     time.Time      `json:"startTime"`
+	EndTime       time.Time      `json:"endTime"`
+	Comment       sql.NullString `json:"comment"`
+	CustomerName  string         `json:"customerName"`
+	ServiceName   string         `json:"serviceName"`
+	UserName      string         `json:"userName"`
+	Cost          sql.NullString `json:"cost"`
+	BufferTime    sql.NullInt32  `json:"bufferTime"`
+	DependentID   sql.NullInt64  `json:"dependentId"`
+	DependentName sql.NullString `json:"dependentName"`
+	Version       sql.NullInt32  `json:"version"`
 }
 
 func (q *Queries) UpdateEvent(ctx context.Context, arg UpdateEventParams) (*Event, error) {
@@ -600,6 +1209,9 @@ func (q *Queries) UpdateEvent(ctx context.Context, arg UpdateEventParams) (*Even
 		arg.UserName,
 		arg.Cost,
 		arg.BufferTime,
+		arg.DependentID,
+		arg.DependentName,
+		arg.Version,
 	)
 	var i Event
 	err := row.Scan(
@@ -618,6 +1230,10 @@ func (q *Queries) UpdateEvent(ctx context.Context, arg UpdateEventParams) (*Even
 		&i.Cost,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.NoShow,
+		&i.DependentID,
+		&i.DependentName,
+		&i.Version,
 	)
 	return &i, err
 }
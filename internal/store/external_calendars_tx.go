@@ -0,0 +1,52 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+type SyncExternalCalendarTxParams struct {
+	Calendar *ExternalCalendar
+	// StartTimes and EndTimes are the busy periods, in UTC
+	StartTimes []time.Time
+	EndTimes   []time.Time
+	// LastError reports the events that were skipped
+	LastError  string
+	NextSyncAt time.Time
+}
+
+// SyncExternalCalendarTx replaces the busy times of a calendar with the ones
+// of its latest version and records the sync
+func (s *SQLStore) SyncExternalCalendarTx(ctx context.Context, arg SyncExternalCalendarTxParams) (*ExternalCalendar, error) {
+	var calendar *ExternalCalendar
+
+	err := s.execTx(ctx, func(q Querier) error {
+		if err := q.DeleteExternalBusyTimes(ctx, arg.Calendar.ID); err != nil {
+			return err
+		}
+
+		if len(arg.StartTimes) > 0 {
+			err := q.CreateExternalBusyTimes(ctx, CreateExternalBusyTimesParams{
+				CalendarID: arg.Calendar.ID,
+				BrandID:    arg.Calendar.BrandID,
+				UserID:     arg.Calendar.UserID,
+				StartTimes: arg.StartTimes,
+				EndTimes:   arg.EndTimes,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		var err error
+		calendar, err = q.RecordExternalCalendarSync(ctx, RecordExternalCalendarSyncParams{
+			ID:         arg.Calendar.ID,
+			Synced:     true,
+			LastError:  arg.LastError,
+			NextSyncAt: arg.NextSyncAt,
+		})
+		return err
+	})
+
+	return calendar, err
+}
@@ -0,0 +1,264 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ImportStatusCreated   = "created"
+	ImportStatusValid     = "valid"
+	ImportStatusDuplicate = "duplicate"
+	ImportStatusInvalid   = "invalid"
+)
+
+// ImportRowResult is the outcome of a single imported row. Rows are numbered
+// as in the source file, starting at 1 for the first data row.
+type ImportRowResult struct {
+	Row      int      `json:"row"`
+	Status   string   `json:"status"`
+	EntityID int64    `json:"entityId,omitempty"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+type ImportCustomerRow struct {
+	Row         int
+	Name        string
+	Email       string
+	PhoneNumber string
+	PhoneE164   string
+	Tags        []string
+}
+
+type ImportCustomersTxParams struct {
+	BrandID int32
+	DryRun  bool
+	Rows    []ImportCustomerRow
+}
+
+// ImportCustomersTx creates the customers of a batch in a single transaction.
+// Rows matching an existing customer of the brand by phone number or email are
+// reported as duplicates. In dry-run mode nothing is written.
+func (s *SQLStore) ImportCustomersTx(ctx context.Context, arg ImportCustomersTxParams) ([]ImportRowResult, error) {
+	var results []ImportRowResult
+
+	err := s.execTx(ctx, func(q Querier) error {
+		for _, row := range arg.Rows {
+			existing, err := findImportedCustomer(ctx, q, arg.BrandID, row.PhoneNumber, row.PhoneE164, row.Email)
+			if err != nil {
+				return err
+			}
+
+			if existing != nil {
+				results = append(results, ImportRowResult{
+					Row:      row.Row,
+					Status:   ImportStatusDuplicate,
+					EntityID: existing.ID,
+				})
+				continue
+			}
+
+			if arg.DryRun {
+				results = append(results, ImportRowResult{Row: row.Row, Status: ImportStatusValid})
+				continue
+			}
+
+			customer, err := q.CreateGuestCustomer(ctx, CreateGuestCustomerParams{
+				Name: row.Name,
+				Email: sql.NullString{
+					String: row.Email,
+					Valid:  row.Email != "",
+				},
+				PhoneNumber: row.PhoneNumber,
+				BrandID:     arg.BrandID,
+				PhoneE164: sql.NullString{
+					String: row.PhoneE164,
+					Valid:  row.PhoneE164 != "",
+				},
+			})
+			if err != nil {
+				return err
+			}
+
+			if len(row.Tags) > 0 {
+				if _, err := q.UpdateCustomerTags(ctx, UpdateCustomerTagsParams{
+					ID:   customer.ID,
+					Tags: row.Tags,
+				}); err != nil {
+					return err
+				}
+			}
+
+			results = append(results, ImportRowResult{
+				Row:      row.Row,
+				Status:   ImportStatusCreated,
+				EntityID: customer.ID,
+			})
+		}
+		return nil
+	})
+
+	return results, err
+}
+
+type ImportEventRow struct {
+	Row           int
+	CustomerName  string
+	CustomerEmail string
+	CustomerPhone string
+	CustomerE164  string
+	ServiceID     uuid.UUID
+	ServiceName   string
+	Cost          sql.NullString
+	BufferTime    sql.NullInt32
+	UserID        int64
+	UserName      string
+	StartTime     time.Time
+	EndTime       time.Time
+	Comment       string
+}
+
+type ImportEventsTxParams struct {
+	BrandID int32
+	DryRun  bool
+	Rows    []ImportEventRow
+}
+
+// ImportEventsTx creates the events of a batch in a single transaction. The
+// customer of every event is matched by phone number or email and created when
+// missing. An event of the same staff member starting at the same time is
+// reported as a duplicate, an overlapping one makes the row invalid.
+func (s *SQLStore) ImportEventsTx(ctx context.Context, arg ImportEventsTxParams) ([]ImportRowResult, error) {
+	var results []ImportRowResult
+
+	err := s.execTx(ctx, func(q Querier) error {
+		for _, row := range arg.Rows {
+			existing, err := q.GetEventByUserAndStart(ctx, GetEventByUserAndStartParams{
+				UserID:    row.UserID,
+				StartTime: row.StartTime,
+			})
+			if err != nil && err != sql.ErrNoRows {
+				return err
+			}
+
+			if err == nil {
+				results = append(results, ImportRowResult{
+					Row:      row.Row,
+					Status:   ImportStatusDuplicate,
+					EntityID: existing.ID,
+				})
+				continue
+			}
+
+			available, err := q.CheckSpecificTimeslotAvailability(ctx, CheckSpecificTimeslotAvailabilityParams{
+				UserID:    row.UserID,
+				EndTime:   row.EndTime,
+				StartTime: row.StartTime,
+				ServiceID: row.ServiceID,
+			})
+			if err != nil && err != sql.ErrNoRows {
+				return err
+			}
+
+			if available != true {
+				results = append(results, ImportRowResult{
+					Row:    row.Row,
+					Status: ImportStatusInvalid,
+					Errors: []string{"the staff member does not provide the service or is already booked at this time"},
+				})
+				continue
+			}
+
+			if arg.DryRun {
+				results = append(results, ImportRowResult{Row: row.Row, Status: ImportStatusValid})
+				continue
+			}
+
+			customer, err := findImportedCustomer(ctx, q, arg.BrandID, row.CustomerPhone, row.CustomerE164, row.CustomerEmail)
+			if err != nil {
+				return err
+			}
+
+			if customer == nil {
+				customer, err = q.CreateGuestCustomer(ctx, CreateGuestCustomerParams{
+					Name: row.CustomerName,
+					Email: sql.NullString{
+						String: row.CustomerEmail,
+						Valid:  row.CustomerEmail != "",
+					},
+					PhoneNumber: row.CustomerPhone,
+					BrandID:     arg.BrandID,
+					PhoneE164: sql.NullString{
+						String: row.CustomerE164,
+						Valid:  row.CustomerE164 != "",
+					},
+				})
+				if err != nil {
+					return err
+				}
+			}
+
+			event, err := q.CreateEvent(ctx, CreateEventParams{
+				CustomerID: sql.NullInt64{Int64: customer.ID, Valid: true},
+				ServiceID:  row.ServiceID,
+				UserID:     row.UserID,
+				BrandID:    arg.BrandID,
+				StartTime:  row.StartTime,
+				EndTime:    row.EndTime,
+				Comment: sql.NullString{
+					String: row.Comment,
+					Valid:  row.Comment != "",
+				},
+				CustomerName: customer.Name,
+				ServiceName:  row.ServiceName,
+				UserName:     row.UserName,
+				Cost:         row.Cost,
+				BufferTime:   row.BufferTime,
+			})
+			if err != nil {
+				return err
+			}
+
+			results = append(results, ImportRowResult{
+				Row:      row.Row,
+				Status:   ImportStatusCreated,
+				EntityID: event.ID,
+			})
+		}
+		return nil
+	})
+
+	return results, err
+}
+
+// findImportedCustomer looks up a customer of the brand by phone number and
+// then by email. It returns nil when there is no match.
+func findImportedCustomer(ctx context.Context, q Querier, brandID int32, phone, phoneE164, email string) (*Customer, error) {
+	customer, err := q.GetCustomerByPhone(ctx, GetCustomerByPhoneParams{
+		BrandID:     brandID,
+		PhoneNumber: phone,
+		PhoneE164: sql.NullString{
+			String: phoneE164,
+			Valid:  phoneE164 != "",
+		},
+	})
+	if err == sql.ErrNoRows && email != "" {
+		customer, err = q.GetCustomerByEmail(ctx, GetCustomerByEmailParams{
+			BrandID: brandID,
+			Email: sql.NullString{
+				String: email,
+				Valid:  true,
+			},
+		})
+	}
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return customer, nil
+}
@@ -6,28 +6,73 @@ package store
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type ApiKey struct {
+	ID         int64          `json:"id"`
+	BrandID    int32          `json:"brandId"`
+	CreatedBy  int64          `json:"createdBy"`
+	Name       string         `json:"name"`
+	Prefix     string         `json:"prefix"`
+	KeyHash    string         `json:"keyHash"`
+	Scopes     []string       `json:"scopes"`
+	ExpiresAt  sql.NullTime   `json:"expiresAt"`
+	LastUsedAt sql.NullTime   `json:"lastUsedAt"`
+	LastUsedIp sql.NullString `json:"lastUsedIp"`
+	RevokedAt  sql.NullTime   `json:"revokedAt"`
+	CreatedAt  time.Time      `json:"createdAt"`
+}
+
+type AuditLog struct {
+	ID         int64           `json:"id"`
+	BrandID    sql.NullInt32   `json:"brandId"`
+	ActorType  string          `json:"actorType"`
+	ActorID    sql.NullInt64   `json:"actorId"`
+	ActorName  string          `json:"actorName"`
+	ApiKeyID   sql.NullInt64   `json:"apiKeyId"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entityType"`
+	EntityID   string          `json:"entityId"`
+	Changes    json.RawMessage `json:"changes"`
+	RequestID  string          `json:"requestId"`
+	IpAddress  string          `json:"ipAddress"`
+	CreatedAt  time.Time       `json:"createdAt"`
+}
+
 type Brand struct {
-	ID          int32          `json:"id"`
-	Name        string         `json:"name"`
-	PageUrl     string         `json:"pageUrl"`
-	Description sql.NullString `json:"description"`
-	Email       sql.NullString `json:"email"`
-	Phone       sql.NullString `json:"phone"`
-	Country     sql.NullString `json:"country"`
-	State       sql.NullString `json:"state"`
-	ZipCode     sql.NullString `json:"zipCode"`
-	City        sql.NullString `json:"city"`
-	Address     sql.NullString `json:"address"`
-	LogoUrl     sql.NullString `json:"logoUrl"`
-	BannerUrl   sql.NullString `json:"bannerUrl"`
-	Currency    sql.NullString `json:"currency"`
-	CreatedAt   time.Time      `json:"createdAt"`
-	UpdatedAt   time.Time      `json:"updatedAt"`
+	ID               int32          `json:"id"`
+	Name             string         `json:"name"`
+	PageUrl          string         `json:"pageUrl"`
+	Description      sql.NullString `json:"description"`
+	Email            sql.NullString `json:"email"`
+	Phone            sql.NullString `json:"phone"`
+	Country          sql.NullString `json:"country"`
+	State            sql.NullString `json:"state"`
+	ZipCode          sql.NullString `json:"zipCode"`
+	City             sql.NullString `json:"city"`
+	Address          sql.NullString `json:"address"`
+	LogoUrl          sql.NullString `json:"logoUrl"`
+	BannerUrl        sql.NullString `json:"bannerUrl"`
+	Currency         sql.NullString `json:"currency"`
+	CreatedAt        time.Time      `json:"createdAt"`
+	UpdatedAt        time.Time      `json:"updatedAt"`
+	RequireTwoFactor bool           `json:"requireTwoFactor"`
+}
+
+type BrandOidcProvider struct {
+	BrandID        int32     `json:"brandId"`
+	Issuer         string    `json:"issuer"`
+	ClientID       string    `json:"clientId"`
+	ClientSecret   string    `json:"clientSecret"`
+	AllowedDomains []string  `json:"allowedDomains"`
+	DefaultRole    string    `json:"defaultRole"`
+	Enabled        bool      `json:"enabled"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
 }
 
 type BrandSocialLink struct {
@@ -50,6 +95,28 @@ type BrandWorkingHour struct {
 	UpdatedAt time.Time    `json:"updatedAt"`
 }
 
+type CalendarChange struct {
+	ID             int64         `json:"id"`
+	BrandID        int32         `json:"brandId"`
+	EventID        int64         `json:"eventId"`
+	Kind           string        `json:"kind"`
+	UserID         int64         `json:"userId"`
+	PreviousUserID sql.NullInt64 `json:"previousUserId"`
+	StartTime      time.Time     `json:"startTime"`
+	EndTime        time.Time     `json:"endTime"`
+	CreatedAt      time.Time     `json:"createdAt"`
+}
+
+type CalendarFeed struct {
+	ID            int64         `json:"id"`
+	BrandID       int32         `json:"brandId"`
+	UserID        sql.NullInt64 `json:"userId"`
+	CustomerID    sql.NullInt64 `json:"customerId"`
+	TokenHash     string        `json:"tokenHash"`
+	LastFetchedAt sql.NullTime  `json:"lastFetchedAt"`
+	CreatedAt     time.Time     `json:"createdAt"`
+}
+
 type Customer struct {
 	ID          int64          `json:"id"`
 	Name        string         `json:"name"`
@@ -59,32 +126,217 @@ type Customer struct {
 	BrandID     int32          `json:"brandId"`
 	CreatedAt   time.Time      `json:"createdAt"`
 	UpdatedAt   time.Time      `json:"updatedAt"`
+	PhoneE164   sql.NullString `json:"phoneE164"`
+	Tags        []string       `json:"tags"`
+	Verified    bool           `json:"verified"`
+}
+
+type CustomerConsent struct {
+	CustomerID int64     `json:"customerId"`
+	BrandID    int32     `json:"brandId"`
+	Channel    string    `json:"channel"`
+	Purpose    string    `json:"purpose"`
+	Granted    bool      `json:"granted"`
+	Source     string    `json:"source"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+type CustomerDependent struct {
+	ID         int64          `json:"id"`
+	CustomerID int64          `json:"customerId"`
+	BrandID    int32          `json:"brandId"`
+	Name       string         `json:"name"`
+	BirthDate  sql.NullTime   `json:"birthDate"`
+	Notes      sql.NullString `json:"notes"`
+	CreatedAt  time.Time      `json:"createdAt"`
+	UpdatedAt  time.Time      `json:"updatedAt"`
+}
+
+type CustomerErasure struct {
+	ID               int64         `json:"id"`
+	BrandID          int32         `json:"brandId"`
+	CustomerID       int64         `json:"customerId"`
+	EventsAnonymized int32         `json:"eventsAnonymized"`
+	ErasedBy         sql.NullInt64 `json:"erasedBy"`
+	CreatedAt        time.Time     `json:"createdAt"`
+}
+
+type CustomerField struct {
+	ID        int64     `json:"id"`
+	BrandID   int32     `json:"brandId"`
+	Label     string    `json:"label"`
+	FieldType string    `json:"fieldType"`
+	Options   []string  `json:"options"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type CustomerFieldValue struct {
+	CustomerID int64     `json:"customerId"`
+	FieldID    int64     `json:"fieldId"`
+	Value      string    `json:"value"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+type CustomerMerge struct {
+	ID               int64           `json:"id"`
+	BrandID          int32           `json:"brandId"`
+	SurvivorID       int64           `json:"survivorId"`
+	MergedCustomerID int64           `json:"mergedCustomerId"`
+	MergedCustomer   json.RawMessage `json:"mergedCustomer"`
+	EventsMoved      int32           `json:"eventsMoved"`
+	MergedBy         sql.NullInt64   `json:"mergedBy"`
+	CreatedAt        time.Time       `json:"createdAt"`
+}
+
+type CustomerNote struct {
+	ID         int64         `json:"id"`
+	CustomerID int64         `json:"customerId"`
+	BrandID    int32         `json:"brandId"`
+	AuthorID   sql.NullInt64 `json:"authorId"`
+	AuthorName string        `json:"authorName"`
+	Body       string        `json:"body"`
+	Pinned     bool          `json:"pinned"`
+	CreatedAt  time.Time     `json:"createdAt"`
+	UpdatedAt  time.Time     `json:"updatedAt"`
 }
 
 type CustomerSession struct {
-	ID         uuid.UUID `json:"id"`
+	ID                uuid.UUID `json:"id"`
+	CustomerID        int64     `json:"customerId"`
+	ExpiresAt         time.Time `json:"expiresAt"`
+	UserAgent         string    `json:"userAgent"`
+	IpAddress         string    `json:"ipAddress"`
+	CreatedAt         time.Time `json:"createdAt"`
+	LastSeenAt        time.Time `json:"lastSeenAt"`
+	AbsoluteExpiresAt time.Time `json:"absoluteExpiresAt"`
+	Remember          bool      `json:"remember"`
+}
+
+type CustomerSignInCode struct {
+	ID         int64        `json:"id"`
+	CustomerID int64        `json:"customerId"`
+	BrandID    int32        `json:"brandId"`
+	Token      string       `json:"token"`
+	Code       string       `json:"code"`
+	Channel    string       `json:"channel"`
+	Attempts   int32        `json:"attempts"`
+	Expiry     time.Time    `json:"expiry"`
+	UsedAt     sql.NullTime `json:"usedAt"`
+	CreatedAt  time.Time    `json:"createdAt"`
+}
+
+type CustomerToken struct {
+	Token      string    `json:"token"`
 	CustomerID int64     `json:"customerId"`
-	ExpiresAt  time.Time `json:"expiresAt"`
+	BrandID    int32     `json:"brandId"`
+	Scope      string    `json:"scope"`
+	Expiry     time.Time `json:"expiry"`
 }
 
 type Event struct {
+	ID            int64          `json:"id"`
+	CustomerID    sql.NullInt64  `json:"customerId"`
+	ServiceID     uuid.UUID      `json:"serviceId"`
+	UserID        int64          `json:"userId"`
+	BrandID       int32          `json:"brandId"`
+	StartTime     time.Time      `json:"startTime"`
+	EndTime       time.Time      `json:"endTime"`
+	CustomerName  string         `json:"customerName"`
+	ServiceName   string         `json:"serviceName"`
+	UserName      string         `json:"userName"`
+	Comment       sql.NullString `json:"comment"`
+	BufferTime    sql.NullInt32  `json:"bufferTime"`
+	Cost          sql.NullString `json:"cost"`
+	CreatedAt     time.Time      `json:"createdAt"`
+	UpdatedAt     time.Time      `json:"updatedAt"`
+	NoShow        bool           `json:"noShow"`
+	DependentID   sql.NullInt64  `json:"dependentId"`
+	DependentName sql.NullString `json:"dependentName"`
+	Version       int32          `json:"version"`
+}
+
+type EventHistory struct {
+	ID        int64           `json:"id"`
+	EventID   int64           `json:"eventId"`
+	BrandID   int32           `json:"brandId"`
+	Version   int32           `json:"version"`
+	Action    string          `json:"action"`
+	ActorType string          `json:"actorType"`
+	ActorID   sql.NullInt64   `json:"actorId"`
+	ActorName string          `json:"actorName"`
+	Changes   json.RawMessage `json:"changes"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+type ExternalBusyTime struct {
+	ID         int64     `json:"id"`
+	CalendarID int64     `json:"calendarId"`
+	BrandID    int32     `json:"brandId"`
+	UserID     int64     `json:"userId"`
+	StartTime  time.Time `json:"startTime"`
+	EndTime    time.Time `json:"endTime"`
+}
+
+type ExternalCalendar struct {
 	ID           int64          `json:"id"`
-	CustomerID   int64          `json:"customerId"`
-	ServiceID    uuid.UUID      `json:"serviceId"`
-	UserID       int64          `json:"userId"`
 	BrandID      int32          `json:"brandId"`
-	StartTime    time.Time      `json:"startTime"`
-	EndTime      time.Time      `json:"endTime"`
-	CustomerName string         `json:"customerName"`
-	ServiceName  string         `json:"serviceName"`
-	UserName     string         `json:"userName"`
-	Comment      sql.NullString `json:"comment"`
-	BufferTime   sql.NullInt32  `json:"bufferTime"`
-	Cost         sql.NullString `json:"cost"`
+	UserID       int64          `json:"userId"`
+	Name         string         `json:"name"`
+	Url          sql.NullString `json:"url"`
+	Content      sql.NullString `json:"content"`
+	LastSyncedAt sql.NullTime   `json:"lastSyncedAt"`
+	LastError    string         `json:"lastError"`
+	NextSyncAt   time.Time      `json:"nextSyncAt"`
 	CreatedAt    time.Time      `json:"createdAt"`
 	UpdatedAt    time.Time      `json:"updatedAt"`
 }
 
+type IdempotencyKey struct {
+	ID             int64         `json:"id"`
+	Scope          string        `json:"scope"`
+	IdempotencyKey string        `json:"idempotencyKey"`
+	Method         string        `json:"method"`
+	Path           string        `json:"path"`
+	Fingerprint    string        `json:"fingerprint"`
+	StatusCode     sql.NullInt32 `json:"statusCode"`
+	ContentType    string        `json:"contentType"`
+	ResponseBody   []byte        `json:"responseBody"`
+	CreatedAt      time.Time     `json:"createdAt"`
+	ExpiresAt      time.Time     `json:"expiresAt"`
+}
+
+type ImportJob struct {
+	ID            int64           `json:"id"`
+	BrandID       int32           `json:"brandId"`
+	CreatedBy     sql.NullInt64   `json:"createdBy"`
+	Kind          string          `json:"kind"`
+	FileName      string          `json:"fileName"`
+	DryRun        bool            `json:"dryRun"`
+	Status        string          `json:"status"`
+	TotalRows     int32           `json:"totalRows"`
+	ProcessedRows int32           `json:"processedRows"`
+	CreatedRows   int32           `json:"createdRows"`
+	DuplicateRows int32           `json:"duplicateRows"`
+	InvalidRows   int32           `json:"invalidRows"`
+	Report        json.RawMessage `json:"report"`
+	Error         sql.NullString  `json:"error"`
+	CreatedAt     time.Time       `json:"createdAt"`
+	UpdatedAt     time.Time       `json:"updatedAt"`
+	FinishedAt    sql.NullTime    `json:"finishedAt"`
+}
+
+type OidcLoginState struct {
+	State        string    `json:"state"`
+	BrandID      int32     `json:"brandId"`
+	Nonce        string    `json:"nonce"`
+	CodeVerifier string    `json:"codeVerifier"`
+	Remember     bool      `json:"remember"`
+	Expiry       time.Time `json:"expiry"`
+}
+
 type Role struct {
 	ID    int32  `json:"id"`
 	Name  string `json:"name"`
@@ -105,6 +357,26 @@ type Service struct {
 	UpdatedAt   time.Time      `json:"updatedAt"`
 }
 
+type SignInAttempt struct {
+	ID        int64         `json:"id"`
+	Principal string        `json:"principal"`
+	AccountID sql.NullInt64 `json:"accountId"`
+	BrandID   sql.NullInt32 `json:"brandId"`
+	Email     string        `json:"email"`
+	IpAddress string        `json:"ipAddress"`
+	UserAgent string        `json:"userAgent"`
+	Success   bool          `json:"success"`
+	Reason    string        `json:"reason"`
+	CreatedAt time.Time     `json:"createdAt"`
+}
+
+type SignInThrottle struct {
+	Key          string       `json:"key"`
+	Failures     int32        `json:"failures"`
+	BlockedUntil sql.NullTime `json:"blockedUntil"`
+	ExpiresAt    time.Time    `json:"expiresAt"`
+}
+
 type User struct {
 	ID        int64          `json:"id"`
 	Name      string         `json:"name"`
@@ -118,12 +390,29 @@ type User struct {
 	Role      string         `json:"role"`
 }
 
+type UserIdentity struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"userId"`
+	Issuer    string    `json:"issuer"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
 type UserInvitation struct {
 	Token  string    `json:"token"`
 	UserID int64     `json:"userId"`
 	Expiry time.Time `json:"expiry"`
 }
 
+type UserRecoveryCode struct {
+	ID        int64        `json:"id"`
+	UserID    int64        `json:"userId"`
+	CodeHash  string       `json:"codeHash"`
+	UsedAt    sql.NullTime `json:"usedAt"`
+	CreatedAt time.Time    `json:"createdAt"`
+}
+
 type UserService struct {
 	UserID    int64     `json:"userId"`
 	ServiceID uuid.UUID `json:"serviceId"`
@@ -132,7 +421,65 @@ type UserService struct {
 }
 
 type UserSession struct {
-	ID        uuid.UUID `json:"id"`
-	UserID    int64     `json:"userId"`
-	ExpiresAt time.Time `json:"expiresAt"`
+	ID                uuid.UUID `json:"id"`
+	UserID            int64     `json:"userId"`
+	ExpiresAt         time.Time `json:"expiresAt"`
+	UserAgent         string    `json:"userAgent"`
+	IpAddress         string    `json:"ipAddress"`
+	CreatedAt         time.Time `json:"createdAt"`
+	LastSeenAt        time.Time `json:"lastSeenAt"`
+	AbsoluteExpiresAt time.Time `json:"absoluteExpiresAt"`
+	Remember          bool      `json:"remember"`
+}
+
+type UserSignInChallenge struct {
+	Token    string    `json:"token"`
+	UserID   int64     `json:"userId"`
+	Remember bool      `json:"remember"`
+	Attempts int32     `json:"attempts"`
+	Expiry   time.Time `json:"expiry"`
+}
+
+type UserToken struct {
+	Token  string    `json:"token"`
+	UserID int64     `json:"userId"`
+	Scope  string    `json:"scope"`
+	Expiry time.Time `json:"expiry"`
+}
+
+type UserTotp struct {
+	UserID       int64        `json:"userId"`
+	Secret       string       `json:"secret"`
+	ConfirmedAt  sql.NullTime `json:"confirmedAt"`
+	LastUsedStep int64        `json:"lastUsedStep"`
+	CreatedAt    time.Time    `json:"createdAt"`
+}
+
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	SubscriptionID int64           `json:"subscriptionId"`
+	BrandID        int32           `json:"brandId"`
+	EventType      string          `json:"eventType"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int32           `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"nextAttemptAt"`
+	LastAttemptAt  sql.NullTime    `json:"lastAttemptAt"`
+	LastStatusCode sql.NullInt32   `json:"lastStatusCode"`
+	LastError      string          `json:"lastError"`
+	DeliveredAt    sql.NullTime    `json:"deliveredAt"`
+	RedeliveryOf   sql.NullInt64   `json:"redeliveryOf"`
+	CreatedAt      time.Time       `json:"createdAt"`
+}
+
+type WebhookSubscription struct {
+	ID         int64         `json:"id"`
+	BrandID    int32         `json:"brandId"`
+	Url        string        `json:"url"`
+	EventTypes []string      `json:"eventTypes"`
+	Secret     string        `json:"secret"`
+	Active     bool          `json:"active"`
+	CreatedBy  sql.NullInt64 `json:"createdBy"`
+	CreatedAt  time.Time     `json:"createdAt"`
+	UpdatedAt  time.Time     `json:"updatedAt"`
 }
@@ -7,73 +7,236 @@ package store
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 type Querier interface {
 	AddBrandSocialLink(ctx context.Context, arg AddBrandSocialLinkParams) (*BrandSocialLink, error)
+	AnonymizeCustomerEvents(ctx context.Context, arg AnonymizeCustomerEventsParams) (int64, error)
 	AssignServiceToUser(ctx context.Context, arg AssignServiceToUserParams) error
 	AssociateUserWithBrand(ctx context.Context, arg AssociateUserWithBrandParams) error
+	BlockSignInThrottle(ctx context.Context, arg BlockSignInThrottleParams) error
 	CheckSpecificTimeslotAvailability(ctx context.Context, arg CheckSpecificTimeslotAvailabilityParams) (interface{}, error)
+	ClaimDueExternalCalendars(ctx context.Context, arg ClaimDueExternalCalendarsParams) ([]*ExternalCalendar, error)
+	ClaimIdempotencyKey(ctx context.Context, arg ClaimIdempotencyKeyParams) (int64, error)
+	// Deliveries of paused subscriptions wait until the subscription is active again
+	ClaimWebhookDeliveries(ctx context.Context, arg ClaimWebhookDeliveriesParams) ([]*ClaimWebhookDeliveriesRow, error)
+	CompleteIdempotencyKey(ctx context.Context, arg CompleteIdempotencyKeyParams) error
+	ConfirmUserTotp(ctx context.Context, arg ConfirmUserTotpParams) (int64, error)
+	ConsumeCustomerToken(ctx context.Context, arg ConsumeCustomerTokenParams) (int64, error)
+	ConsumeOidcLoginState(ctx context.Context, state string) (*OidcLoginState, error)
+	ConsumeUserSignInChallenge(ctx context.Context, arg ConsumeUserSignInChallengeParams) (int64, error)
+	ConsumeUserToken(ctx context.Context, arg ConsumeUserTokenParams) (int64, error)
+	// Moves the consents of a merged customer, the choice made last wins
+	CopyCustomerConsents(ctx context.Context, arg CopyCustomerConsentsParams) error
+	CopyCustomerFieldValues(ctx context.Context, arg CopyCustomerFieldValuesParams) error
+	CountActiveBrandApiKeys(ctx context.Context, brandID int32) (int64, error)
+	CountUserRecoveryCodes(ctx context.Context, userID int64) (int64, error)
+	CreateApiKey(ctx context.Context, arg CreateApiKeyParams) (*ApiKey, error)
 	CreateBrand(ctx context.Context, arg CreateBrandParams) (*Brand, error)
 	CreateCustomer(ctx context.Context, arg CreateCustomerParams) (*Customer, error)
+	CreateCustomerDependent(ctx context.Context, arg CreateCustomerDependentParams) (*CustomerDependent, error)
+	CreateCustomerErasure(ctx context.Context, arg CreateCustomerErasureParams) (*CustomerErasure, error)
+	CreateCustomerField(ctx context.Context, arg CreateCustomerFieldParams) (*CustomerField, error)
+	CreateCustomerMerge(ctx context.Context, arg CreateCustomerMergeParams) (*CustomerMerge, error)
+	CreateCustomerNote(ctx context.Context, arg CreateCustomerNoteParams) (*CustomerNote, error)
 	CreateCustomerSession(ctx context.Context, arg CreateCustomerSessionParams) (*CustomerSession, error)
+	CreateCustomerSignInCode(ctx context.Context, arg CreateCustomerSignInCodeParams) (*CustomerSignInCode, error)
+	CreateCustomerToken(ctx context.Context, arg CreateCustomerTokenParams) error
 	CreateEvent(ctx context.Context, arg CreateEventParams) (*Event, error)
+	CreateExternalBusyTimes(ctx context.Context, arg CreateExternalBusyTimesParams) error
+	CreateExternalCalendar(ctx context.Context, arg CreateExternalCalendarParams) (*ExternalCalendar, error)
 	CreateGuestCustomer(ctx context.Context, arg CreateGuestCustomerParams) (*Customer, error)
+	CreateImportJob(ctx context.Context, arg CreateImportJobParams) (*ImportJob, error)
+	CreateOidcLoginState(ctx context.Context, arg CreateOidcLoginStateParams) error
 	CreateService(ctx context.Context, arg CreateServiceParams) (*Service, error)
+	CreateSignInAttempt(ctx context.Context, arg CreateSignInAttemptParams) error
 	CreateUser(ctx context.Context, arg CreateUserParams) (*User, error)
+	CreateUserIdentity(ctx context.Context, arg CreateUserIdentityParams) (*UserIdentity, error)
 	CreateUserInvitation(ctx context.Context, arg CreateUserInvitationParams) error
+	CreateUserRecoveryCode(ctx context.Context, arg CreateUserRecoveryCodeParams) error
 	CreateUserSession(ctx context.Context, arg CreateUserSessionParams) (*UserSession, error)
+	CreateUserSignInChallenge(ctx context.Context, arg CreateUserSignInChallengeParams) error
+	CreateUserToken(ctx context.Context, arg CreateUserTokenParams) error
+	CreateWebhookSubscription(ctx context.Context, arg CreateWebhookSubscriptionParams) (*WebhookSubscription, error)
+	DeleteAuditLogBefore(ctx context.Context, createdAt time.Time) (int64, error)
+	DeleteBrandOidcProvider(ctx context.Context, brandID int32) (int64, error)
 	DeleteBrandSocialLinks(ctx context.Context, brandID int32) error
+	DeleteCalendarChangesBefore(ctx context.Context, createdAt time.Time) (int64, error)
+	DeleteCalendarFeed(ctx context.Context, id int64) (int64, error)
 	DeleteCustomer(ctx context.Context, id int64) error
+	DeleteCustomerDependent(ctx context.Context, arg DeleteCustomerDependentParams) (int64, error)
+	// Drops the history of the customer's events before an erasure, the old
+	// versions still hold the customer and their comments
+	DeleteCustomerEventHistory(ctx context.Context, customerID sql.NullInt64) error
+	DeleteCustomerField(ctx context.Context, arg DeleteCustomerFieldParams) (int64, error)
+	DeleteCustomerFieldValue(ctx context.Context, arg DeleteCustomerFieldValueParams) error
+	DeleteCustomerNote(ctx context.Context, arg DeleteCustomerNoteParams) (int64, error)
+	DeleteCustomerTokens(ctx context.Context, arg DeleteCustomerTokensParams) error
 	DeleteEvent(ctx context.Context, id int64) error
+	DeleteExpiredCustomerSessions(ctx context.Context) (int64, error)
+	DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error)
+	DeleteExpiredOidcLoginStates(ctx context.Context) (int64, error)
+	DeleteExpiredSignInThrottles(ctx context.Context) (int64, error)
+	DeleteExpiredUserSessions(ctx context.Context) (int64, error)
+	DeleteExternalBusyTimes(ctx context.Context, calendarID int64) error
+	DeleteExternalCalendar(ctx context.Context, arg DeleteExternalCalendarParams) (int64, error)
 	DeleteService(ctx context.Context, id uuid.UUID) error
+	DeleteSignInThrottle(ctx context.Context, key string) error
 	DeleteUser(ctx context.Context, id int64) error
 	DeleteUserInvitation(ctx context.Context, userID int64) error
+	DeleteUserRecoveryCodes(ctx context.Context, userID int64) error
+	DeleteUserSignInChallenges(ctx context.Context, userID int64) error
+	DeleteUserTokens(ctx context.Context, arg DeleteUserTokensParams) error
+	DeleteUserTotp(ctx context.Context, userID int64) error
+	DeleteWebhookDeliveriesBefore(ctx context.Context, createdAt time.Time) (int64, error)
+	DeleteWebhookSubscription(ctx context.Context, arg DeleteWebhookSubscriptionParams) (int64, error)
+	ExpireBrandUserSessionsWithoutTwoFactor(ctx context.Context, brandID sql.NullInt32) (int64, error)
+	ExpireCustomerSessions(ctx context.Context, customerID int64) error
+	ExpireUserSessions(ctx context.Context, userID int64) error
+	ExportCustomers(ctx context.Context, brandID int32) ([]*Customer, error)
+	ExportEvents(ctx context.Context, arg ExportEventsParams) ([]*ExportEventsRow, error)
+	FailSignInThrottle(ctx context.Context, arg FailSignInThrottleParams) (int32, error)
+	FailStaleImportJobs(ctx context.Context) (int64, error)
+	FillCustomerAccount(ctx context.Context, arg FillCustomerAccountParams) (*Customer, error)
+	FinishImportJob(ctx context.Context, arg FinishImportJobParams) (*ImportJob, error)
+	GetActiveCustomerSignInCode(ctx context.Context, customerID int64) (*CustomerSignInCode, error)
+	GetApiKeyByPrefix(ctx context.Context, prefix string) (*ApiKey, error)
 	GetBrand(ctx context.Context, id int32) (*Brand, error)
 	GetBrandById(ctx context.Context, id int32) (*Brand, error)
 	GetBrandByUrl(ctx context.Context, pageUrl string) (int32, error)
+	GetBrandCalendarFeed(ctx context.Context, brandID int32) (*CalendarFeed, error)
+	GetBrandOidcProvider(ctx context.Context, brandID int32) (*BrandOidcProvider, error)
 	GetBrandSocialLinks(ctx context.Context, brandID int32) ([]*BrandSocialLink, error)
 	GetBrandUsers(ctx context.Context, brandID sql.NullInt32) ([]*User, error)
 	GetBrandWorkingHours(ctx context.Context, brandID int32) ([]*BrandWorkingHour, error)
-	GetCustomerByEmail(ctx context.Context, email sql.NullString) (*Customer, error)
+	GetCalendarFeedByTokenHash(ctx context.Context, tokenHash string) (*CalendarFeed, error)
+	GetCustomerByEmail(ctx context.Context, arg GetCustomerByEmailParams) (*Customer, error)
 	GetCustomerById(ctx context.Context, id int64) (*Customer, error)
-	GetCustomerByNameAndPhone(ctx context.Context, arg GetCustomerByNameAndPhoneParams) (*Customer, error)
+	// Numbers entered before they were normalized can match several customers,
+	// accounts come first and then the oldest
+	GetCustomerByPhone(ctx context.Context, arg GetCustomerByPhoneParams) (*Customer, error)
+	GetCustomerCalendarFeed(ctx context.Context, customerID sql.NullInt64) (*CalendarFeed, error)
+	GetCustomerConsentByRecipient(ctx context.Context, arg GetCustomerConsentByRecipientParams) (*CustomerConsent, error)
+	GetCustomerDependent(ctx context.Context, arg GetCustomerDependentParams) (*CustomerDependent, error)
+	GetCustomerField(ctx context.Context, id int64) (*CustomerField, error)
 	GetCustomerSessionById(ctx context.Context, id uuid.UUID) (*CustomerSession, error)
+	GetCustomerStats(ctx context.Context, customerID sql.NullInt64) (*GetCustomerStatsRow, error)
 	GetCustomersByBrand(ctx context.Context, brandID int32) ([]*Customer, error)
 	GetEventByID(ctx context.Context, id int64) (*Event, error)
+	GetEventByUserAndStart(ctx context.Context, arg GetEventByUserAndStartParams) (*Event, error)
 	GetEventsByDay(ctx context.Context, arg GetEventsByDayParams) ([]*Event, error)
 	GetEventsByWeek(ctx context.Context, arg GetEventsByWeekParams) ([]*Event, error)
+	GetExternalCalendar(ctx context.Context, arg GetExternalCalendarParams) (*ExternalCalendar, error)
+	GetIdempotencyKey(ctx context.Context, arg GetIdempotencyKeyParams) (*IdempotencyKey, error)
+	GetImportJob(ctx context.Context, id int64) (*ImportJob, error)
+	GetNextCustomerEvent(ctx context.Context, customerID sql.NullInt64) (*Event, error)
 	GetService(ctx context.Context, id uuid.UUID) (*Service, error)
-	GetSessionByCustomerId(ctx context.Context, customerID int64) (*CustomerSession, error)
-	GetSessionByUserId(ctx context.Context, userID int64) (*UserSession, error)
+	GetSignInThrottle(ctx context.Context, key string) (*SignInThrottle, error)
 	GetUserByEmail(ctx context.Context, email string) (*User, error)
 	GetUserById(ctx context.Context, id int64) (*User, error)
+	GetUserCalendarFeed(ctx context.Context, userID sql.NullInt64) (*CalendarFeed, error)
 	GetUserEventsByDay(ctx context.Context, arg GetUserEventsByDayParams) ([]*Event, error)
 	GetUserEventsByWeek(ctx context.Context, arg GetUserEventsByWeekParams) ([]*Event, error)
 	GetUserFromInvitation(ctx context.Context, token string) (int64, error)
+	GetUserIdentity(ctx context.Context, arg GetUserIdentityParams) (*UserIdentity, error)
 	GetUserSessionById(ctx context.Context, id uuid.UUID) (*UserSession, error)
+	GetUserSignInChallenge(ctx context.Context, arg GetUserSignInChallengeParams) (*UserSignInChallenge, error)
+	GetUserTotp(ctx context.Context, userID int64) (*UserTotp, error)
 	GetUsersByBrand(ctx context.Context, brandID sql.NullInt32) ([]*User, error)
+	GetWebhookSubscription(ctx context.Context, arg GetWebhookSubscriptionParams) (*WebhookSubscription, error)
+	IncrementCustomerSignInCodeAttempts(ctx context.Context, id int64) error
+	IncrementUserSignInChallengeAttempts(ctx context.Context, token string) error
+	InvalidateCustomerSignInCodes(ctx context.Context, customerID int64) error
+	ListAuditLog(ctx context.Context, arg ListAuditLogParams) ([]*AuditLog, error)
+	ListBrandApiKeys(ctx context.Context, brandID int32) ([]*ApiKey, error)
+	ListCalendarChangesAfter(ctx context.Context, arg ListCalendarChangesAfterParams) ([]*CalendarChange, error)
+	ListCalendarFeedEvents(ctx context.Context, arg ListCalendarFeedEventsParams) ([]*Event, error)
+	ListCustomerConsents(ctx context.Context, customerID int64) ([]*CustomerConsent, error)
+	ListCustomerDependents(ctx context.Context, customerID int64) ([]*CustomerDependent, error)
+	ListCustomerEvents(ctx context.Context, customerID sql.NullInt64) ([]*Event, error)
+	ListCustomerFieldValues(ctx context.Context, customerID int64) ([]*ListCustomerFieldValuesRow, error)
+	ListCustomerFields(ctx context.Context, brandID int32) ([]*CustomerField, error)
+	ListCustomerMerges(ctx context.Context, survivorID int64) ([]*CustomerMerge, error)
+	ListCustomerNotes(ctx context.Context, customerID int64) ([]*CustomerNote, error)
+	ListCustomerSessions(ctx context.Context, customerID int64) ([]*CustomerSession, error)
+	ListCustomerVisits(ctx context.Context, arg ListCustomerVisitsParams) ([]*Event, error)
+	ListCustomers(ctx context.Context, arg ListCustomersParams) ([]*ListCustomersRow, error)
+	// Name matches use the trigram index through the % operator, whose threshold
+	// is set by SetSimilarityThreshold in the same transaction
+	ListDuplicateCustomers(ctx context.Context, arg ListDuplicateCustomersParams) ([]*ListDuplicateCustomersRow, error)
+	ListEventHistory(ctx context.Context, eventID int64) ([]*EventHistory, error)
+	ListEvents(ctx context.Context, arg ListEventsParams) ([]*ListEventsRow, error)
 	ListEventsByBrand(ctx context.Context, arg ListEventsByBrandParams) ([]*Event, error)
 	ListEventsByCustomer(ctx context.Context, arg ListEventsByCustomerParams) ([]*Event, error)
 	ListEventsByUser(ctx context.Context, arg ListEventsByUserParams) ([]*Event, error)
+	ListExternalCalendars(ctx context.Context, userID int64) ([]*ListExternalCalendarsRow, error)
+	ListImportJobs(ctx context.Context, arg ListImportJobsParams) ([]*ImportJob, error)
+	ListServiceProviders(ctx context.Context, serviceIds []uuid.UUID) ([]*ListServiceProvidersRow, error)
+	ListServices(ctx context.Context, arg ListServicesParams) ([]*ListServicesRow, error)
 	ListServicesWithProviders(ctx context.Context, brandID int32) ([]*ListServicesWithProvidersRow, error)
+	ListUserBusyTimes(ctx context.Context, arg ListUserBusyTimesParams) ([]*ExternalBusyTime, error)
 	ListUserServices(ctx context.Context, userID int64) ([]*Service, error)
+	ListUserSessions(ctx context.Context, userID int64) ([]*UserSession, error)
+	ListUsers(ctx context.Context, arg ListUsersParams) ([]*ListUsersRow, error)
 	ListVisibleServices(ctx context.Context, brandID int32) ([]*Service, error)
+	ListWebhookDeliveries(ctx context.Context, arg ListWebhookDeliveriesParams) ([]*WebhookDelivery, error)
+	ListWebhookSubscriptions(ctx context.Context, brandID int32) ([]*WebhookSubscription, error)
+	ReassignCustomerDependents(ctx context.Context, arg ReassignCustomerDependentsParams) (int64, error)
+	ReassignCustomerEvents(ctx context.Context, arg ReassignCustomerEventsParams) (int64, error)
+	ReassignCustomerNotes(ctx context.Context, arg ReassignCustomerNotesParams) (int64, error)
+	RecordExternalCalendarSync(ctx context.Context, arg RecordExternalCalendarSyncParams) (*ExternalCalendar, error)
+	RecordWebhookAttempt(ctx context.Context, arg RecordWebhookAttemptParams) error
+	// Strips an erased customer from the deliveries, sent ones can be sent again.
+	// Integrations still learn which customer was deleted.
+	RedactCustomerWebhookDeliveries(ctx context.Context, arg RedactCustomerWebhookDeliveriesParams) (int64, error)
+	RedeliverWebhookDelivery(ctx context.Context, arg RedeliverWebhookDeliveryParams) (*WebhookDelivery, error)
+	ReleaseIdempotencyKey(ctx context.Context, id int64) error
 	RemoveUsersFromService(ctx context.Context, serviceID uuid.UUID) error
+	RevokeApiKey(ctx context.Context, arg RevokeApiKeyParams) (*ApiKey, error)
+	RevokeCustomerSession(ctx context.Context, arg RevokeCustomerSessionParams) (int64, error)
+	RevokeOtherCustomerSessions(ctx context.Context, arg RevokeOtherCustomerSessionsParams) (int64, error)
+	RevokeOtherUserSessions(ctx context.Context, arg RevokeOtherUserSessionsParams) (int64, error)
+	RevokeUserSession(ctx context.Context, arg RevokeUserSessionParams) (int64, error)
+	RotateBrandCalendarFeed(ctx context.Context, arg RotateBrandCalendarFeedParams) (*CalendarFeed, error)
+	RotateCustomerCalendarFeed(ctx context.Context, arg RotateCustomerCalendarFeedParams) (*CalendarFeed, error)
+	RotateCustomerSession(ctx context.Context, arg RotateCustomerSessionParams) (*CustomerSession, error)
+	RotateUserCalendarFeed(ctx context.Context, arg RotateUserCalendarFeedParams) (*CalendarFeed, error)
+	RotateUserSession(ctx context.Context, arg RotateUserSessionParams) (*UserSession, error)
+	SetBrandRequireTwoFactor(ctx context.Context, arg SetBrandRequireTwoFactorParams) (*Brand, error)
+	SetEventNoShow(ctx context.Context, arg SetEventNoShowParams) (*Event, error)
+	SetSimilarityThreshold(ctx context.Context, threshold float32) error
+	TouchApiKey(ctx context.Context, arg TouchApiKeyParams) error
+	TouchCalendarFeed(ctx context.Context, id int64) error
+	TouchCustomerSession(ctx context.Context, arg TouchCustomerSessionParams) error
+	TouchUserSession(ctx context.Context, arg TouchUserSessionParams) error
 	UpdateBrand(ctx context.Context, arg UpdateBrandParams) (*Brand, error)
 	UpdateBrandPartial(ctx context.Context, arg UpdateBrandPartialParams) (*Brand, error)
 	UpdateBrandSocialLink(ctx context.Context, arg UpdateBrandSocialLinkParams) (*BrandSocialLink, error)
+	UpdateCustomerDependent(ctx context.Context, arg UpdateCustomerDependentParams) (*CustomerDependent, error)
+	UpdateCustomerPassword(ctx context.Context, arg UpdateCustomerPasswordParams) error
 	UpdateCustomerSession(ctx context.Context, arg UpdateCustomerSessionParams) (*CustomerSession, error)
+	UpdateCustomerTags(ctx context.Context, arg UpdateCustomerTagsParams) (*Customer, error)
 	UpdateEvent(ctx context.Context, arg UpdateEventParams) (*Event, error)
+	UpdateImportJobProgress(ctx context.Context, arg UpdateImportJobProgressParams) (*ImportJob, error)
 	UpdateService(ctx context.Context, arg UpdateServiceParams) (*Service, error)
+	UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error
 	UpdateUserSession(ctx context.Context, arg UpdateUserSessionParams) (*UserSession, error)
+	UpdateWebhookSubscription(ctx context.Context, arg UpdateWebhookSubscriptionParams) (*WebhookSubscription, error)
+	UpsertBrandOidcProvider(ctx context.Context, arg UpsertBrandOidcProviderParams) (*BrandOidcProvider, error)
 	UpsertBrandSocialLink(ctx context.Context, arg UpsertBrandSocialLinkParams) (*BrandSocialLink, error)
 	UpsertBrandWorkingHours(ctx context.Context, arg UpsertBrandWorkingHoursParams) (*BrandWorkingHour, error)
-	UpsertCustomerSession(ctx context.Context, arg UpsertCustomerSessionParams) (*CustomerSession, error)
-	UpsertUserSession(ctx context.Context, arg UpsertUserSessionParams) (*UserSession, error)
+	UpsertCustomerConsent(ctx context.Context, arg UpsertCustomerConsentParams) (*CustomerConsent, error)
+	UpsertCustomerFieldValue(ctx context.Context, arg UpsertCustomerFieldValueParams) (*CustomerFieldValue, error)
+	UpsertUserTotp(ctx context.Context, arg UpsertUserTotpParams) (*UserTotp, error)
+	UseCustomerSignInCode(ctx context.Context, id int64) error
+	UseCustomerSignInToken(ctx context.Context, arg UseCustomerSignInTokenParams) (int64, error)
+	UseUserRecoveryCode(ctx context.Context, arg UseUserRecoveryCodeParams) (int64, error)
+	UseUserTotpStep(ctx context.Context, arg UseUserTotpStepParams) (int64, error)
 	ValidateUsersCount(ctx context.Context, arg ValidateUsersCountParams) (int64, error)
+	VerifyCustomer(ctx context.Context, id int64) error
 	VerifyUser(ctx context.Context, id int64) error
 }
 
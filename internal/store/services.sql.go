@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const assignServiceToUser = `-- name: AssignServiceToUser :exec
@@ -120,6 +121,141 @@ func (q *Queries) GetService(ctx context.Context, id uuid.UUID) (*Service, error
 	return &i, err
 }
 
+const listServiceProviders = `-- name: ListServiceProviders :many
+SELECT us.service_id, us.user_id
+FROM user_services us
+JOIN users u ON u.id = us.user_id
+WHERE us.service_id = ANY($1::uuid[])
+ORDER BY u.name
+`
+
+type ListServiceProvidersRow struct {
+	ServiceID uuid.UUID `json:"serviceId"`
+	UserID    int64     `json:"userId"`
+}
+
+func (q *Queries) ListServiceProviders(ctx context.Context, serviceIds []uuid.UUID) ([]*ListServiceProvidersRow, error) {
+	rows, err := q.db.QueryContext(ctx, listServiceProviders, pq.Array(serviceIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*ListServiceProvidersRow
+	for rows.Next() {
+		var i ListServiceProvidersRow
+		if err := rows.Scan(&i.ServiceID, &i.UserID); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listServices = `-- name: ListServices :many
+WITH listed AS (
+    SELECT
+        s.id, s.title, s.description, s.duration, s.buffer_time, s.cost, s.is_visible, s.image_url, s.brand_id, s.created_at, s.updated_at,
+        (CASE $1::text
+            WHEN 'createdAt' THEN to_char(s.created_at, 'YYYY-MM-DD"T"HH24:MI:SS')
+            ELSE lower(s.title)
+        END)::text AS sort_key
+    FROM services s
+    WHERE s.brand_id = $2
+    AND (
+        $3::text = ''
+        OR s.title ILIKE $3::text || '%'
+        OR s.title % $3::text
+    )
+)
+SELECT id, title, description, duration, buffer_time, cost, is_visible, image_url, brand_id, created_at, updated_at, sort_key FROM listed
+WHERE NOT $4::boolean
+OR ($5::boolean AND (sort_key, id) < ($6::text, $7::uuid))
+OR (NOT $5::boolean AND (sort_key, id) > ($6::text, $7::uuid))
+ORDER BY
+    CASE WHEN $5::boolean THEN sort_key END DESC,
+    CASE WHEN $5::boolean THEN id END DESC,
+    sort_key,
+    id
+LIMIT $8
+`
+
+type ListServicesParams struct {
+	Sort       string    `json:"sort"`
+	BrandID    int32     `json:"brandId"`
+	Search     string    `json:"search"`
+	HasCursor  bool      `json:"hasCursor"`
+	Descending bool      `json:"descending"`
+	CursorKey  string    `json:"cursorKey"`
+	CursorID   uuid.UUID `json:"cursorId"`
+	PageLimit  int32     `json:"pageLimit"`
+}
+
+type ListServicesRow struct {
+	ID          uuid.UUID      `json:"id"`
+	Title       string         `json:"title"`
+	Description sql.NullString `json:"description"`
+	Duration    int32          `json:"duration"`
+	BufferTime  sql.NullInt32  `json:"bufferTime"`
+	Cost        sql.NullString `json:"cost"`
+	IsVisible   bool           `json:"isVisible"`
+	ImageUrl    sql.NullString `json:"imageUrl"`
+	BrandID     int32          `json:"brandId"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	UpdatedAt   time.Time      `json:"updatedAt"`
+	SortKey     string         `json:"sortKey"`
+}
+
+func (q *Queries) ListServices(ctx context.Context, arg ListServicesParams) ([]*ListServicesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listServices,
+		arg.Sort,
+		arg.BrandID,
+		arg.Search,
+		arg.HasCursor,
+		arg.Descending,
+		arg.CursorKey,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*ListServicesRow
+	for rows.Next() {
+		var i ListServicesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Description,
+			&i.Duration,
+			&i.BufferTime,
+			&i.Cost,
+			&i.IsVisible,
+			&i.ImageUrl,
+			&i.BrandID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.SortKey,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listServicesWithProviders = `-- name: ListServicesWithProviders :many
 SELECT
     services.id,
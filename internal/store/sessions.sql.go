@@ -13,90 +13,428 @@ import (
 )
 
 const createCustomerSession = `-- name: CreateCustomerSession :one
-INSERT INTO customer_sessions (customer_id, expires_at)
-VALUES ($1, $2)
-RETURNING id, customer_id, expires_at
+INSERT INTO customer_sessions (customer_id, expires_at, absolute_expires_at, remember, user_agent, ip_address)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, customer_id, expires_at, user_agent, ip_address, created_at, last_seen_at, absolute_expires_at, remember
 `
 
 type CreateCustomerSessionParams struct {
-	CustomerID int64     `json:"customerId"`
-	ExpiresAt  time.Time `json:"expiresAt"`
+	CustomerID        int64     `json:"customerId"`
+	ExpiresAt         time.Time `json:"expiresAt"`
+	AbsoluteExpiresAt time.Time `json:"absoluteExpiresAt"`
+	Remember          bool      `json:"remember"`
+	UserAgent         string    `json:"userAgent"`
+	IpAddress         string    `json:"ipAddress"`
 }
 
 func (q *Queries) CreateCustomerSession(ctx context.Context, arg CreateCustomerSessionParams) (*CustomerSession, error) {
-	row := q.db.QueryRowContext(ctx, createCustomerSession, arg.CustomerID, arg.ExpiresAt)
+	row := q.db.QueryRowContext(ctx, createCustomerSession,
+		arg.CustomerID,
+		arg.ExpiresAt,
+		arg.AbsoluteExpiresAt,
+		arg.Remember,
+		arg.UserAgent,
+		arg.IpAddress,
+	)
 	var i CustomerSession
-	err := row.Scan(&i.ID, &i.CustomerID, &i.ExpiresAt)
+	err := row.Scan(
+		&i.ID,
+		&i.CustomerID,
+		&i.ExpiresAt,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.CreatedAt,
+		&i.LastSeenAt,
+		&i.AbsoluteExpiresAt,
+		&i.Remember,
+	)
 	return &i, err
 }
 
 const createUserSession = `-- name: CreateUserSession :one
-INSERT INTO user_sessions (user_id, expires_at)
-VALUES ($1, $2)
-RETURNING id, user_id, expires_at
+INSERT INTO user_sessions (user_id, expires_at, absolute_expires_at, remember, user_agent, ip_address)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, user_id, expires_at, user_agent, ip_address, created_at, last_seen_at, absolute_expires_at, remember
 `
 
 type CreateUserSessionParams struct {
-	UserID    int64     `json:"userId"`
-	ExpiresAt time.Time `json:"expiresAt"`
+	UserID            int64     `json:"userId"`
+	ExpiresAt         time.Time `json:"expiresAt"`
+	AbsoluteExpiresAt time.Time `json:"absoluteExpiresAt"`
+	Remember          bool      `json:"remember"`
+	UserAgent         string    `json:"userAgent"`
+	IpAddress         string    `json:"ipAddress"`
 }
 
 func (q *Queries) CreateUserSession(ctx context.Context, arg CreateUserSessionParams) (*UserSession, error) {
-	row := q.db.QueryRowContext(ctx, createUserSession, arg.UserID, arg.ExpiresAt)
+	row := q.db.QueryRowContext(ctx, createUserSession,
+		arg.UserID,
+		arg.ExpiresAt,
+		arg.AbsoluteExpiresAt,
+		arg.Remember,
+		arg.UserAgent,
+		arg.IpAddress,
+	)
 	var i UserSession
-	err := row.Scan(&i.ID, &i.UserID, &i.ExpiresAt)
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.ExpiresAt,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.CreatedAt,
+		&i.LastSeenAt,
+		&i.AbsoluteExpiresAt,
+		&i.Remember,
+	)
 	return &i, err
 }
 
+const deleteExpiredCustomerSessions = `-- name: DeleteExpiredCustomerSessions :execrows
+DELETE FROM customer_sessions
+WHERE expires_at < NOW()
+`
+
+func (q *Queries) DeleteExpiredCustomerSessions(ctx context.Context) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteExpiredCustomerSessions)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteExpiredUserSessions = `-- name: DeleteExpiredUserSessions :execrows
+DELETE FROM user_sessions
+WHERE expires_at < NOW()
+`
+
+func (q *Queries) DeleteExpiredUserSessions(ctx context.Context) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteExpiredUserSessions)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const expireCustomerSessions = `-- name: ExpireCustomerSessions :exec
+UPDATE customer_sessions
+SET expires_at = NOW()
+WHERE customer_id = $1
+`
+
+func (q *Queries) ExpireCustomerSessions(ctx context.Context, customerID int64) error {
+	_, err := q.db.ExecContext(ctx, expireCustomerSessions, customerID)
+	return err
+}
+
+const expireUserSessions = `-- name: ExpireUserSessions :exec
+UPDATE user_sessions
+SET expires_at = NOW()
+WHERE user_id = $1
+`
+
+func (q *Queries) ExpireUserSessions(ctx context.Context, userID int64) error {
+	_, err := q.db.ExecContext(ctx, expireUserSessions, userID)
+	return err
+}
+
 const getCustomerSessionById = `-- name: GetCustomerSessionById :one
-SELECT id, customer_id, expires_at FROM customer_sessions WHERE id = $1
+SELECT id, customer_id, expires_at, user_agent, ip_address, created_at, last_seen_at, absolute_expires_at, remember FROM customer_sessions WHERE id = $1
 `
 
 func (q *Queries) GetCustomerSessionById(ctx context.Context, id uuid.UUID) (*CustomerSession, error) {
 	row := q.db.QueryRowContext(ctx, getCustomerSessionById, id)
 	var i CustomerSession
-	err := row.Scan(&i.ID, &i.CustomerID, &i.ExpiresAt)
+	err := row.Scan(
+		&i.ID,
+		&i.CustomerID,
+		&i.ExpiresAt,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.CreatedAt,
+		&i.LastSeenAt,
+		&i.AbsoluteExpiresAt,
+		&i.Remember,
+	)
 	return &i, err
 }
 
-const getSessionByCustomerId = `-- name: GetSessionByCustomerId :one
-SELECT id, customer_id, expires_at FROM customer_sessions WHERE customer_id = $1
+const getUserSessionById = `-- name: GetUserSessionById :one
+SELECT id, user_id, expires_at, user_agent, ip_address, created_at, last_seen_at, absolute_expires_at, remember FROM user_sessions WHERE id = $1
 `
 
-func (q *Queries) GetSessionByCustomerId(ctx context.Context, customerID int64) (*CustomerSession, error) {
-	row := q.db.QueryRowContext(ctx, getSessionByCustomerId, customerID)
-	var i CustomerSession
-	err := row.Scan(&i.ID, &i.CustomerID, &i.ExpiresAt)
+func (q *Queries) GetUserSessionById(ctx context.Context, id uuid.UUID) (*UserSession, error) {
+	row := q.db.QueryRowContext(ctx, getUserSessionById, id)
+	var i UserSession
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.ExpiresAt,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.CreatedAt,
+		&i.LastSeenAt,
+		&i.AbsoluteExpiresAt,
+		&i.Remember,
+	)
 	return &i, err
 }
 
-const getSessionByUserId = `-- name: GetSessionByUserId :one
-SELECT id, user_id, expires_at FROM user_sessions WHERE user_id = $1
+const listCustomerSessions = `-- name: ListCustomerSessions :many
+SELECT id, customer_id, expires_at, user_agent, ip_address, created_at, last_seen_at, absolute_expires_at, remember FROM customer_sessions
+WHERE customer_id = $1
+ORDER BY last_seen_at DESC
 `
 
-func (q *Queries) GetSessionByUserId(ctx context.Context, userID int64) (*UserSession, error) {
-	row := q.db.QueryRowContext(ctx, getSessionByUserId, userID)
-	var i UserSession
-	err := row.Scan(&i.ID, &i.UserID, &i.ExpiresAt)
+func (q *Queries) ListCustomerSessions(ctx context.Context, customerID int64) ([]*CustomerSession, error) {
+	rows, err := q.db.QueryContext(ctx, listCustomerSessions, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*CustomerSession
+	for rows.Next() {
+		var i CustomerSession
+		if err := rows.Scan(
+			&i.ID,
+			&i.CustomerID,
+			&i.ExpiresAt,
+			&i.UserAgent,
+			&i.IpAddress,
+			&i.CreatedAt,
+			&i.LastSeenAt,
+			&i.AbsoluteExpiresAt,
+			&i.Remember,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserSessions = `-- name: ListUserSessions :many
+SELECT id, user_id, expires_at, user_agent, ip_address, created_at, last_seen_at, absolute_expires_at, remember FROM user_sessions
+WHERE user_id = $1
+ORDER BY last_seen_at DESC
+`
+
+func (q *Queries) ListUserSessions(ctx context.Context, userID int64) ([]*UserSession, error) {
+	rows, err := q.db.QueryContext(ctx, listUserSessions, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*UserSession
+	for rows.Next() {
+		var i UserSession
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.ExpiresAt,
+			&i.UserAgent,
+			&i.IpAddress,
+			&i.CreatedAt,
+			&i.LastSeenAt,
+			&i.AbsoluteExpiresAt,
+			&i.Remember,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokeCustomerSession = `-- name: RevokeCustomerSession :execrows
+UPDATE customer_sessions
+SET expires_at = NOW()
+WHERE id = $1 AND customer_id = $2 AND expires_at > NOW()
+`
+
+type RevokeCustomerSessionParams struct {
+	ID         uuid.UUID `json:"id"`
+	CustomerID int64     `json:"customerId"`
+}
+
+func (q *Queries) RevokeCustomerSession(ctx context.Context, arg RevokeCustomerSessionParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeCustomerSession, arg.ID, arg.CustomerID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const revokeOtherCustomerSessions = `-- name: RevokeOtherCustomerSessions :execrows
+UPDATE customer_sessions
+SET expires_at = NOW()
+WHERE customer_id = $1 AND id <> $2 AND expires_at > NOW()
+`
+
+type RevokeOtherCustomerSessionsParams struct {
+	CustomerID int64     `json:"customerId"`
+	ID         uuid.UUID `json:"id"`
+}
+
+func (q *Queries) RevokeOtherCustomerSessions(ctx context.Context, arg RevokeOtherCustomerSessionsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeOtherCustomerSessions, arg.CustomerID, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const revokeOtherUserSessions = `-- name: RevokeOtherUserSessions :execrows
+UPDATE user_sessions
+SET expires_at = NOW()
+WHERE user_id = $1 AND id <> $2 AND expires_at > NOW()
+`
+
+type RevokeOtherUserSessionsParams struct {
+	UserID int64     `json:"userId"`
+	ID     uuid.UUID `json:"id"`
+}
+
+func (q *Queries) RevokeOtherUserSessions(ctx context.Context, arg RevokeOtherUserSessionsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeOtherUserSessions, arg.UserID, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const revokeUserSession = `-- name: RevokeUserSession :execrows
+UPDATE user_sessions
+SET expires_at = NOW()
+WHERE id = $1 AND user_id = $2 AND expires_at > NOW()
+`
+
+type RevokeUserSessionParams struct {
+	ID     uuid.UUID `json:"id"`
+	UserID int64     `json:"userId"`
+}
+
+func (q *Queries) RevokeUserSession(ctx context.Context, arg RevokeUserSessionParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeUserSession, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const rotateCustomerSession = `-- name: RotateCustomerSession :one
+INSERT INTO customer_sessions (customer_id, expires_at, absolute_expires_at, remember, user_agent, ip_address, created_at)
+SELECT customer_id, expires_at, absolute_expires_at, remember, $1::text, $2::text, created_at
+FROM customer_sessions
+WHERE id = $3 AND expires_at > NOW()
+RETURNING id, customer_id, expires_at, user_agent, ip_address, created_at, last_seen_at, absolute_expires_at, remember
+`
+
+type RotateCustomerSessionParams struct {
+	UserAgent string    `json:"userAgent"`
+	IpAddress string    `json:"ipAddress"`
+	ID        uuid.UUID `json:"id"`
+}
+
+func (q *Queries) RotateCustomerSession(ctx context.Context, arg RotateCustomerSessionParams) (*CustomerSession, error) {
+	row := q.db.QueryRowContext(ctx, rotateCustomerSession, arg.UserAgent, arg.IpAddress, arg.ID)
+	var i CustomerSession
+	err := row.Scan(
+		&i.ID,
+		&i.CustomerID,
+		&i.ExpiresAt,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.CreatedAt,
+		&i.LastSeenAt,
+		&i.AbsoluteExpiresAt,
+		&i.Remember,
+	)
 	return &i, err
 }
 
-const getUserSessionById = `-- name: GetUserSessionById :one
-SELECT id, user_id, expires_at FROM user_sessions WHERE id = $1
+const rotateUserSession = `-- name: RotateUserSession :one
+INSERT INTO user_sessions (user_id, expires_at, absolute_expires_at, remember, user_agent, ip_address, created_at)
+SELECT user_id, expires_at, absolute_expires_at, remember, $1::text, $2::text, created_at
+FROM user_sessions
+WHERE id = $3 AND expires_at > NOW()
+RETURNING id, user_id, expires_at, user_agent, ip_address, created_at, last_seen_at, absolute_expires_at, remember
 `
 
-func (q *Queries) GetUserSessionById(ctx context.Context, id uuid.UUID) (*UserSession, error) {
-	row := q.db.QueryRowContext(ctx, getUserSessionById, id)
+type RotateUserSessionParams struct {
+	UserAgent string    `json:"userAgent"`
+	IpAddress string    `json:"ipAddress"`
+	ID        uuid.UUID `json:"id"`
+}
+
+func (q *Queries) RotateUserSession(ctx context.Context, arg RotateUserSessionParams) (*UserSession, error) {
+	row := q.db.QueryRowContext(ctx, rotateUserSession, arg.UserAgent, arg.IpAddress, arg.ID)
 	var i UserSession
-	err := row.Scan(&i.ID, &i.UserID, &i.ExpiresAt)
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.ExpiresAt,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.CreatedAt,
+		&i.LastSeenAt,
+		&i.AbsoluteExpiresAt,
+		&i.Remember,
+	)
 	return &i, err
 }
 
+const touchCustomerSession = `-- name: TouchCustomerSession :exec
+UPDATE customer_sessions
+SET last_seen_at = NOW(),
+expires_at = LEAST($1::timestamp, absolute_expires_at)
+WHERE id = $2 AND expires_at > NOW()
+`
+
+type TouchCustomerSessionParams struct {
+	ExpiresAt time.Time `json:"expiresAt"`
+	ID        uuid.UUID `json:"id"`
+}
+
+func (q *Queries) TouchCustomerSession(ctx context.Context, arg TouchCustomerSessionParams) error {
+	_, err := q.db.ExecContext(ctx, touchCustomerSession, arg.ExpiresAt, arg.ID)
+	return err
+}
+
+const touchUserSession = `-- name: TouchUserSession :exec
+UPDATE user_sessions
+SET last_seen_at = NOW(),
+expires_at = LEAST($1::timestamp, absolute_expires_at)
+WHERE id = $2 AND expires_at > NOW()
+`
+
+type TouchUserSessionParams struct {
+	ExpiresAt time.Time `json:"expiresAt"`
+	ID        uuid.UUID `json:"id"`
+}
+
+func (q *Queries) TouchUserSession(ctx context.Context, arg TouchUserSessionParams) error {
+	_, err := q.db.ExecContext(ctx, touchUserSession, arg.ExpiresAt, arg.ID)
+	return err
+}
+
 const updateCustomerSession = `-- name: UpdateCustomerSession :one
 UPDATE customer_sessions
 SET expires_at = $2
 WHERE id = $1
-RETURNING id, customer_id, expires_at
+RETURNING id, customer_id, expires_at, user_agent, ip_address, created_at, last_seen_at, absolute_expires_at, remember
 `
 
 type UpdateCustomerSessionParams struct {
@@ -107,7 +445,17 @@ type UpdateCustomerSessionParams struct {
 func (q *Queries) UpdateCustomerSession(ctx context.Context, arg UpdateCustomerSessionParams) (*CustomerSession, error) {
 	row := q.db.QueryRowContext(ctx, updateCustomerSession, arg.ID, arg.ExpiresAt)
 	var i CustomerSession
-	err := row.Scan(&i.ID, &i.CustomerID, &i.ExpiresAt)
+	err := row.Scan(
+		&i.ID,
+		&i.CustomerID,
+		&i.ExpiresAt,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.CreatedAt,
+		&i.LastSeenAt,
+		&i.AbsoluteExpiresAt,
+		&i.Remember,
+	)
 	return &i, err
 }
 
@@ -115,7 +463,7 @@ const updateUserSession = `-- name: UpdateUserSession :one
 UPDATE user_sessions
 SET expires_at = $2
 WHERE id = $1
-RETURNING id, user_id, expires_at
+RETURNING id, user_id, expires_at, user_agent, ip_address, created_at, last_seen_at, absolute_expires_at, remember
 `
 
 type UpdateUserSessionParams struct {
@@ -126,48 +474,16 @@ type UpdateUserSessionParams struct {
 func (q *Queries) UpdateUserSession(ctx context.Context, arg UpdateUserSessionParams) (*UserSession, error) {
 	row := q.db.QueryRowContext(ctx, updateUserSession, arg.ID, arg.ExpiresAt)
 	var i UserSession
-	err := row.Scan(&i.ID, &i.UserID, &i.ExpiresAt)
-	return &i, err
-}
-
-const upsertCustomerSession = `-- name: UpsertCustomerSession :one
-INSERT INTO customer_sessions (customer_id, expires_at)
-VALUES ($1, $2)
-ON CONFLICT (customer_id)
-DO UPDATE SET
-    expires_at = EXCLUDED.expires_at
-RETURNING id, customer_id, expires_at
-`
-
-type UpsertCustomerSessionParams struct {
-	CustomerID int64     `json:"customerId"`
-	ExpiresAt  time.Time `json:"expiresAt"`
-}
-
-func (q *Queries) UpsertCustomerSession(ctx context.Context, arg UpsertCustomerSessionParams) (*CustomerSession, error) {
-	row := q.db.QueryRowContext(ctx, upsertCustomerSession, arg.CustomerID, arg.ExpiresAt)
-	var i CustomerSession
-	err := row.Scan(&i.ID, &i.CustomerID, &i.ExpiresAt)
-	return &i, err
-}
-
-const upsertUserSession = `-- name: UpsertUserSession :one
-INSERT INTO user_sessions (user_id, expires_at)
-VALUES ($1, $2)
-ON CONFLICT (user_id)
-DO UPDATE SET
-    expires_at = EXCLUDED.expires_at
-RETURNING id, user_id, expires_at
-`
-
-type UpsertUserSessionParams struct {
-	UserID    int64     `json:"userId"`
-	ExpiresAt time.Time `json:"expiresAt"`
-}
-
-func (q *Queries) UpsertUserSession(ctx context.Context, arg UpsertUserSessionParams) (*UserSession, error) {
-	row := q.db.QueryRowContext(ctx, upsertUserSession, arg.UserID, arg.ExpiresAt)
-	var i UserSession
-	err := row.Scan(&i.ID, &i.UserID, &i.ExpiresAt)
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.ExpiresAt,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.CreatedAt,
+		&i.LastSeenAt,
+		&i.AbsoluteExpiresAt,
+		&i.Remember,
+	)
 	return &i, err
 }
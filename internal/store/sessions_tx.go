@@ -0,0 +1,69 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type RotateSessionTxParams struct {
+	ID        uuid.UUID
+	UserAgent string
+	IpAddress string
+}
+
+// RotateUserSessionTx replaces a session with a new ID that keeps its
+// lifetime. The old ID stops working so a leaked ID can't outlive a change of
+// privileges.
+func (s *SQLStore) RotateUserSessionTx(ctx context.Context, arg RotateSessionTxParams) (*UserSession, error) {
+	var result *UserSession
+	err := s.execTx(ctx, func(q Querier) error {
+		session, err := q.RotateUserSession(ctx, RotateUserSessionParams{
+			UserAgent: arg.UserAgent,
+			IpAddress: arg.IpAddress,
+			ID:        arg.ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		if _, err := q.UpdateUserSession(ctx, UpdateUserSessionParams{
+			ID:        arg.ID,
+			ExpiresAt: time.Unix(0, 0),
+		}); err != nil {
+			return err
+		}
+
+		result = session
+		return nil
+	})
+
+	return result, err
+}
+
+func (s *SQLStore) RotateCustomerSessionTx(ctx context.Context, arg RotateSessionTxParams) (*CustomerSession, error) {
+	var result *CustomerSession
+	err := s.execTx(ctx, func(q Querier) error {
+		session, err := q.RotateCustomerSession(ctx, RotateCustomerSessionParams{
+			UserAgent: arg.UserAgent,
+			IpAddress: arg.IpAddress,
+			ID:        arg.ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		if _, err := q.UpdateCustomerSession(ctx, UpdateCustomerSessionParams{
+			ID:        arg.ID,
+			ExpiresAt: time.Unix(0, 0),
+		}); err != nil {
+			return err
+		}
+
+		result = session
+		return nil
+	})
+
+	return result, err
+}
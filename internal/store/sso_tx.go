@@ -0,0 +1,95 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+var ErrSsoEmailTaken = errors.New("the email belongs to a user of another brand")
+
+type ProvisionOidcUserTxParams struct {
+	BrandID int32
+	Issuer  string
+	Subject string
+	// Email is normalized and verified by the provider
+	Email string
+	Name  string
+	Role  string
+	// Password is the hash of a random password for users created here. They
+	// can set their own with a password reset.
+	Password []byte
+}
+
+type ProvisionOidcUserTxResult struct {
+	User    *User
+	Created bool
+}
+
+// ProvisionOidcUserTx returns the user the provider identity is linked to. An
+// unknown identity is linked to the user of the brand with the same email, or
+// to a new user when there is none.
+func (s *SQLStore) ProvisionOidcUserTx(ctx context.Context, arg ProvisionOidcUserTxParams) (*ProvisionOidcUserTxResult, error) {
+	var result ProvisionOidcUserTxResult
+	err := s.execTx(ctx, func(q Querier) error {
+		identity, err := q.GetUserIdentity(ctx, GetUserIdentityParams{
+			Issuer:  arg.Issuer,
+			Subject: arg.Subject,
+		})
+		switch err {
+		case nil:
+			user, err := q.GetUserById(ctx, identity.UserID)
+			if err != nil {
+				return err
+			}
+			if !user.BrandID.Valid || user.BrandID.Int32 != arg.BrandID {
+				return ErrSsoEmailTaken
+			}
+			result.User = user
+			return nil
+		case sql.ErrNoRows:
+		default:
+			return err
+		}
+
+		user, err := q.GetUserByEmail(ctx, arg.Email)
+		switch err {
+		case nil:
+			if !user.BrandID.Valid || user.BrandID.Int32 != arg.BrandID {
+				return ErrSsoEmailTaken
+			}
+		case sql.ErrNoRows:
+			user, err = q.CreateUser(ctx, CreateUserParams{
+				Name:     arg.Name,
+				Email:    arg.Email,
+				Password: arg.Password,
+				Role:     arg.Role,
+				Verified: true,
+				BrandID:  sql.NullInt32{Int32: arg.BrandID, Valid: true},
+			})
+			if err != nil {
+				return err
+			}
+			result.Created = true
+		default:
+			return err
+		}
+
+		if _, err := q.CreateUserIdentity(ctx, CreateUserIdentityParams{
+			UserID:  user.ID,
+			Issuer:  arg.Issuer,
+			Subject: arg.Subject,
+			Email:   arg.Email,
+		}); err != nil {
+			return err
+		}
+
+		result.User = user
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
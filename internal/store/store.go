@@ -11,9 +11,30 @@ type Store interface {
 	CreateServiceTx(ctx context.Context, arg CreateServiceTxParams) (*ServiceTxResult, error)
 	UpdateServiceTx(ctx context.Context, arg UpdateServiceTxParams) (*ServiceTxResult, error)
 	ActivateUserTx(ctx context.Context, arg ActivateUserTxParams) error
+	RotateUserSessionTx(ctx context.Context, arg RotateSessionTxParams) (*UserSession, error)
+	RotateCustomerSessionTx(ctx context.Context, arg RotateSessionTxParams) (*CustomerSession, error)
+	ResetUserPasswordTx(ctx context.Context, arg ResetUserPasswordTxParams) (int64, error)
+	EnableUserTotpTx(ctx context.Context, arg EnableUserTotpTxParams) error
+	ReplaceUserRecoveryCodesTx(ctx context.Context, arg ReplaceUserRecoveryCodesTxParams) error
+	DisableUserTotpTx(ctx context.Context, userID int64) error
+	CompleteUserSignInTx(ctx context.Context, arg CompleteUserSignInTxParams) (*UserSession, error)
+	ProvisionOidcUserTx(ctx context.Context, arg ProvisionOidcUserTxParams) (*ProvisionOidcUserTxResult, error)
 	CreateBrandTx(ctx context.Context, arg CreateBrandTxParams) (*Brand, []*BrandWorkingHour, error)
 	CreateGuestTx(ctx context.Context, arg CreateGuestTxParams) (*Customer, bool, error)
+	VerifyCustomerTx(ctx context.Context, token string) (int64, error)
+	ResetCustomerPasswordTx(ctx context.Context, arg ResetCustomerPasswordTxParams) (int64, error)
+	CreateCustomerSignInCodeTx(ctx context.Context, arg CreateCustomerSignInCodeTxParams) (*CustomerSignInCode, error)
+	SignInCustomerWithCodeTx(ctx context.Context, arg SignInCustomerWithCodeTxParams) (*CustomerSession, error)
+	SignInCustomerWithLinkTx(ctx context.Context, arg SignInCustomerWithLinkTxParams) (*CustomerSession, error)
+	ListDuplicateCustomersTx(ctx context.Context, arg ListDuplicateCustomersTxParams) ([]*ListDuplicateCustomersRow, error)
+	MergeCustomersTx(ctx context.Context, arg MergeCustomersTxParams) (*MergeCustomersTxResult, error)
+	EraseCustomerTx(ctx context.Context, arg EraseCustomerTxParams) (*CustomerErasure, error)
+	SetCustomerFieldValuesTx(ctx context.Context, arg SetCustomerFieldValuesTxParams) ([]*ListCustomerFieldValuesRow, error)
+	UpdateCustomerConsentsTx(ctx context.Context, arg UpdateCustomerConsentsTxParams) ([]*CustomerConsent, error)
+	ImportCustomersTx(ctx context.Context, arg ImportCustomersTxParams) ([]ImportRowResult, error)
+	ImportEventsTx(ctx context.Context, arg ImportEventsTxParams) ([]ImportRowResult, error)
 	GetBrandProfileTx(ctx context.Context, brandID int32) (*Brand, []*BrandSocialLink, []*BrandWorkingHour, error)
+	SyncExternalCalendarTx(ctx context.Context, arg SyncExternalCalendarTxParams) (*ExternalCalendar, error)
 }
 
 type SQLStore struct {
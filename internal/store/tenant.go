@@ -0,0 +1,172 @@
+package store
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strconv"
+)
+
+type tenantKey string
+
+const (
+	brandIDKey    tenantKey = "tenant-brand"
+	privilegedKey tenantKey = "tenant-privileged"
+)
+
+const setTenant = `SELECT set_config('app.brand_id', $1, false), set_config('app.bypass_rls', $2, false), set_config('app.actor', $3, false)`
+
+// WithBrandID scopes every query issued with the returned context to a single
+// brand. Row level security policies reject rows of any other brand.
+func WithBrandID(ctx context.Context, brandID int32) context.Context {
+	return context.WithValue(ctx, brandIDKey, brandID)
+}
+
+// WithPrivileges marks the context as privileged so queries bypass row level
+// security. It is meant for cross-tenant admin tasks and background jobs only.
+func WithPrivileges(ctx context.Context) context.Context {
+	return context.WithValue(ctx, privilegedKey, true)
+}
+
+func BrandIDFromContext(ctx context.Context) (int32, bool) {
+	brandID, ok := ctx.Value(brandIDKey).(int32)
+	return brandID, ok
+}
+
+func tenantFromContext(ctx context.Context) (string, string) {
+	brandID := ""
+	if id, ok := BrandIDFromContext(ctx); ok {
+		brandID = strconv.FormatInt(int64(id), 10)
+	}
+
+	bypass := "off"
+	if privileged, _ := ctx.Value(privilegedKey).(bool); privileged {
+		bypass = "on"
+	}
+
+	return brandID, bypass
+}
+
+// NewTenantConnector wraps a driver connector so that every connection applies
+// the tenant settings carried by the statement context before running it.
+func NewTenantConnector(connector driver.Connector) driver.Connector {
+	return &tenantConnector{Connector: connector}
+}
+
+type tenantConnector struct {
+	driver.Connector
+}
+
+func (c *tenantConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := conn.(contextConn); !ok {
+		conn.Close()
+		return nil, errors.New("tenant connector: driver connection does not support contexts")
+	}
+
+	return &tenantConn{Conn: conn}, nil
+}
+
+type contextConn interface {
+	driver.ExecerContext
+	driver.QueryerContext
+	driver.ConnBeginTx
+	driver.ConnPrepareContext
+}
+
+type tenantConn struct {
+	driver.Conn
+	applied bool
+	brandID string
+	bypass  string
+	actor   string
+}
+
+// apply sets the tenant settings and the audit actor on the session when they
+// differ from what was last applied on this connection.
+func (c *tenantConn) apply(ctx context.Context) error {
+	brandID, bypass := tenantFromContext(ctx)
+	actor := actorFromContext(ctx)
+	if c.applied && c.brandID == brandID && c.bypass == bypass && c.actor == actor {
+		return nil
+	}
+
+	_, err := c.Conn.(contextConn).ExecContext(ctx, setTenant, []driver.NamedValue{
+		{Ordinal: 1, Value: brandID},
+		{Ordinal: 2, Value: bypass},
+		{Ordinal: 3, Value: actor},
+	})
+	if err != nil {
+		c.applied = false
+		return err
+	}
+
+	c.applied, c.brandID, c.bypass, c.actor = true, brandID, bypass, actor
+	return nil
+}
+
+func (c *tenantConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.apply(ctx); err != nil {
+		return nil, err
+	}
+	return c.Conn.(contextConn).ExecContext(ctx, query, args)
+}
+
+func (c *tenantConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := c.apply(ctx); err != nil {
+		return nil, err
+	}
+	return c.Conn.(contextConn).QueryContext(ctx, query, args)
+}
+
+func (c *tenantConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if err := c.apply(ctx); err != nil {
+		return nil, err
+	}
+	return c.Conn.(contextConn).PrepareContext(ctx, query)
+}
+
+func (c *tenantConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	tx, err := c.Conn.(contextConn).BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &tenantTx{Tx: tx, conn: c}, nil
+}
+
+func (c *tenantConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *tenantConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *tenantConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+// tenantTx forgets the applied settings on rollback, since a rolled back
+// transaction also reverts any set_config issued inside it.
+type tenantTx struct {
+	driver.Tx
+	conn *tenantConn
+}
+
+func (t *tenantTx) Rollback() error {
+	t.conn.applied = false
+	return t.Tx.Rollback()
+}
@@ -0,0 +1,157 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+var (
+	ErrTwoFactorNotPending     = errors.New("two-factor authentication is not being set up")
+	ErrInvalidTwoFactorCode    = errors.New("invalid two-factor code")
+	ErrInvalidSignInChallenge  = errors.New("invalid or expired sign in challenge")
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+)
+
+type EnableUserTotpTxParams struct {
+	UserID int64
+	// Step is the TOTP step of the code that confirmed the secret
+	Step               int64
+	RecoveryCodeHashes []string
+}
+
+// EnableUserTotpTx confirms the pending secret of the user and replaces their
+// recovery codes
+func (s *SQLStore) EnableUserTotpTx(ctx context.Context, arg EnableUserTotpTxParams) error {
+	return s.execTx(ctx, func(q Querier) error {
+		return enableUserTotp(ctx, q, arg)
+	})
+}
+
+type ReplaceUserRecoveryCodesTxParams struct {
+	UserID             int64
+	RecoveryCodeHashes []string
+}
+
+func (s *SQLStore) ReplaceUserRecoveryCodesTx(ctx context.Context, arg ReplaceUserRecoveryCodesTxParams) error {
+	return s.execTx(ctx, func(q Querier) error {
+		return replaceUserRecoveryCodes(ctx, q, arg.UserID, arg.RecoveryCodeHashes)
+	})
+}
+
+func (s *SQLStore) DisableUserTotpTx(ctx context.Context, userID int64) error {
+	return s.execTx(ctx, func(q Querier) error {
+		if err := q.DeleteUserRecoveryCodes(ctx, userID); err != nil {
+			return err
+		}
+		return q.DeleteUserTotp(ctx, userID)
+	})
+}
+
+type CompleteUserSignInTxParams struct {
+	Token       string
+	MaxAttempts int32
+	// Step is the TOTP step of a valid code. It is ignored when
+	// RecoveryCodeHash is set.
+	Step             int64
+	RecoveryCodeHash string
+	// Enable confirms a secret that was set up during the sign in. The
+	// recovery codes replace any the user had.
+	Enable             bool
+	RecoveryCodeHashes []string
+	Session            CreateUserSessionParams
+}
+
+// CompleteUserSignInTx uses the sign in challenge and the second factor and
+// creates the session. Nothing is kept when the code was already used, so the
+// caller can count the failed attempt.
+func (s *SQLStore) CompleteUserSignInTx(ctx context.Context, arg CompleteUserSignInTxParams) (*UserSession, error) {
+	var result *UserSession
+	err := s.execTx(ctx, func(q Querier) error {
+		userID, err := q.ConsumeUserSignInChallenge(ctx, ConsumeUserSignInChallengeParams{
+			Token:    arg.Token,
+			Attempts: arg.MaxAttempts,
+		})
+		if err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrInvalidSignInChallenge
+			default:
+				return err
+			}
+		}
+
+		switch {
+		case arg.Enable:
+			if err := enableUserTotp(ctx, q, EnableUserTotpTxParams{
+				UserID:             userID,
+				Step:               arg.Step,
+				RecoveryCodeHashes: arg.RecoveryCodeHashes,
+			}); err != nil {
+				return err
+			}
+		case arg.RecoveryCodeHash != "":
+			used, err := q.UseUserRecoveryCode(ctx, UseUserRecoveryCodeParams{
+				UserID:   userID,
+				CodeHash: arg.RecoveryCodeHash,
+			})
+			if err != nil {
+				return err
+			}
+			if used == 0 {
+				return ErrInvalidTwoFactorCode
+			}
+		default:
+			used, err := q.UseUserTotpStep(ctx, UseUserTotpStepParams{
+				UserID:       userID,
+				LastUsedStep: arg.Step,
+			})
+			if err != nil {
+				return err
+			}
+			// The code was already used to sign in
+			if used == 0 {
+				return ErrInvalidTwoFactorCode
+			}
+		}
+
+		session := arg.Session
+		session.UserID = userID
+		result, err = q.CreateUserSession(ctx, session)
+		return err
+	})
+
+	return result, err
+}
+
+func enableUserTotp(ctx context.Context, q Querier, arg EnableUserTotpTxParams) error {
+	confirmed, err := q.ConfirmUserTotp(ctx, ConfirmUserTotpParams{
+		UserID:       arg.UserID,
+		LastUsedStep: arg.Step,
+	})
+	if err != nil {
+		return err
+	}
+	if confirmed == 0 {
+		return ErrTwoFactorNotPending
+	}
+
+	return replaceUserRecoveryCodes(ctx, q, arg.UserID, arg.RecoveryCodeHashes)
+}
+
+func replaceUserRecoveryCodes(ctx context.Context, q Querier, userID int64, hashes []string) error {
+	if err := q.DeleteUserRecoveryCodes(ctx, userID); err != nil {
+		return err
+	}
+
+	for _, hash := range hashes {
+		if err := q.CreateUserRecoveryCode(ctx, CreateUserRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: hash,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -8,6 +8,7 @@ package store
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/lib/pq"
 )
@@ -142,6 +143,123 @@ func (q *Queries) GetUsersByBrand(ctx context.Context, brandID sql.NullInt32) ([
 	return items, nil
 }
 
+const listUsers = `-- name: ListUsers :many
+WITH listed AS (
+    SELECT
+        u.id, u.name, u.email, u.password, u.avatar, u.verified, u.created_at, u.updated_at, u.brand_id, u.role,
+        (CASE $1::text
+            WHEN 'createdAt' THEN to_char(u.created_at, 'YYYY-MM-DD"T"HH24:MI:SS')
+            ELSE lower(u.name)
+        END)::text AS sort_key
+    FROM users u
+    WHERE u.brand_id = $2
+    AND (
+        $3::text = ''
+        OR u.name ILIKE $3::text || '%'
+        OR u.email ILIKE $3::text || '%'
+    )
+    AND ($4::text = '' OR u.role = $4::text)
+)
+SELECT id, name, email, password, avatar, verified, created_at, updated_at, brand_id, role, sort_key FROM listed
+WHERE NOT $5::boolean
+OR ($6::boolean AND (sort_key, id) < ($7::text, $8::bigint))
+OR (NOT $6::boolean AND (sort_key, id) > ($7::text, $8::bigint))
+ORDER BY
+    CASE WHEN $6::boolean THEN sort_key END DESC,
+    CASE WHEN $6::boolean THEN id END DESC,
+    sort_key,
+    id
+LIMIT $9
+`
+
+type ListUsersParams struct {
+	Sort       string        `json:"sort"`
+	BrandID    sql.NullInt32 `json:"brandId"`
+	Search     string        `json:"search"`
+	Role       string        `json:"role"`
+	HasCursor  bool          `json:"hasCursor"`
+	Descending bool          `json:"descending"`
+	CursorKey  string        `json:"cursorKey"`
+	CursorID   int64         `json:"cursorId"`
+	PageLimit  int32         `json:"pageLimit"`
+}
+
+type ListUsersRow struct {
+	ID        int64          `json:"id"`
+	Name      string         `json:"name"`
+	Email     string         `json:"email"`
+	Password  []byte         `json:"-"`
+	Avatar    sql.NullString `json:"avatar"`
+	Verified  bool           `json:"verified"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	BrandID   sql.NullInt32  `json:"brandId"`
+	Role      string         `json:"role"`
+	SortKey   string         `json:"sortKey"`
+}
+
+func (q *Queries) ListUsers(ctx context.Context, arg ListUsersParams) ([]*ListUsersRow, error) {
+	rows, err := q.db.QueryContext(ctx, listUsers,
+		arg.Sort,
+		arg.BrandID,
+		arg.Search,
+		arg.Role,
+		arg.HasCursor,
+		arg.Descending,
+		arg.CursorKey,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*ListUsersRow
+	for rows.Next() {
+		var i ListUsersRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Email,
+			&i.Password,
+			&i.Avatar,
+			&i.Verified,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.BrandID,
+			&i.Role,
+			&i.SortKey,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users SET
+password = $2,
+updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdateUserPasswordParams struct {
+	ID       int64  `json:"id"`
+	Password []byte `json:"password"`
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.ID, arg.Password)
+	return err
+}
+
 const validateUsersCount = `-- name: ValidateUsersCount :one
 SELECT COUNT(*) FROM users
 WHERE id = ANY($1::bigint[]) AND brand_id = $2
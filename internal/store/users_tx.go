@@ -40,3 +40,59 @@ func (s *SQLStore) ActivateUserTx(ctx context.Context, arg ActivateUserTxParams)
 
 	return err
 }
+
+const (
+	TokenScopeVerification  = "verification"
+	TokenScopePasswordReset = "password_reset"
+)
+
+type ResetUserPasswordTxParams struct {
+	Token    string
+	Password []byte
+}
+
+var ErrInvalidResetToken = errors.New("invalid or expired password reset link")
+
+func (s *SQLStore) ResetUserPasswordTx(ctx context.Context, arg ResetUserPasswordTxParams) (int64, error) {
+	var userID int64
+	err := s.execTx(ctx, func(q Querier) error {
+		// Consuming the token deletes it so a reset link works only once
+		id, err := q.ConsumeUserToken(ctx, ConsumeUserTokenParams{
+			Token: arg.Token,
+			Scope: TokenScopePasswordReset,
+		})
+		if err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrInvalidResetToken
+			default:
+				return err
+			}
+		}
+
+		if err := q.UpdateUserPassword(ctx, UpdateUserPasswordParams{
+			ID:       id,
+			Password: arg.Password,
+		}); err != nil {
+			return err
+		}
+
+		// Other links sent before this one are no longer valid
+		if err := q.DeleteUserTokens(ctx, DeleteUserTokensParams{
+			UserID: id,
+			Scope:  TokenScopePasswordReset,
+		}); err != nil {
+			return err
+		}
+
+		// Sessions opened with the old password are signed out
+		if err := q.ExpireUserSessions(ctx, id); err != nil {
+			return err
+		}
+
+		userID = id
+		return nil
+	})
+
+	return userID, err
+}
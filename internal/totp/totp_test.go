@@ -0,0 +1,126 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 key of the RFC 6238 test vectors, "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCodeRFC6238(t *testing.T) {
+	// The RFC lists 8 digit codes, 6 digit codes are their last 6 digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1111111111, want: "050471"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+		{unix: 20000000000, want: "353130"},
+	}
+
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code(%d): %v", tt.unix, err)
+		}
+		if got != tt.want {
+			t.Errorf("Code(%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestCodeLowerCaseSecret(t *testing.T) {
+	got, err := Code("gezdgnbvgy3tqojqgezdgnbvgy3tqojq", Step(time.Unix(59, 0)))
+	if err != nil || got != "287082" {
+		t.Fatalf("Code = %s, %v, want 287082", got, err)
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	step := Step(now)
+
+	tests := []struct {
+		name   string
+		offset int64
+		want   bool
+	}{
+		{name: "previous period", offset: -1, want: true},
+		{name: "current period", offset: 0, want: true},
+		{name: "next period", offset: 1, want: true},
+		{name: "two periods ago", offset: -2, want: false},
+		{name: "two periods ahead", offset: 2, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := Code(rfcSecret, step+tt.offset)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			matched, ok := Validate(rfcSecret, code, now, 0)
+			if ok != tt.want {
+				t.Fatalf("Validate = %v, want %v", ok, tt.want)
+			}
+			if ok && matched != step+tt.offset {
+				t.Fatalf("Validate matched step %d, want %d", matched, step+tt.offset)
+			}
+		})
+	}
+}
+
+func TestValidateRejectsUsedSteps(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	step := Step(now)
+
+	code, err := Code(rfcSecret, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	used, ok := Validate(rfcSecret, code, now, 0)
+	if !ok {
+		t.Fatal("Validate rejected a fresh code")
+	}
+
+	// The same code again, also a few seconds later in the same period
+	if _, ok := Validate(rfcSecret, code, now.Add(5*time.Second), used); ok {
+		t.Fatal("Validate accepted a replayed code")
+	}
+
+	// An older code still inside the skew once a newer one was used
+	previous, err := Code(rfcSecret, step-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := Validate(rfcSecret, previous, now, used); ok {
+		t.Fatal("Validate accepted a code older than the last used one")
+	}
+
+	// The code of the next period still works
+	next, err := Code(rfcSecret, step+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if matched, ok := Validate(rfcSecret, next, now.Add(Period), used); !ok || matched != step+1 {
+		t.Fatalf("Validate = %d, %v, want step %d", matched, ok, step+1)
+	}
+}
+
+func TestValidateMalformedCodes(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	for _, code := range []string{"", "12345", "1234567", "abcdef"} {
+		if _, ok := Validate(rfcSecret, code, now, 0); ok {
+			t.Errorf("Validate(%q) succeeded", code)
+		}
+	}
+
+	if _, ok := Validate("not base32!", "123456", now, 0); ok {
+		t.Error("Validate succeeded with an invalid secret")
+	}
+}
@@ -0,0 +1,128 @@
+// Package webhook sends signed JSON payloads to the URLs brands subscribe.
+//
+// Every request carries the headers
+//
+//	Webhook-Id: <delivery id>
+//	Webhook-Timestamp: <unix seconds>
+//	Webhook-Signature: v1=<hex HMAC-SHA256 of "<timestamp>.<body>" with the secret>
+//
+// Receivers should recompute the signature and reject old timestamps to
+// guard against replays.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/georgifotev1/bms/internal/safehttp"
+)
+
+const (
+	secretPrefix = "whsec_"
+	secretBytes  = 32
+
+	// maxResponseBody is how much of the response is read for the log
+	maxResponseBody = 1 << 10
+)
+
+// Backoff is the wait before the next attempt after the given number of
+// failed ones: 30s, 1m, 2m and so on up to 12h
+func Backoff(failures int) time.Duration {
+	const (
+		base    = 30 * time.Second
+		maxWait = 12 * time.Hour
+	)
+
+	if failures < 1 {
+		return base
+	}
+	if failures > 16 {
+		return maxWait
+	}
+	return min(base<<(failures-1), maxWait)
+}
+
+// GenerateSecret returns a new signing secret
+func GenerateSecret() (string, error) {
+	b := make([]byte, secretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return secretPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Sign returns the Webhook-Signature header value of the body
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+type Client struct {
+	http *http.Client
+}
+
+// NewClient returns a client that gives up after timeout. Unless
+// allowPrivate is set, it refuses to connect to loopback, private and link
+// local addresses so subscriptions can't reach internal services.
+func NewClient(timeout time.Duration, allowPrivate bool) *Client {
+	client := safehttp.NewClient(timeout, allowPrivate)
+	// A receiver answers itself, a redirect is reported as its answer
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return &Client{http: client}
+}
+
+// Result is the outcome of a delivery attempt. StatusCode is 0 when no
+// response was received.
+type Result struct {
+	StatusCode int
+	Err        error
+}
+
+func (r Result) OK() bool {
+	return r.Err == nil && r.StatusCode >= 200 && r.StatusCode < 300
+}
+
+// Send posts the body to the URL signed with the secret
+func (c *Client) Send(ctx context.Context, url, secret, deliveryID string, body []byte) Result {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return Result{Err: err}
+	}
+
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "bms-webhooks/1.0")
+	req.Header.Set("Webhook-Id", deliveryID)
+	req.Header.Set("Webhook-Timestamp", strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set("Webhook-Signature", Sign(secret, now, body))
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return Result{Err: err}
+	}
+	defer resp.Body.Close()
+
+	result := Result{StatusCode: resp.StatusCode}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+		result.Err = fmt.Errorf("webhook: %s answered %d: %s", url, resp.StatusCode, bytes.TrimSpace(snippet))
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBody))
+
+	return result
+}